@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/right1121/railway-control-center-simulator/internal/infrastructure/filesystem"
+	applogger "github.com/right1121/railway-control-center-simulator/pkg/logger"
+)
+
+// railML の設備のファイルを路線の形式（line.json）に変換する。
+// 取り込めなかった要素は標準エラーに1行ずつ書く。
+func main() {
+	logger := applogger.New(
+		applogger.WithLevel(applogger.LevelInfo),
+		applogger.WithFormat("json"),
+	)
+	applogger.SetDefault(logger)
+
+	inPath := flag.String("in", "", "railML のファイルのパス")
+	outPath := flag.String("out", "", "変換した路線のファイルのパス（省略時は標準出力）")
+	flag.Parse()
+
+	if *inPath == "" {
+		logger.Error("--in を指定してください")
+		os.Exit(2)
+	}
+
+	imported, err := filesystem.NewRailMLImporter(*inPath).Import(context.Background())
+	if err != nil {
+		logger.Error("railML の変換に失敗: %v", err)
+		os.Exit(1)
+	}
+	for _, element := range imported.Unsupported {
+		fmt.Fprintf(os.Stderr, "unsupported %s %q: %s\n", element.Element, element.ID, element.Reason)
+	}
+
+	if *outPath == "" {
+		_, _ = os.Stdout.Write(imported.Document)
+		return
+	}
+	if err := os.WriteFile(*outPath, imported.Document, 0o644); err != nil {
+		logger.Error("路線のファイルの書き込みに失敗: %v", err)
+		os.Exit(1)
+	}
+}
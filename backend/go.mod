@@ -2,4 +2,4 @@ module github.com/right1121/railway-control-center-simulator
 
 go 1.25.5
 
-require github.com/google/uuid v1.6.0 // indirect
+require github.com/google/uuid v1.6.0
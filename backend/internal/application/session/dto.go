@@ -7,8 +7,11 @@ import (
 )
 
 type SessionSnapshotDTO struct {
-	SessionID   string          `json:"sessionId"`
-	Dispatchers []DispatcherDTO `json:"dispatchers"`
+	SessionID    string          `json:"sessionId"`
+	Version      int64           `json:"version"`
+	Status       string          `json:"status"`
+	LastActiveAt time.Time       `json:"lastActiveAt"`
+	Dispatchers  []DispatcherDTO `json:"dispatchers"`
 }
 
 type DispatcherDTO struct {
@@ -30,7 +33,10 @@ func toSnapshotDTO(s *domain.TrainingSession) SessionSnapshotDTO {
 	}
 
 	return SessionSnapshotDTO{
-		SessionID:   s.ID().String(),
-		Dispatchers: out,
+		SessionID:    s.ID().String(),
+		Version:      s.Version(),
+		Status:       s.Status().String(),
+		LastActiveAt: s.LastActiveAt(),
+		Dispatchers:  out,
 	}
 }
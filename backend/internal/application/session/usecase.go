@@ -7,20 +7,56 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	domain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
 )
 
 type UseCase interface {
+	CreateSession(ctx context.Context, input CreateSessionInput) (SessionSnapshotDTO, error)
+	ListSessions(ctx context.Context) ([]SessionSnapshotDTO, error)
+	DeleteSession(ctx context.Context, input DeleteSessionInput) error
 	JoinDispatcher(ctx context.Context, input JoinDispatcherInput) (JoinDispatcherOutput, error)
 	LeaveDispatcher(ctx context.Context, input LeaveDispatcherInput) error
-	GetSnapshot(ctx context.Context) (SessionSnapshotDTO, error)
+	GetSnapshot(ctx context.Context, sessionID domain.SessionID) (SessionSnapshotDTO, error)
+	ChangeStatus(ctx context.Context, input ChangeStatusInput) (SessionSnapshotDTO, error)
+}
+
+// SimulationProvisioner はセッションが所有するシミュレーションの生成・停止・破棄を担う
+type SimulationProvisioner interface {
+	Provision(ctx context.Context, sessionID domain.SessionID) error
+	Close(ctx context.Context, sessionID domain.SessionID) error
+	Dispose(ctx context.Context, sessionID domain.SessionID) error
+}
+
+// CreateSessionInput はセッション作成ユースケースの入力
+// SessionID が空の場合はIDを自動採番する。
+type CreateSessionInput struct {
+	SessionID string
+	Now       time.Time
+}
+
+// DeleteSessionInput はセッション削除ユースケースの入力
+type DeleteSessionInput struct {
+	SessionID       domain.SessionID
+	ExpectedVersion *int64 // nil なら無条件（If-Match 未指定）
+}
+
+// ChangeStatusInput はセッション状態遷移ユースケースの入力
+type ChangeStatusInput struct {
+	SessionID       domain.SessionID
+	Status          domain.SessionStatus
+	ExpectedVersion *int64
+	Now             time.Time
 }
 
 // JoinDispatcherInput は参加ユースケースの入力
 type JoinDispatcherInput struct {
-	DispatcherID domain.DispatcherID
-	Name         domain.DispatcherName
-	Now          time.Time
+	SessionID       domain.SessionID
+	DispatcherID    domain.DispatcherID
+	Name            domain.DispatcherName
+	ExpectedVersion *int64
+	Now             time.Time
 }
 
 // JoinDispatcherOutput は参加ユースケースの出力
@@ -31,28 +67,165 @@ type JoinDispatcherOutput struct {
 
 // LeaveDispatcherInput は退出ユースケースの入力
 type LeaveDispatcherInput struct {
-	DispatcherID string
-	Now          time.Time
+	SessionID       domain.SessionID
+	DispatcherID    string
+	ExpectedVersion *int64
+	Now             time.Time
 }
 
 // service は UseCase の実装
 // - repo 経由でドメインを取得・保存
 // - mutex で整合性（複数操作の直列化）を保証
 type service struct {
-	repo domain.Repository
-	mu   sync.Mutex
+	repo        domain.Repository
+	simulations SimulationProvisioner
+	mu          sync.Mutex
 }
 
 // NewUseCase は UseCase 実装を生成する
-func NewUseCase(repo domain.Repository) UseCase {
+// simulations は演習開始・セッション終了に合わせてシミュレーションを生成・破棄するために使う。
+func NewUseCase(repo domain.Repository, simulations SimulationProvisioner) UseCase {
 	return &service{
-		repo: repo,
+		repo:        repo,
+		simulations: simulations,
+	}
+}
+
+// CreateSession は訓練セッションを新規作成します
+func (s *service) CreateSession(ctx context.Context, input CreateSessionInput) (SessionSnapshotDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := input.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	rawID := input.SessionID
+	if rawID == "" {
+		rawID = uuid.NewString()
+	}
+	id, err := domain.NewSessionID(rawID)
+	if err != nil {
+		return SessionSnapshotDTO{}, fmt.Errorf("セッションIDの生成に失敗: %w", err)
+	}
+
+	session := domain.NewTrainingSession(id, now)
+	if err := s.repo.Create(ctx, session); err != nil {
+		return SessionSnapshotDTO{}, fmt.Errorf("セッションの作成に失敗: %w", err)
+	}
+
+	return toSnapshotDTO(session), nil
+}
+
+// ListSessions はすべての訓練セッションを返します
+func (s *service) ListSessions(ctx context.Context) ([]SessionSnapshotDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]SessionSnapshotDTO, 0, len(sessions))
+	for _, session := range sessions {
+		out = append(out, toSnapshotDTO(session))
 	}
+	return out, nil
+}
+
+// DeleteSession は訓練セッションと紐づくシミュレーションを削除します
+func (s *service) DeleteSession(ctx context.Context, input DeleteSessionInput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if input.ExpectedVersion != nil {
+		session, err := s.repo.Get(ctx, input.SessionID)
+		if err != nil {
+			return err
+		}
+		if err := checkExpectedVersion(session, input.ExpectedVersion); err != nil {
+			return err
+		}
+	}
+
+	// 先にシミュレーションを破棄し、破棄できなければセッションを残してやり直せるようにする
+	if err := s.simulations.Dispose(ctx, input.SessionID); err != nil {
+		return fmt.Errorf("シミュレーションの破棄に失敗: %w", err)
+	}
+	return s.repo.Delete(ctx, input.SessionID)
+}
+
+// ChangeStatus はセッションのライフサイクル状態を遷移させます
+// - RUNNING（LOBBYから）: シミュレーションを生成してから演習を開始する（保存に失敗したら生成を取り消す）
+// - CLOSED: 状態を保存してからシミュレーションを止める（記録は振り返りのためにセッションの削除まで残す）
+func (s *service) ChangeStatus(ctx context.Context, input ChangeStatusInput) (SessionSnapshotDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := input.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	session, err := s.repo.Get(ctx, input.SessionID)
+	if err != nil {
+		return SessionSnapshotDTO{}, err
+	}
+	if err := checkExpectedVersion(session, input.ExpectedVersion); err != nil {
+		return SessionSnapshotDTO{}, err
+	}
+
+	provisioned := false
+	switch input.Status {
+	case domain.SessionStatusRunning:
+		if session.Status() == domain.SessionStatusLobby {
+			// 生成に失敗したら状態を変えない（集約を変更する前に行う）
+			if err := s.simulations.Provision(ctx, session.ID()); err != nil {
+				return SessionSnapshotDTO{}, fmt.Errorf("シミュレーションの生成に失敗: %w", err)
+			}
+			provisioned = true
+			err = session.Start(now)
+		} else {
+			err = session.Resume(now)
+		}
+	case domain.SessionStatusPaused:
+		err = session.Pause(now)
+	case domain.SessionStatusDebrief:
+		err = session.End(now)
+	case domain.SessionStatusClosed:
+		err = session.Close(now)
+	default:
+		err = domain.ErrInvalidStatusTransition
+	}
+	if err == nil {
+		if saveErr := s.repo.Save(ctx, session); saveErr != nil {
+			err = fmt.Errorf("セッションの保存に失敗: %w", saveErr)
+		}
+	}
+	if err != nil {
+		// 開始できなかったセッションに生成したシミュレーションを残さない
+		if provisioned {
+			if disposeErr := s.simulations.Dispose(ctx, session.ID()); disposeErr != nil {
+				return SessionSnapshotDTO{}, errors.Join(err, fmt.Errorf("シミュレーションの破棄に失敗: %w", disposeErr))
+			}
+		}
+		return SessionSnapshotDTO{}, err
+	}
+
+	if session.Status() == domain.SessionStatusClosed {
+		if err := s.simulations.Close(ctx, session.ID()); err != nil {
+			return SessionSnapshotDTO{}, fmt.Errorf("シミュレーションの停止に失敗: %w", err)
+		}
+	}
+
+	return toSnapshotDTO(session), nil
 }
 
 // JoinDispatcher は管理者をセッションに参加させます
 func (s *service) JoinDispatcher(ctx context.Context, input JoinDispatcherInput) (JoinDispatcherOutput, error) {
-	// すべての更新系ユースケースは直列化する（単一セッションの整合性確保）
+	// すべての更新系ユースケースは直列化する（セッション集約の整合性確保）
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -67,10 +240,13 @@ func (s *service) JoinDispatcher(ctx context.Context, input JoinDispatcherInput)
 	)
 
 	// ドメイン集約取得
-	session, err := s.ensureSession(ctx, now)
+	session, err := s.repo.Get(ctx, input.SessionID)
 	if err != nil {
 		return JoinDispatcherOutput{}, err
 	}
+	if err := checkExpectedVersion(session, input.ExpectedVersion); err != nil {
+		return JoinDispatcherOutput{}, err
+	}
 
 	// ドメイン操作
 	if err := session.JoinDispatcher(dispatcher, now); err != nil {
@@ -105,18 +281,16 @@ func (s *service) LeaveDispatcher(ctx context.Context, input LeaveDispatcherInpu
 		return err
 	}
 
-	session, err := s.repo.Get(ctx)
+	session, err := s.repo.Get(ctx, input.SessionID)
 	if err != nil {
 		return err
 	}
+	if err := checkExpectedVersion(session, input.ExpectedVersion); err != nil {
+		return err
+	}
 
 	if err := session.LeaveDispatcher(idVO, now); err != nil {
-		switch err {
-		case domain.ErrDispatcherNotFound:
-			return err
-		default:
-			return err
-		}
+		return err
 	}
 
 	if err := s.repo.Save(ctx, session); err != nil {
@@ -126,50 +300,23 @@ func (s *service) LeaveDispatcher(ctx context.Context, input LeaveDispatcherInpu
 	return nil
 }
 
-func (s *service) GetSnapshot(ctx context.Context) (SessionSnapshotDTO, error) {
+func (s *service) GetSnapshot(ctx context.Context, sessionID domain.SessionID) (SessionSnapshotDTO, error) {
 	// 読み取りでも、repoがメモリ参照ならロック不要にできるが、
 	// まずは安全側（更新と競合させない）で mu を使うのが無難。
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	session, err := s.repo.Get(ctx)
+	session, err := s.repo.Get(ctx, sessionID)
 	if err != nil {
 		return SessionSnapshotDTO{}, err
 	}
 	return toSnapshotDTO(session), nil
 }
 
-// ensureSession は「無ければ作る」をUseCaseの明示的な責務として実装する
-func (s *service) ensureSession(ctx context.Context, now time.Time) (*domain.TrainingSession, error) {
-	session, err := s.repo.Get(ctx)
-	if err == nil {
-		return session, nil
-	}
-
-	if !errors.Is(err, domain.ErrSessionAlreadyExists) {
-		return nil, err
-	}
-
-	// 無ければ明示的に作る
-	id, err := domain.NewSessionID("default")
-	if err != nil {
-		return nil, fmt.Errorf("セッションIDの生成に失敗。%w", err)
-	}
-	newSession := domain.NewTrainingSession(id, now)
-
-	// Create（競合したら再Getで救済）
-	if err := s.repo.Create(ctx, newSession); err != nil {
-		// 同時リクエストで二重作成になった場合を救済
-		// （service.mu があるなら基本起きないが、repoが別プロセス等になっても安全）
-		if errors.Is(err, domain.ErrSessionAlreadyExists) {
-			again, gerr := s.repo.Get(ctx)
-			if gerr == nil {
-				return again, nil
-			}
-			return nil, fmt.Errorf("セッション作成競合後の取得に失敗: %w", err)
-		}
-		return nil, fmt.Errorf("セッションの作成に失敗: %w", err)
+// checkExpectedVersion はクライアントが読んだバージョン（If-Match）と現在のバージョンを突き合わせる
+func checkExpectedVersion(session *domain.TrainingSession, expected *int64) error {
+	if expected != nil && *expected != session.Version() {
+		return domain.ErrVersionConflict
 	}
-
-	return newSession, nil
+	return nil
 }
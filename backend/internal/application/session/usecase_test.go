@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
+	"github.com/right1121/railway-control-center-simulator/internal/infrastructure/memory"
+)
+
+func TestChangeStatusDisposesSimulationWhenStartCannotBeSaved(t *testing.T) {
+	sessions := &failingSaveRepository{Repository: memory.NewInMemorySessionRepository(), err: errors.New("disk full")}
+	simulations := &recordingProvisioner{}
+	uc := NewUseCase(sessions, simulations)
+	ctx := context.Background()
+
+	created, err := uc.CreateSession(ctx, CreateSessionInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	id, _ := domain.NewSessionID(created.SessionID)
+
+	if _, err := uc.ChangeStatus(ctx, ChangeStatusInput{SessionID: id, Status: domain.SessionStatusRunning}); !errors.Is(err, sessions.err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	// 保存できなかった開始のために生成したシミュレーションは残さない
+	if simulations.provisioned != 1 || simulations.disposed != 1 {
+		t.Fatalf("expected provision to be undone, got provisioned=%d disposed=%d", simulations.provisioned, simulations.disposed)
+	}
+	snapshot, err := uc.GetSnapshot(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	if snapshot.Status != string(domain.SessionStatusLobby) {
+		t.Fatalf("expected session to stay in LOBBY, got %s", snapshot.Status)
+	}
+}
+
+func TestChangeStatusKeepsSimulationWhenCloseCannotBeSaved(t *testing.T) {
+	sessions := &failingSaveRepository{Repository: memory.NewInMemorySessionRepository()}
+	simulations := &recordingProvisioner{}
+	uc := NewUseCase(sessions, simulations)
+	ctx := context.Background()
+
+	created, err := uc.CreateSession(ctx, CreateSessionInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	id, _ := domain.NewSessionID(created.SessionID)
+	if _, err := uc.ChangeStatus(ctx, ChangeStatusInput{SessionID: id, Status: domain.SessionStatusRunning}); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	sessions.err = errors.New("disk full")
+	if _, err := uc.ChangeStatus(ctx, ChangeStatusInput{SessionID: id, Status: domain.SessionStatusClosed, Now: time.Now()}); !errors.Is(err, sessions.err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	// 閉じたことを保存できなければ、演習中のシミュレーションは止めない
+	if simulations.closed != 0 || simulations.disposed != 0 {
+		t.Fatalf("expected simulation to keep running, got closed=%d disposed=%d", simulations.closed, simulations.disposed)
+	}
+
+	sessions.err = nil
+	if _, err := uc.ChangeStatus(ctx, ChangeStatusInput{SessionID: id, Status: domain.SessionStatusClosed, Now: time.Now()}); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	// 閉じたら止めるが、振り返りのために記録は破棄しない
+	if simulations.closed != 1 || simulations.disposed != 0 {
+		t.Fatalf("expected simulation to be stopped but kept, got closed=%d disposed=%d", simulations.closed, simulations.disposed)
+	}
+}
+
+func TestDeleteSessionKeepsSessionWhenDisposeFails(t *testing.T) {
+	simulations := &recordingProvisioner{disposeErr: errors.New("disk full")}
+	uc := NewUseCase(memory.NewInMemorySessionRepository(), simulations)
+	ctx := context.Background()
+
+	created, err := uc.CreateSession(ctx, CreateSessionInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	id, _ := domain.NewSessionID(created.SessionID)
+
+	if err := uc.DeleteSession(ctx, DeleteSessionInput{SessionID: id}); !errors.Is(err, simulations.disposeErr) {
+		t.Fatalf("expected dispose error, got %v", err)
+	}
+	// シミュレーションを破棄できなければセッションを残し、削除をやり直せる
+	if _, err := uc.GetSnapshot(ctx, id); err != nil {
+		t.Fatalf("expected session to be kept, got %v", err)
+	}
+
+	simulations.disposeErr = nil
+	if err := uc.DeleteSession(ctx, DeleteSessionInput{SessionID: id}); err != nil {
+		t.Fatalf("retry DeleteSession failed: %v", err)
+	}
+	if _, err := uc.GetSnapshot(ctx, id); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+// failingSaveRepository は err が設定されている間、Save を失敗させる
+type failingSaveRepository struct {
+	domain.Repository
+	err error
+}
+
+func (r *failingSaveRepository) Save(ctx context.Context, s *domain.TrainingSession) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Repository.Save(ctx, s)
+}
+
+// recordingProvisioner は生成・停止・破棄の回数を数える。disposeErr が設定されている間は破棄に失敗する
+type recordingProvisioner struct {
+	provisioned int
+	closed      int
+	disposed    int
+	disposeErr  error
+}
+
+func (p *recordingProvisioner) Provision(context.Context, domain.SessionID) error {
+	p.provisioned++
+	return nil
+}
+
+func (p *recordingProvisioner) Close(context.Context, domain.SessionID) error {
+	p.closed++
+	return nil
+}
+
+func (p *recordingProvisioner) Dispose(context.Context, domain.SessionID) error {
+	if p.disposeErr != nil {
+		return p.disposeErr
+	}
+	p.disposed++
+	return nil
+}
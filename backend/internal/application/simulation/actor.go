@@ -0,0 +1,178 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// actorQueueSize は actor が受け付けて順番待ちにできる指令の数。
+// 埋まっていれば送る側が空くまで（またはその ctx が終わるまで）待つ。
+const actorQueueSize = 64
+
+// commandFunc は actor のゴルーチンで実行する指令。
+// 引数はその時点の現在の状態で、返した状態が以後の現在の状態になる（nil なら変えない）。
+// err を返しても状態を返せばそれを採用する（保存した後の失敗で、保存済みの状態とずれないようにする）。
+// 現在の状態は他の指令も使うので、変える指令は Clone してから変えること。
+type commandFunc func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error)
+
+type actorCommand struct {
+	ctx   context.Context
+	run   commandFunc
+	reply chan error
+}
+
+// simulationActor は1つのシミュレーションの状態を所有するゴルーチン。
+// 指令は受け取った順に1件ずつ実行するので、同じセッションへの同時の指令も順序が決まる。
+type simulationActor struct {
+	commands chan actorCommand
+	stop     chan struct{}
+	stopped  chan struct{}
+	state    *domain.SimulationState // actor のゴルーチンだけが読み書きする
+}
+
+func newSimulationActor(state *domain.SimulationState) *simulationActor {
+	a := &simulationActor{
+		commands: make(chan actorCommand, actorQueueSize),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		state:    state,
+	}
+	go a.loop()
+	return a
+}
+
+func (a *simulationActor) loop() {
+	defer close(a.stopped)
+	for {
+		select {
+		case <-a.stop:
+			return
+		case cmd := <-a.commands:
+			// 順番を待つあいだに呼び出し側が諦めた指令は実行しない
+			if err := cmd.ctx.Err(); err != nil {
+				cmd.reply <- err
+				continue
+			}
+			next, err := a.execute(cmd)
+			if next != nil {
+				a.state = next
+			}
+			cmd.reply <- err
+		}
+	}
+}
+
+// execute は指令を実行する。指令の panic で actor ごと止まらないよう error に変える。
+func (a *simulationActor) execute(cmd actorCommand) (next *domain.SimulationState, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			next, err = nil, fmt.Errorf("simulation command panicked: %v", r)
+		}
+	}()
+	return cmd.run(cmd.ctx, a.state)
+}
+
+// do は指令を順番待ちに入れ、実行結果を待つ。
+// ctx が先に終われば ctx.Err() を返す（実行が始まっていた指令は最後まで実行される）。
+// actor が止まっていれば domain.ErrSimulationNotFound を返す。
+func (a *simulationActor) do(ctx context.Context, run commandFunc) error {
+	cmd := actorCommand{ctx: ctx, run: run, reply: make(chan error, 1)}
+	select {
+	case a.commands <- cmd:
+	case <-a.stopped:
+		return domain.ErrSimulationNotFound
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-cmd.reply:
+		return err
+	case <-a.stopped:
+		// 止まる直前に実行し終えていれば、その結果を返す
+		select {
+		case err := <-cmd.reply:
+			return err
+		default:
+			return domain.ErrSimulationNotFound
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *simulationActor) shutdown() {
+	close(a.stop)
+	<-a.stopped
+}
+
+// SimulationActors はセッションごとのシミュレーションの actor を管理する。
+// 生成・破棄する Provisioner と、指令を送る UseCase で共有する。
+// 状態は actor が持ち、repo は永続化（と再起動後の復元）にだけ使う。
+type SimulationActors struct {
+	repo   domain.Repository
+	mu     sync.Mutex
+	actors map[string]*simulationActor
+}
+
+func NewSimulationActors(repo domain.Repository) *SimulationActors {
+	return &SimulationActors{
+		repo:   repo,
+		actors: make(map[string]*simulationActor),
+	}
+}
+
+// Start は state を所有する actor を起動する。既にあれば止めて置き換える。
+// 渡した state は以後 actor だけが触るので、呼び出し側は使わないこと。
+func (r *SimulationActors) Start(state *domain.SimulationState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := state.ID().String()
+	if old, ok := r.actors[key]; ok {
+		old.shutdown()
+	}
+	r.actors[key] = newSimulationActor(state)
+}
+
+// Stop は actor を止める。実行中の指令は終わるまで待ち、順番待ちの指令は捨てる。
+func (r *SimulationActors) Stop(id domain.SimulationID) {
+	r.mu.Lock()
+	actor, ok := r.actors[id.String()]
+	delete(r.actors, id.String())
+	r.mu.Unlock()
+
+	if ok {
+		actor.shutdown()
+	}
+}
+
+// do は id のシミュレーションの actor で run を実行する。
+// シミュレーションが無ければ domain.ErrSimulationNotFound を返す。
+func (r *SimulationActors) do(ctx context.Context, id domain.SimulationID, run commandFunc) error {
+	actor, err := r.actor(ctx, id)
+	if err != nil {
+		return err
+	}
+	return actor.do(ctx, run)
+}
+
+func (r *SimulationActors) actor(ctx context.Context, id domain.SimulationID) (*simulationActor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if actor, ok := r.actors[id.String()]; ok {
+		return actor, nil
+	}
+	// 再起動後など actor がまだ無ければ、保存済みの状態から起こす
+	state, err := r.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	actor := newSimulationActor(state)
+	r.actors[id.String()] = actor
+	return actor, nil
+}
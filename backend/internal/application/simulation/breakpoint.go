@@ -0,0 +1,367 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// BreakpointDTO は Tick・早送りの刻みごとに評価され、成立すると演習を一時停止させる条件
+type BreakpointDTO struct {
+	ID                   string           `json:"id"`
+	Condition            StopConditionDTO `json:"condition"`
+	Enabled              bool             `json:"enabled"`
+	HitCount             int              `json:"hitCount"`
+	LastHitSimTimeMillis *int64           `json:"lastHitSimTimeMillis,omitempty"`
+}
+
+// BreakpointHitDTO は SIMULATION_PAUSED 通知の付帯情報（どの条件で止まったか）
+type BreakpointHitDTO struct {
+	BreakpointID string           `json:"breakpointId"`
+	Condition    StopConditionDTO `json:"condition"`
+	Reason       StopReasonDTO    `json:"reason"`
+}
+
+type CreateBreakpointInput struct {
+	SessionID string
+	Condition StopConditionDTO
+	Enabled   *bool // nil なら有効
+}
+
+// UpdateBreakpointInput は nil の項目を変更しない
+type UpdateBreakpointInput struct {
+	SessionID    string
+	BreakpointID string
+	Condition    *StopConditionDTO
+	Enabled      *bool
+}
+
+type breakpoint struct {
+	id        string
+	condition StopConditionDTO
+	compiled  domain.StopCondition
+	enabled   bool
+	hits      int
+	lastHit   *int64
+}
+
+type sessionBreakpoints struct {
+	order   []*breakpoint
+	watcher *domain.ConditionWatcher
+}
+
+// BreakpointRegistry はセッションごとのブレークポイントを保持する。
+// 定義（条件と有効・無効）は repo に保存し、再起動後は最初に使うときに読み込む。
+// 成立の回数と、Tick をまたいで数える列車の待ち時間などの評価の途中経過はここにだけ置く（永続化はしない）。
+type BreakpointRegistry struct {
+	mu       sync.Mutex
+	repo     domain.BreakpointRepository
+	sessions map[string]*sessionBreakpoints
+}
+
+func NewBreakpointRegistry(repo domain.BreakpointRepository) *BreakpointRegistry {
+	return &BreakpointRegistry{
+		repo:     repo,
+		sessions: make(map[string]*sessionBreakpoints),
+	}
+}
+
+func (r *BreakpointRegistry) list(ctx context.Context, sessionID string) ([]BreakpointDTO, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BreakpointDTO, 0, len(entry.order))
+	for _, bp := range entry.order {
+		out = append(out, bp.toDTO())
+	}
+	return out, nil
+}
+
+func (r *BreakpointRegistry) create(ctx context.Context, sessionID string, condition StopConditionDTO, enabled bool) (BreakpointDTO, error) {
+	compiled, err := newBreakpointCondition(condition)
+	if err != nil {
+		return BreakpointDTO{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(ctx, sessionID)
+	if err != nil {
+		return BreakpointDTO{}, err
+	}
+	bp := &breakpoint{
+		id:        uuid.NewString(),
+		condition: condition,
+		compiled:  compiled,
+		enabled:   enabled,
+	}
+	order := append(append([]*breakpoint(nil), entry.order...), bp)
+	if err := r.save(ctx, sessionID, order); err != nil {
+		return BreakpointDTO{}, err
+	}
+	entry.order = order
+	return bp.toDTO(), nil
+}
+
+func (r *BreakpointRegistry) update(ctx context.Context, input UpdateBreakpointInput) (BreakpointDTO, error) {
+	var compiled domain.StopCondition
+	if input.Condition != nil {
+		c, err := newBreakpointCondition(*input.Condition)
+		if err != nil {
+			return BreakpointDTO{}, err
+		}
+		compiled = c
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(ctx, input.SessionID)
+	if err != nil {
+		return BreakpointDTO{}, err
+	}
+	bp, i, err := entry.find(input.BreakpointID)
+	if err != nil {
+		return BreakpointDTO{}, err
+	}
+	// 保存できてから置き換える（成立の回数は引き継ぐ）
+	updated := *bp
+	if input.Condition != nil {
+		updated.condition = *input.Condition
+		updated.compiled = compiled
+	}
+	if input.Enabled != nil {
+		updated.enabled = *input.Enabled
+	}
+	order := append([]*breakpoint(nil), entry.order...)
+	order[i] = &updated
+	if err := r.save(ctx, input.SessionID, order); err != nil {
+		return BreakpointDTO{}, err
+	}
+	entry.order = order
+	return updated.toDTO(), nil
+}
+
+func (r *BreakpointRegistry) delete(ctx context.Context, sessionID, breakpointID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	_, i, err := entry.find(breakpointID)
+	if err != nil {
+		return err
+	}
+	order := append(append([]*breakpoint(nil), entry.order[:i]...), entry.order[i+1:]...)
+	if err := r.save(ctx, sessionID, order); err != nil {
+		return err
+	}
+	entry.order = order
+	return nil
+}
+
+// evaluate は from に始まる1回の Tick で起きた出来事で有効なブレークポイントを評価し、最初に成立したものを返す
+func (r *BreakpointRegistry) evaluate(ctx context.Context, sessionID string, from domain.SimTime, events []domain.Event, state *domain.SimulationState) (*BreakpointHitDTO, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make([]*breakpoint, 0, len(entry.order))
+	conditions := make([]domain.StopCondition, 0, len(entry.order))
+	for _, bp := range entry.order {
+		if bp.enabled {
+			enabled = append(enabled, bp)
+			conditions = append(conditions, bp.compiled)
+		}
+	}
+
+	reason, hit := entry.watcher.Step(from, events, state, conditions)
+	if !hit {
+		return nil, nil
+	}
+	return enabled[reason.ConditionIndex].recordHit(reason, state.SimTime()), nil
+}
+
+// enabledConditions は有効なブレークポイントの条件と、同じ並びのブレークポイントIDを返す（早送りの停止条件に加えるため）
+func (r *BreakpointRegistry) enabledConditions(ctx context.Context, sessionID string) ([]domain.StopCondition, []string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conditions []domain.StopCondition
+	var ids []string
+	for _, bp := range entry.order {
+		if bp.enabled {
+			conditions = append(conditions, bp.compiled)
+			ids = append(ids, bp.id)
+		}
+	}
+	return conditions, ids, nil
+}
+
+// hit は早送りで成立したブレークポイントを数える。早送りの間に削除されていれば nil を返す
+func (r *BreakpointRegistry) hit(sessionID, breakpointID string, reason domain.StopReason, at domain.SimTime) *BreakpointHitDTO {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	bp, _, err := entry.find(breakpointID)
+	if err != nil {
+		return nil
+	}
+	return bp.recordHit(reason, at)
+}
+
+// resetObservation は待ち時間などの途中経過を捨てる（巻き戻し・取り込みで状態が不連続になったとき）
+func (r *BreakpointRegistry) resetObservation(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.sessions[sessionID]; ok {
+		entry.watcher = domain.NewConditionWatcher()
+	}
+}
+
+// Forget はセッションのブレークポイントを保存した定義ごとすべて破棄する（シミュレーションの破棄時）
+func (r *BreakpointRegistry) Forget(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := domain.NewSimulationID(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("breakpoint delete failed: %w", err)
+	}
+	delete(r.sessions, sessionID)
+	return nil
+}
+
+// entry はセッションのブレークポイントを返す。まだ読み込んでいなければ保存した定義を読む（r.mu を持って呼ぶ）。
+func (r *BreakpointRegistry) entry(ctx context.Context, sessionID string) (*sessionBreakpoints, error) {
+	if entry, ok := r.sessions[sessionID]; ok {
+		return entry, nil
+	}
+	id, err := domain.NewSimulationID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := r.repo.List(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("breakpoint load failed: %w", err)
+	}
+	entry := &sessionBreakpoints{watcher: domain.NewConditionWatcher(), order: make([]*breakpoint, 0, len(saved))}
+	for _, def := range saved {
+		condition := toStopConditionDTO(def.Condition)
+		compiled, err := newBreakpointCondition(condition)
+		if err != nil {
+			return nil, fmt.Errorf("breakpoint %s: %w", def.ID, err)
+		}
+		entry.order = append(entry.order, &breakpoint{id: def.ID, condition: condition, compiled: compiled, enabled: def.Enabled})
+	}
+	r.sessions[sessionID] = entry
+	return entry, nil
+}
+
+// save はブレークポイントの定義を登録順に保存する（r.mu を持って呼ぶ）
+func (r *BreakpointRegistry) save(ctx context.Context, sessionID string, order []*breakpoint) error {
+	id, err := domain.NewSimulationID(sessionID)
+	if err != nil {
+		return err
+	}
+	defs := make([]domain.Breakpoint, 0, len(order))
+	for _, bp := range order {
+		defs = append(defs, domain.Breakpoint{ID: bp.id, Condition: toStopConditionSpec(bp.condition), Enabled: bp.enabled})
+	}
+	if err := r.repo.Save(ctx, id, defs); err != nil {
+		return fmt.Errorf("breakpoint save failed: %w", err)
+	}
+	return nil
+}
+
+func (entry *sessionBreakpoints) find(breakpointID string) (*breakpoint, int, error) {
+	for i, bp := range entry.order {
+		if bp.id == breakpointID {
+			return bp, i, nil
+		}
+	}
+	return nil, -1, fmt.Errorf("%w: %s", ErrBreakpointNotFound, breakpointID)
+}
+
+func toStopConditionSpec(c StopConditionDTO) domain.StopConditionSpec {
+	return domain.StopConditionSpec{
+		Type:           c.Type,
+		TrainID:        c.TrainID,
+		StationID:      c.StationID,
+		DurationMillis: c.DurationMillis,
+		EventType:      c.EventType,
+	}
+}
+
+func toStopConditionDTO(c domain.StopConditionSpec) StopConditionDTO {
+	return StopConditionDTO{
+		Type:           c.Type,
+		TrainID:        c.TrainID,
+		StationID:      c.StationID,
+		DurationMillis: c.DurationMillis,
+		EventType:      c.EventType,
+	}
+}
+
+func newBreakpointCondition(c StopConditionDTO) (domain.StopCondition, error) {
+	compiled, err := newStopCondition(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBreakpoint, err)
+	}
+	return compiled, nil
+}
+
+// recordHit は成立を数え、一時停止の通知に載せる付帯情報を返す
+func (bp *breakpoint) recordHit(reason domain.StopReason, at domain.SimTime) *BreakpointHitDTO {
+	millis := at.Millis()
+	bp.hits++
+	bp.lastHit = &millis
+
+	reasonDTO := toStopReasonDTO(reason)
+	reasonDTO.ConditionIndex = -1 // 添字は有効なものだけの並びなので返さない
+	return &BreakpointHitDTO{
+		BreakpointID: bp.id,
+		Condition:    bp.condition,
+		Reason:       reasonDTO,
+	}
+}
+
+func (bp *breakpoint) toDTO() BreakpointDTO {
+	dto := BreakpointDTO{
+		ID:        bp.id,
+		Condition: bp.condition,
+		Enabled:   bp.enabled,
+		HitCount:  bp.hits,
+	}
+	if bp.lastHit != nil {
+		at := *bp.lastHit
+		dto.LastHitSimTimeMillis = &at
+	}
+	return dto
+}
@@ -0,0 +1,128 @@
+package simulation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// maxChangesWaitMillis は差分の取得で次の更新を待てる上限
+const maxChangesWaitMillis int64 = 60_000
+
+// ChangesInput は差分取得の入力。SinceVersion の版から最新までに変わったものを返す。
+// WaitMillis が正なら、SinceVersion が最新のあいだは次の更新をその時間まで待つ（長いポーリング）。
+type ChangesInput struct {
+	SessionID    string
+	SinceVersion int64
+	WaitMillis   int64
+}
+
+// SimulationDeltaDTO は FromVersion の版から Version の版までに変わった列車と区間。
+// FromVersion の状態をもう保持していなければ Full を立て、すべての列車と区間を返す。
+// 路線の構成は変わらないので含めない（TopologyDTO で別に取得する）。
+// 警報と優先規則は小さいので、変化の有無によらず最新の全量を返す。
+type SimulationDeltaDTO struct {
+	SessionID       string          `json:"sessionId"`
+	FromVersion     int64           `json:"fromVersion"`
+	Version         int64           `json:"version"`
+	SimTimeMillis   int64           `json:"simTimeMillis"`
+	Full            bool            `json:"full"`
+	Trains          []TrainDTO      `json:"trains"`
+	RemovedTrainIDs []string        `json:"removedTrainIds"`
+	Blocks          []BlockStateDTO `json:"blocks"`
+	Alarms          []AlarmDTO      `json:"alarms"`
+	PriorityRules   []string        `json:"priorityRules"`
+}
+
+// BlockStateDTO は区間の在線。空いていれば OccupiedBy は空。
+type BlockStateDTO struct {
+	ID         string `json:"id"`
+	OccupiedBy string `json:"occupiedBy,omitempty"`
+}
+
+// TopologyDTO は演習中に変わらない路線網の構成。Revision は内容から決まるので ETag に使える。
+// Stations と Blocks は網の全駅と全区間（SimulationDTO.Line と同じ）で、Schematic はその路線図での配置。
+type TopologyDTO struct {
+	Revision     string            `json:"revision"`
+	Stations     []string          `json:"stations"`
+	Blocks       []string          `json:"blocks"`
+	Schematic    *LineSchematicDTO `json:"schematic"`
+	Lines        []LineDTO         `json:"lines"`
+	Interchanges []string          `json:"interchanges"`
+	Transfers    []TransferDTO     `json:"transfers"`
+}
+
+// toSimulationDelta は base から latest までの差分を作る。base が nil なら全量。
+func toSimulationDelta(since int64, base *SimulationDTO, latest SimulationDTO) SimulationDeltaDTO {
+	delta := SimulationDeltaDTO{
+		SessionID:       latest.SessionID,
+		FromVersion:     since,
+		Version:         latest.Version,
+		SimTimeMillis:   latest.SimTimeMillis,
+		Trains:          []TrainDTO{},
+		RemovedTrainIDs: []string{},
+		Blocks:          []BlockStateDTO{},
+		Alarms:          latest.Alarms,
+		PriorityRules:   latest.PriorityRules,
+	}
+
+	occupancy := blockOccupancy(latest)
+	if base == nil {
+		delta.Full = true
+		delta.Trains = append(delta.Trains, latest.Trains...)
+		for _, id := range latest.Line.Blocks {
+			delta.Blocks = append(delta.Blocks, BlockStateDTO{ID: id, OccupiedBy: occupancy[id]})
+		}
+		return delta
+	}
+
+	before := make(map[string]TrainDTO, len(base.Trains))
+	for _, train := range base.Trains {
+		before[train.ID] = train
+	}
+	for _, train := range latest.Trains {
+		if prev, ok := before[train.ID]; !ok || prev != train {
+			delta.Trains = append(delta.Trains, train)
+		}
+		delete(before, train.ID)
+	}
+	for _, train := range base.Trains {
+		if _, removed := before[train.ID]; removed {
+			delta.RemovedTrainIDs = append(delta.RemovedTrainIDs, train.ID)
+		}
+	}
+
+	previous := blockOccupancy(*base)
+	for _, id := range latest.Line.Blocks {
+		if occupancy[id] != previous[id] {
+			delta.Blocks = append(delta.Blocks, BlockStateDTO{ID: id, OccupiedBy: occupancy[id]})
+		}
+	}
+	return delta
+}
+
+func blockOccupancy(dto SimulationDTO) map[string]string {
+	occupancy := make(map[string]string, len(dto.Trains))
+	for _, train := range dto.Trains {
+		occupancy[train.BlockID] = train.ID
+	}
+	return occupancy
+}
+
+// newTopologyDTO は網の構成と、その内容から決まる Revision を作る（網ごとに1度だけ作って使い回す）
+func newTopologyDTO(network LineDTO, lines []LineDTO, interchanges []string, transfers []TransferDTO) TopologyDTO {
+	topology := TopologyDTO{
+		Stations:     network.Stations,
+		Blocks:       network.Blocks,
+		Schematic:    network.Schematic,
+		Lines:        lines,
+		Interchanges: interchanges,
+		Transfers:    transfers,
+	}
+
+	// 構成の内容から決まる値にする（同じ網なら再起動後も同じ）
+	content, _ := json.Marshal(topology)
+	sum := sha256.Sum256(content)
+	topology.Revision = hex.EncodeToString(sum[:16])
+	return topology
+}
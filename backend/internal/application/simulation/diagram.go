@@ -0,0 +1,436 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// TimetableDTO は計画ダイヤ。運行は与えた順。
+type TimetableDTO struct {
+	Services []ServiceDTO `json:"services"`
+}
+
+// ServiceDTO は時刻表の1本の列車（運行）。TrainID はこの運行を受け持つ列車（決まっていなければ省く）。
+type ServiceDTO struct {
+	ID      string           `json:"id"`
+	TrainID string           `json:"trainId,omitempty"`
+	Stops   []ServiceStopDTO `json:"stops"`
+}
+
+type ServiceStopDTO struct {
+	StationID              string `json:"stationId"`
+	ArrivalSimTimeMillis   int64  `json:"arrivalSimTimeMillis"`
+	DepartureSimTimeMillis int64  `json:"departureSimTimeMillis"`
+}
+
+// SetTimetableInput は計画ダイヤの置き換え。Services が空なら計画ダイヤを消す。
+type SetTimetableInput struct {
+	SessionID string
+	Timetable TimetableDTO
+}
+
+// DiagramInput は運行図表の取得。LineID が空なら網の最初の路線を描く。
+type DiagramInput struct {
+	SessionID string
+	LineID    string
+}
+
+const (
+	// DiagramDistanceKilometers は駅のキロ程で距離を表すこと、DiagramDistanceStations は駅の数で数えることを表す
+	DiagramDistanceKilometers = "KILOMETERS"
+	DiagramDistanceStations   = "STATIONS"
+)
+
+// DiagramDTO は1つの路線の運行図表（縦に駅までの距離、横にシミュレーション時刻）。
+// Planned は計画ダイヤの線で、運行がこの路線を離れるところで分ける（同じ ServiceID が複数になりうる）。
+// Actual は列車の実際の走行の記録。Tick などで状態を公開するたびの位置を結んだもの。
+type DiagramDTO struct {
+	SessionID     string              `json:"sessionId"`
+	LineID        string              `json:"lineId"`
+	SimTimeMillis int64               `json:"simTimeMillis"`
+	DistanceUnit  string              `json:"distanceUnit"`
+	Stations      []DiagramStationDTO `json:"stations"`
+	Planned       []PlannedRunDTO     `json:"planned"`
+	Actual        []ActualRunDTO      `json:"actual"`
+}
+
+// DiagramStationDTO の Distance は路線の先頭の駅からの距離（単位は DistanceUnit）
+type DiagramStationDTO struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name,omitempty"`
+	Distance float64 `json:"distance"`
+}
+
+type PlannedRunDTO struct {
+	ServiceID string            `json:"serviceId"`
+	TrainID   string            `json:"trainId,omitempty"`
+	Points    []DiagramPointDTO `json:"points"`
+}
+
+type ActualRunDTO struct {
+	TrainID string            `json:"trainId"`
+	Points  []DiagramPointDTO `json:"points"`
+}
+
+type DiagramPointDTO struct {
+	SimTimeMillis int64   `json:"simTimeMillis"`
+	Distance      float64 `json:"distance"`
+}
+
+// maxDiagramPoints は列車ごとに残す走行の記録の点の数。超えたら古い点から捨てる。
+const maxDiagramPoints = 20_000
+
+type diagramRunKey struct {
+	line  string
+	train string
+}
+
+type sessionDiagram struct {
+	timetable *domain.Timetable
+	runs      map[diagramRunKey][]DiagramPointDTO
+	// loaded は保存した計画ダイヤと入力ログから組み直した記録か（再起動後は公開した分しか持たない）
+	loaded bool
+}
+
+// DiagramRegistry はセッションごとの計画ダイヤと列車の走行の記録を保持する。
+// 計画ダイヤは timetables に保存し、走行の記録は入力ログを再生すれば組み直せるので、再起動後は最初に使うときに読み込む。
+// 走行の記録は公開した状態ごとの列車の位置で、時刻が戻れば（巻き戻し）その時刻より後の記録を捨てる。
+type DiagramRegistry struct {
+	mu         sync.Mutex
+	timetables domain.TimetableRepository
+	logs       domain.InputLogRepository
+	sessions   map[string]*sessionDiagram
+}
+
+func NewDiagramRegistry(timetables domain.TimetableRepository, logs domain.InputLogRepository) *DiagramRegistry {
+	return &DiagramRegistry{
+		timetables: timetables,
+		logs:       logs,
+		sessions:   make(map[string]*sessionDiagram),
+	}
+}
+
+// start は生成したシミュレーションの計画ダイヤを保存し、初期状態から記録を始める
+func (r *DiagramRegistry) start(ctx context.Context, state *domain.SimulationState, timetable *domain.Timetable) error {
+	if timetable != nil {
+		if err := r.timetables.Save(ctx, state.ID(), timetable); err != nil {
+			return fmt.Errorf("timetable save failed: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &sessionDiagram{timetable: timetable, runs: make(map[diagramRunKey][]DiagramPointDTO), loaded: true}
+	entry.record(state)
+	r.sessions[state.ID().String()] = entry
+	return nil
+}
+
+// record は公開した状態の列車の位置を走行の記録に加える（actor の指令の中で呼ぶ）
+func (r *DiagramRegistry) record(state *domain.SimulationState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entry(state.ID().String()).record(state)
+}
+
+// resetRuns は走行の記録を捨てる（取り込みで別の状態に置き換えたとき）。計画ダイヤは残す。
+func (r *DiagramRegistry) resetRuns(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.sessions[sessionID]; ok {
+		entry.runs = make(map[diagramRunKey][]DiagramPointDTO)
+	}
+}
+
+// setTimetable は計画ダイヤを保存してから置き換える（actor の指令の中で呼ぶ）
+func (r *DiagramRegistry) setTimetable(ctx context.Context, id domain.SimulationID, timetable *domain.Timetable) error {
+	if err := r.timetables.Save(ctx, id, timetable); err != nil {
+		return fmt.Errorf("timetable save failed: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entry(id.String()).timetable = timetable
+	return nil
+}
+
+// timetable は計画ダイヤを返す（actor の指令の中で呼ぶ）
+func (r *DiagramRegistry) timetable(ctx context.Context, id domain.SimulationID) (*domain.Timetable, error) {
+	if err := r.load(ctx, id); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sessions[id.String()].timetable, nil
+}
+
+// load はまだ読み込んでいなければ、保存した計画ダイヤを読み、走行の記録を入力ログの再生で組み直す。
+// 同じセッションの記録は actor の指令の中でしか変わらないので、読み込みの間はロックを持たない。
+func (r *DiagramRegistry) load(ctx context.Context, id domain.SimulationID) error {
+	r.mu.Lock()
+	entry, ok := r.sessions[id.String()]
+	r.mu.Unlock()
+	if ok && entry.loaded {
+		return nil
+	}
+
+	timetable, err := r.timetables.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("timetable load failed: %w", err)
+	}
+	records, err := r.logs.List(ctx, id)
+	if err != nil {
+		return fmt.Errorf("input log read failed: %w", err)
+	}
+	loaded := &sessionDiagram{timetable: timetable, runs: make(map[diagramRunKey][]DiagramPointDTO), loaded: true}
+	if len(records) > 0 {
+		_, err := domain.ReplayEach(records, func(record domain.InputRecord, state *domain.SimulationState) {
+			switch record.Kind {
+			case domain.InputCheckpoint:
+				return // 状態は変わらず、公開もしていない
+			case domain.InputRestored:
+				loaded.runs = make(map[diagramRunKey][]DiagramPointDTO)
+			}
+			loaded.record(state)
+		})
+		if err != nil {
+			return fmt.Errorf("diagram rebuild failed: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[id.String()] = loaded
+	return nil
+}
+
+// diagram は路線の運行図表を組み立てる（actor の指令の中で呼ぶ）
+func (r *DiagramRegistry) diagram(ctx context.Context, state *domain.SimulationState, lineID domain.LineID) (DiagramDTO, error) {
+	if err := r.load(ctx, state.ID()); err != nil {
+		return DiagramDTO{}, err
+	}
+	sessionID := state.ID().String()
+	network := state.Network()
+	line, _ := network.Line(lineID)
+	distances, kilometers := line.StationDistances()
+
+	dto := DiagramDTO{
+		SessionID:     sessionID,
+		LineID:        lineID.String(),
+		SimTimeMillis: state.SimTime().Millis(),
+		DistanceUnit:  DiagramDistanceStations,
+		Stations:      make([]DiagramStationDTO, 0, len(distances)),
+		Planned:       []PlannedRunDTO{},
+		Actual:        []ActualRunDTO{},
+	}
+	if kilometers {
+		dto.DistanceUnit = DiagramDistanceKilometers
+	}
+	stationDistance := make(map[string]float64, len(distances))
+	for i, station := range line.Stations() {
+		info, _ := network.StationInfo(station)
+		dto.Stations = append(dto.Stations, DiagramStationDTO{ID: station.String(), Name: info.Name, Distance: distances[i]})
+		stationDistance[station.String()] = distances[i]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := r.sessions[sessionID]
+	if entry.timetable != nil {
+		for _, service := range entry.timetable.Services() {
+			dto.Planned = append(dto.Planned, plannedRuns(service, stationDistance)...)
+		}
+	}
+	for key, points := range entry.runs {
+		if key.line != lineID.String() || len(points) == 0 {
+			continue
+		}
+		copied := make([]DiagramPointDTO, len(points))
+		copy(copied, points)
+		dto.Actual = append(dto.Actual, ActualRunDTO{TrainID: key.train, Points: copied})
+	}
+	sort.Slice(dto.Actual, func(i, j int) bool { return dto.Actual[i].TrainID < dto.Actual[j].TrainID })
+	return dto, nil
+}
+
+// Forget はセッションの計画ダイヤと走行の記録を破棄する（シミュレーションの破棄時）
+func (r *DiagramRegistry) Forget(ctx context.Context, id domain.SimulationID) error {
+	r.mu.Lock()
+	delete(r.sessions, id.String())
+	r.mu.Unlock()
+
+	if err := r.timetables.Delete(ctx, id); err != nil {
+		return fmt.Errorf("timetable delete failed: %w", err)
+	}
+	return nil
+}
+
+func (r *DiagramRegistry) entry(sessionID string) *sessionDiagram {
+	entry, ok := r.sessions[sessionID]
+	if !ok {
+		entry = &sessionDiagram{runs: make(map[diagramRunKey][]DiagramPointDTO)}
+		r.sessions[sessionID] = entry
+	}
+	return entry
+}
+
+// record は状態の列車の位置を走行の記録に加える。状態の時刻より後の点は捨てる。
+func (entry *sessionDiagram) record(state *domain.SimulationState) {
+	at := state.SimTime().Millis()
+	for key, points := range entry.runs {
+		entry.runs[key] = truncateDiagramRun(points, at)
+	}
+
+	network := state.Network()
+	distances := make(map[string][]float64)
+	for _, train := range state.Trains() {
+		lineID, ok := network.LineOf(train.BlockID())
+		if !ok {
+			continue
+		}
+		line, _ := network.Line(lineID)
+		if _, ok := distances[lineID.String()]; !ok {
+			distances[lineID.String()], _ = line.StationDistances()
+		}
+		distance, ok := trainDistance(line, distances[lineID.String()], train)
+		if !ok {
+			continue
+		}
+
+		key := diagramRunKey{line: lineID.String(), train: train.ID().String()}
+		points := entry.runs[key]
+		point := DiagramPointDTO{SimTimeMillis: at, Distance: distance}
+		if n := len(points); n > 0 && points[n-1].SimTimeMillis == at {
+			points[n-1] = point
+		} else {
+			points = append(points, point)
+		}
+		if len(points) > maxDiagramPoints {
+			points = append(points[:0], points[len(points)-maxDiagramPoints:]...)
+		}
+		entry.runs[key] = points
+	}
+}
+
+// truncateDiagramRun は at より後の点を捨てる
+func truncateDiagramRun(points []DiagramPointDTO, at int64) []DiagramPointDTO {
+	end := len(points)
+	for end > 0 && points[end-1].SimTimeMillis > at {
+		end--
+	}
+	return points[:end]
+}
+
+// trainDistance は列車の位置を路線の先頭の駅からの距離で表す（区間の進み具合で両側の駅のあいだを比例配分する）
+func trainDistance(line *domain.Line, distances []float64, train domain.Train) (float64, bool) {
+	index, ok := line.IndexOfBlock(train.BlockID())
+	if !ok || index+1 >= len(distances) {
+		return 0, false
+	}
+	from, to := distances[index], distances[index+1]
+	return from + (to-from)*train.Progress().Float64(), true
+}
+
+// plannedRuns は運行の計画の線を、この路線の駅に続けて停まるところごとに分けて返す。
+// 駅ごとに着と発の2点を置く（同じ時刻なら1点）。路線の駅が1つしか続かないところは線にならないので省く。
+func plannedRuns(service domain.Service, stationDistance map[string]float64) []PlannedRunDTO {
+	var out []PlannedRunDTO
+	var points []DiagramPointDTO
+	stops := 0
+	flush := func() {
+		if stops >= 2 {
+			out = append(out, PlannedRunDTO{ServiceID: service.ID.String(), TrainID: service.Train.String(), Points: points})
+		}
+		points, stops = nil, 0
+	}
+	for _, stop := range service.Stops {
+		distance, ok := stationDistance[stop.Station.String()]
+		if !ok {
+			flush()
+			continue
+		}
+		stops++
+		points = append(points, DiagramPointDTO{SimTimeMillis: stop.Arrival.Millis(), Distance: distance})
+		if stop.Departure != stop.Arrival {
+			points = append(points, DiagramPointDTO{SimTimeMillis: stop.Departure.Millis(), Distance: distance})
+		}
+	}
+	flush()
+	return out
+}
+
+func toTimetableDTO(timetable *domain.Timetable) TimetableDTO {
+	dto := TimetableDTO{Services: []ServiceDTO{}}
+	if timetable == nil {
+		return dto
+	}
+	for _, service := range timetable.Services() {
+		stops := make([]ServiceStopDTO, 0, len(service.Stops))
+		for _, stop := range service.Stops {
+			stops = append(stops, ServiceStopDTO{
+				StationID:              stop.Station.String(),
+				ArrivalSimTimeMillis:   stop.Arrival.Millis(),
+				DepartureSimTimeMillis: stop.Departure.Millis(),
+			})
+		}
+		dto.Services = append(dto.Services, ServiceDTO{ID: service.ID.String(), TrainID: service.Train.String(), Stops: stops})
+	}
+	return dto
+}
+
+// fromTimetableDTO は計画ダイヤを検証して時刻表にする。運行が無ければ nil（計画ダイヤなし）。
+func fromTimetableDTO(dto TimetableDTO, network *domain.Network) (*domain.Timetable, error) {
+	if len(dto.Services) == 0 {
+		return nil, nil
+	}
+	services := make([]domain.Service, 0, len(dto.Services))
+	for _, s := range dto.Services {
+		id, err := domain.NewServiceID(s.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+		}
+		service := domain.Service{ID: id, Stops: make([]domain.ServiceStop, 0, len(s.Stops))}
+		if s.TrainID != "" {
+			if service.Train, err = domain.NewTrainID(s.TrainID); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+			}
+		}
+		for _, stop := range s.Stops {
+			station, err := domain.NewStationID(stop.StationID)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+			}
+			arrival, err := domain.NewSimTime(stop.ArrivalSimTimeMillis)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+			}
+			departure, err := domain.NewSimTime(stop.DepartureSimTimeMillis)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+			}
+			service.Stops = append(service.Stops, domain.ServiceStop{Station: station, Arrival: arrival, Departure: departure})
+		}
+		services = append(services, service)
+	}
+
+	timetable, err := domain.NewTimetable(services)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+	}
+	if err := timetable.CheckStations(network); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTimetable, err)
+	}
+	return timetable, nil
+}
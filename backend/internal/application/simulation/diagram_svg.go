@@ -0,0 +1,153 @@
+package simulation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// 運行図表の SVG の寸法と色
+const (
+	diagramPlotWidth = 960.0
+	// diagramStationGap は駅と駅の平均の縦の間隔（図の高さはこれに駅の数を掛けて決める）
+	diagramStationGap      = 60.0
+	diagramMinPlotHeight   = 120.0
+	diagramAxisLabelWidth  = 80.0
+	diagramMaxTimeTicks    = 12
+	diagramTrainLabelShift = 4.0
+
+	diagramGridColor    = "#d9e2ec"
+	diagramPlannedColor = "#7b8794"
+	diagramNowColor     = "#d64545"
+)
+
+// diagramTickSteps は時刻の目盛りの間隔の候補（目盛りが diagramMaxTimeTicks を超えない最も細かいものを使う）
+var diagramTickSteps = []time.Duration{
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// RenderDiagramSVG は運行図表（縦に駅、横にシミュレーション時刻）の SVG を w に書く。
+// 計画ダイヤは灰色の破線、列車の走行の記録は実線で描き、線の終わりに列車IDを添える。現在時刻には赤い縦線を引く。
+// 同じ DTO からはいつも同じバイト列になる（ゴールデンファイルと比べられる）。
+func RenderDiagramSVG(w io.Writer, dto DiagramDTO) error {
+	fromMillis, untilMillis := diagramTimeRange(dto)
+	tick := diagramTickStep(untilMillis - fromMillis)
+	fromMillis = fromMillis / tick * tick
+	untilMillis = (untilMillis + tick - 1) / tick * tick
+	if untilMillis <= fromMillis {
+		untilMillis = fromMillis + tick
+	}
+
+	maxDistance := 0.0
+	for _, station := range dto.Stations {
+		maxDistance = math.Max(maxDistance, station.Distance)
+	}
+	plotHeight := math.Max(diagramMinPlotHeight, diagramStationGap*float64(len(dto.Stations)-1))
+	left, top := svgMargin+diagramAxisLabelWidth, svgMargin+svgHeaderHeight
+	x := func(millis int64) float64 {
+		return left + float64(millis-fromMillis)/float64(untilMillis-fromMillis)*diagramPlotWidth
+	}
+	y := func(distance float64) float64 {
+		if maxDistance == 0 {
+			return top
+		}
+		return top + distance/maxDistance*plotHeight
+	}
+	points := func(run []DiagramPointDTO) []PointDTO {
+		out := make([]PointDTO, 0, len(run))
+		for _, p := range run {
+			out = append(out, PointDTO{X: x(p.SimTimeMillis), Y: y(p.Distance)})
+		}
+		return out
+	}
+	width := left + diagramPlotWidth + svgMargin
+	height := top + plotHeight + svgMargin + svgHeaderHeight
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif" font-size="12">`+"\n",
+		svgNumber(width), svgNumber(height), svgNumber(width), svgNumber(height))
+	fmt.Fprintf(out, `<rect x="0" y="0" width="%s" height="%s" fill="#ffffff"/>`+"\n", svgNumber(width), svgNumber(height))
+	fmt.Fprintf(out, `<text x="%s" y="%s" font-weight="bold">%s  %s  %s</text>`+"\n",
+		svgNumber(svgMargin), svgNumber(svgMargin), svgText(dto.SessionID), svgText(dto.LineID), formatSimClock(dto.SimTimeMillis))
+
+	out.WriteString(`<g id="grid" stroke="` + diagramGridColor + `" stroke-width="1">` + "\n")
+	for t := fromMillis; t <= untilMillis; t += tick {
+		fmt.Fprintf(out, `<line x1="%s" y1="%s" x2="%s" y2="%s"/>`+"\n", svgNumber(x(t)), svgNumber(top), svgNumber(x(t)), svgNumber(top+plotHeight))
+	}
+	for _, station := range dto.Stations {
+		fmt.Fprintf(out, `<line data-station-id="%s" x1="%s" y1="%s" x2="%s" y2="%s"/>`+"\n",
+			svgText(station.ID), svgNumber(left), svgNumber(y(station.Distance)), svgNumber(left+diagramPlotWidth), svgNumber(y(station.Distance)))
+	}
+	out.WriteString("</g>\n")
+
+	out.WriteString(`<g id="axes">` + "\n")
+	for t := fromMillis; t <= untilMillis; t += tick {
+		fmt.Fprintf(out, `<text x="%s" y="%s" text-anchor="middle">%s</text>`+"\n",
+			svgNumber(x(t)), svgNumber(top+plotHeight+svgHeaderHeight), formatDiagramClock(t))
+	}
+	for _, station := range dto.Stations {
+		label := station.ID
+		if station.Name != "" {
+			label = station.Name
+		}
+		fmt.Fprintf(out, `<text x="%s" y="%s" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n",
+			svgNumber(left-diagramTrainLabelShift*2), svgNumber(y(station.Distance)), svgText(label))
+	}
+	out.WriteString("</g>\n")
+
+	out.WriteString(`<g id="planned" fill="none" stroke="` + diagramPlannedColor + `" stroke-width="1.5" stroke-dasharray="6 4">` + "\n")
+	for _, run := range dto.Planned {
+		fmt.Fprintf(out, `<polyline data-service-id="%s" points="%s"><title>%s</title></polyline>`+"\n",
+			svgText(run.ServiceID), svgPoints(points(run.Points)), svgText(run.ServiceID))
+	}
+	out.WriteString("</g>\n")
+
+	out.WriteString(`<g id="actual" fill="none" stroke="` + svgTrainColor + `" stroke-width="2">` + "\n")
+	for _, run := range dto.Actual {
+		drawn := points(run.Points)
+		last := drawn[len(drawn)-1]
+		fmt.Fprintf(out, `<g class="train" data-train-id="%s"><polyline points="%s"/><text x="%s" y="%s" fill="%s" stroke="none">%s</text></g>`+"\n",
+			svgText(run.TrainID), svgPoints(drawn), svgNumber(last.X+diagramTrainLabelShift), svgNumber(last.Y-diagramTrainLabelShift), svgTrainColor, svgText(run.TrainID))
+	}
+	out.WriteString("</g>\n")
+
+	fmt.Fprintf(out, `<line id="now" x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`+"\n",
+		svgNumber(x(dto.SimTimeMillis)), svgNumber(top), svgNumber(x(dto.SimTimeMillis)), svgNumber(top+plotHeight), diagramNowColor)
+	out.WriteString("</svg>\n")
+	return out.Flush()
+}
+
+// diagramTimeRange は計画・走行の記録・現在時刻を含む時刻の範囲
+func diagramTimeRange(dto DiagramDTO) (int64, int64) {
+	from, until := dto.SimTimeMillis, dto.SimTimeMillis
+	extend := func(run []DiagramPointDTO) {
+		for _, p := range run {
+			from, until = min(from, p.SimTimeMillis), max(until, p.SimTimeMillis)
+		}
+	}
+	for _, run := range dto.Planned {
+		extend(run.Points)
+	}
+	for _, run := range dto.Actual {
+		extend(run.Points)
+	}
+	return from, until
+}
+
+func diagramTickStep(spanMillis int64) int64 {
+	for _, step := range diagramTickSteps {
+		if spanMillis/step.Milliseconds() <= diagramMaxTimeTicks {
+			return step.Milliseconds()
+		}
+	}
+	return diagramTickSteps[len(diagramTickSteps)-1].Milliseconds()
+}
+
+// formatDiagramClock はシミュレーション時刻を目盛り用に h:mm と書く
+func formatDiagramClock(millis int64) string {
+	d := time.Duration(millis) * time.Millisecond
+	return fmt.Sprintf("%d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
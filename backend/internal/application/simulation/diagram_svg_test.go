@@ -0,0 +1,54 @@
+package simulation
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderDiagramSVGMatchesGolden(t *testing.T) {
+	dto := DiagramDTO{
+		SessionID:     "room-a",
+		LineID:        "RED",
+		SimTimeMillis: 150_000,
+		DistanceUnit:  DiagramDistanceKilometers,
+		Stations: []DiagramStationDTO{
+			{ID: "A0", Name: "赤羽", Distance: 0},
+			{ID: "X", Name: "交差", Distance: 1.5},
+			{ID: "A2", Distance: 4},
+		},
+		Planned: []PlannedRunDTO{{
+			ServiceID: "101",
+			TrainID:   "T0",
+			Points:    []DiagramPointDTO{{0, 0}, {60_000, 1.5}, {90_000, 1.5}, {240_000, 4}},
+		}},
+		Actual: []ActualRunDTO{{
+			TrainID: "T0",
+			Points:  []DiagramPointDTO{{0, 0}, {75_000, 1.5}, {105_000, 1.5}, {150_000, 2.25}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderDiagramSVG(&buf, dto); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	assertGolden(t, "diagram_red.svg", buf.Bytes())
+}
+
+func TestRenderDiagramSVGWithoutRuns(t *testing.T) {
+	dto := DiagramDTO{
+		SessionID:    "room-a",
+		LineID:       "MAIN",
+		DistanceUnit: DiagramDistanceStations,
+		Stations:     []DiagramStationDTO{{ID: "S0"}, {ID: "S1", Distance: 1}},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderDiagramSVG(&buf, dto); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// 記録が無くても1目盛り分の時刻の軸を描く
+	if svg := buf.String(); !strings.Contains(svg, ">0:00</text>") || !strings.Contains(svg, ">0:01</text>") {
+		t.Fatalf("expected a one-minute time axis:\n%s", svg)
+	}
+}
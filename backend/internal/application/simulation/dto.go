@@ -3,14 +3,61 @@ package simulation
 import domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
 
 type SimulationDTO struct {
-	SimTimeMillis int64      `json:"simTimeMillis"`
-	Line          LineDTO    `json:"line"`
-	Trains        []TrainDTO `json:"trains"`
+	SessionID     string  `json:"sessionId"`
+	Version       int64   `json:"version"`
+	SimTimeMillis int64   `json:"simTimeMillis"`
+	Line          LineDTO `json:"line"`
+	// Lines は網の路線ごとの駅・区間と、その路線を走る列車
+	Lines []NetworkLineDTO `json:"lines"`
+	// Interchanges は複数の路線が通る駅、Transfers は別々の駅のあいだの乗換の連絡
+	Interchanges []string      `json:"interchanges"`
+	Transfers    []TransferDTO `json:"transfers"`
+	// Trains は全路線の列車（列車ID順）
+	Trains []TrainDTO `json:"trains"`
+	Alarms []AlarmDTO `json:"alarms"`
+	// PriorityRules は同じ区間を同時に求めた列車の順位を決める規則（適用する順）
+	PriorityRules []string `json:"priorityRules"`
 }
 
+// LineDTO は路線の駅と区間。SimulationDTO.Line では網の全駅（重複なし）と全区間を表し、ID は持たない。
+// StationDetails と BlockDetails は Stations / Blocks と同じ並びの案内用の情報。
 type LineDTO struct {
-	Stations []string `json:"stations"`
-	Blocks   []string `json:"blocks"`
+	ID             string           `json:"id,omitempty"`
+	Stations       []string         `json:"stations"`
+	Blocks         []string         `json:"blocks"`
+	StationDetails []StationInfoDTO `json:"stationDetails"`
+	BlockDetails   []BlockInfoDTO   `json:"blockDetails"`
+	// Schematic は路線図での配置（定義に無ければ自動で並べたもの）
+	Schematic *LineSchematicDTO `json:"schematic,omitempty"`
+}
+
+// StationInfoDTO は駅の案内用の情報。分からない項目は省く。
+type StationInfoDTO struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	NameRomaji    string   `json:"nameRomaji,omitempty"`
+	Code          string   `json:"code,omitempty"`
+	KilometerPost *float64 `json:"kilometerPost,omitempty"` // 0 km の起点も返す
+	Platforms     int      `json:"platforms,omitempty"`
+}
+
+// BlockInfoDTO は区間の線路の情報。分からない項目は省く。
+type BlockInfoDTO struct {
+	ID               string  `json:"id"`
+	TrackNumber      string  `json:"trackNumber,omitempty"`
+	Electrification  string  `json:"electrification,omitempty"`
+	GradientPermille float64 `json:"gradientPermille,omitempty"`
+}
+
+type NetworkLineDTO struct {
+	LineDTO
+	Trains []TrainDTO `json:"trains"`
+}
+
+type TransferDTO struct {
+	FromStationID     string `json:"fromStationId"`
+	ToStationID       string `json:"toStationId"`
+	MinTransferMillis int64  `json:"minTransferMillis"`
 }
 
 type TrainDTO struct {
@@ -22,40 +69,176 @@ type TrainDTO struct {
 	PendingTurnback bool    `json:"pendingTurnback"`
 }
 
+const AlarmDeadlock = "DEADLOCK"
+
+// AlarmDTO は指令員の対処が必要な異常。状態から導くので、解消すれば次の取得で消える。
+// Type が DEADLOCK なら Deadlock に待ち合っている列車と区間を持つ。
+type AlarmDTO struct {
+	Type     string       `json:"type"`
+	Deadlock *DeadlockDTO `json:"deadlock,omitempty"`
+}
+
+// DeadlockDTO は互いの在線を待ち合って進めなくなった列車の組。BlockIDs は TrainIDs と同じ順で各列車の在線区間。
+type DeadlockDTO struct {
+	Kind     string   `json:"kind"`
+	TrainIDs []string `json:"trainIds"`
+	BlockIDs []string `json:"blockIds"`
+}
+
 func toSimulationDTO(state *domain.SimulationState) SimulationDTO {
-	line := state.Line()
-	stations := line.Stations()
-	blocks := line.Blocks()
-	trains := state.Trains()
+	return newNetworkView(state.Network()).simulationDTO(state)
+}
 
-	stationIDs := make([]string, 0, len(stations))
-	for _, station := range stations {
-		stationIDs = append(stationIDs, station.String())
+// networkView は SimulationDTO のうち路線網だけで決まる部分（駅・区間・路線図・乗換）と、網の構成（TopologyDTO）。
+// 路線網は演習中に変わらないので、同じ網の状態を公開するあいだ使い回す。
+type networkView struct {
+	network      *domain.Network
+	line         LineDTO
+	lines        []LineDTO
+	lineIndex    map[string]int
+	interchanges []string
+	transfers    []TransferDTO
+	topology     TopologyDTO
+}
+
+func newNetworkView(network *domain.Network) *networkView {
+	layouts := network.Layouts()
+	lines := make([]LineDTO, 0, len(network.Lines()))
+	lineIndex := make(map[string]int, len(network.Lines()))
+	for i, l := range network.Lines() {
+		line := toLineDTO(l.ID.String(), l.Line.Stations(), l.Line.Blocks(), l.Line.StationInfos(), l.Line.BlockInfos())
+		line.Schematic = toLineSchematicDTO(l.Line.Stations(), l.Line.Blocks(), layouts[i].Layout, layouts[i].Generated)
+		lineIndex[l.ID.String()] = len(lines)
+		lines = append(lines, line)
 	}
+	flattened := toLineDTO("", network.Stations(), network.Blocks(), network.StationInfos(), network.BlockInfos())
+	flattened.Schematic = toNetworkSchematicDTO(network, layouts)
 
-	blockIDs := make([]string, 0, len(blocks))
-	for _, block := range blocks {
-		blockIDs = append(blockIDs, block.String())
+	interchanges := make([]string, 0)
+	for _, station := range network.Interchanges() {
+		interchanges = append(interchanges, station.String())
+	}
+	transfers := make([]TransferDTO, 0, len(network.Transfers()))
+	for _, t := range network.Transfers() {
+		transfers = append(transfers, TransferDTO{
+			FromStationID:     t.From.String(),
+			ToStationID:       t.To.String(),
+			MinTransferMillis: t.MinTransfer.Milliseconds(),
+		})
+	}
+
+	return &networkView{
+		network:      network,
+		line:         flattened,
+		lines:        lines,
+		lineIndex:    lineIndex,
+		interchanges: interchanges,
+		transfers:    transfers,
+		topology:     newTopologyDTO(flattened, lines, interchanges, transfers),
+	}
+}
+
+// simulationDTO は網の部分を共有したまま、列車・警報など刻みごとに変わる部分を state から作る
+func (v *networkView) simulationDTO(state *domain.SimulationState) SimulationDTO {
+	network := state.Network()
+	trains := state.Trains()
+
+	lines := make([]NetworkLineDTO, 0, len(v.lines))
+	for _, line := range v.lines {
+		lines = append(lines, NetworkLineDTO{LineDTO: line, Trains: []TrainDTO{}})
 	}
 
 	trainDTOs := make([]TrainDTO, 0, len(trains))
 	for _, train := range trains {
-		trainDTOs = append(trainDTOs, TrainDTO{
+		dto := TrainDTO{
 			ID:              train.ID().String(),
 			BlockID:         train.BlockID().String(),
 			Progress:        train.Progress().Float64(),
 			Forward:         train.Forward(),
 			Speed:           train.Speed(),
 			PendingTurnback: train.PendingTurnback(),
-		})
+		}
+		trainDTOs = append(trainDTOs, dto)
+		if line, ok := network.LineOf(train.BlockID()); ok {
+			l := &lines[v.lineIndex[line.String()]]
+			l.Trains = append(l.Trains, dto)
+		}
+	}
+
+	deadlocks := state.Deadlocks()
+	alarms := make([]AlarmDTO, 0, len(deadlocks))
+	for _, d := range deadlocks {
+		deadlock := toDeadlockDTO(d)
+		alarms = append(alarms, AlarmDTO{Type: AlarmDeadlock, Deadlock: &deadlock})
+	}
+
+	rules := state.PriorityRules()
+	ruleNames := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		ruleNames = append(ruleNames, string(rule))
 	}
 
 	return SimulationDTO{
+		SessionID:     state.ID().String(),
+		Version:       state.Version(),
 		SimTimeMillis: state.SimTime().Millis(),
-		Line: LineDTO{
-			Stations: stationIDs,
-			Blocks:   blockIDs,
-		},
-		Trains: trainDTOs,
+		Line:          v.line,
+		Lines:         lines,
+		Interchanges:  v.interchanges,
+		Transfers:     v.transfers,
+		Trains:        trainDTOs,
+		Alarms:        alarms,
+		PriorityRules: ruleNames,
+	}
+}
+
+// toKilometerPostDTO はキロ程が分かれば値を、不明なら nil を返す
+func toKilometerPostDTO(info domain.StationInfo) *float64 {
+	if !info.HasKilometerPost {
+		return nil
+	}
+	kilometerPost := info.KilometerPost
+	return &kilometerPost
+}
+
+func toLineDTO(id string, stations []domain.StationID, blocks []domain.BlockID, stationInfo []domain.StationInfo, blockInfo []domain.BlockInfo) LineDTO {
+	stationIDs := make([]string, 0, len(stations))
+	stationDetails := make([]StationInfoDTO, 0, len(stations))
+	for i, station := range stations {
+		stationIDs = append(stationIDs, station.String())
+		info := stationInfo[i]
+		stationDetails = append(stationDetails, StationInfoDTO{
+			ID:            station.String(),
+			Name:          info.Name,
+			NameRomaji:    info.NameRomaji,
+			Code:          info.Code,
+			KilometerPost: toKilometerPostDTO(info),
+			Platforms:     info.Platforms,
+		})
+	}
+	blockIDs := make([]string, 0, len(blocks))
+	blockDetails := make([]BlockInfoDTO, 0, len(blocks))
+	for i, block := range blocks {
+		blockIDs = append(blockIDs, block.String())
+		info := blockInfo[i]
+		blockDetails = append(blockDetails, BlockInfoDTO{
+			ID:               block.String(),
+			TrackNumber:      info.TrackNumber,
+			Electrification:  info.Electrification,
+			GradientPermille: info.GradientPermille,
+		})
+	}
+	return LineDTO{ID: id, Stations: stationIDs, Blocks: blockIDs, StationDetails: stationDetails, BlockDetails: blockDetails}
+}
+
+func toDeadlockDTO(d domain.Deadlock) DeadlockDTO {
+	trainIDs := make([]string, 0, len(d.Trains))
+	for _, id := range d.Trains {
+		trainIDs = append(trainIDs, id.String())
+	}
+	blockIDs := make([]string, 0, len(d.Blocks))
+	for _, id := range d.Blocks {
+		blockIDs = append(blockIDs, id.String())
 	}
+	return DeadlockDTO{Kind: string(d.Kind), TrainIDs: trainIDs, BlockIDs: blockIDs}
 }
@@ -3,5 +3,22 @@ package simulation
 import "errors"
 
 var (
-	ErrInvalidTickDelta = errors.New("invalid tick delta")
+	ErrInvalidTickDelta     = errors.New("invalid tick delta")
+	ErrInvalidEngine        = errors.New("invalid simulation engine")
+	ErrInvalidSessionID     = errors.New("invalid session id")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrSessionNotRunning    = errors.New("session is not running")
+	ErrSimulationNotStarted = errors.New("simulation has not been started")
+	ErrInvalidRewindTarget  = errors.New("invalid rewind target")
+	ErrInvalidForecast      = errors.New("invalid forecast request")
+	ErrInvalidFastForward   = errors.New("invalid fast forward request")
+	ErrInvalidBreakpoint    = errors.New("invalid breakpoint")
+	ErrBreakpointNotFound   = errors.New("breakpoint not found")
+	ErrInvalidPriorityRules = errors.New("invalid priority rules")
+	ErrInvalidChangesQuery  = errors.New("invalid changes query")
+	ErrInvalidTimetable     = errors.New("invalid timetable")
+	ErrInvalidDiagramQuery  = errors.New("invalid diagram query")
+
+	ErrInvalidSnapshot            = errors.New("invalid snapshot")
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot schema version")
 )
@@ -0,0 +1,177 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+const (
+	StopConditionTrainReachesStation = "TRAIN_REACHES_STATION"
+	StopConditionTrainBlockedFor     = "TRAIN_BLOCKED_FOR"
+	StopConditionEvent               = "EVENT"
+	StopConditionTrainsFaceEachOther = "TRAINS_FACE_EACH_OTHER"
+)
+
+const (
+	defaultFastForwardStepMillis int64 = 1000
+	// maxFastForwardSteps は1回の指令（早送り・EVENT の Tick）で進める刻みの上限。
+	// EVENT は出来事ごとに刻みを区切るので、刻みの幅からではなく進めた数で打ち切る。
+	maxFastForwardSteps int64 = 100_000
+)
+
+// FastForwardInput は早送りの入力。UntilSimTimeMillis に達するか、条件のいずれかが成立するまで進める。
+type FastForwardInput struct {
+	SessionID          string
+	UntilSimTimeMillis int64
+	StepMillis         int64  // 0 なら既定の刻み。EVENT では刻みの上限（0 なら上限なし）
+	Engine             string // TICK（既定）か EVENT
+	Conditions         []StopConditionDTO
+	ExpectedVersion    *int64
+}
+
+// StopConditionDTO は早送りを止める条件。
+// Type が TRAIN_REACHES_STATION なら TrainID と StationID、TRAIN_BLOCKED_FOR なら DurationMillis（TrainID は任意）、
+// EVENT なら EventType（TrainID は任意）を使う。TRAINS_FACE_EACH_OTHER は項目を使わない。
+type StopConditionDTO struct {
+	Type           string `json:"type"`
+	TrainID        string `json:"trainId,omitempty"`
+	StationID      string `json:"stationId,omitempty"`
+	DurationMillis int64  `json:"durationMillis,omitempty"`
+	EventType      string `json:"eventType,omitempty"`
+}
+
+// FastForwardResultDTO の Breakpoint は、ブレークポイントの成立で止まった（演習を一時停止した）ときだけ持つ
+type FastForwardResultDTO struct {
+	Simulation        SimulationDTO     `json:"simulation"`
+	FromSimTimeMillis int64             `json:"fromSimTimeMillis"`
+	ToSimTimeMillis   int64             `json:"toSimTimeMillis"`
+	Steps             int               `json:"steps"`
+	StopReason        StopReasonDTO     `json:"stopReason"`
+	Breakpoint        *BreakpointHitDTO `json:"breakpoint,omitempty"`
+}
+
+// StopReasonDTO は早送りが止まった理由。目標時刻に達した場合 ConditionIndex は -1 で Event は無い。
+// ブレークポイントで止まった場合も ConditionIndex は -1（Conditions の条件ではない）。
+type StopReasonDTO struct {
+	Kind           string              `json:"kind"`
+	ConditionIndex int                 `json:"conditionIndex"`
+	Event          *SimulationEventDTO `json:"event,omitempty"`
+}
+
+type SimulationEventDTO struct {
+	Type          string       `json:"type"`
+	SimTimeMillis int64        `json:"simTimeMillis"`
+	TrainID       string       `json:"trainId"`
+	StationID     string       `json:"stationId,omitempty"`
+	BlockID       string       `json:"blockId,omitempty"`
+	BlockedBy     string       `json:"blockedBy,omitempty"`
+	Deadlock      *DeadlockDTO `json:"deadlock,omitempty"`
+}
+
+func newRunUntilSpec(input FastForwardInput, now domain.SimTime) (domain.RunUntilSpec, error) {
+	until, err := domain.NewSimTime(input.UntilSimTimeMillis)
+	if err != nil || until.Millis() <= now.Millis() {
+		return domain.RunUntilSpec{}, fmt.Errorf("%w: until must be after the current time %dms", ErrInvalidFastForward, now.Millis())
+	}
+	engine, err := domain.ParseEngine(input.Engine)
+	if err != nil {
+		return domain.RunUntilSpec{}, fmt.Errorf("%w: %v", ErrInvalidFastForward, err)
+	}
+
+	stepMillis := input.StepMillis
+	if stepMillis == 0 {
+		stepMillis = defaultFastForwardStepMillis
+		// 出来事ごとに区切るなら、刻みの上限を置かずに次の出来事まで一気に進める
+		if engine == domain.EngineEvent {
+			stepMillis = until.Millis() - now.Millis()
+		}
+	}
+	if stepMillis < 0 || (until.Millis()-now.Millis())/stepMillis > maxFastForwardSteps {
+		return domain.RunUntilSpec{}, fmt.Errorf("%w: step must be positive and at most %d steps", ErrInvalidFastForward, maxFastForwardSteps)
+	}
+	step, err := domain.NewTickDelta(time.Duration(stepMillis) * time.Millisecond)
+	if err != nil {
+		return domain.RunUntilSpec{}, fmt.Errorf("%w: %v", ErrInvalidFastForward, err)
+	}
+
+	conditions := make([]domain.StopCondition, 0, len(input.Conditions))
+	for i, c := range input.Conditions {
+		condition, err := newStopCondition(c)
+		if err != nil {
+			return domain.RunUntilSpec{}, fmt.Errorf("%w: conditions[%d]: %v", ErrInvalidFastForward, i, err)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	return domain.RunUntilSpec{Until: until, Step: step, Engine: engine, Conditions: conditions, MaxSteps: int(maxFastForwardSteps)}, nil
+}
+
+func newStopCondition(c StopConditionDTO) (domain.StopCondition, error) {
+	// TrainID は条件によっては省略できる（空ならどの列車でもよい）
+	var trainID domain.TrainID
+	if c.TrainID != "" {
+		id, err := domain.NewTrainID(c.TrainID)
+		if err != nil {
+			return nil, err
+		}
+		trainID = id
+	}
+
+	switch c.Type {
+	case StopConditionTrainReachesStation:
+		if trainID.String() == "" {
+			return nil, domain.ErrTrainIDEmpty
+		}
+		stationID, err := domain.NewStationID(c.StationID)
+		if err != nil {
+			return nil, err
+		}
+		return domain.TrainReachesStation{TrainID: trainID, StationID: stationID}, nil
+	case StopConditionTrainBlockedFor:
+		if c.DurationMillis <= 0 {
+			return nil, errors.New("duration must be positive")
+		}
+		return domain.TrainBlockedFor{TrainID: trainID, Duration: time.Duration(c.DurationMillis) * time.Millisecond}, nil
+	case StopConditionEvent:
+		eventType, err := domain.ParseEventType(c.EventType)
+		if err != nil {
+			return nil, err
+		}
+		return domain.EventOccurs{Type: eventType, TrainID: trainID}, nil
+	case StopConditionTrainsFaceEachOther:
+		return domain.TrainsFaceEachOther{}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", c.Type)
+	}
+}
+
+func toStopReasonDTO(reason domain.StopReason) StopReasonDTO {
+	out := StopReasonDTO{
+		Kind:           string(reason.Kind),
+		ConditionIndex: reason.ConditionIndex,
+	}
+	if reason.Event != nil {
+		event := toSimulationEventDTO(*reason.Event)
+		out.Event = &event
+	}
+	return out
+}
+
+func toSimulationEventDTO(e domain.Event) SimulationEventDTO {
+	out := SimulationEventDTO{
+		Type:          string(e.Type),
+		SimTimeMillis: e.At.Millis(),
+		TrainID:       e.TrainID.String(),
+		StationID:     e.StationID.String(),
+		BlockID:       e.BlockID.String(),
+		BlockedBy:     e.BlockedBy.String(),
+	}
+	if e.Deadlock != nil {
+		deadlock := toDeadlockDTO(*e.Deadlock)
+		out.Deadlock = &deadlock
+	}
+	return out
+}
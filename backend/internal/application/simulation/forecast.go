@@ -0,0 +1,156 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+const (
+	HypotheticalHoldTrain = "HOLD_TRAIN"
+	HypotheticalSetSpeed  = "SET_SPEED"
+)
+
+const (
+	defaultForecastStepMillis int64 = 1000
+	maxForecastHorizonMillis  int64 = 24 * 60 * 60 * 1000
+	// maxForecastSteps は1回の予測で進める刻みの上限（リクエスト1件で CPU を占有しないため）
+	maxForecastSteps int64 = 100_000
+)
+
+// ForecastInput は「もし〜したら」の予測の入力。本番の状態は変更しない。
+type ForecastInput struct {
+	SessionID     string
+	HorizonMillis int64
+	StepMillis    int64 // 0 なら既定の刻み
+	Commands      []HypotheticalCommandDTO
+}
+
+// HypotheticalCommandDTO は予測でだけ適用する仮の指令。
+// Type が HOLD_TRAIN なら DurationMillis、SET_SPEED なら Speed を使う。
+type HypotheticalCommandDTO struct {
+	Type           string  `json:"type"`
+	TrainID        string  `json:"trainId"`
+	DurationMillis int64   `json:"durationMillis,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+type ForecastDTO struct {
+	FromSimTimeMillis  int64                  `json:"fromSimTimeMillis"`
+	UntilSimTimeMillis int64                  `json:"untilSimTimeMillis"`
+	Conflicts          []PredictedConflictDTO `json:"conflicts"`
+	Delays             []PredictedDelayDTO    `json:"delays"`
+	Arrivals           []PredictedArrivalDTO  `json:"arrivals"`
+	Final              SimulationDTO          `json:"final"`
+}
+
+type PredictedConflictDTO struct {
+	TrainID        string `json:"trainId"`
+	BlockID        string `json:"blockId"`
+	BlockedBy      string `json:"blockedBy"`
+	FromMillis     int64  `json:"fromSimTimeMillis"`
+	UntilMillis    int64  `json:"untilSimTimeMillis"`
+	DurationMillis int64  `json:"durationMillis"`
+}
+
+type PredictedDelayDTO struct {
+	TrainID        string `json:"trainId"`
+	StationID      string `json:"stationId"`
+	BaselineMillis int64  `json:"baselineSimTimeMillis"`
+	ExpectedMillis int64  `json:"expectedSimTimeMillis"`
+	Reached        bool   `json:"reached"`
+	DelayMillis    int64  `json:"delayMillis"`
+}
+
+type PredictedArrivalDTO struct {
+	TrainID   string `json:"trainId"`
+	StationID string `json:"stationId"`
+	AtMillis  int64  `json:"simTimeMillis"`
+}
+
+func newForecastSpec(input ForecastInput) (domain.ForecastSpec, error) {
+	if input.HorizonMillis <= 0 || input.HorizonMillis > maxForecastHorizonMillis {
+		return domain.ForecastSpec{}, fmt.Errorf("%w: horizon must be in (0, %d] ms", ErrInvalidForecast, maxForecastHorizonMillis)
+	}
+	stepMillis := input.StepMillis
+	if stepMillis == 0 {
+		stepMillis = defaultForecastStepMillis
+	}
+	if stepMillis < 0 || input.HorizonMillis/stepMillis > maxForecastSteps {
+		return domain.ForecastSpec{}, fmt.Errorf("%w: step must be positive and at most %d steps", ErrInvalidForecast, maxForecastSteps)
+	}
+	step, err := domain.NewTickDelta(time.Duration(stepMillis) * time.Millisecond)
+	if err != nil {
+		return domain.ForecastSpec{}, fmt.Errorf("%w: %v", ErrInvalidForecast, err)
+	}
+
+	commands := make([]domain.HypotheticalCommand, 0, len(input.Commands))
+	for i, c := range input.Commands {
+		trainID, err := domain.NewTrainID(c.TrainID)
+		if err != nil {
+			return domain.ForecastSpec{}, fmt.Errorf("%w: commands[%d]: %v", ErrInvalidForecast, i, err)
+		}
+		switch c.Type {
+		case HypotheticalHoldTrain:
+			if c.DurationMillis <= 0 || c.DurationMillis > maxForecastHorizonMillis {
+				return domain.ForecastSpec{}, fmt.Errorf("%w: commands[%d]: duration must be in (0, %d] ms", ErrInvalidForecast, i, maxForecastHorizonMillis)
+			}
+			commands = append(commands, domain.HoldTrain{TrainID: trainID, Duration: time.Duration(c.DurationMillis) * time.Millisecond})
+		case HypotheticalSetSpeed:
+			commands = append(commands, domain.SetTrainSpeed{TrainID: trainID, Speed: c.Speed})
+		default:
+			return domain.ForecastSpec{}, fmt.Errorf("%w: commands[%d]: unknown type %q", ErrInvalidForecast, i, c.Type)
+		}
+	}
+
+	return domain.ForecastSpec{
+		Commands: commands,
+		Horizon:  time.Duration(input.HorizonMillis) * time.Millisecond,
+		Step:     step,
+	}, nil
+}
+
+func toForecastDTO(result domain.ForecastResult) ForecastDTO {
+	conflicts := make([]PredictedConflictDTO, 0, len(result.Conflicts))
+	for _, c := range result.Conflicts {
+		conflicts = append(conflicts, PredictedConflictDTO{
+			TrainID:        c.TrainID.String(),
+			BlockID:        c.BlockID.String(),
+			BlockedBy:      c.BlockedBy.String(),
+			FromMillis:     c.From.Millis(),
+			UntilMillis:    c.Until.Millis(),
+			DurationMillis: c.Until.Millis() - c.From.Millis(),
+		})
+	}
+
+	delays := make([]PredictedDelayDTO, 0, len(result.Delays))
+	for _, d := range result.Delays {
+		delays = append(delays, PredictedDelayDTO{
+			TrainID:        d.TrainID.String(),
+			StationID:      d.StationID.String(),
+			BaselineMillis: d.Baseline.Millis(),
+			ExpectedMillis: d.Expected.Millis(),
+			Reached:        d.Reached,
+			DelayMillis:    d.Delay.Milliseconds(),
+		})
+	}
+
+	arrivals := make([]PredictedArrivalDTO, 0, len(result.Arrivals))
+	for _, a := range result.Arrivals {
+		arrivals = append(arrivals, PredictedArrivalDTO{
+			TrainID:   a.TrainID.String(),
+			StationID: a.StationID.String(),
+			AtMillis:  a.At.Millis(),
+		})
+	}
+
+	return ForecastDTO{
+		FromSimTimeMillis:  result.From.Millis(),
+		UntilSimTimeMillis: result.Until.Millis(),
+		Conflicts:          conflicts,
+		Delays:             delays,
+		Arrivals:           arrivals,
+		Final:              toSimulationDTO(result.Final),
+	}
+}
@@ -0,0 +1,87 @@
+package simulation
+
+import "sync"
+
+type NotificationType string
+
+const (
+	// NotificationRewound はシミュレーションが過去の時刻へ巻き戻されたことを表す
+	NotificationRewound NotificationType = "SIMULATION_REWOUND"
+	// NotificationPaused はブレークポイントの成立で演習が一時停止したことを表す（Payload は BreakpointHitDTO）
+	NotificationPaused NotificationType = "SIMULATION_PAUSED"
+	// NotificationDeadlock は列車どうしの待ち合いが新たに生じたことを表す（Payload は DeadlockDTO）
+	NotificationDeadlock NotificationType = "DEADLOCK_DETECTED"
+)
+
+// Notification は接続中のクライアントへ配信する通知。
+// クライアントは受け取ったら状態を取り直す（差分は含めない）。
+type Notification struct {
+	Type          NotificationType `json:"type"`
+	SessionID     string           `json:"sessionId"`
+	Version       int64            `json:"version"`
+	SimTimeMillis int64            `json:"simTimeMillis"`
+	Payload       any              `json:"payload,omitempty"`
+}
+
+// RewoundPayload は SIMULATION_REWOUND 通知の付帯情報
+type RewoundPayload struct {
+	FromSimTimeMillis int64 `json:"fromSimTimeMillis"`
+	ToSimTimeMillis   int64 `json:"toSimTimeMillis"`
+}
+
+// notificationBuffer は購読者ごとの未読上限。溢れた通知は捨てる（遅い購読者で進行を止めない）。
+const notificationBuffer = 16
+
+// NotificationHub はセッション単位でプロセス内の購読者へ通知を配る。
+type NotificationHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Notification]struct{}
+}
+
+func NewNotificationHub() *NotificationHub {
+	return &NotificationHub{
+		subscribers: make(map[string]map[chan Notification]struct{}),
+	}
+}
+
+// Subscribe はセッションの通知を受け取るチャネルと、購読を解除する関数を返す。
+// 解除するとチャネルは閉じられる。
+func (h *NotificationHub) Subscribe(sessionID string) (<-chan Notification, func()) {
+	ch := make(chan Notification, notificationBuffer)
+
+	h.mu.Lock()
+	subs, ok := h.subscribers[sessionID]
+	if !ok {
+		subs = make(map[chan Notification]struct{})
+		h.subscribers[sessionID] = subs
+	}
+	subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(h.subscribers, sessionID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+func (h *NotificationHub) Publish(n Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[n.SessionID] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
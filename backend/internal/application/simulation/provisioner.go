@@ -0,0 +1,196 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sessiondomain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// Provisioner は訓練セッションが所有するシミュレーションの生成・破棄を担う。
+// セッションのライフサイクル（演習開始・セッション終了）から呼び出される。
+type Provisioner struct {
+	repo        domain.Repository
+	logs        domain.InputLogRepository
+	networks    NetworkLoader
+	timetables  TimetableLoader
+	breakpoints *BreakpointRegistry
+	diagrams    *DiagramRegistry
+	views       *ViewPublisher
+	actors      *SimulationActors
+}
+
+// NewProvisioner の timetables は nil でもよい（時刻表を読まず、既定の列車を1本置く）
+func NewProvisioner(repo domain.Repository, logs domain.InputLogRepository, networks NetworkLoader, timetables TimetableLoader, breakpoints *BreakpointRegistry, diagrams *DiagramRegistry, views *ViewPublisher, actors *SimulationActors) *Provisioner {
+	return &Provisioner{
+		repo:        repo,
+		logs:        logs,
+		networks:    networks,
+		timetables:  timetables,
+		breakpoints: breakpoints,
+		diagrams:    diagrams,
+		views:       views,
+		actors:      actors,
+	}
+}
+
+// Provision はセッション用のシミュレーションを初期状態で生成する。
+func (p *Provisioner) Provision(ctx context.Context, sessionID sessiondomain.SessionID) error {
+	id, err := domain.NewSimulationID(sessionID.String())
+	if err != nil {
+		return err
+	}
+
+	network, err := p.networks.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("network load failed: %w", err)
+	}
+	state, err := domain.NewNetworkSimulationState(id, network)
+	if err != nil {
+		return err
+	}
+
+	var timetable *domain.Timetable
+	if p.timetables != nil {
+		timetable, err = p.startFromTimetable(ctx, state)
+	} else {
+		err = addInitialTrain(state)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := p.repo.Create(ctx, state); err != nil {
+		return err
+	}
+	// 再生の起点として初期状態をログの先頭に残す
+	if _, err := p.logs.Append(ctx, id, domain.NewInitializedInput(state, time.Now())); err != nil {
+		// 再生の起点が無い状態は残さない（途中まで書いたログも消す）
+		return p.discard(ctx, id, fmt.Errorf("input log append failed: %w", err))
+	}
+	if err := p.diagrams.start(ctx, state, timetable); err != nil {
+		return p.discard(ctx, id, err)
+	}
+	// 以後の状態は actor が所有する
+	p.views.PublishState(state)
+	p.actors.Start(state)
+	return nil
+}
+
+// discard は生成の途中で失敗したシミュレーションの保存済みの状態・ログ・計画ダイヤを消し、cause に消せなかった理由を添えて返す
+func (p *Provisioner) discard(ctx context.Context, id domain.SimulationID, cause error) error {
+	err := cause
+	if deleteErr := p.repo.Delete(ctx, id); deleteErr != nil {
+		err = errors.Join(err, deleteErr)
+	}
+	if deleteErr := p.logs.Delete(ctx, id); deleteErr != nil {
+		err = errors.Join(err, deleteErr)
+	}
+	if deleteErr := p.diagrams.Forget(ctx, id); deleteErr != nil {
+		err = errors.Join(err, deleteErr)
+	}
+	return err
+}
+
+// startFromTimetable は演習を最も早い発車の時刻から始め、時刻表の列車をそれぞれ始発の発時刻に網へ入れる。
+// 運行を受け持たない列車は始めから網に置く。
+func (p *Provisioner) startFromTimetable(ctx context.Context, state *domain.SimulationState) (*domain.Timetable, error) {
+	timetable, trains, err := p.timetables.Load(ctx, state.Network())
+	if err != nil {
+		return nil, fmt.Errorf("timetable load failed: %w", err)
+	}
+	if err := timetable.CheckStations(state.Network()); err != nil {
+		return nil, err
+	}
+
+	if services := timetable.Services(); len(services) > 0 {
+		start := services[0].Stops[0].Departure
+		for _, service := range services[1:] {
+			if departure := service.Stops[0].Departure; departure.Millis() < start.Millis() {
+				start = departure
+			}
+		}
+		if err := state.StartAt(start); err != nil {
+			return nil, err
+		}
+	}
+	for _, train := range trains {
+		if departure, ok := timetable.FirstDeparture(train.ID()); ok {
+			err = state.ScheduleTrain(train, departure)
+		} else {
+			err = state.AddTrain(train)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, train.ID())
+		}
+	}
+	return timetable, nil
+}
+
+// addInitialTrain は時刻表が無いときの既定の列車を最初の区間に置く
+func addInitialTrain(state *domain.SimulationState) error {
+	initialTrainID, err := domain.NewTrainID("T0")
+	if err != nil {
+		return err
+	}
+	initialBlock, ok := state.Network().BlockAt(0)
+	if !ok {
+		return domain.ErrLineHasNoBlocks
+	}
+	initialProgress, err := domain.NewBlockProgress(0)
+	if err != nil {
+		return err
+	}
+
+	initialTrain, err := domain.NewTrain(
+		initialTrainID,
+		initialBlock,
+		initialProgress,
+		true,
+		0.5,
+	)
+	if err != nil {
+		return err
+	}
+	return state.AddTrain(initialTrain)
+}
+
+// Close は演習を終えたセッションの actor を止める。保存済みの状態・ログ・計画ダイヤは演習後の振り返りや
+// 運行図表の書き出しのために残す（取得すれば保存済みの状態から actor を起こす）。破棄はセッションの削除で Dispose が行う。
+func (p *Provisioner) Close(ctx context.Context, sessionID sessiondomain.SessionID) error {
+	_ = ctx
+
+	id, err := domain.NewSimulationID(sessionID.String())
+	if err != nil {
+		return err
+	}
+	p.actors.Stop(id)
+	return nil
+}
+
+// Dispose はセッションのシミュレーションを破棄する。未生成なら何もしない。
+// 先に保存済みの状態を消すので、actor を止めるまでに届いた指令も保存できずに失敗する。
+func (p *Provisioner) Dispose(ctx context.Context, sessionID sessiondomain.SessionID) error {
+	id, err := domain.NewSimulationID(sessionID.String())
+	if err != nil {
+		return err
+	}
+	if err := p.repo.Delete(ctx, id); err != nil && !errors.Is(err, domain.ErrSimulationNotFound) {
+		return err
+	}
+	if err := p.logs.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := p.diagrams.Forget(ctx, id); err != nil {
+		return err
+	}
+	if err := p.breakpoints.Forget(ctx, sessionID.String()); err != nil {
+		return err
+	}
+	p.actors.Stop(id)
+	p.views.Forget(sessionID.String())
+	return nil
+}
@@ -0,0 +1,48 @@
+package simulation
+
+import (
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+type InputRecordDTO struct {
+	Seq           int64     `json:"seq"`
+	Kind          string    `json:"kind"`
+	RecordedAt    time.Time `json:"recordedAt"`
+	SimTimeMillis int64     `json:"simTimeMillis"`
+	DeltaMillis   int64     `json:"deltaMillis,omitempty"`
+	StepMillis    int64     `json:"stepMillis,omitempty"`
+	Engine        string    `json:"engine,omitempty"`
+	Version       int64     `json:"version"`
+}
+
+type ReplayResultDTO struct {
+	Consistent  bool           `json:"consistent"`
+	InputCount  int            `json:"inputCount"`
+	Differences []string       `json:"differences"`
+	Replayed    *SimulationDTO `json:"replayed,omitempty"`
+}
+
+// RewindResultDTO は巻き戻し後の状態と、破棄した時点のスナップショット文書
+type RewindResultDTO struct {
+	Simulation SimulationDTO    `json:"simulation"`
+	Abandoned  SnapshotDocument `json:"abandoned"`
+}
+
+func toInputRecordDTOs(records []domain.InputRecord) []InputRecordDTO {
+	out := make([]InputRecordDTO, 0, len(records))
+	for _, r := range records {
+		out = append(out, InputRecordDTO{
+			Seq:           r.Seq,
+			Kind:          string(r.Kind),
+			RecordedAt:    r.RecordedAt,
+			SimTimeMillis: r.SimTimeMillis,
+			DeltaMillis:   r.DeltaMillis,
+			StepMillis:    r.StepMillis,
+			Engine:        string(r.Engine),
+			Version:       r.Version,
+		})
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package simulation
+
+import domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+
+// LineSchematicDTO は路線図（スケマティック）での配置。Stations / Blocks は LineDTO の駅と区間と同じ並び。
+// Generated なら定義に配置が無く、サーバーが自動で並べたもの。
+type LineSchematicDTO struct {
+	Generated bool                  `json:"generated"`
+	Stations  []StationSchematicDTO `json:"stations"`
+	Blocks    []BlockSchematicDTO   `json:"blocks"`
+}
+
+type StationSchematicDTO struct {
+	ID          string   `json:"id"`
+	Position    PointDTO `json:"position"`
+	LabelAnchor PointDTO `json:"labelAnchor"`
+}
+
+// BlockSchematicDTO の Polyline は手前の駅から先の駅までの線、SignalLabelAnchor は区間に入る信号の札の位置
+type BlockSchematicDTO struct {
+	ID                string     `json:"id"`
+	Polyline          []PointDTO `json:"polyline"`
+	SignalLabelAnchor PointDTO   `json:"signalLabelAnchor"`
+}
+
+// PointDTO は路線図の座標。y は下向き。
+type PointDTO struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func toLineSchematicDTO(stations []domain.StationID, blocks []domain.BlockID, layout domain.LineLayout, generated bool) *LineSchematicDTO {
+	out := &LineSchematicDTO{
+		Generated: generated,
+		Stations:  make([]StationSchematicDTO, 0, len(stations)),
+		Blocks:    make([]BlockSchematicDTO, 0, len(blocks)),
+	}
+	for i, station := range stations {
+		out.Stations = append(out.Stations, StationSchematicDTO{
+			ID:          station.String(),
+			Position:    PointDTO(layout.Stations[i].Position),
+			LabelAnchor: PointDTO(layout.Stations[i].Label),
+		})
+	}
+	for i, block := range blocks {
+		out.Blocks = append(out.Blocks, toBlockSchematicDTO(block, layout.Blocks[i]))
+	}
+	return out
+}
+
+// toNetworkSchematicDTO は網の全駅（重複なし）と全区間の配置。乗換駅は最初の路線での位置を使う。
+func toNetworkSchematicDTO(network *domain.Network, layouts []domain.NetworkLineLayout) *LineSchematicDTO {
+	out := &LineSchematicDTO{
+		Stations: make([]StationSchematicDTO, 0, len(network.Stations())),
+		Blocks:   make([]BlockSchematicDTO, 0, len(network.Blocks())),
+	}
+	seen := make(map[string]struct{}, len(network.Stations()))
+	for i, l := range network.Lines() {
+		layout := layouts[i].Layout
+		out.Generated = out.Generated || layouts[i].Generated
+		for j, station := range l.Line.Stations() {
+			if _, dup := seen[station.String()]; dup {
+				continue
+			}
+			seen[station.String()] = struct{}{}
+			out.Stations = append(out.Stations, StationSchematicDTO{
+				ID:          station.String(),
+				Position:    PointDTO(layout.Stations[j].Position),
+				LabelAnchor: PointDTO(layout.Stations[j].Label),
+			})
+		}
+		for j, block := range l.Line.Blocks() {
+			out.Blocks = append(out.Blocks, toBlockSchematicDTO(block, layout.Blocks[j]))
+		}
+	}
+	return out
+}
+
+func toBlockSchematicDTO(block domain.BlockID, layout domain.BlockLayout) BlockSchematicDTO {
+	polyline := make([]PointDTO, 0, len(layout.Polyline))
+	for _, p := range layout.Polyline {
+		polyline = append(polyline, PointDTO(p))
+	}
+	return BlockSchematicDTO{
+		ID:                block.String(),
+		Polyline:          polyline,
+		SignalLabelAnchor: PointDTO(layout.SignalLabel),
+	}
+}
@@ -0,0 +1,194 @@
+package simulation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 路線図の SVG の寸法と色
+const (
+	svgMargin        = 40.0
+	svgHeaderHeight  = 24.0
+	svgTrackWidth    = 4.0
+	svgStationRadius = 5.0
+	// svgInterchangeRadius は乗換駅の印の大きさ
+	svgInterchangeRadius = 7.0
+	svgArrowLength       = 9.0
+	svgTrainLabelOffset  = 18.0
+
+	svgFreeColor     = "#9aa5b1"
+	svgOccupiedColor = "#d64545"
+	svgTrainColor    = "#1f4e8c"
+)
+
+// RenderSchematicSVG は SimulationDTO の路線図に、区間の在線と列車の位置を描いた SVG を w に書く。
+// 空いている区間は灰色、列車のいる区間は赤で描き、列車は進む向きの矢印と列車IDで示す。
+// 同じ DTO からはいつも同じバイト列になる（ゴールデンファイルと比べられる）。
+func RenderSchematicSVG(w io.Writer, dto SimulationDTO) error {
+	schematic := dto.Line.Schematic
+	if schematic == nil {
+		return errors.New("simulation has no schematic")
+	}
+
+	minX, minY, maxX, maxY := schematicBounds(schematic)
+	minY -= svgHeaderHeight
+	width, height := maxX-minX+2*svgMargin, maxY-minY+2*svgMargin
+	originX, originY := minX-svgMargin, minY-svgMargin
+
+	occupiedBy := blockOccupancy(dto)
+	blocks := make(map[string]BlockSchematicDTO, len(schematic.Blocks))
+	for _, block := range schematic.Blocks {
+		blocks[block.ID] = block
+	}
+	names := make(map[string]string, len(dto.Line.StationDetails))
+	for _, station := range dto.Line.StationDetails {
+		names[station.ID] = station.Name
+	}
+	interchanges := make(map[string]bool, len(dto.Interchanges))
+	for _, station := range dto.Interchanges {
+		interchanges[station] = true
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s" font-family="sans-serif" font-size="12">`+"\n",
+		svgNumber(width), svgNumber(height), svgNumber(originX), svgNumber(originY), svgNumber(width), svgNumber(height))
+	fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s" fill="#ffffff"/>`+"\n",
+		svgNumber(originX), svgNumber(originY), svgNumber(width), svgNumber(height))
+	fmt.Fprintf(out, `<text x="%s" y="%s" font-weight="bold">%s  %s  v%d</text>`+"\n",
+		svgNumber(minX), svgNumber(minY), svgText(dto.SessionID), formatSimClock(dto.SimTimeMillis), dto.Version)
+
+	out.WriteString(`<g id="blocks" fill="none" stroke-linecap="round" stroke-linejoin="round">` + "\n")
+	for _, block := range schematic.Blocks {
+		color, class := svgFreeColor, "block"
+		if occupiedBy[block.ID] != "" {
+			color, class = svgOccupiedColor, "block occupied"
+		}
+		fmt.Fprintf(out, `<polyline class="%s" data-block-id="%s" points="%s" stroke="%s" stroke-width="%s"><title>%s</title></polyline>`+"\n",
+			class, svgText(block.ID), svgPoints(block.Polyline), color, svgNumber(svgTrackWidth), svgText(block.ID))
+	}
+	out.WriteString("</g>\n")
+
+	out.WriteString(`<g id="stations" text-anchor="middle">` + "\n")
+	for _, station := range schematic.Stations {
+		radius := svgStationRadius
+		if interchanges[station.ID] {
+			radius = svgInterchangeRadius
+		}
+		label := station.ID
+		if names[station.ID] != "" {
+			label = names[station.ID]
+		}
+		fmt.Fprintf(out, `<circle data-station-id="%s" cx="%s" cy="%s" r="%s" fill="#ffffff" stroke="#333333" stroke-width="2"/>`+"\n",
+			svgText(station.ID), svgNumber(station.Position.X), svgNumber(station.Position.Y), svgNumber(radius))
+		fmt.Fprintf(out, `<text x="%s" y="%s">%s</text>`+"\n",
+			svgNumber(station.LabelAnchor.X), svgNumber(station.LabelAnchor.Y), svgText(label))
+	}
+	out.WriteString("</g>\n")
+
+	out.WriteString(`<g id="trains" text-anchor="middle">` + "\n")
+	for _, train := range dto.Trains {
+		block, ok := blocks[train.BlockID]
+		if !ok {
+			continue
+		}
+		at, dx, dy := pointAlong(block.Polyline, train.Progress)
+		if !train.Forward {
+			dx, dy = -dx, -dy
+		}
+		tip := PointDTO{X: at.X + dx*svgArrowLength, Y: at.Y + dy*svgArrowLength}
+		// 矢印の根元の両端（進む向きに直角に開く）
+		left := PointDTO{X: at.X - dx*svgArrowLength/2 - dy*svgArrowLength/2, Y: at.Y - dy*svgArrowLength/2 + dx*svgArrowLength/2}
+		right := PointDTO{X: at.X - dx*svgArrowLength/2 + dy*svgArrowLength/2, Y: at.Y - dy*svgArrowLength/2 - dx*svgArrowLength/2}
+		fmt.Fprintf(out, `<g class="train" data-train-id="%s"><polygon points="%s" fill="%s"/><text x="%s" y="%s" fill="%s">%s</text></g>`+"\n",
+			svgText(train.ID), svgPoints([]PointDTO{tip, left, right}), svgTrainColor,
+			svgNumber(at.X), svgNumber(at.Y+svgTrainLabelOffset), svgTrainColor, svgText(train.ID))
+	}
+	out.WriteString("</g>\n")
+	out.WriteString("</svg>\n")
+	return out.Flush()
+}
+
+// schematicBounds は路線図のすべての点を囲む範囲
+func schematicBounds(schematic *LineSchematicDTO) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	extend := func(p PointDTO) {
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+	for _, station := range schematic.Stations {
+		extend(station.Position)
+		extend(station.LabelAnchor)
+	}
+	for _, block := range schematic.Blocks {
+		for _, p := range block.Polyline {
+			extend(p)
+		}
+		extend(block.SignalLabelAnchor)
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0
+	}
+	return minX, minY, maxX, maxY
+}
+
+// pointAlong は折れ線の長さの割合 fraction の位置と、そこでの線の向き（単位ベクトル）を返す
+func pointAlong(polyline []PointDTO, fraction float64) (PointDTO, float64, float64) {
+	total := 0.0
+	for i := 1; i < len(polyline); i++ {
+		total += math.Hypot(polyline[i].X-polyline[i-1].X, polyline[i].Y-polyline[i-1].Y)
+	}
+	if total == 0 {
+		return polyline[0], 1, 0
+	}
+
+	remaining := math.Max(0, math.Min(1, fraction)) * total
+	for i := 1; i < len(polyline); i++ {
+		from, to := polyline[i-1], polyline[i]
+		length := math.Hypot(to.X-from.X, to.Y-from.Y)
+		if length == 0 {
+			continue
+		}
+		dx, dy := (to.X-from.X)/length, (to.Y-from.Y)/length
+		if remaining <= length || i == len(polyline)-1 {
+			step := math.Min(remaining, length)
+			return PointDTO{X: from.X + dx*step, Y: from.Y + dy*step}, dx, dy
+		}
+		remaining -= length
+	}
+	return polyline[len(polyline)-1], 1, 0
+}
+
+func svgPoints(points []PointDTO) string {
+	parts := make([]string, 0, len(points))
+	for _, p := range points {
+		parts = append(parts, svgNumber(p.X)+","+svgNumber(p.Y))
+	}
+	return strings.Join(parts, " ")
+}
+
+// svgNumber は座標を小数第2位までに丸めて書く（浮動小数の誤差で出力が揺れないように）
+func svgNumber(v float64) string {
+	v = math.Round(v*100) / 100
+	if v == 0 {
+		v = 0 // -0 を 0 と書く
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func svgText(s string) string {
+	return html.EscapeString(s)
+}
+
+// formatSimClock はシミュレーション時刻を h:mm:ss.mmm と書く
+func formatSimClock(millis int64) string {
+	d := time.Duration(millis) * time.Millisecond
+	return fmt.Sprintf("%d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, millis%1000)
+}
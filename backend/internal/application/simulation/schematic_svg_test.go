@@ -0,0 +1,67 @@
+package simulation
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "testdata のゴールデンファイルを書き直す")
+
+func TestRenderSchematicSVGMatchesGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderSchematicSVG(&buf, toSimulationDTO(testNetworkState(t))); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	assertGolden(t, "schematic_network.svg", buf.Bytes())
+}
+
+func TestRenderSchematicSVGPointsArrowBackward(t *testing.T) {
+	dto := SimulationDTO{
+		SessionID: "room-a",
+		Line: LineDTO{
+			Stations: []string{"S0", "S1"},
+			Blocks:   []string{"B0"},
+			Schematic: &LineSchematicDTO{
+				Stations: []StationSchematicDTO{{ID: "S0", LabelAnchor: PointDTO{Y: -12}}, {ID: "S1", Position: PointDTO{X: 100}, LabelAnchor: PointDTO{X: 100, Y: -12}}},
+				Blocks:   []BlockSchematicDTO{{ID: "B0", Polyline: []PointDTO{{}, {X: 100}}, SignalLabelAnchor: PointDTO{X: 12, Y: 12}}},
+			},
+		},
+		Trains: []TrainDTO{{ID: "T9", BlockID: "B0", Progress: 0.25, Forward: false}},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderSchematicSVG(&buf, dto); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	svg := buf.String()
+	if !strings.Contains(svg, `class="block occupied" data-block-id="B0"`) {
+		t.Fatalf("expected B0 to be drawn occupied:\n%s", svg)
+	}
+	// 25 の位置から左向き（S0 の方）に矢印の先を置く
+	if !strings.Contains(svg, `<polygon points="16,0 29.5,-4.5 29.5,4.5"`) {
+		t.Fatalf("expected the arrow to point towards S0:\n%s", svg)
+	}
+}
+
+// assertGolden は got を testdata/name と比べる。-update なら testdata/name を got で書き直す。
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden failed: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from the golden file (run with -update to accept):\n%s", name, got)
+	}
+}
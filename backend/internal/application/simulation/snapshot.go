@@ -0,0 +1,399 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// SnapshotSchemaVersion はスナップショット文書の形式バージョン
+const SnapshotSchemaVersion = 1
+
+// SnapshotDocument はシミュレーションを保存・共有するための自己完結した文書。
+// 路線・時刻・列車・占有状態・折返し待ちを含み、別セッションへそのまま取り込める。
+// 路線1つだけの網は Line で、複数の路線の網は Lines と Transfers で表す。
+type SnapshotDocument struct {
+	SchemaVersion    int                      `json:"schemaVersion"`
+	SourceSessionID  string                   `json:"sourceSessionId,omitempty"`
+	ExportedAt       time.Time                `json:"exportedAt"`
+	SimTimeMillis    int64                    `json:"simTimeMillis"`
+	Line             SnapshotLineDTO          `json:"line,omitzero"`
+	Lines            []SnapshotNetworkLineDTO `json:"lines,omitempty"`
+	Transfers        []TransferDTO            `json:"transfers,omitempty"`
+	Trains           []TrainDTO               `json:"trains"`
+	Occupancy        []OccupancyDTO           `json:"occupancy"`
+	PendingTurnbacks []string                 `json:"pendingTurnbacks"`
+	// ScheduledTrains は始発の時刻を待っていてまだ網に入っていない列車
+	ScheduledTrains []ScheduledTrainDTO `json:"scheduledTrains,omitempty"`
+	// PriorityRules は区間の競合に使う規則。省略すれば既定の規則になる
+	PriorityRules []string `json:"priorityRules,omitempty"`
+}
+
+type SnapshotLineDTO struct {
+	Stations []SnapshotStationDTO `json:"stations"`
+	Blocks   []SnapshotBlockDTO   `json:"blocks"`
+}
+
+type SnapshotNetworkLineDTO struct {
+	ID string `json:"id"`
+	SnapshotLineDTO
+}
+
+// SnapshotStationDTO と SnapshotBlockDTO の id と両端の駅以外の項目は案内用の情報で、省略できる
+type SnapshotStationDTO struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	NameRomaji    string   `json:"nameRomaji,omitempty"`
+	Code          string   `json:"code,omitempty"`
+	KilometerPost *float64 `json:"kilometerPost,omitempty"`
+	Platforms     int      `json:"platforms,omitempty"`
+	// Position と LabelAnchor は路線図での配置（路線に配置があるときだけ持つ）
+	Position    *PointDTO `json:"position,omitempty"`
+	LabelAnchor *PointDTO `json:"labelAnchor,omitempty"`
+}
+
+type SnapshotBlockDTO struct {
+	ID               string  `json:"id"`
+	FromStationID    string  `json:"fromStationId"`
+	ToStationID      string  `json:"toStationId"`
+	TrackNumber      string  `json:"trackNumber,omitempty"`
+	Electrification  string  `json:"electrification,omitempty"`
+	GradientPermille float64 `json:"gradientPermille,omitempty"`
+	// Polyline と SignalLabelAnchor は路線図での配置（路線に配置があるときだけ持つ）
+	Polyline          []PointDTO `json:"polyline,omitempty"`
+	SignalLabelAnchor *PointDTO  `json:"signalLabelAnchor,omitempty"`
+}
+
+// ScheduledTrainDTO は AtMillis（シミュレーション時刻）に網へ入る列車
+type ScheduledTrainDTO struct {
+	TrainDTO
+	AtMillis int64 `json:"atMillis"`
+}
+
+type OccupancyDTO struct {
+	BlockID string `json:"blockId"`
+	TrainID string `json:"trainId"`
+}
+
+func toSnapshotDocument(state *domain.SimulationState, exportedAt time.Time) SnapshotDocument {
+	snap := state.Snapshot()
+
+	lines := make([]SnapshotNetworkLineDTO, 0, len(snap.Lines))
+	for _, l := range snap.Lines {
+		lines = append(lines, SnapshotNetworkLineDTO{ID: l.ID, SnapshotLineDTO: toSnapshotLineDTO(l)})
+	}
+	transfers := make([]TransferDTO, 0, len(snap.Transfers))
+	for _, t := range snap.Transfers {
+		transfers = append(transfers, TransferDTO{FromStationID: t.From, ToStationID: t.To, MinTransferMillis: t.MinTransferMillis})
+	}
+
+	trains := make([]TrainDTO, 0, len(snap.Trains))
+	occupancy := make([]OccupancyDTO, 0, len(snap.Trains))
+	pending := make([]string, 0)
+	for _, t := range snap.Trains {
+		trains = append(trains, TrainDTO{
+			ID:              t.ID,
+			BlockID:         t.BlockID,
+			Progress:        t.Progress,
+			Forward:         t.Forward,
+			Speed:           t.Speed,
+			PendingTurnback: t.PendingTurnback,
+		})
+		occupancy = append(occupancy, OccupancyDTO{BlockID: t.BlockID, TrainID: t.ID})
+		if t.PendingTurnback {
+			pending = append(pending, t.ID)
+		}
+	}
+
+	var scheduled []ScheduledTrainDTO
+	for _, t := range snap.Scheduled {
+		scheduled = append(scheduled, ScheduledTrainDTO{TrainDTO: TrainDTO(t.TrainSnapshot), AtMillis: t.AtMillis})
+	}
+
+	single := toSnapshotLineDTO(domain.LineSnapshot{
+		Stations:    snap.Stations,
+		Blocks:      snap.Blocks,
+		StationInfo: snap.StationInfo,
+		BlockInfo:   snap.BlockInfo,
+		Layout:      snap.Layout,
+	})
+	return SnapshotDocument{
+		SchemaVersion:    SnapshotSchemaVersion,
+		SourceSessionID:  snap.ID,
+		ExportedAt:       exportedAt,
+		SimTimeMillis:    snap.SimTimeMillis,
+		Line:             single,
+		Lines:            lines,
+		Transfers:        transfers,
+		Trains:           trains,
+		Occupancy:        occupancy,
+		PendingTurnbacks: pending,
+		ScheduledTrains:  scheduled,
+		PriorityRules:    snap.PriorityRules,
+	}
+}
+
+// toSnapshotLineDTO は路線を文書の形にする。案内用の情報が無ければ（nil）その項目を省く。
+func toSnapshotLineDTO(line domain.LineSnapshot) SnapshotLineDTO {
+	if len(line.Stations) == 0 && len(line.Blocks) == 0 {
+		return SnapshotLineDTO{}
+	}
+	stations := make([]SnapshotStationDTO, 0, len(line.Stations))
+	for i, id := range line.Stations {
+		station := SnapshotStationDTO{ID: id}
+		if i < len(line.StationInfo) {
+			info := line.StationInfo[i]
+			station.Name = info.Name
+			station.NameRomaji = info.NameRomaji
+			station.Code = info.Code
+			station.KilometerPost = toKilometerPostDTO(info)
+			station.Platforms = info.Platforms
+		}
+		if line.Layout != nil {
+			position, label := PointDTO(line.Layout.Stations[i].Position), PointDTO(line.Layout.Stations[i].Label)
+			station.Position, station.LabelAnchor = &position, &label
+		}
+		stations = append(stations, station)
+	}
+	blocks := make([]SnapshotBlockDTO, 0, len(line.Blocks))
+	for i, id := range line.Blocks {
+		block := SnapshotBlockDTO{
+			ID:            id,
+			FromStationID: line.Stations[i],
+			ToStationID:   line.Stations[i+1],
+		}
+		if i < len(line.BlockInfo) {
+			info := line.BlockInfo[i]
+			block.TrackNumber = info.TrackNumber
+			block.Electrification = info.Electrification
+			block.GradientPermille = info.GradientPermille
+		}
+		if line.Layout != nil {
+			layout := line.Layout.Blocks[i]
+			for _, p := range layout.Polyline {
+				block.Polyline = append(block.Polyline, PointDTO(p))
+			}
+			label := PointDTO(layout.SignalLabel)
+			block.SignalLabelAnchor = &label
+		}
+		blocks = append(blocks, block)
+	}
+	return SnapshotLineDTO{Stations: stations, Blocks: blocks}
+}
+
+// fromSnapshotLineDTO は路線の駅と区間（と案内用の情報）を取り出す。区間の両端が駅の並びと合わなければエラー。
+// 案内用の情報がどの駅・区間にも無ければ nil にする（情報の無い文書は旧い形式のまま復元する）。
+func fromSnapshotLineDTO(line SnapshotLineDTO) (domain.LineSnapshot, error) {
+	out := domain.LineSnapshot{
+		Stations: make([]string, 0, len(line.Stations)),
+		Blocks:   make([]string, 0, len(line.Blocks)),
+	}
+	stationInfo := make([]domain.StationInfo, 0, len(line.Stations))
+	hasStationInfo := false
+	for _, s := range line.Stations {
+		out.Stations = append(out.Stations, s.ID)
+		info := domain.StationInfo{
+			Name:       s.Name,
+			NameRomaji: s.NameRomaji,
+			Code:       s.Code,
+			Platforms:  s.Platforms,
+		}
+		if s.KilometerPost != nil {
+			info.KilometerPost, info.HasKilometerPost = *s.KilometerPost, true
+		}
+		hasStationInfo = hasStationInfo || info != domain.StationInfo{}
+		stationInfo = append(stationInfo, info)
+	}
+	blockInfo := make([]domain.BlockInfo, 0, len(line.Blocks))
+	hasBlockInfo := false
+	for _, b := range line.Blocks {
+		out.Blocks = append(out.Blocks, b.ID)
+		info := domain.BlockInfo{
+			TrackNumber:      b.TrackNumber,
+			Electrification:  b.Electrification,
+			GradientPermille: b.GradientPermille,
+		}
+		hasBlockInfo = hasBlockInfo || info != domain.BlockInfo{}
+		blockInfo = append(blockInfo, info)
+	}
+	if hasStationInfo {
+		out.StationInfo = stationInfo
+	}
+	if hasBlockInfo {
+		out.BlockInfo = blockInfo
+	}
+
+	if len(line.Blocks) == len(line.Stations)-1 {
+		for i, b := range line.Blocks {
+			if strings.TrimSpace(b.FromStationID) != strings.TrimSpace(out.Stations[i]) ||
+				strings.TrimSpace(b.ToStationID) != strings.TrimSpace(out.Stations[i+1]) {
+				return domain.LineSnapshot{}, fmt.Errorf("%w: %v", ErrInvalidSnapshot, domain.ErrLineConnectivityInvalid)
+			}
+		}
+		layout, err := fromSnapshotLayout(line)
+		if err != nil {
+			return domain.LineSnapshot{}, err
+		}
+		out.Layout = layout
+	}
+	return out, nil
+}
+
+// fromSnapshotLayout は路線図での配置を取り出す。どの駅にも位置が無ければ nil。
+// 札の位置や区間の線を省いた駅・区間は、路線の定義を読むときと同じく既定の置き方にする。
+func fromSnapshotLayout(line SnapshotLineDTO) (*domain.LineLayout, error) {
+	positioned := 0
+	for _, s := range line.Stations {
+		if s.Position != nil {
+			positioned++
+		}
+	}
+	if positioned == 0 {
+		return nil, nil
+	}
+	if positioned != len(line.Stations) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, domain.ErrLineLayoutMismatch)
+	}
+
+	layout := &domain.LineLayout{
+		Stations: make([]domain.StationLayout, 0, len(line.Stations)),
+		Blocks:   make([]domain.BlockLayout, 0, len(line.Blocks)),
+	}
+	for _, s := range line.Stations {
+		station := domain.NewStationLayout(domain.Point(*s.Position))
+		if s.LabelAnchor != nil {
+			station.Label = domain.Point(*s.LabelAnchor)
+		}
+		layout.Stations = append(layout.Stations, station)
+	}
+	for i, b := range line.Blocks {
+		polyline := []domain.Point{layout.Stations[i].Position, layout.Stations[i+1].Position}
+		if len(b.Polyline) > 0 {
+			polyline = make([]domain.Point, 0, len(b.Polyline))
+			for _, p := range b.Polyline {
+				polyline = append(polyline, domain.Point(p))
+			}
+		}
+		block := domain.NewBlockLayout(polyline...)
+		if b.SignalLabelAnchor != nil {
+			block.SignalLabel = domain.Point(*b.SignalLabelAnchor)
+		}
+		layout.Blocks = append(layout.Blocks, block)
+	}
+	return layout, nil
+}
+
+// fromSnapshotDocument は文書を検証し、指定IDのシミュレーションとして復元する。
+// 路線網と列車の不変条件は RestoreSimulationState（NewLine / NewNetwork / AddTrain）で検証し、
+// 占有状態と折返し待ちは列車から導いた結果と一致することを確認する。
+func fromSnapshotDocument(doc SnapshotDocument, id domain.SimulationID, version int64) (*domain.SimulationState, error) {
+	if doc.SchemaVersion != SnapshotSchemaVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSnapshotVersion, doc.SchemaVersion)
+	}
+
+	single, err := fromSnapshotLineDTO(doc.Line)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]domain.LineSnapshot, 0, len(doc.Lines))
+	for _, l := range doc.Lines {
+		line, err := fromSnapshotLineDTO(l.SnapshotLineDTO)
+		if err != nil {
+			return nil, err
+		}
+		line.ID = l.ID
+		lines = append(lines, line)
+	}
+	transfers := make([]domain.TransferSnapshot, 0, len(doc.Transfers))
+	for _, t := range doc.Transfers {
+		transfers = append(transfers, domain.TransferSnapshot{From: t.FromStationID, To: t.ToStationID, MinTransferMillis: t.MinTransferMillis})
+	}
+
+	trains := make([]domain.TrainSnapshot, 0, len(doc.Trains))
+	for _, t := range doc.Trains {
+		trains = append(trains, domain.TrainSnapshot{
+			ID:              t.ID,
+			BlockID:         t.BlockID,
+			Progress:        t.Progress,
+			Forward:         t.Forward,
+			Speed:           t.Speed,
+			PendingTurnback: t.PendingTurnback,
+		})
+	}
+
+	var scheduled []domain.ScheduledTrainSnapshot
+	for _, t := range doc.ScheduledTrains {
+		scheduled = append(scheduled, domain.ScheduledTrainSnapshot{TrainSnapshot: domain.TrainSnapshot(t.TrainDTO), AtMillis: t.AtMillis})
+	}
+
+	state, err := domain.RestoreSimulationState(domain.StateSnapshot{
+		ID:            id.String(),
+		Version:       version,
+		Stations:      single.Stations,
+		Blocks:        single.Blocks,
+		StationInfo:   single.StationInfo,
+		BlockInfo:     single.BlockInfo,
+		Layout:        single.Layout,
+		Lines:         lines,
+		Transfers:     transfers,
+		SimTimeMillis: doc.SimTimeMillis,
+		Trains:        trains,
+		Scheduled:     scheduled,
+		PriorityRules: doc.PriorityRules,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	if err := verifyDerivedState(state, doc); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func verifyDerivedState(state *domain.SimulationState, doc SnapshotDocument) error {
+	restored := state.Trains()
+
+	expectedOccupancy := make(map[string]string, len(restored))
+	expectedPending := make(map[string]struct{})
+	for _, t := range restored {
+		expectedOccupancy[t.BlockID().String()] = t.ID().String()
+		if t.PendingTurnback() {
+			expectedPending[t.ID().String()] = struct{}{}
+		}
+	}
+
+	if len(doc.Occupancy) != len(expectedOccupancy) {
+		return fmt.Errorf("%w: occupancy does not match trains", ErrInvalidSnapshot)
+	}
+	// 件数が同じでも重複があれば他の区間が抜けているので、同じ区間は一度しか認めない
+	seenBlocks := make(map[string]struct{}, len(doc.Occupancy))
+	for _, o := range doc.Occupancy {
+		block := strings.TrimSpace(o.BlockID)
+		if _, dup := seenBlocks[block]; dup {
+			return fmt.Errorf("%w: duplicate occupancy of block %s", ErrInvalidSnapshot, o.BlockID)
+		}
+		seenBlocks[block] = struct{}{}
+		if expectedOccupancy[block] != strings.TrimSpace(o.TrainID) {
+			return fmt.Errorf("%w: occupancy of block %s does not match trains", ErrInvalidSnapshot, o.BlockID)
+		}
+	}
+
+	if len(doc.PendingTurnbacks) != len(expectedPending) {
+		return fmt.Errorf("%w: pending turnbacks do not match trains", ErrInvalidSnapshot)
+	}
+	seenPending := make(map[string]struct{}, len(doc.PendingTurnbacks))
+	for _, id := range doc.PendingTurnbacks {
+		train := strings.TrimSpace(id)
+		if _, dup := seenPending[train]; dup {
+			return fmt.Errorf("%w: duplicate pending turnback of train %s", ErrInvalidSnapshot, id)
+		}
+		seenPending[train] = struct{}{}
+		if _, ok := expectedPending[train]; !ok {
+			return fmt.Errorf("%w: pending turnback of train %s does not match trains", ErrInvalidSnapshot, id)
+		}
+	}
+	return nil
+}
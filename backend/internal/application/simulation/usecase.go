@@ -4,126 +4,826 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"slices"
 	"time"
 
+	sessiondomain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
 	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
 )
 
-type LineLoader interface {
-	Load(ctx context.Context) (*domain.Line, error)
+// NetworkLoader は演習で使う路線網を読み込む
+type NetworkLoader interface {
+	Load(ctx context.Context) (*domain.Network, error)
+}
+
+// TimetableLoader は演習の計画ダイヤと、それを受け持つ列車の初期配置を網に合わせて読み込む
+type TimetableLoader interface {
+	Load(ctx context.Context, network *domain.Network) (*domain.Timetable, []*domain.Train, error)
 }
 
 type UseCase interface {
-	GetSimulation(ctx context.Context) (SimulationDTO, error)
+	GetSimulation(ctx context.Context, sessionID string) (SimulationDTO, error)
+	GetChanges(ctx context.Context, input ChangesInput) (SimulationDeltaDTO, error)
+	GetTopology(ctx context.Context, sessionID string) (TopologyDTO, error)
 	Tick(ctx context.Context, input TickInput) (SimulationDTO, error)
+	ExportSnapshot(ctx context.Context, sessionID string) (SnapshotDocument, error)
+	ImportSnapshot(ctx context.Context, input ImportSnapshotInput) (SimulationDTO, error)
+	GetInputLog(ctx context.Context, sessionID string) ([]InputRecordDTO, error)
+	VerifyReplay(ctx context.Context, sessionID string) (ReplayResultDTO, error)
+	Rewind(ctx context.Context, input RewindInput) (RewindResultDTO, error)
+	Subscribe(ctx context.Context, sessionID string) (<-chan Notification, func(), error)
+	Forecast(ctx context.Context, input ForecastInput) (ForecastDTO, error)
+	FastForward(ctx context.Context, input FastForwardInput) (FastForwardResultDTO, error)
+	ListBreakpoints(ctx context.Context, sessionID string) ([]BreakpointDTO, error)
+	CreateBreakpoint(ctx context.Context, input CreateBreakpointInput) (BreakpointDTO, error)
+	UpdateBreakpoint(ctx context.Context, input UpdateBreakpointInput) (BreakpointDTO, error)
+	DeleteBreakpoint(ctx context.Context, sessionID string, breakpointID string) error
+	SetPriorityRules(ctx context.Context, input SetPriorityRulesInput) (SimulationDTO, error)
+	GetTimetable(ctx context.Context, sessionID string) (TimetableDTO, error)
+	SetTimetable(ctx context.Context, input SetTimetableInput) (TimetableDTO, error)
+	GetDiagram(ctx context.Context, input DiagramInput) (DiagramDTO, error)
 }
 
 type TickInput struct {
-	DeltaMillis int64
+	SessionID       string
+	DeltaMillis     int64
+	Engine          string // TICK（既定）か EVENT
+	ExpectedVersion *int64 // nil なら無条件（If-Match 未指定）
+}
+
+// ImportSnapshotInput はスナップショット取り込みの入力。
+// 取り込み先セッションのシミュレーションを文書の内容で置き換える。
+type ImportSnapshotInput struct {
+	SessionID       string
+	Document        SnapshotDocument
+	ExpectedVersion *int64
+}
+
+// RewindInput は巻き戻しの入力。TargetSimTimeMillis 時点の状態に戻し、それ以降の進行は破棄する。
+// 戻れるのは Tick の区切りの時刻だけ（Tick の途中の時刻は ErrInvalidRewindTarget）。
+type RewindInput struct {
+	SessionID           string
+	TargetSimTimeMillis int64
+	ExpectedVersion     *int64
+}
+
+// SetPriorityRulesInput は区間の競合に使う規則の変更。Rules が空なら既定の規則に戻す。
+type SetPriorityRulesInput struct {
+	SessionID       string
+	Rules           []string
+	ExpectedVersion *int64
 }
 
+// checkpointIntervalMillis ごと（シミュレーション時刻）に入力ログへ状態の控えを残す。
+// 巻き戻しはここから再生するので、長い演習でも先頭から再生し直さずに済む。
+const checkpointIntervalMillis int64 = 60_000
+
 type service struct {
-	repo       domain.Repository
-	lineLoader LineLoader
-	mu         sync.Mutex
+	repo          domain.Repository
+	logs          domain.InputLogRepository
+	sessions      sessiondomain.Repository
+	notifications *NotificationHub
+	breakpoints   *BreakpointRegistry
+	diagrams      *DiagramRegistry
+	views         *ViewPublisher
+	actors        *SimulationActors
 }
 
-func NewUseCase(repo domain.Repository, lineLoader LineLoader) UseCase {
+// NewUseCase は UseCase 実装を生成する。
+// シミュレーションの生成・破棄は Provisioner が担い、ここでは参照と進行のみを扱う。
+// 状態を変えた入力はすべて logs に追記する（事後の再生・検証用）。
+// 巻き戻しのように他の参加者の画面を無効にする操作は notifications で知らせる。
+// Tick のたびに breakpoints を評価し、成立すれば演習を一時停止する。
+// 状態を変えるたびに views へ公開し、GetSimulation は更新とロックを取り合わずにそれを返す。
+// 公開した状態の列車の位置は diagrams に走行の記録として残し、運行図表に描く。
+// シミュレーションの状態は actors がセッションごとのゴルーチンで所有し、指令はそこで順に実行する。
+func NewUseCase(repo domain.Repository, logs domain.InputLogRepository, sessions sessiondomain.Repository, notifications *NotificationHub, breakpoints *BreakpointRegistry, diagrams *DiagramRegistry, views *ViewPublisher, actors *SimulationActors) UseCase {
 	return &service{
-		repo:       repo,
-		lineLoader: lineLoader,
+		repo:          repo,
+		logs:          logs,
+		sessions:      sessions,
+		notifications: notifications,
+		breakpoints:   breakpoints,
+		diagrams:      diagrams,
+		views:         views,
+		actors:        actors,
 	}
 }
 
-func (s *service) GetSimulation(ctx context.Context) (SimulationDTO, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *service) GetSimulation(ctx context.Context, sessionID string) (SimulationDTO, error) {
+	sid, err := sessiondomain.NewSessionID(sessionID)
+	if err != nil {
+		return SimulationDTO{}, fmt.Errorf("%w: %v", ErrInvalidSessionID, err)
+	}
+	if dto, ok := s.views.Load(sid.String()); ok {
+		return dto, nil
+	}
 
-	state, err := s.ensureState(ctx)
+	// 再起動直後など、まだ公開していなければ現在の状態から作って公開する
+	session, err := s.loadSession(ctx, sessionID)
 	if err != nil {
 		return SimulationDTO{}, err
 	}
-	return toSimulationDTO(state), nil
+	var dto SimulationDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		dto = s.publish(current)
+		return nil, nil
+	})
+	return dto, err
 }
 
-func (s *service) Tick(ctx context.Context, input TickInput) (SimulationDTO, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetChanges は SinceVersion の版から変わった列車と区間だけを返す。
+// 公開済みの状態どうしを比べるので、GetSimulation と同じく更新とロックを取り合わない。
+func (s *service) GetChanges(ctx context.Context, input ChangesInput) (SimulationDeltaDTO, error) {
+	if input.SinceVersion < 0 {
+		return SimulationDeltaDTO{}, fmt.Errorf("%w: since must not be negative", ErrInvalidChangesQuery)
+	}
+	if input.WaitMillis < 0 || input.WaitMillis > maxChangesWaitMillis {
+		return SimulationDeltaDTO{}, fmt.Errorf("%w: wait must be between 0 and %dms", ErrInvalidChangesQuery, maxChangesWaitMillis)
+	}
+	sid, err := sessiondomain.NewSessionID(input.SessionID)
+	if err != nil {
+		return SimulationDeltaDTO{}, fmt.Errorf("%w: %v", ErrInvalidSessionID, err)
+	}
+
+	var timeout <-chan time.Time
+	if input.WaitMillis > 0 {
+		timer := time.NewTimer(time.Duration(input.WaitMillis) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		base, latest, changed, ok := s.views.since(sid.String(), input.SinceVersion)
+		if !ok {
+			// まだ公開していない（または破棄された）なら、GetSimulation で公開し直してから比べる
+			if _, err := s.GetSimulation(ctx, input.SessionID); err != nil {
+				return SimulationDeltaDTO{}, err
+			}
+			continue
+		}
+		if latest.Version != input.SinceVersion || timeout == nil {
+			return toSimulationDelta(input.SinceVersion, base, latest), nil
+		}
 
+		select {
+		case <-changed:
+		case <-timeout:
+			return toSimulationDelta(input.SinceVersion, base, latest), nil
+		case <-ctx.Done():
+			return SimulationDeltaDTO{}, ctx.Err()
+		}
+	}
+}
+
+// GetTopology は演習中に変わらない路線の構成を返す。
+// 構成と Revision は網を公開したときに作ったものを返すので、要求ごとに作り直さない。
+func (s *service) GetTopology(ctx context.Context, sessionID string) (TopologyDTO, error) {
+	// まだ公開していなければ GetSimulation が公開する
+	dto, err := s.GetSimulation(ctx, sessionID)
+	if err != nil {
+		return TopologyDTO{}, err
+	}
+	topology, ok := s.views.Topology(dto.SessionID)
+	if !ok {
+		// 公開を取り下げた（シミュレーションを破棄した）直後
+		return TopologyDTO{}, fmt.Errorf("%w: %s", ErrSimulationNotStarted, dto.SessionID)
+	}
+	return topology, nil
+}
+
+// publish は保存済みの状態を公開し、公開した DTO を返す（actor の指令の中で呼ぶ）
+func (s *service) publish(state *domain.SimulationState) SimulationDTO {
+	dto := s.views.PublishState(state)
+	s.diagrams.record(state)
+	return dto
+}
+
+func (s *service) Tick(ctx context.Context, input TickInput) (SimulationDTO, error) {
 	delta, err := newTickDelta(input.DeltaMillis)
 	if err != nil {
 		return SimulationDTO{}, err
 	}
+	engine, err := domain.ParseEngine(input.Engine)
+	if err != nil {
+		return SimulationDTO{}, fmt.Errorf("%w: %v", ErrInvalidEngine, err)
+	}
 
-	state, err := s.ensureState(ctx)
+	session, err := s.loadSession(ctx, input.SessionID)
 	if err != nil {
 		return SimulationDTO{}, err
 	}
+	var dto SimulationDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		if err := s.requireSessionStatus(ctx, session.ID(), sessiondomain.SessionStatusRunning); err != nil {
+			return nil, err
+		}
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != current.Version() {
+			return nil, domain.ErrVersionConflict
+		}
 
-	if err := state.Tick(delta); err != nil {
-		return SimulationDTO{}, err
+		state := current.Clone()
+		before := state.SimTime()
+		var err error
+		var events []domain.Event
+		if engine == domain.EngineEvent {
+			events, err = state.AdvanceByEventsWithEvents(ctx, delta, int(maxFastForwardSteps))
+		} else {
+			events, err = state.TickWithEvents(delta)
+		}
+		if errors.Is(err, domain.ErrEventStepLimitExceeded) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTickDelta, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		if err := s.saveWithInputs(ctx, state, withCheckpointIfDue(before, state, now, domain.NewTickInput(before, delta, engine, state, now))...); err != nil {
+			return nil, err
+		}
+		// 保存した状態は採用されるので、後続が失敗しても先に公開しておく
+		dto = s.publish(state)
+
+		s.publishDeadlocks(state, events)
+		hit, err := s.breakpoints.evaluate(ctx, state.ID().String(), before, events, state)
+		if err != nil {
+			return state, err
+		}
+		if hit != nil {
+			if err := s.pauseAtBreakpoint(ctx, session.ID(), state, *hit, now); err != nil {
+				return state, err
+			}
+		}
+		return state, nil
+	})
+	return dto, err
+}
+
+// maxPauseAttempts はブレークポイントでの一時停止が参加・退出などと競合したときに試す回数
+const maxPauseAttempts = 3
+
+// pauseAtBreakpoint はブレークポイントの成立で演習を一時停止し、成立した条件を通知する。
+// セッションは読み直してから止め、他の更新と競合したら読み直してやり直す。
+func (s *service) pauseAtBreakpoint(ctx context.Context, sessionID sessiondomain.SessionID, state *domain.SimulationState, hit BreakpointHitDTO, now time.Time) error {
+	for attempt := 1; ; attempt++ {
+		session, err := s.reloadSession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.Status() != sessiondomain.SessionStatusRunning {
+			// 既に一時停止・終了されていれば止めるものが無い
+			return nil
+		}
+		if err := session.Pause(now); err != nil {
+			return err
+		}
+		err = s.sessions.Save(ctx, session)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, sessiondomain.ErrVersionConflict) || attempt == maxPauseAttempts {
+			return fmt.Errorf("pause at breakpoint failed: %w", err)
+		}
 	}
-	if err := s.repo.Save(ctx, state); err != nil {
-		return SimulationDTO{}, err
+
+	s.notifications.Publish(Notification{
+		Type:          NotificationPaused,
+		SessionID:     state.ID().String(),
+		Version:       state.Version(),
+		SimTimeMillis: state.SimTime().Millis(),
+		Payload:       hit,
+	})
+	return nil
+}
+
+// publishDeadlocks は Tick・早送りで新たに生じた待ち合いを警報として知らせる（状態側の alarms にも載る）
+func (s *service) publishDeadlocks(state *domain.SimulationState, events []domain.Event) {
+	for _, e := range events {
+		if e.Type != domain.EventDeadlockDetected || e.Deadlock == nil {
+			continue
+		}
+		s.notifications.Publish(Notification{
+			Type:          NotificationDeadlock,
+			SessionID:     state.ID().String(),
+			Version:       state.Version(),
+			SimTimeMillis: state.SimTime().Millis(),
+			Payload:       toDeadlockDTO(*e.Deadlock),
+		})
 	}
+}
+
+// FastForward は一定の刻みで、目標時刻に達するか停止条件が成立するまで進める。
+// 有効なブレークポイントも停止条件に加え、成立すれば Tick と同じく演習を一時停止して通知する。
+// 刻みごとの状態は保存せず、止まった時点で1回だけ保存・記録する。
+func (s *service) FastForward(ctx context.Context, input FastForwardInput) (FastForwardResultDTO, error) {
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return FastForwardResultDTO{}, err
+	}
+	var out FastForwardResultDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		if err := s.requireSessionStatus(ctx, session.ID(), sessiondomain.SessionStatusRunning); err != nil {
+			return nil, err
+		}
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != current.Version() {
+			return nil, domain.ErrVersionConflict
+		}
 
-	return toSimulationDTO(state), nil
+		spec, err := newRunUntilSpec(input, current.SimTime())
+		if err != nil {
+			return nil, err
+		}
+		requested := len(spec.Conditions)
+		breakpoints, breakpointIDs, err := s.breakpoints.enabledConditions(ctx, current.ID().String())
+		if err != nil {
+			return nil, err
+		}
+		spec.Conditions = append(spec.Conditions, breakpoints...)
+
+		state := current.Clone()
+		result, err := state.RunUntilContext(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		var hit *BreakpointHitDTO
+		if result.Reason.ConditionIndex >= requested {
+			hit = s.breakpoints.hit(state.ID().String(), breakpointIDs[result.Reason.ConditionIndex-requested], result.Reason, state.SimTime())
+			result.Reason.ConditionIndex = -1
+		}
+		now := time.Now()
+		if err := s.saveWithInputs(ctx, state, withCheckpointIfDue(result.From, state, now, domain.NewFastForwardInput(result, spec, state, now))...); err != nil {
+			return nil, err
+		}
+		s.breakpoints.resetObservation(state.ID().String())
+		dto := s.publish(state)
+
+		out = FastForwardResultDTO{
+			Simulation:        dto,
+			FromSimTimeMillis: result.From.Millis(),
+			ToSimTimeMillis:   result.To.Millis(),
+			Steps:             result.Steps,
+			StopReason:        toStopReasonDTO(result.Reason),
+			Breakpoint:        hit,
+		}
+		s.publishDeadlocks(state, result.Deadlocks)
+		if hit != nil {
+			if err := s.pauseAtBreakpoint(ctx, session.ID(), state, *hit, now); err != nil {
+				return state, err
+			}
+		}
+		return state, nil
+	})
+	return out, err
 }
 
-func (s *service) ensureState(ctx context.Context) (*domain.SimulationState, error) {
-	state, err := s.repo.Get(ctx)
-	if err == nil {
+func (s *service) ExportSnapshot(ctx context.Context, sessionID string) (SnapshotDocument, error) {
+	session, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return SnapshotDocument{}, err
+	}
+	var doc SnapshotDocument
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		doc = toSnapshotDocument(current, time.Now())
+		return nil, nil
+	})
+	return doc, err
+}
+
+func (s *service) ImportSnapshot(ctx context.Context, input ImportSnapshotInput) (SimulationDTO, error) {
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return SimulationDTO{}, err
+	}
+	var dto SimulationDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		// 振り返り以降は記録を保つため置き換えを許可しない
+		if err := s.requireSessionStatus(ctx, session.ID(), sessiondomain.SessionStatusRunning, sessiondomain.SessionStatusPaused); err != nil {
+			return nil, err
+		}
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != current.Version() {
+			return nil, domain.ErrVersionConflict
+		}
+
+		state, err := fromSnapshotDocument(input.Document, current.ID(), current.Version())
+		if err != nil {
+			return nil, err
+		}
+		if err := s.saveWithInputs(ctx, state, domain.NewRestoredInput(current.SimTime(), state, time.Now())); err != nil {
+			return nil, err
+		}
+		s.breakpoints.resetObservation(state.ID().String())
+		s.diagrams.resetRuns(state.ID().String())
+		dto = s.publish(state)
 		return state, nil
+	})
+	return dto, err
+}
+
+// SetPriorityRules は同じ区間を同時に求めた列車の順位を決める規則を置き換える。
+// 以降の進行が変わるので、再生できるよう入力ログに記録する。
+func (s *service) SetPriorityRules(ctx context.Context, input SetPriorityRulesInput) (SimulationDTO, error) {
+	rules := make([]domain.PriorityRule, 0, len(input.Rules))
+	for _, raw := range input.Rules {
+		rule, err := domain.ParsePriorityRule(raw)
+		if err != nil {
+			return SimulationDTO{}, fmt.Errorf("%w: %v", ErrInvalidPriorityRules, err)
+		}
+		rules = append(rules, rule)
 	}
-	if !errors.Is(err, domain.ErrSimulationNotFound) {
-		return nil, err
+
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return SimulationDTO{}, err
 	}
+	var dto SimulationDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		if err := s.requireSessionStatus(ctx, session.ID(), sessiondomain.SessionStatusRunning, sessiondomain.SessionStatusPaused); err != nil {
+			return nil, err
+		}
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != current.Version() {
+			return nil, domain.ErrVersionConflict
+		}
 
-	line, err := s.lineLoader.Load(ctx)
+		state := current.Clone()
+		if err := state.SetPriorityRules(rules); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPriorityRules, err)
+		}
+		if err := s.saveWithInputs(ctx, state, domain.NewPriorityRulesChangedInput(state, time.Now())); err != nil {
+			return nil, err
+		}
+		dto = s.publish(state)
+		return state, nil
+	})
+	return dto, err
+}
+
+func (s *service) GetInputLog(ctx context.Context, sessionID string) ([]InputRecordDTO, error) {
+	session, err := s.loadSession(ctx, sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("line load failed: %w", err)
+		return nil, err
 	}
-	state, err = domain.NewSimulationState(line)
+	var records []domain.InputRecord
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		var err error
+		records, err = s.logs.List(ctx, current.ID())
+		return nil, err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return toInputRecordDTOs(records), nil
+}
 
-	initialTrainID, err := domain.NewTrainID("T0")
+// VerifyReplay は入力ログから状態を再構築し、保存済みの状態と一致するかを検証する
+func (s *service) VerifyReplay(ctx context.Context, sessionID string) (ReplayResultDTO, error) {
+	session, err := s.loadSession(ctx, sessionID)
 	if err != nil {
+		return ReplayResultDTO{}, err
+	}
+	// 同じ時点の状態とログを actor から受け取り、再生は actor の外で行う
+	var state *domain.SimulationState
+	var records []domain.InputRecord
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		var err error
+		records, err = s.logs.List(ctx, current.ID())
+		state = current.Clone()
 		return nil, err
+	})
+	if err != nil {
+		return ReplayResultDTO{}, err
 	}
-	initialBlock, ok := line.BlockAt(0)
-	if !ok {
-		return nil, domain.ErrLineHasNoBlocks
+
+	replayed, err := domain.Replay(records)
+	if err != nil {
+		if errors.Is(err, domain.ErrReplayInvalidLog) || errors.Is(err, domain.ErrReplayDiverged) {
+			return ReplayResultDTO{
+				Consistent:  false,
+				InputCount:  len(records),
+				Differences: []string{err.Error()},
+			}, nil
+		}
+		return ReplayResultDTO{}, err
+	}
+	diffs := domain.DiffSnapshots(state.Snapshot(), replayed.Snapshot())
+	if replayed.Version() != state.Version() {
+		diffs = append(diffs, fmt.Sprintf("version: expected %d, got %d", state.Version(), replayed.Version()))
 	}
-	initialProgress, err := domain.NewBlockProgress(0)
+
+	replayedDTO := toSimulationDTO(replayed)
+	return ReplayResultDTO{
+		Consistent:  len(diffs) == 0,
+		InputCount:  len(records),
+		Differences: diffs,
+		Replayed:    &replayedDTO,
+	}, nil
+}
+
+// Rewind は入力ログから過去の時刻の状態を再構築して現在の状態を置き換える。
+// 破棄した時点の状態は結果に含めるので、取り込み直せば別の展開として続けられる。
+func (s *service) Rewind(ctx context.Context, input RewindInput) (RewindResultDTO, error) {
+	if input.TargetSimTimeMillis < 0 {
+		return RewindResultDTO{}, fmt.Errorf("%w: target must not be negative", ErrInvalidRewindTarget)
+	}
+	target, err := domain.NewSimTime(input.TargetSimTimeMillis)
+	if err != nil {
+		return RewindResultDTO{}, fmt.Errorf("%w: %v", ErrInvalidRewindTarget, err)
+	}
+
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return RewindResultDTO{}, err
+	}
+	var out RewindResultDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		if err := s.requireSessionStatus(ctx, session.ID(), sessiondomain.SessionStatusRunning, sessiondomain.SessionStatusPaused); err != nil {
+			return nil, err
+		}
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != current.Version() {
+			return nil, domain.ErrVersionConflict
+		}
+		if input.TargetSimTimeMillis > current.SimTime().Millis() {
+			return nil, fmt.Errorf("%w: %dms is ahead of the current time", ErrInvalidRewindTarget, input.TargetSimTimeMillis)
+		}
+
+		records, err := s.logs.List(ctx, current.ID())
+		if err != nil {
+			return nil, err
+		}
+		rewound, err := domain.StateAt(records, target)
+		if err != nil {
+			if errors.Is(err, domain.ErrRewindTargetUnavailable) {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidRewindTarget, err)
+			}
+			return nil, err
+		}
+
+		now := time.Now()
+		abandoned := toSnapshotDocument(current, now)
+
+		// 保存時の楽観ロックのため、再構築した状態に現在のバージョンを引き継ぐ
+		snap := rewound.Snapshot()
+		snap.Version = current.Version()
+		state, err := domain.RestoreSimulationState(snap)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.saveWithInputs(ctx, state, domain.NewRewoundInput(current.SimTime(), state, now)); err != nil {
+			return nil, err
+		}
+		s.breakpoints.resetObservation(state.ID().String())
+		dto := s.publish(state)
+
+		s.notifications.Publish(Notification{
+			Type:          NotificationRewound,
+			SessionID:     state.ID().String(),
+			Version:       state.Version(),
+			SimTimeMillis: state.SimTime().Millis(),
+			Payload: RewoundPayload{
+				FromSimTimeMillis: current.SimTime().Millis(),
+				ToSimTimeMillis:   state.SimTime().Millis(),
+			},
+		})
+
+		out = RewindResultDTO{
+			Simulation: dto,
+			Abandoned:  abandoned,
+		}
+		return state, nil
+	})
+	return out, err
+}
+
+// Forecast は現在の状態の複製に仮の指令を適用して先を予測する。本番の状態・入力ログには触れない。
+func (s *service) Forecast(ctx context.Context, input ForecastInput) (ForecastDTO, error) {
+	spec, err := newForecastSpec(input)
+	if err != nil {
+		return ForecastDTO{}, err
+	}
+
+	// actor からは複製だけを受け取り、予測の計算中は他の指令を待たせない
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return ForecastDTO{}, err
+	}
+	var state *domain.SimulationState
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		state = current.Clone()
+		return nil, nil
+	})
+	if err != nil {
+		return ForecastDTO{}, err
+	}
+
+	result, err := domain.Forecast(state, spec)
+	if err != nil {
+		if errors.Is(err, domain.ErrTrainNotFound) || errors.Is(err, domain.ErrTrainSpeedNotPositive) || errors.Is(err, domain.ErrTickDeltaNotPositive) {
+			return ForecastDTO{}, fmt.Errorf("%w: %v", ErrInvalidForecast, err)
+		}
+		return ForecastDTO{}, err
+	}
+	return toForecastDTO(result), nil
+}
+
+// Subscribe はセッションのシミュレーションに関する通知を購読する。
+// 演習開始前でも購読できる（セッションが存在すればよい）。
+func (s *service) Subscribe(ctx context.Context, sessionID string) (<-chan Notification, func(), error) {
+	session, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch, cancel := s.notifications.Subscribe(session.ID().String())
+	return ch, cancel, nil
+}
+
+// ListBreakpoints はセッションのブレークポイントを登録順に返す。演習開始前から登録できる。
+func (s *service) ListBreakpoints(ctx context.Context, sessionID string) ([]BreakpointDTO, error) {
+	session, err := s.loadSession(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
+	return s.breakpoints.list(ctx, session.ID().String())
+}
 
-	initialTrain, err := domain.NewTrain(
-		initialTrainID,
-		initialBlock,
-		initialProgress,
-		true,
-		0.5,
-	)
+func (s *service) CreateBreakpoint(ctx context.Context, input CreateBreakpointInput) (BreakpointDTO, error) {
+	session, err := s.loadSession(ctx, input.SessionID)
 	if err != nil {
+		return BreakpointDTO{}, err
+	}
+	enabled := true
+	if input.Enabled != nil {
+		enabled = *input.Enabled
+	}
+	return s.breakpoints.create(ctx, session.ID().String(), input.Condition, enabled)
+}
+
+func (s *service) UpdateBreakpoint(ctx context.Context, input UpdateBreakpointInput) (BreakpointDTO, error) {
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return BreakpointDTO{}, err
+	}
+	input.SessionID = session.ID().String()
+	return s.breakpoints.update(ctx, input)
+}
+
+func (s *service) DeleteBreakpoint(ctx context.Context, sessionID string, breakpointID string) error {
+	session, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.breakpoints.delete(ctx, session.ID().String(), breakpointID)
+}
+
+// GetTimetable はセッションの計画ダイヤを返す（設定していなければ運行なし）
+func (s *service) GetTimetable(ctx context.Context, sessionID string) (TimetableDTO, error) {
+	session, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return TimetableDTO{}, err
+	}
+	var dto TimetableDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		timetable, err := s.diagrams.timetable(ctx, current.ID())
+		dto = toTimetableDTO(timetable)
 		return nil, err
+	})
+	return dto, err
+}
+
+// SetTimetable は運行図表に重ねる計画ダイヤを置き換える。停車駅は網の駅でなければならない。
+// 列車の進行には影響しないので入力ログには記録しない。
+func (s *service) SetTimetable(ctx context.Context, input SetTimetableInput) (TimetableDTO, error) {
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return TimetableDTO{}, err
 	}
-	if err := state.AddTrain(initialTrain); err != nil {
+	var dto TimetableDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		timetable, err := fromTimetableDTO(input.Timetable, current.Network())
+		if err != nil {
+			return nil, err
+		}
+		if err := s.diagrams.setTimetable(ctx, current.ID(), timetable); err != nil {
+			return nil, err
+		}
+		dto = toTimetableDTO(timetable)
+		return nil, nil
+	})
+	return dto, err
+}
+
+// GetDiagram は路線の運行図表（計画ダイヤと走行の記録）を返す。振り返り（DEBRIEF）でも取得できる。
+func (s *service) GetDiagram(ctx context.Context, input DiagramInput) (DiagramDTO, error) {
+	session, err := s.loadSession(ctx, input.SessionID)
+	if err != nil {
+		return DiagramDTO{}, err
+	}
+	var dto DiagramDTO
+	err = s.run(ctx, session, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+		lineID := current.Network().Lines()[0].ID
+		if input.LineID != "" {
+			id, err := domain.NewLineID(input.LineID)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidDiagramQuery, err)
+			}
+			if _, ok := current.Network().Line(id); !ok {
+				return nil, fmt.Errorf("%w: unknown line %s", ErrInvalidDiagramQuery, input.LineID)
+			}
+			lineID = id
+		}
+		var err error
+		dto, err = s.diagrams.diagram(ctx, current, lineID)
 		return nil, err
+	})
+	return dto, err
+}
+
+// withCheckpointIfDue は進めた時間が控えの間隔の区切りをまたいだら、入力の後に状態の控えを加える
+func withCheckpointIfDue(before domain.SimTime, state *domain.SimulationState, now time.Time, input domain.InputRecord) []domain.InputRecord {
+	if before.Millis()/checkpointIntervalMillis == state.SimTime().Millis()/checkpointIntervalMillis {
+		return []domain.InputRecord{input}
+	}
+	return []domain.InputRecord{input, domain.NewCheckpointInput(state, now)}
+}
+
+// saveWithInputs は入力を先にログへ追記してから状態を保存する。
+// 追記や保存に失敗したら追記した分を取り消すので、ログには保存した状態に至る入力だけが残る。
+func (s *service) saveWithInputs(ctx context.Context, state *domain.SimulationState, records ...domain.InputRecord) error {
+	saved := state.Version() + 1 // 保存でバージョンが1つ進む
+	appended := int64(-1)        // 最初に追記した記録の連番
+	for _, record := range records {
+		record, err := s.logs.Append(ctx, state.ID(), record.SavedAs(saved))
+		if err != nil {
+			return s.rollbackInputs(ctx, state.ID(), appended, fmt.Errorf("input log append failed: %w", err))
+		}
+		if appended < 0 {
+			appended = record.Seq
+		}
+	}
+	if err := s.repo.Save(ctx, state); err != nil {
+		return s.rollbackInputs(ctx, state.ID(), appended, err)
+	}
+	return nil
+}
+
+// rollbackInputs は連番 first 以降の記録を取り消し、cause に取り消しの失敗を添えて返す
+func (s *service) rollbackInputs(ctx context.Context, id domain.SimulationID, first int64, cause error) error {
+	if first < 0 {
+		return cause
+	}
+	if err := s.logs.TruncateAfter(ctx, id, first-1); err != nil {
+		return errors.Join(cause, fmt.Errorf("input log rollback failed: %w", err))
+	}
+	return cause
+}
+
+// run はセッションが所有するシミュレーションの actor で fn を実行する。
+// fn は同じシミュレーションへの他の指令と重ならず、受け付けた順に実行される。
+// 演習未開始（シミュレーションが無い）なら ErrSimulationNotStarted を返す。
+func (s *service) run(ctx context.Context, session *sessiondomain.TrainingSession, fn commandFunc) error {
+	sid := session.ID()
+
+	id, err := domain.NewSimulationID(sid.String())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSessionID, err)
+	}
+	if err := s.actors.do(ctx, id, fn); err != nil {
+		if errors.Is(err, domain.ErrSimulationNotFound) {
+			return fmt.Errorf("%w: %s", ErrSimulationNotStarted, sid.String())
+		}
+		return err
 	}
+	return nil
+}
 
-	if err := s.repo.Create(ctx, state); err != nil {
-		if errors.Is(err, domain.ErrSimulationAlreadyExists) {
-			return s.repo.Get(ctx)
+func (s *service) loadSession(ctx context.Context, sessionID string) (*sessiondomain.TrainingSession, error) {
+	sid, err := sessiondomain.NewSessionID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSessionID, err)
+	}
+	session, err := s.sessions.Get(ctx, sid)
+	if err != nil {
+		if errors.Is(err, sessiondomain.ErrSessionNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sid.String())
 		}
 		return nil, err
 	}
+	return session, nil
+}
+
+// reloadSession は actor の中でセッションを読み直す（指令を待つ間の参加・一時停止などを反映する）
+func (s *service) reloadSession(ctx context.Context, sid sessiondomain.SessionID) (*sessiondomain.TrainingSession, error) {
+	return s.loadSession(ctx, sid.String())
+}
 
-	return state, nil
+// requireSessionStatus は actor の中で読み直したセッションが allowed のいずれかの状態にあることを確かめる
+func (s *service) requireSessionStatus(ctx context.Context, sid sessiondomain.SessionID, allowed ...sessiondomain.SessionStatus) error {
+	session, err := s.reloadSession(ctx, sid)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allowed, session.Status()) {
+		return fmt.Errorf("%w: %s", ErrSessionNotRunning, session.Status())
+	}
+	return nil
 }
 
 func newTickDelta(deltaMillis int64) (domain.TickDelta, error) {
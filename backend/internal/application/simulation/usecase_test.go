@@ -2,20 +2,25 @@ package simulation
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	sessiondomain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
 	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
 	"github.com/right1121/railway-control-center-simulator/internal/infrastructure/memory"
+	"github.com/right1121/railway-control-center-simulator/pkg/apperr"
 )
 
-func TestGetSimulationCreatesStateOnFirstCall(t *testing.T) {
-	repo := memory.NewInMemorySimulationRepository()
-	line := testLine(t)
-	uc := NewUseCase(repo, &stubLineLoader{line: line})
+func TestGetSimulationReturnsInitialStateAfterStart(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
 
-	dto, err := uc.GetSimulation(context.Background())
+	dto, err := uc.GetSimulation(context.Background(), "room-a")
 	if err != nil {
 		t.Fatalf("GetSimulation failed: %v", err)
 	}
@@ -31,21 +36,122 @@ func TestGetSimulationCreatesStateOnFirstCall(t *testing.T) {
 	}
 }
 
-func TestGetSimulationReturnsErrorOnLineLoadFailure(t *testing.T) {
+func TestProvisionReturnsErrorOnLineLoadFailure(t *testing.T) {
 	repo := memory.NewInMemorySimulationRepository()
-	uc := NewUseCase(repo, &stubLineLoader{err: errors.New("broken json")})
+	logs := memory.NewInMemoryInputLogRepository()
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{err: errors.New("broken json")}, nil, NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository()), NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs), NewViewPublisher(), NewSimulationActors(repo))
 
-	if _, err := uc.GetSimulation(context.Background()); err == nil {
+	if err := provisioner.Provision(context.Background(), testSessionID(t, "room-a")); err == nil {
 		t.Fatalf("expected error on line load failure")
 	}
 }
 
-func TestTickAdvancesSimulation(t *testing.T) {
+func TestDisposeRemovesSimulation(t *testing.T) {
+	repo := memory.NewInMemorySimulationRepository()
+	logs := memory.NewInMemoryInputLogRepository()
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: testNetwork(t)}, nil, NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository()), NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs), NewViewPublisher(), NewSimulationActors(repo))
+	sid := testSessionID(t, "room-a")
+
+	if err := provisioner.Provision(context.Background(), sid); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	if err := provisioner.Dispose(context.Background(), sid); err != nil {
+		t.Fatalf("Dispose failed: %v", err)
+	}
+	if err := provisioner.Dispose(context.Background(), sid); err != nil {
+		t.Fatalf("expected Dispose to be idempotent, got %v", err)
+	}
+
+	id, _ := domain.NewSimulationID("room-a")
+	if _, err := repo.Get(context.Background(), id); !errors.Is(err, domain.ErrSimulationNotFound) {
+		t.Fatalf("expected ErrSimulationNotFound, got %v", err)
+	}
+}
+
+func TestProvisionRemovesSimulationWhenInputLogFails(t *testing.T) {
+	repo := memory.NewInMemorySimulationRepository()
+	logs := &failingInputLogRepository{InputLogRepository: memory.NewInMemoryInputLogRepository(), err: errors.New("disk full")}
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: testNetwork(t)}, nil, NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository()), NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs), NewViewPublisher(), NewSimulationActors(repo))
+	sid := testSessionID(t, "room-a")
+
+	if err := provisioner.Provision(context.Background(), sid); !errors.Is(err, logs.err) {
+		t.Fatalf("expected append error, got %v", err)
+	}
+	// 再生の起点を残せなかった状態は消え、やり直しの生成が重複にならない
+	id, _ := domain.NewSimulationID("room-a")
+	if _, err := repo.Get(context.Background(), id); !errors.Is(err, domain.ErrSimulationNotFound) {
+		t.Fatalf("expected ErrSimulationNotFound, got %v", err)
+	}
+	logs.err = nil
+	if err := provisioner.Provision(context.Background(), sid); err != nil {
+		t.Fatalf("retry Provision failed: %v", err)
+	}
+}
+
+func TestProvisionStartsFromTimetable(t *testing.T) {
+	repo := memory.NewInMemorySimulationRepository()
+	logs := memory.NewInMemoryInputLogRepository()
+	diagrams := NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs)
+	network := testNetwork(t)
+
+	s0, _ := domain.NewStationID("S0")
+	s1, _ := domain.NewStationID("S1")
+	trainID, _ := domain.NewTrainID("101")
+	serviceID, _ := domain.NewServiceID("101M")
+	depart, _ := domain.NewSimTime((6 * time.Hour).Milliseconds())
+	arrive, _ := domain.NewSimTime((6*time.Hour + 2*time.Minute).Milliseconds())
+	timetable, err := domain.NewTimetable([]domain.Service{{ID: serviceID, Train: trainID, Stops: []domain.ServiceStop{
+		{Station: s0, Arrival: depart, Departure: depart},
+		{Station: s1, Arrival: arrive, Departure: arrive},
+	}}})
+	if err != nil {
+		t.Fatalf("timetable failed: %v", err)
+	}
+	block, _ := network.BlockAt(0)
+	progress, _ := domain.NewBlockProgress(0)
+	train, _ := domain.NewTrain(trainID, block, progress, true, 1.0/120)
+	loader := &stubTimetableLoader{timetable: timetable, trains: []*domain.Train{train}}
+
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: network}, loader, NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository()), diagrams, NewViewPublisher(), NewSimulationActors(repo))
+	if err := provisioner.Provision(context.Background(), testSessionID(t, "room-a")); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+
+	id, _ := domain.NewSimulationID("room-a")
+	state, err := repo.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get simulation failed: %v", err)
+	}
+	if state.SimTime() != depart {
+		t.Fatalf("expected start at first departure %d, got %d", depart.Millis(), state.SimTime().Millis())
+	}
+	trains := state.Trains()
+	if len(trains) != 1 || trains[0].ID() != trainID {
+		t.Fatalf("expected only the timetable train, got %+v", trains)
+	}
+	if got, err := diagrams.timetable(context.Background(), id); err != nil || got == nil || len(got.Services()) != 1 {
+		t.Fatalf("expected the timetable to be set for the diagram, got %+v", got)
+	}
+}
+
+func TestProvisionRejectsTimetableOffTheNetwork(t *testing.T) {
 	repo := memory.NewInMemorySimulationRepository()
-	line := testLine(t)
-	uc := NewUseCase(repo, &stubLineLoader{line: line})
+	unknown, _ := domain.NewStationID("X9")
+	s0, _ := domain.NewStationID("S0")
+	serviceID, _ := domain.NewServiceID("1")
+	timetable, _ := domain.NewTimetable([]domain.Service{{ID: serviceID, Stops: []domain.ServiceStop{{Station: s0}, {Station: unknown}}}})
+
+	logs := memory.NewInMemoryInputLogRepository()
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: testNetwork(t)}, &stubTimetableLoader{timetable: timetable}, NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository()), NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs), NewViewPublisher(), NewSimulationActors(repo))
+	if err := provisioner.Provision(context.Background(), testSessionID(t, "room-a")); !errors.Is(err, domain.ErrServiceStationNotFound) {
+		t.Fatalf("expected %v, got %v", domain.ErrServiceStationNotFound, err)
+	}
+}
+
+func TestTickAdvancesSimulation(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
 
-	dto, err := uc.Tick(context.Background(), TickInput{DeltaMillis: 1000})
+	dto, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000})
 	if err != nil {
 		t.Fatalf("Tick failed: %v", err)
 	}
@@ -58,53 +164,1841 @@ func TestTickAdvancesSimulation(t *testing.T) {
 	}
 }
 
+func TestGetSimulationReadsPublishedViewWithoutWaitingForUpdates(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	if _, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	// actor が指令を実行している最中でも、読み取りは公開済みの状態をすぐ返す
+	release := blockActor(t, uc, "room-a")
+	defer close(release)
+
+	done := make(chan SimulationDTO, 1)
+	go func() {
+		dto, err := uc.GetSimulation(context.Background(), "room-a")
+		if err != nil {
+			t.Errorf("GetSimulation failed: %v", err)
+		}
+		done <- dto
+	}()
+
+	select {
+	case dto := <-done:
+		if dto.SimTimeMillis != 1000 {
+			t.Fatalf("expected the ticked view, got sim time %d", dto.SimTimeMillis)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("GetSimulation blocked while the actor was busy")
+	}
+}
+
+func TestTickReusesPublishedNetworkView(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	first, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	second, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	// 路線網の部分は作り直さずに前回の公開と共有し、列車は刻みごとに作る
+	if &first.Line.StationDetails[0] != &second.Line.StationDetails[0] || &first.Lines[0].Blocks[0] != &second.Lines[0].Blocks[0] {
+		t.Fatalf("expected the network view to be reused between ticks")
+	}
+	if &first.Trains[0] == &second.Trains[0] || first.Trains[0].Progress == second.Trains[0].Progress {
+		t.Fatalf("expected trains to be rebuilt per tick: %+v %+v", first.Trains[0], second.Trains[0])
+	}
+	// 網の構成と Revision も網を公開したときのものを返し、要求ごとに作り直さない
+	topology, err := uc.GetTopology(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetTopology failed: %v", err)
+	}
+	again, err := uc.GetTopology(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetTopology failed: %v", err)
+	}
+	if topology.Revision == "" || topology.Revision != again.Revision || &topology.Lines[0] != &again.Lines[0] || &topology.Lines[0].Blocks[0] != &second.Lines[0].Blocks[0] {
+		t.Fatalf("expected the topology to be built once per network")
+	}
+	doc, err := uc.ExportSnapshot(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	id, _ := domain.NewSimulationID("room-a")
+	state, err := fromSnapshotDocument(doc, id, second.Version)
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if fresh := toSimulationDTO(state); !reflect.DeepEqual(fresh, second) {
+		t.Fatalf("published view differs from a freshly built one:\n%+v\n%+v", second, fresh)
+	}
+}
+
+func TestGetChangesReturnsOnlyWhatChangedSinceVersion(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ticked, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	// T0 が B0 を出て B1 に入る
+	if _, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 2000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	delta, err := uc.GetChanges(context.Background(), ChangesInput{SessionID: "room-a", SinceVersion: ticked.Version})
+	if err != nil {
+		t.Fatalf("GetChanges failed: %v", err)
+	}
+	if delta.Full || delta.Version != ticked.Version+1 || len(delta.Trains) != 1 {
+		t.Fatalf("unexpected delta: %+v", delta)
+	}
+	want := []BlockStateDTO{{ID: "B0"}, {ID: "B1", OccupiedBy: "T0"}}
+	if !reflect.DeepEqual(delta.Blocks, want) {
+		t.Fatalf("expected blocks %+v, got %+v", want, delta.Blocks)
+	}
+
+	// 保持していない版からは全量を返す
+	full, err := uc.GetChanges(context.Background(), ChangesInput{SessionID: "room-a", SinceVersion: 999})
+	if err != nil {
+		t.Fatalf("GetChanges failed: %v", err)
+	}
+	if !full.Full || len(full.Blocks) != 2 || len(full.Trains) != 1 {
+		t.Fatalf("expected a full delta, got %+v", full)
+	}
+}
+
+func TestGetChangesWaitsForTheNextUpdate(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	current, err := uc.GetSimulation(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+
+	// 更新が無ければ待ちきって空の差分を返す
+	idle, err := uc.GetChanges(context.Background(), ChangesInput{SessionID: "room-a", SinceVersion: current.Version, WaitMillis: 20})
+	if err != nil {
+		t.Fatalf("GetChanges failed: %v", err)
+	}
+	if idle.Version != current.Version || len(idle.Trains) != 0 || len(idle.Blocks) != 0 {
+		t.Fatalf("expected an empty delta, got %+v", idle)
+	}
+
+	done := make(chan SimulationDeltaDTO, 1)
+	go func() {
+		delta, err := uc.GetChanges(context.Background(), ChangesInput{SessionID: "room-a", SinceVersion: current.Version, WaitMillis: 5000})
+		if err != nil {
+			t.Errorf("GetChanges failed: %v", err)
+		}
+		done <- delta
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	select {
+	case delta := <-done:
+		if delta.Version != current.Version+1 || len(delta.Trains) != 1 {
+			t.Fatalf("expected the tick in the delta, got %+v", delta)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("GetChanges did not wake up on the tick")
+	}
+
+	if _, err := uc.GetChanges(context.Background(), ChangesInput{SessionID: "room-a", SinceVersion: 0, WaitMillis: maxChangesWaitMillis + 1}); !errors.Is(err, ErrInvalidChangesQuery) {
+		t.Fatalf("expected ErrInvalidChangesQuery, got %v", err)
+	}
+}
+
+func TestConcurrentTicksAreAppliedOneAfterAnother(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 100})
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+	}
+
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if doc.SimTimeMillis != n*100 {
+		t.Fatalf("expected sim time %d, got %d", n*100, doc.SimTimeMillis)
+	}
+	result, err := uc.VerifyReplay(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !result.Consistent || result.InputCount != n+1 {
+		t.Fatalf("expected a consistent log of %d inputs, got %+v", n+1, result)
+	}
+}
+
+func TestTickGivesUpWhenContextEndsWhileQueued(t *testing.T) {
+	uc := newTestUseCase(t, "room-a", "room-b")
+	release := blockActor(t, uc, "room-a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	// 別のセッションの actor は待たされない
+	if _, err := uc.Tick(context.Background(), TickInput{SessionID: "room-b", DeltaMillis: 1000}); err != nil {
+		t.Fatalf("Tick on another session failed: %v", err)
+	}
+
+	close(release)
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if doc.SimTimeMillis != 0 {
+		t.Fatalf("expected the abandoned tick not to run, got sim time %d", doc.SimTimeMillis)
+	}
+}
+
 func TestTickReturnsValidationErrorWhenDeltaIsNotPositive(t *testing.T) {
-	repo := memory.NewInMemorySimulationRepository()
-	line := testLine(t)
-	uc := NewUseCase(repo, &stubLineLoader{line: line})
+	uc := newTestUseCase(t, "room-a")
 
-	_, err := uc.Tick(context.Background(), TickInput{DeltaMillis: 0})
+	_, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 0})
 	if !errors.Is(err, ErrInvalidTickDelta) {
 		t.Fatalf("expected ErrInvalidTickDelta, got %v", err)
 	}
 }
 
 func TestTickReturnsValidationErrorWhenDeltaOverflowsDuration(t *testing.T) {
-	repo := memory.NewInMemorySimulationRepository()
-	line := testLine(t)
-	uc := NewUseCase(repo, &stubLineLoader{line: line})
+	uc := newTestUseCase(t, "room-a")
 
-	_, err := uc.Tick(context.Background(), TickInput{DeltaMillis: math.MaxInt64})
+	_, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: math.MaxInt64})
 	if !errors.Is(err, ErrInvalidTickDelta) {
 		t.Fatalf("expected ErrInvalidTickDelta, got %v", err)
 	}
 }
 
-type stubLineLoader struct {
-	line *domain.Line
-	err  error
+func TestTickKeepsSimulationsIndependentPerSession(t *testing.T) {
+	uc := newTestUseCase(t, "room-a", "room-b")
+
+	if _, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	other, err := uc.GetSimulation(context.Background(), "room-b")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	if other.SessionID != "room-b" {
+		t.Fatalf("expected session room-b, got %s", other.SessionID)
+	}
+	if other.SimTimeMillis != 0 {
+		t.Fatalf("expected room-b sim time 0, got %d", other.SimTimeMillis)
+	}
+}
+
+func TestGetSimulationReturnsNotFoundForUnknownSession(t *testing.T) {
+	uc := newTestUseCase(t)
+
+	_, err := uc.GetSimulation(context.Background(), "missing")
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
 }
 
-func (s *stubLineLoader) Load(ctx context.Context) (*domain.Line, error) {
-	_ = ctx
-	if s.err != nil {
-		return nil, s.err
+func TestGetSimulationReturnsNotStartedWhileInLobby(t *testing.T) {
+	sessions := memory.NewInMemorySessionRepository()
+	createTestSession(t, sessions, "room-a")
+	repo := memory.NewInMemorySimulationRepository()
+	logs := memory.NewInMemoryInputLogRepository()
+	uc := NewUseCase(repo, logs, sessions, NewNotificationHub(), NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository()), NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs), NewViewPublisher(), NewSimulationActors(repo))
+
+	_, err := uc.GetSimulation(context.Background(), "room-a")
+	if !errors.Is(err, ErrSimulationNotStarted) {
+		t.Fatalf("expected ErrSimulationNotStarted, got %v", err)
 	}
-	return s.line, nil
 }
 
-func testLine(t *testing.T) *domain.Line {
-	t.Helper()
+func TestTickRejectedWhenSessionIsPaused(t *testing.T) {
+	sessions := memory.NewInMemorySessionRepository()
+	uc := newTestUseCaseWithSessions(t, sessions, "room-a")
+
+	session, _ := sessions.Get(context.Background(), testSessionID(t, "room-a"))
+	if err := session.Pause(time.Now()); err != nil {
+		t.Fatalf("pause failed: %v", err)
+	}
+	if err := sessions.Save(context.Background(), session); err != nil {
+		t.Fatalf("save session failed: %v", err)
+	}
+
+	_, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000})
+	if !errors.Is(err, ErrSessionNotRunning) {
+		t.Fatalf("expected ErrSessionNotRunning, got %v", err)
+	}
+}
+
+func TestTickRejectsStaleExpectedVersion(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	dto, err := uc.GetSimulation(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	read := dto.Version
+
+	ticked, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000, ExpectedVersion: &read})
+	if err != nil {
+		t.Fatalf("Tick with current version failed: %v", err)
+	}
+	if ticked.Version != read+1 {
+		t.Fatalf("expected version %d, got %d", read+1, ticked.Version)
+	}
+
+	_, err = uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 1000, ExpectedVersion: &read})
+	if !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
+
+func TestExportSnapshotCanBeImportedIntoAnotherSession(t *testing.T) {
+	uc := newTestUseCase(t, "room-a", "room-b")
+
+	// B0 終端まで進めて折返し待ちを作る
+	if _, err := uc.Tick(context.Background(), TickInput{SessionID: "room-a", DeltaMillis: 5000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if len(doc.PendingTurnbacks) != 1 || len(doc.Occupancy) != 1 {
+		t.Fatalf("unexpected snapshot document: %+v", doc)
+	}
+
+	imported, err := uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-b", Document: doc})
+	if err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	if imported.SessionID != "room-b" {
+		t.Fatalf("expected session room-b, got %s", imported.SessionID)
+	}
+	if imported.SimTimeMillis != 5000 {
+		t.Fatalf("expected sim time 5000, got %d", imported.SimTimeMillis)
+	}
+	if !imported.Trains[0].PendingTurnback {
+		t.Fatalf("expected pending turnback to be imported")
+	}
+}
+
+func TestImportedNetworkGroupsTrainsByLine(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	state := testNetworkState(t)
+	doc := toSnapshotDocument(state, time.Now())
+	if len(doc.Lines) != 2 || len(doc.Line.Stations) != 0 {
+		t.Fatalf("expected the document to list the lines, got %+v", doc)
+	}
+
+	dto, err := uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-a", Document: doc})
+	if err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+
+	if len(dto.Lines) != 2 || dto.Lines[0].ID != "RED" || dto.Lines[1].ID != "BLUE" {
+		t.Fatalf("unexpected lines: %+v", dto.Lines)
+	}
+	if len(dto.Lines[0].Trains) != 1 || dto.Lines[0].Trains[0].ID != "T0" {
+		t.Fatalf("expected T0 on RED, got %+v", dto.Lines[0].Trains)
+	}
+	if len(dto.Lines[1].Trains) != 1 || dto.Lines[1].Trains[0].ID != "T1" {
+		t.Fatalf("expected T1 on BLUE, got %+v", dto.Lines[1].Trains)
+	}
+	if !reflect.DeepEqual(dto.Interchanges, []string{"X"}) {
+		t.Fatalf("expected X to be the interchange, got %v", dto.Interchanges)
+	}
+	if !reflect.DeepEqual(dto.Line.Stations, []string{"A0", "X", "A2", "B0", "B2"}) {
+		t.Fatalf("expected every station once, got %v", dto.Line.Stations)
+	}
+
+	topology, err := uc.GetTopology(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("GetTopology failed: %v", err)
+	}
+	want := []TransferDTO{{FromStationID: "B0", ToStationID: "A0", MinTransferMillis: 180_000}}
+	if len(topology.Lines) != 2 || !reflect.DeepEqual(topology.Transfers, want) {
+		t.Fatalf("unexpected topology: %+v", topology)
+	}
+}
+
+func TestSnapshotDocumentCarriesLineMetadata(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
 
 	s0, _ := domain.NewStationID("S0")
 	s1, _ := domain.NewStationID("S1")
-	s2, _ := domain.NewStationID("S2")
 	b0, _ := domain.NewBlockID("B0")
-	b1, _ := domain.NewBlockID("B1")
-
-	line, err := domain.NewLine([]domain.StationID{s0, s1, s2}, []domain.BlockID{b0, b1})
+	line, err := domain.NewLineWithInfo(
+		[]domain.StationID{s0, s1},
+		[]domain.BlockID{b0},
+		[]domain.StationInfo{{Name: "東京", NameRomaji: "Tokyo", Code: "T01", HasKilometerPost: true, Platforms: 4}, {}},
+		[]domain.BlockInfo{{TrackNumber: "1", Electrification: "DC1500V", GradientPermille: 2.5}},
+	)
 	if err != nil {
 		t.Fatalf("line build failed: %v", err)
 	}
-	return line
+	id, _ := domain.NewSimulationID("room-a")
+	state, err := domain.NewSimulationState(id, line)
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+
+	doc := toSnapshotDocument(state, time.Now())
+	if doc.Line.Stations[0].Name != "東京" || doc.Line.Blocks[0].Electrification != "DC1500V" {
+		t.Fatalf("expected the document to carry metadata, got %+v", doc.Line)
+	}
+	dto, err := uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-a", Document: doc})
+	if err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+
+	origin := 0.0
+	wantStations := []StationInfoDTO{{ID: "S0", Name: "東京", NameRomaji: "Tokyo", Code: "T01", KilometerPost: &origin, Platforms: 4}, {ID: "S1"}}
+	if !reflect.DeepEqual(dto.Line.StationDetails, wantStations) {
+		t.Fatalf("unexpected station details: %+v", dto.Line.StationDetails)
+	}
+	// 起点の 0 km は省かず、キロ程の分からない駅だけ省く
+	encoded, err := json.Marshal(dto.Line.StationDetails)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if got := string(encoded); !strings.Contains(got, `"kilometerPost":0`) || strings.Count(got, "kilometerPost") != 1 {
+		t.Fatalf("expected only the origin to carry its kilometer post, got %s", got)
+	}
+	wantBlocks := []BlockInfoDTO{{ID: "B0", TrackNumber: "1", Electrification: "DC1500V", GradientPermille: 2.5}}
+	if !reflect.DeepEqual(dto.Lines[0].BlockDetails, wantBlocks) {
+		t.Fatalf("unexpected block details: %+v", dto.Lines[0].BlockDetails)
+	}
+}
+
+func TestSnapshotDocumentKeepsMinimalLineFormat(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	data, err := json.Marshal(doc.Line)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	// 案内用の情報の無い路線は id と両端の駅だけの形のまま
+	want := `{"stations":[{"id":"S0"},{"id":"S1"},{"id":"S2"}],"blocks":[{"id":"B0","fromStationId":"S0","toStationId":"S1"},{"id":"B1","fromStationId":"S1","toStationId":"S2"}]}`
+	if string(data) != want {
+		t.Fatalf("unexpected line JSON:\n%s", data)
+	}
+}
+
+func TestTopologyIncludesGeneratedSchematic(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	topology, err := uc.GetTopology(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("GetTopology failed: %v", err)
+	}
+	schematic := topology.Schematic
+	if schematic == nil || !schematic.Generated || len(schematic.Stations) != 3 || len(schematic.Blocks) != 2 {
+		t.Fatalf("expected a generated schematic for every station and block, got %+v", schematic)
+	}
+	if got := schematic.Blocks[1].Polyline; !reflect.DeepEqual(got, []PointDTO{{X: 100}, {X: 200}}) {
+		t.Fatalf("unexpected B1 polyline: %+v", got)
+	}
+	if len(topology.Lines) != 1 || !reflect.DeepEqual(topology.Lines[0].Schematic, schematic) {
+		t.Fatalf("expected the line schematic to match the network schematic, got %+v", topology.Lines)
+	}
+}
+
+func TestImportedSnapshotKeepsSchematicLayout(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	for i := range doc.Line.Stations {
+		doc.Line.Stations[i].Position = &PointDTO{X: float64(i) * 50, Y: 10}
+	}
+	doc.Line.Blocks[1].Polyline = []PointDTO{{X: 50, Y: 10}, {X: 75, Y: 30}, {X: 100, Y: 10}}
+
+	if _, err := uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-a", Document: doc}); err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	topology, err := uc.GetTopology(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("GetTopology failed: %v", err)
+	}
+	schematic := topology.Schematic
+	if schematic.Generated || schematic.Stations[2].Position != (PointDTO{X: 100, Y: 10}) {
+		t.Fatalf("expected the imported layout, got %+v", schematic)
+	}
+	// 省いた区間の線は両側の駅を直線で結ぶ
+	if got := schematic.Blocks[0].Polyline; !reflect.DeepEqual(got, []PointDTO{{Y: 10}, {X: 50, Y: 10}}) {
+		t.Fatalf("unexpected B0 polyline: %+v", got)
+	}
+	if got := schematic.Blocks[1].Polyline; len(got) != 3 {
+		t.Fatalf("unexpected B1 polyline: %+v", got)
+	}
+
+	exported, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if exported.Line.Stations[1].LabelAnchor == nil || exported.Line.Blocks[0].SignalLabelAnchor == nil {
+		t.Fatalf("expected the exported document to carry the layout, got %+v", exported.Line)
+	}
+}
+
+func TestImportSnapshotRejectsInconsistentOccupancy(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	doc.Occupancy = []OccupancyDTO{{BlockID: "B1", TrainID: "T0"}}
+
+	_, err = uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-a", Document: doc})
+	if !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot, got %v", err)
+	}
+}
+
+func TestImportSnapshotRejectsDuplicateOccupancy(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	// 2本目の列車の在線を1本目の在線の重複で置き換えると、件数は合うが B1 が抜ける
+	doc.Trains = append(doc.Trains, TrainDTO{ID: "T1", BlockID: "B1", Progress: 0.5, Forward: false, Speed: 0.5})
+	doc.Occupancy = append(doc.Occupancy, doc.Occupancy[0])
+
+	_, err = uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-a", Document: doc})
+	if !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot, got %v", err)
+	}
+}
+
+func TestImportSnapshotRejectsUnknownBlock(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+
+	doc, err := uc.ExportSnapshot(context.Background(), "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	doc.Trains[0].BlockID = "B9"
+	doc.Occupancy[0].BlockID = "B9"
+
+	_, err = uc.ImportSnapshot(context.Background(), ImportSnapshotInput{SessionID: "room-a", Document: doc})
+	if !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot, got %v", err)
+	}
+}
+
+func TestVerifyReplayReproducesRecordedState(t *testing.T) {
+	uc := newTestUseCase(t, "room-a", "room-b")
+	ctx := context.Background()
+
+	for _, delta := range []int64{700, 1300, 2500, 333, 4000} {
+		if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: delta}); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+	}
+	doc, err := uc.ExportSnapshot(ctx, "room-b")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if _, err := uc.ImportSnapshot(ctx, ImportSnapshotInput{SessionID: "room-a", Document: doc}); err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 900}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	inputs, err := uc.GetInputLog(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetInputLog failed: %v", err)
+	}
+	if len(inputs) != 8 || inputs[0].Kind != "INITIALIZED" || inputs[6].Kind != "RESTORED" {
+		t.Fatalf("unexpected input log: %+v", inputs)
+	}
+
+	result, err := uc.VerifyReplay(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !result.Consistent {
+		t.Fatalf("expected replay to be consistent, got differences %v", result.Differences)
+	}
+	if result.Replayed.SimTimeMillis != 900 {
+		t.Fatalf("expected replayed sim time 900, got %d", result.Replayed.SimTimeMillis)
+	}
+}
+
+func TestRewindRestoresPastStateAndNotifiesSubscribers(t *testing.T) {
+	uc := newTestUseCase(t, "room-a", "room-b")
+	ctx := context.Background()
+
+	events, cancel, err := uc.Subscribe(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	// チェックポイント（60秒ごと）を跨いで進める
+	for range 5 {
+		if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 30_000}); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+	}
+	for _, target := range []int64{90_000, 30_000} {
+		result, err := uc.Rewind(ctx, RewindInput{SessionID: "room-a", TargetSimTimeMillis: target})
+		if err != nil {
+			t.Fatalf("Rewind to %d failed: %v", target, err)
+		}
+		if result.Simulation.SimTimeMillis != target {
+			t.Fatalf("expected sim time %d, got %d", target, result.Simulation.SimTimeMillis)
+		}
+
+		n := <-events
+		if n.Type != NotificationRewound || n.SimTimeMillis != target || n.Version != result.Simulation.Version {
+			t.Fatalf("unexpected notification: %+v", n)
+		}
+	}
+
+	// 巻き戻した時点の状態は、同じ時刻まで素直に進めた状態と一致する
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-b", DeltaMillis: 30_000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	rewound, err := uc.GetSimulation(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	straight, err := uc.GetSimulation(ctx, "room-b")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	if rewound.Trains[0] != straight.Trains[0] {
+		t.Fatalf("expected rewound train %+v, got %+v", straight.Trains[0], rewound.Trains[0])
+	}
+
+	result, err := uc.VerifyReplay(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !result.Consistent {
+		t.Fatalf("expected replay to be consistent, got differences %v", result.Differences)
+	}
+}
+
+func TestRewindRejectsTargetOutsideHistory(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	// 範囲外のほか、Tick の途中の時刻にも戻れない
+	for _, target := range []int64{-1, 1001, 500} {
+		_, err := uc.Rewind(ctx, RewindInput{SessionID: "room-a", TargetSimTimeMillis: target})
+		if !errors.Is(err, ErrInvalidRewindTarget) {
+			t.Fatalf("target %d: expected ErrInvalidRewindTarget, got %v", target, err)
+		}
+	}
+}
+
+func TestForecastDoesNotChangeLiveSimulation(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	before, err := uc.GetSimulation(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+
+	forecast, err := uc.Forecast(ctx, ForecastInput{
+		SessionID:     "room-a",
+		HorizonMillis: 10_000,
+		Commands: []HypotheticalCommandDTO{
+			{Type: HypotheticalHoldTrain, TrainID: "T0", DurationMillis: 3000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Forecast failed: %v", err)
+	}
+	if forecast.UntilSimTimeMillis != 10_000 || forecast.Final.SimTimeMillis != 10_000 {
+		t.Fatalf("expected forecast until 10000, got %+v", forecast)
+	}
+	// 抑止がなければ T0 は 2 秒で S1 に着く
+	if len(forecast.Delays) == 0 || forecast.Delays[0].StationID != "S1" || forecast.Delays[0].DelayMillis != 3000 {
+		t.Fatalf("expected 3s delay at S1, got %+v", forecast.Delays)
+	}
+
+	after, err := uc.GetSimulation(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	if after.Version != before.Version || after.SimTimeMillis != before.SimTimeMillis || after.Trains[0] != before.Trains[0] {
+		t.Fatalf("expected live simulation untouched, before %+v after %+v", before, after)
+	}
+	inputs, err := uc.GetInputLog(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetInputLog failed: %v", err)
+	}
+	if len(inputs) != 1 {
+		t.Fatalf("expected forecast not to be logged, got %+v", inputs)
+	}
+}
+
+func TestForecastRejectsInvalidCommands(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	for _, command := range []HypotheticalCommandDTO{
+		{Type: "TELEPORT", TrainID: "T0"},
+		{Type: HypotheticalHoldTrain, TrainID: "T9", DurationMillis: 1000},
+		{Type: HypotheticalSetSpeed, TrainID: "T0", Speed: 0},
+	} {
+		_, err := uc.Forecast(ctx, ForecastInput{SessionID: "room-a", HorizonMillis: 1000, Commands: []HypotheticalCommandDTO{command}})
+		if !errors.Is(err, ErrInvalidForecast) {
+			t.Fatalf("command %+v: expected ErrInvalidForecast, got %v", command, err)
+		}
+	}
+}
+
+func TestFastForwardStopsOnConditionAndIsReplayable(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	result, err := uc.FastForward(ctx, FastForwardInput{
+		SessionID:          "room-a",
+		UntilSimTimeMillis: 3_600_000,
+		StepMillis:         250,
+		Conditions: []StopConditionDTO{
+			{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("FastForward failed: %v", err)
+	}
+	if result.StopReason.Kind != "TRAIN_REACHED_STATION" || result.StopReason.Event == nil || result.StopReason.Event.StationID != "S2" {
+		t.Fatalf("unexpected stop reason: %+v", result.StopReason)
+	}
+	if result.ToSimTimeMillis != 4000 || result.Simulation.SimTimeMillis != 4000 || result.Steps != 16 {
+		t.Fatalf("expected stop at 4000ms after 16 steps, got %+v", result)
+	}
+
+	replay, err := uc.VerifyReplay(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !replay.Consistent {
+		t.Fatalf("expected replay to be consistent, got differences %v", replay.Differences)
+	}
+}
+
+func TestFastForwardPausesSessionAtBreakpoint(t *testing.T) {
+	sessions := memory.NewInMemorySessionRepository()
+	uc := newTestUseCaseWithSessions(t, sessions, "room-a")
+	ctx := context.Background()
+
+	events, cancel, err := uc.Subscribe(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	condition := StopConditionDTO{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S1"}
+	bp, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "room-a", Condition: condition})
+	if err != nil {
+		t.Fatalf("CreateBreakpoint failed: %v", err)
+	}
+
+	// 早送りの条件（S2 着）より先にブレークポイント（S1 着）が成立する
+	result, err := uc.FastForward(ctx, FastForwardInput{
+		SessionID:          "room-a",
+		UntilSimTimeMillis: 3_600_000,
+		StepMillis:         250,
+		Conditions: []StopConditionDTO{
+			{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("FastForward failed: %v", err)
+	}
+	if result.ToSimTimeMillis != 2000 || result.StopReason.ConditionIndex != -1 || result.Breakpoint == nil || result.Breakpoint.BreakpointID != bp.ID {
+		t.Fatalf("expected stop at the breakpoint at 2000ms, got %+v", result)
+	}
+
+	session, err := sessions.Get(ctx, testSessionID(t, "room-a"))
+	if err != nil {
+		t.Fatalf("get session failed: %v", err)
+	}
+	if session.Status() != sessiondomain.SessionStatusPaused {
+		t.Fatalf("expected session to be paused, got %s", session.Status())
+	}
+	n := <-events
+	if hit, ok := n.Payload.(BreakpointHitDTO); n.Type != NotificationPaused || !ok || hit.BreakpointID != bp.ID {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+	listed, err := uc.ListBreakpoints(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ListBreakpoints failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].HitCount != 1 || listed[0].LastHitSimTimeMillis == nil || *listed[0].LastHitSimTimeMillis != 2000 {
+		t.Fatalf("unexpected breakpoints: %+v", listed)
+	}
+}
+
+func TestFastForwardRechecksSessionStatusInsideActor(t *testing.T) {
+	sessions := memory.NewInMemorySessionRepository()
+	uc := newTestUseCaseWithSessions(t, sessions, "room-a")
+	ctx := context.Background()
+
+	release := blockActor(t, uc, "room-a")
+	done := make(chan error, 1)
+	go func() {
+		_, err := uc.FastForward(ctx, FastForwardInput{SessionID: "room-a", UntilSimTimeMillis: 5000})
+		done <- err
+	}()
+	// 早送りが actor を待っている間に一時停止される
+	time.Sleep(20 * time.Millisecond)
+	session, err := sessions.Get(ctx, testSessionID(t, "room-a"))
+	if err != nil {
+		t.Fatalf("get session failed: %v", err)
+	}
+	if err := session.Pause(time.Now()); err != nil {
+		t.Fatalf("pause failed: %v", err)
+	}
+	if err := sessions.Save(ctx, session); err != nil {
+		t.Fatalf("save session failed: %v", err)
+	}
+	close(release)
+
+	if err := <-done; !errors.Is(err, ErrSessionNotRunning) {
+		t.Fatalf("expected ErrSessionNotRunning, got %v", err)
+	}
+	doc, err := uc.ExportSnapshot(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if doc.SimTimeMillis != 0 {
+		t.Fatalf("expected the paused session not to advance, got sim time %d", doc.SimTimeMillis)
+	}
+}
+
+func TestFastForwardRejectsInvalidRequests(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	for _, input := range []FastForwardInput{
+		{SessionID: "room-a", UntilSimTimeMillis: 0},
+		{SessionID: "room-a", UntilSimTimeMillis: 1000, StepMillis: -1},
+		{SessionID: "room-a", UntilSimTimeMillis: 1000, Conditions: []StopConditionDTO{{Type: StopConditionEvent, EventType: "EXPLODED"}}},
+		{SessionID: "room-a", UntilSimTimeMillis: 1000, Conditions: []StopConditionDTO{{Type: StopConditionTrainBlockedFor}}},
+	} {
+		if _, err := uc.FastForward(ctx, input); !errors.Is(err, ErrInvalidFastForward) {
+			t.Fatalf("input %+v: expected ErrInvalidFastForward, got %v", input, err)
+		}
+	}
+}
+
+func TestTickPausesSessionAtBreakpoint(t *testing.T) {
+	sessions := memory.NewInMemorySessionRepository()
+	uc := newTestUseCaseWithSessions(t, sessions, "room-a")
+	ctx := context.Background()
+
+	events, cancel, err := uc.Subscribe(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	condition := StopConditionDTO{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S1"}
+	bp, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "room-a", Condition: condition})
+	if err != nil {
+		t.Fatalf("CreateBreakpoint failed: %v", err)
+	}
+	if !bp.Enabled || bp.ID == "" {
+		t.Fatalf("unexpected breakpoint: %+v", bp)
+	}
+
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1500}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	session, err := sessions.Get(ctx, testSessionID(t, "room-a"))
+	if err != nil {
+		t.Fatalf("get session failed: %v", err)
+	}
+	if session.Status() != sessiondomain.SessionStatusPaused {
+		t.Fatalf("expected session to be paused, got %s", session.Status())
+	}
+
+	n := <-events
+	hit, ok := n.Payload.(BreakpointHitDTO)
+	if n.Type != NotificationPaused || !ok || hit.BreakpointID != bp.ID || hit.Condition != condition {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+	if hit.Reason.Event == nil || hit.Reason.Event.StationID != "S1" {
+		t.Fatalf("unexpected reason: %+v", hit.Reason)
+	}
+
+	// 一時停止中は Tick できない
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); !errors.Is(err, ErrSessionNotRunning) {
+		t.Fatalf("expected ErrSessionNotRunning, got %v", err)
+	}
+
+	listed, err := uc.ListBreakpoints(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ListBreakpoints failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].HitCount != 1 || listed[0].LastHitSimTimeMillis == nil || *listed[0].LastHitSimTimeMillis != 2500 {
+		t.Fatalf("unexpected breakpoints: %+v", listed)
+	}
+}
+
+func TestTickPausesAtBreakpointAfterConcurrentSessionUpdate(t *testing.T) {
+	sessions := &racingSessionRepository{Repository: memory.NewInMemorySessionRepository(), races: 1}
+	uc := newTestUseCaseWithSessions(t, sessions, "room-a")
+	ctx := context.Background()
+
+	condition := StopConditionDTO{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S1"}
+	if _, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "room-a", Condition: condition}); err != nil {
+		t.Fatalf("CreateBreakpoint failed: %v", err)
+	}
+
+	// 一時停止の保存の直前に管制員が参加しても、読み直して止める
+	dto, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 2500})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	session, err := sessions.Get(ctx, testSessionID(t, "room-a"))
+	if err != nil {
+		t.Fatalf("get session failed: %v", err)
+	}
+	if session.Status() != sessiondomain.SessionStatusPaused || len(session.Dispatchers()) != 1 {
+		t.Fatalf("expected paused session with the joined dispatcher, got %s %d", session.Status(), len(session.Dispatchers()))
+	}
+	if got, err := uc.GetSimulation(ctx, "room-a"); err != nil || got.Version != dto.Version || got.SimTimeMillis != 2500 {
+		t.Fatalf("unexpected published view: %+v %v", got, err)
+	}
+}
+
+func TestTickPublishesSavedStateWhenPauseFails(t *testing.T) {
+	sessions := &racingSessionRepository{Repository: memory.NewInMemorySessionRepository(), races: maxPauseAttempts}
+	uc := newTestUseCaseWithSessions(t, sessions, "room-a")
+	ctx := context.Background()
+
+	condition := StopConditionDTO{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S1"}
+	if _, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "room-a", Condition: condition}); err != nil {
+		t.Fatalf("CreateBreakpoint failed: %v", err)
+	}
+
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 2500}); !errors.Is(err, sessiondomain.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	// 進めた状態は保存済みなので、一時停止に失敗しても公開されている
+	got, err := uc.GetSimulation(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	if got.SimTimeMillis != 2500 {
+		t.Fatalf("expected published sim time 2500, got %d", got.SimTimeMillis)
+	}
+}
+
+func TestTickRaisesDeadlockAlarm(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	doc, err := uc.ExportSnapshot(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	doc.Trains = append(doc.Trains, TrainDTO{ID: "T1", BlockID: "B1", Progress: 0.5, Forward: false, Speed: 0.5})
+	doc.Occupancy = append(doc.Occupancy, OccupancyDTO{BlockID: "B1", TrainID: "T1"})
+	if _, err := uc.ImportSnapshot(ctx, ImportSnapshotInput{SessionID: "room-a", Document: doc}); err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+
+	events, cancel, err := uc.Subscribe(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	dto, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 3000})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	if len(dto.Alarms) != 1 || dto.Alarms[0].Type != AlarmDeadlock || dto.Alarms[0].Deadlock == nil {
+		t.Fatalf("unexpected alarms: %+v", dto.Alarms)
+	}
+	want := DeadlockDTO{Kind: "HEAD_ON", TrainIDs: []string{"T0", "T1"}, BlockIDs: []string{"B0", "B1"}}
+	if got := *dto.Alarms[0].Deadlock; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected deadlock %+v, got %+v", want, got)
+	}
+
+	n := <-events
+	if n.Type != NotificationDeadlock || !reflect.DeepEqual(n.Payload, want) {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+}
+
+func TestFastForwardRaisesDeadlockAlarm(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	doc, err := uc.ExportSnapshot(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	doc.Trains = append(doc.Trains, TrainDTO{ID: "T1", BlockID: "B1", Progress: 0.5, Forward: false, Speed: 0.5})
+	doc.Occupancy = append(doc.Occupancy, OccupancyDTO{BlockID: "B1", TrainID: "T1"})
+	if _, err := uc.ImportSnapshot(ctx, ImportSnapshotInput{SessionID: "room-a", Document: doc}); err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+
+	events, cancel, err := uc.Subscribe(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	result, err := uc.FastForward(ctx, FastForwardInput{SessionID: "room-a", UntilSimTimeMillis: 60_000, StepMillis: 500})
+	if err != nil {
+		t.Fatalf("FastForward failed: %v", err)
+	}
+	if len(result.Simulation.Alarms) != 1 || result.Simulation.Alarms[0].Type != AlarmDeadlock {
+		t.Fatalf("unexpected alarms: %+v", result.Simulation.Alarms)
+	}
+	// 待ち合いは早送りの途中で生じても、Tick と同じく一度だけ知らせる
+	want := DeadlockDTO{Kind: "HEAD_ON", TrainIDs: []string{"T0", "T1"}, BlockIDs: []string{"B0", "B1"}}
+	n := <-events
+	if n.Type != NotificationDeadlock || !reflect.DeepEqual(n.Payload, want) {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+	select {
+	case n := <-events:
+		t.Fatalf("unexpected second notification: %+v", n)
+	default:
+	}
+}
+
+func TestSetPriorityRulesIsRecordedForReplay(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	if _, err := uc.SetPriorityRules(ctx, SetPriorityRulesInput{SessionID: "room-a", Rules: []string{"NOPE"}}); !errors.Is(err, ErrInvalidPriorityRules) {
+		t.Fatalf("expected ErrInvalidPriorityRules, got %v", err)
+	}
+
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 700}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	dto, err := uc.SetPriorityRules(ctx, SetPriorityRulesInput{SessionID: "room-a", Rules: []string{"BACKWARD_FIRST", "FASTER_FIRST"}})
+	if err != nil {
+		t.Fatalf("SetPriorityRules failed: %v", err)
+	}
+	if !reflect.DeepEqual(dto.PriorityRules, []string{"BACKWARD_FIRST", "FASTER_FIRST"}) {
+		t.Fatalf("unexpected priority rules: %v", dto.PriorityRules)
+	}
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1300}); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+
+	inputs, err := uc.GetInputLog(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetInputLog failed: %v", err)
+	}
+	if len(inputs) != 4 || inputs[2].Kind != "PRIORITY_RULES_CHANGED" {
+		t.Fatalf("unexpected input log: %+v", inputs)
+	}
+	result, err := uc.VerifyReplay(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !result.Consistent || !reflect.DeepEqual(result.Replayed.PriorityRules, dto.PriorityRules) {
+		t.Fatalf("expected replay to keep priority rules, got %+v", result)
+	}
+}
+
+func TestEventEngineStepsAreCappedPerCommand(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+	const farFuture = int64(1_000_000_000_000)
+
+	// 出来事ごとに区切ると上限を超える Tick は進めずに断る
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: farFuture, Engine: "EVENT"}); !errors.Is(err, ErrInvalidTickDelta) {
+		t.Fatalf("expected ErrInvalidTickDelta, got %v", err)
+	}
+	dto, err := uc.GetSimulation(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	if dto.SimTimeMillis != 0 {
+		t.Fatalf("expected rejected tick to keep the state, got %dms", dto.SimTimeMillis)
+	}
+
+	// 早送りは上限で止まり、その理由を返す
+	result, err := uc.FastForward(ctx, FastForwardInput{SessionID: "room-a", UntilSimTimeMillis: farFuture, Engine: "EVENT"})
+	if err != nil {
+		t.Fatalf("FastForward failed: %v", err)
+	}
+	if result.StopReason.Kind != string(domain.StopStepLimitReached) || int64(result.Steps) != maxFastForwardSteps || result.ToSimTimeMillis >= farFuture {
+		t.Fatalf("expected to stop at the step limit, got %+v", result.StopReason)
+	}
+}
+
+func TestTickWithEventEngineIsRecordedForReplay(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000, Engine: "WARP"}); !errors.Is(err, ErrInvalidEngine) {
+		t.Fatalf("expected ErrInvalidEngine, got %v", err)
+	}
+
+	// T0 は 4 秒で S2 に着き、EVENT ならその場で折り返して残り 1 秒戻る
+	dto, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 5000, Engine: "EVENT"})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	train := dto.Trains[0]
+	if train.BlockID != "B1" || train.Forward || math.Abs(train.Progress-0.5) > 1e-9 {
+		t.Fatalf("unexpected train after event engine tick: %+v", train)
+	}
+
+	inputs, err := uc.GetInputLog(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetInputLog failed: %v", err)
+	}
+	if last := inputs[len(inputs)-1]; last.Kind != "TICK" || last.Engine != "EVENT" {
+		t.Fatalf("unexpected input record: %+v", last)
+	}
+	result, err := uc.VerifyReplay(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !result.Consistent {
+		t.Fatalf("expected replay to be consistent, got differences %v", result.Differences)
+	}
+}
+
+func TestTickLogsInputOnlyWhenStateIsSaved(t *testing.T) {
+	repo := &failingSimulationRepository{Repository: memory.NewInMemorySimulationRepository()}
+	logs := &failingInputLogRepository{InputLogRepository: memory.NewInMemoryInputLogRepository()}
+	uc := newTestUseCaseWithRepositories(t, repo, logs, memory.NewInMemorySessionRepository(), "room-a")
+	ctx := context.Background()
+
+	// 保存できなければ、先に追記した入力を取り消す
+	repo.err = errors.New("disk full")
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); !errors.Is(err, repo.err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	repo.err = nil
+	// 追記できなければ状態を保存しない
+	logs.err = errors.New("disk full")
+	if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); !errors.Is(err, logs.err) {
+		t.Fatalf("expected append error, got %v", err)
+	}
+	logs.err = nil
+	got, err := uc.GetSimulation(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetSimulation failed: %v", err)
+	}
+	if got.SimTimeMillis != 0 {
+		t.Fatalf("expected failed ticks to leave sim time 0, got %d", got.SimTimeMillis)
+	}
+
+	dto, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000})
+	if err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	inputs, err := uc.GetInputLog(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetInputLog failed: %v", err)
+	}
+	if len(inputs) != 2 || inputs[1].Kind != "TICK" || inputs[1].Seq != 2 || inputs[1].Version != dto.Version {
+		t.Fatalf("expected only the saved tick to be logged, got %+v", inputs)
+	}
+	result, err := uc.VerifyReplay(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("VerifyReplay failed: %v", err)
+	}
+	if !result.Consistent {
+		t.Fatalf("expected replay to be consistent, got differences %v", result.Differences)
+	}
+}
+
+func TestBreakpointCRUD(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	if _, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "room-a", Condition: StopConditionDTO{Type: "NOPE"}}); !errors.Is(err, ErrInvalidBreakpoint) {
+		t.Fatalf("expected ErrInvalidBreakpoint, got %v", err)
+	}
+	if _, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "missing", Condition: StopConditionDTO{Type: StopConditionTrainsFaceEachOther}}); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	bp, err := uc.CreateBreakpoint(ctx, CreateBreakpointInput{SessionID: "room-a", Condition: StopConditionDTO{Type: StopConditionTrainsFaceEachOther}})
+	if err != nil {
+		t.Fatalf("CreateBreakpoint failed: %v", err)
+	}
+
+	disabled := false
+	updated, err := uc.UpdateBreakpoint(ctx, UpdateBreakpointInput{SessionID: "room-a", BreakpointID: bp.ID, Enabled: &disabled})
+	if err != nil {
+		t.Fatalf("UpdateBreakpoint failed: %v", err)
+	}
+	if updated.Enabled || updated.Condition.Type != StopConditionTrainsFaceEachOther {
+		t.Fatalf("unexpected updated breakpoint: %+v", updated)
+	}
+
+	if err := uc.DeleteBreakpoint(ctx, "room-a", bp.ID); err != nil {
+		t.Fatalf("DeleteBreakpoint failed: %v", err)
+	}
+	if err := uc.DeleteBreakpoint(ctx, "room-a", bp.ID); !errors.Is(err, ErrBreakpointNotFound) {
+		t.Fatalf("expected ErrBreakpointNotFound, got %v", err)
+	}
+	listed, err := uc.ListBreakpoints(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("ListBreakpoints failed: %v", err)
+	}
+	if len(listed) != 0 {
+		t.Fatalf("expected no breakpoints, got %+v", listed)
+	}
+}
+
+func TestBreakpointsSurviveRestart(t *testing.T) {
+	repo := memory.NewInMemoryBreakpointRepository()
+	ctx := context.Background()
+
+	registry := NewBreakpointRegistry(repo)
+	faced, err := registry.create(ctx, "room-a", StopConditionDTO{Type: StopConditionTrainsFaceEachOther}, true)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	reached, err := registry.create(ctx, "room-a", StopConditionDTO{Type: StopConditionTrainReachesStation, TrainID: "T0", StationID: "S1"}, true)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	disabled := false
+	if _, err := registry.update(ctx, UpdateBreakpointInput{SessionID: "room-a", BreakpointID: faced.ID, Enabled: &disabled}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	// 再起動後も定義を登録順に読み直し、評価に使える
+	restarted := NewBreakpointRegistry(repo)
+	listed, err := restarted.list(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	faced.Enabled = false
+	if !reflect.DeepEqual(listed, []BreakpointDTO{faced, reached}) {
+		t.Fatalf("expected the definitions to be kept, got %+v", listed)
+	}
+	conditions, ids, err := restarted.enabledConditions(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("enabledConditions failed: %v", err)
+	}
+	if len(conditions) != 1 || !reflect.DeepEqual(ids, []string{reached.ID}) {
+		t.Fatalf("expected only the enabled breakpoint, got %v", ids)
+	}
+
+	// 破棄すれば保存した定義も消える
+	if err := restarted.Forget(ctx, "room-a"); err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if listed, err := NewBreakpointRegistry(repo).list(ctx, "room-a"); err != nil || len(listed) != 0 {
+		t.Fatalf("expected no breakpoints after forget, got %+v %v", listed, err)
+	}
+}
+
+func TestBreakpointIsNotChangedWhenSaveFails(t *testing.T) {
+	repo := &failingBreakpointRepository{BreakpointRepository: memory.NewInMemoryBreakpointRepository()}
+	registry := NewBreakpointRegistry(repo)
+	ctx := context.Background()
+
+	bp, err := registry.create(ctx, "room-a", StopConditionDTO{Type: StopConditionTrainsFaceEachOther}, true)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	repo.err = errors.New("disk full")
+	if _, err := registry.create(ctx, "room-a", StopConditionDTO{Type: StopConditionTrainsFaceEachOther}, true); !errors.Is(err, repo.err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	disabled := false
+	if _, err := registry.update(ctx, UpdateBreakpointInput{SessionID: "room-a", BreakpointID: bp.ID, Enabled: &disabled}); !errors.Is(err, repo.err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	if err := registry.delete(ctx, "room-a", bp.ID); !errors.Is(err, repo.err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	// 保存できなかった変更は保持しているブレークポイントにも反映しない
+	listed, err := registry.list(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !reflect.DeepEqual(listed, []BreakpointDTO{bp}) {
+		t.Fatalf("expected the breakpoint to be unchanged, got %+v", listed)
+	}
+}
+
+// newTestUseCase は演習開始済み（RUNNING・シミュレーション生成済み）のセッションを用意する
+func newTestUseCase(t *testing.T, sessionIDs ...string) UseCase {
+	t.Helper()
+	return newTestUseCaseWithSessions(t, memory.NewInMemorySessionRepository(), sessionIDs...)
+}
+
+func newTestUseCaseWithSessions(t *testing.T, sessions sessiondomain.Repository, sessionIDs ...string) UseCase {
+	t.Helper()
+	return newTestUseCaseWithRepositories(t, memory.NewInMemorySimulationRepository(), memory.NewInMemoryInputLogRepository(), sessions, sessionIDs...)
+}
+
+func newTestUseCaseWithRepositories(t *testing.T, repo domain.Repository, logs domain.InputLogRepository, sessions sessiondomain.Repository, sessionIDs ...string) UseCase {
+	t.Helper()
+
+	breakpoints := NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository())
+	diagrams := NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs)
+	views := NewViewPublisher()
+	actors := NewSimulationActors(repo)
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: testNetwork(t)}, nil, breakpoints, diagrams, views, actors)
+	for _, raw := range sessionIDs {
+		session := createTestSession(t, sessions, raw)
+		if err := provisioner.Provision(context.Background(), session.ID()); err != nil {
+			t.Fatalf("provision failed: %v", err)
+		}
+		if err := session.Start(time.Now()); err != nil {
+			t.Fatalf("start session failed: %v", err)
+		}
+		if err := sessions.Save(context.Background(), session); err != nil {
+			t.Fatalf("save session failed: %v", err)
+		}
+	}
+	return NewUseCase(repo, logs, sessions, NewNotificationHub(), breakpoints, diagrams, views, actors)
+}
+
+// failingInputLogRepository は err が設定されている間、Append を失敗させる
+type failingInputLogRepository struct {
+	domain.InputLogRepository
+	err error
+}
+
+func (r *failingInputLogRepository) Append(ctx context.Context, id domain.SimulationID, record domain.InputRecord) (domain.InputRecord, error) {
+	if r.err != nil {
+		return domain.InputRecord{}, r.err
+	}
+	return r.InputLogRepository.Append(ctx, id, record)
+}
+
+// failingBreakpointRepository は err が設定されている間、Save を失敗させる
+type failingBreakpointRepository struct {
+	domain.BreakpointRepository
+	err error
+}
+
+func (r *failingBreakpointRepository) Save(ctx context.Context, id domain.SimulationID, breakpoints []domain.Breakpoint) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.BreakpointRepository.Save(ctx, id, breakpoints)
+}
+
+// failingSimulationRepository は err が設定されている間、Save を失敗させる
+type failingSimulationRepository struct {
+	domain.Repository
+	err error
+}
+
+func (r *failingSimulationRepository) Save(ctx context.Context, state *domain.SimulationState) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Repository.Save(ctx, state)
+}
+
+// racingSessionRepository は一時停止の保存の直前に、races 回まで別の管制員を参加させて競合させる
+type racingSessionRepository struct {
+	sessiondomain.Repository
+	races  int
+	joined int
+}
+
+func (r *racingSessionRepository) Save(ctx context.Context, session *sessiondomain.TrainingSession) error {
+	if session.Status() == sessiondomain.SessionStatusPaused && r.joined < r.races {
+		r.joined++
+		latest, err := r.Repository.Get(ctx, session.ID())
+		if err != nil {
+			return err
+		}
+		id, _ := sessiondomain.NewDispatcherID(fmt.Sprintf("d%d", r.joined))
+		name, _ := sessiondomain.NewDispatcherName("racer")
+		if err := latest.JoinDispatcher(sessiondomain.NewDispatcher(id, name), time.Now()); err != nil {
+			return err
+		}
+		if err := r.Repository.Save(ctx, latest); err != nil {
+			return err
+		}
+	}
+	return r.Repository.Save(ctx, session)
+}
+
+// blockActor はセッションの actor に、返した chan を閉じるまで終わらない指令を実行させる
+func blockActor(t *testing.T, uc UseCase, sessionID string) chan struct{} {
+	t.Helper()
+
+	id, err := domain.NewSimulationID(sessionID)
+	if err != nil {
+		t.Fatalf("new simulation id failed: %v", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = uc.(*service).actors.do(context.Background(), id, func(ctx context.Context, current *domain.SimulationState) (*domain.SimulationState, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+	}()
+	<-started
+	return release
+}
+
+func createTestSession(t *testing.T, sessions sessiondomain.Repository, raw string) *sessiondomain.TrainingSession {
+	t.Helper()
+
+	session := sessiondomain.NewTrainingSession(testSessionID(t, raw), time.Now())
+	if err := sessions.Create(context.Background(), session); err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+	return session
+}
+
+func testSessionID(t *testing.T, raw string) sessiondomain.SessionID {
+	t.Helper()
+
+	id, err := sessiondomain.NewSessionID(raw)
+	if err != nil {
+		t.Fatalf("new session id failed: %v", err)
+	}
+	return id
+}
+
+// testNetworkState は X で交わる RED（A0-X-A2）と BLUE（B0-X-B2）の網に、路線ごとに1本ずつ列車を置いた状態
+func testNetworkState(t *testing.T) *domain.SimulationState {
+	t.Helper()
+
+	newLine := func(stationIDs, blockIDs []string) *domain.Line {
+		var stations []domain.StationID
+		for _, raw := range stationIDs {
+			id, _ := domain.NewStationID(raw)
+			stations = append(stations, id)
+		}
+		var blocks []domain.BlockID
+		for _, raw := range blockIDs {
+			id, _ := domain.NewBlockID(raw)
+			blocks = append(blocks, id)
+		}
+		line, err := domain.NewLine(stations, blocks)
+		if err != nil {
+			t.Fatalf("line build failed: %v", err)
+		}
+		return line
+	}
+	red, _ := domain.NewLineID("RED")
+	blue, _ := domain.NewLineID("BLUE")
+	a0, _ := domain.NewStationID("A0")
+	b0, _ := domain.NewStationID("B0")
+	network, err := domain.NewNetwork([]domain.NetworkLine{
+		{ID: red, Line: newLine([]string{"A0", "X", "A2"}, []string{"RB0", "RB1"})},
+		{ID: blue, Line: newLine([]string{"B0", "X", "B2"}, []string{"BB0", "BB1"})},
+	}, []domain.TransferLink{{From: b0, To: a0, MinTransfer: 3 * time.Minute}})
+	if err != nil {
+		t.Fatalf("network build failed: %v", err)
+	}
+
+	id, _ := domain.NewSimulationID("network")
+	state, err := domain.NewNetworkSimulationState(id, network)
+	if err != nil {
+		t.Fatalf("state build failed: %v", err)
+	}
+	for _, train := range []struct{ id, block string }{{"T0", "RB1"}, {"T1", "BB0"}} {
+		trainID, _ := domain.NewTrainID(train.id)
+		block, _ := domain.NewBlockID(train.block)
+		progress, _ := domain.NewBlockProgress(0.5)
+		built, err := domain.NewTrain(trainID, block, progress, true, 0.5)
+		if err != nil {
+			t.Fatalf("train build failed: %v", err)
+		}
+		if err := state.AddTrain(built); err != nil {
+			t.Fatalf("add train failed: %v", err)
+		}
+	}
+	return state
+}
+
+type stubNetworkLoader struct {
+	network *domain.Network
+	err     error
+}
+
+func (s *stubNetworkLoader) Load(ctx context.Context) (*domain.Network, error) {
+	_ = ctx
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.network, nil
+}
+
+type stubTimetableLoader struct {
+	timetable *domain.Timetable
+	trains    []*domain.Train
+}
+
+func (s *stubTimetableLoader) Load(ctx context.Context, network *domain.Network) (*domain.Timetable, []*domain.Train, error) {
+	_ = ctx
+	_ = network
+	return s.timetable, s.trains, nil
+}
+
+func testNetwork(t *testing.T) *domain.Network {
+	t.Helper()
+
+	s0, _ := domain.NewStationID("S0")
+	s1, _ := domain.NewStationID("S1")
+	s2, _ := domain.NewStationID("S2")
+	b0, _ := domain.NewBlockID("B0")
+	b1, _ := domain.NewBlockID("B1")
+
+	line, err := domain.NewLine([]domain.StationID{s0, s1, s2}, []domain.BlockID{b0, b1})
+	if err != nil {
+		t.Fatalf("line build failed: %v", err)
+	}
+	network, err := domain.NewSingleLineNetwork(line)
+	if err != nil {
+		t.Fatalf("network build failed: %v", err)
+	}
+	return network
+}
+
+func TestDiagramRecordsRunsAndOverlaysTimetable(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+	}
+	timetable := TimetableDTO{Services: []ServiceDTO{{
+		ID:      "101",
+		TrainID: "T0",
+		Stops: []ServiceStopDTO{
+			{StationID: "S0", ArrivalSimTimeMillis: 0, DepartureSimTimeMillis: 0},
+			{StationID: "S1", ArrivalSimTimeMillis: 2000, DepartureSimTimeMillis: 2500},
+			{StationID: "S2", ArrivalSimTimeMillis: 4500, DepartureSimTimeMillis: 4500},
+		},
+	}}}
+	if _, err := uc.SetTimetable(ctx, SetTimetableInput{SessionID: "room-a", Timetable: timetable}); err != nil {
+		t.Fatalf("SetTimetable failed: %v", err)
+	}
+	got, err := uc.GetTimetable(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetTimetable failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, timetable) {
+		t.Fatalf("unexpected timetable: %+v", got)
+	}
+
+	diagram, err := uc.GetDiagram(ctx, DiagramInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("GetDiagram failed: %v", err)
+	}
+	if diagram.LineID != "MAIN" || diagram.DistanceUnit != DiagramDistanceStations || len(diagram.Stations) != 3 || diagram.Stations[2].Distance != 2 {
+		t.Fatalf("unexpected diagram axis: %+v", diagram)
+	}
+	wantPlanned := []DiagramPointDTO{{0, 0}, {2000, 1}, {2500, 1}, {4500, 2}}
+	if len(diagram.Planned) != 1 || !reflect.DeepEqual(diagram.Planned[0].Points, wantPlanned) {
+		t.Fatalf("unexpected planned runs: %+v", diagram.Planned)
+	}
+	wantActual := []DiagramPointDTO{{0, 0}, {1000, 0.5}, {2000, 1}}
+	if len(diagram.Actual) != 1 || diagram.Actual[0].TrainID != "T0" || !reflect.DeepEqual(diagram.Actual[0].Points, wantActual) {
+		t.Fatalf("unexpected actual runs: %+v", diagram.Actual)
+	}
+
+	// 巻き戻すと戻した時刻より後の記録は捨てる
+	if _, err := uc.Rewind(ctx, RewindInput{SessionID: "room-a", TargetSimTimeMillis: 1000}); err != nil {
+		t.Fatalf("Rewind failed: %v", err)
+	}
+	diagram, err = uc.GetDiagram(ctx, DiagramInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("GetDiagram failed: %v", err)
+	}
+	if !reflect.DeepEqual(diagram.Actual[0].Points, wantActual[:2]) {
+		t.Fatalf("expected the run to be cut at the rewind target, got %+v", diagram.Actual[0].Points)
+	}
+}
+
+func TestDiagramSurvivesCloseAndRestart(t *testing.T) {
+	repo := memory.NewInMemorySimulationRepository()
+	logs := memory.NewInMemoryInputLogRepository()
+	timetables := memory.NewInMemoryTimetableRepository()
+	sessions := memory.NewInMemorySessionRepository()
+	ctx := context.Background()
+
+	// 再起動を模すため、登録簿と actor は保存先を共有したまま作り直せるようにする
+	newUseCase := func() (UseCase, *Provisioner) {
+		breakpoints := NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository())
+		diagrams := NewDiagramRegistry(timetables, logs)
+		views := NewViewPublisher()
+		actors := NewSimulationActors(repo)
+		provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: testNetwork(t)}, nil, breakpoints, diagrams, views, actors)
+		return NewUseCase(repo, logs, sessions, NewNotificationHub(), breakpoints, diagrams, views, actors), provisioner
+	}
+	uc, provisioner := newUseCase()
+	session := createTestSession(t, sessions, "room-a")
+	if err := provisioner.Provision(ctx, session.ID()); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	if err := session.Start(time.Now()); err != nil {
+		t.Fatalf("start session failed: %v", err)
+	}
+	if err := sessions.Save(ctx, session); err != nil {
+		t.Fatalf("save session failed: %v", err)
+	}
+
+	for range 2 {
+		if _, err := uc.Tick(ctx, TickInput{SessionID: "room-a", DeltaMillis: 1000}); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+	}
+	timetable := TimetableDTO{Services: []ServiceDTO{{
+		ID:      "101",
+		TrainID: "T0",
+		Stops: []ServiceStopDTO{
+			{StationID: "S0", ArrivalSimTimeMillis: 0, DepartureSimTimeMillis: 0},
+			{StationID: "S2", ArrivalSimTimeMillis: 4000, DepartureSimTimeMillis: 4000},
+		},
+	}}}
+	if _, err := uc.SetTimetable(ctx, SetTimetableInput{SessionID: "room-a", Timetable: timetable}); err != nil {
+		t.Fatalf("SetTimetable failed: %v", err)
+	}
+	want, err := uc.GetDiagram(ctx, DiagramInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("GetDiagram failed: %v", err)
+	}
+	if len(want.Planned) != 1 || len(want.Actual) != 1 || len(want.Actual[0].Points) != 3 {
+		t.Fatalf("unexpected diagram: %+v", want)
+	}
+
+	// 演習を閉じても振り返りのために運行図表を返す
+	if err := provisioner.Close(ctx, session.ID()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	got, err := uc.GetDiagram(ctx, DiagramInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("GetDiagram after close failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the diagram to be kept after close, got %+v", got)
+	}
+
+	// 再起動後は計画ダイヤを保存先から読み、実績を入力ログから組み直す
+	restarted, _ := newUseCase()
+	got, err = restarted.GetDiagram(ctx, DiagramInput{SessionID: "room-a"})
+	if err != nil {
+		t.Fatalf("GetDiagram after restart failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the diagram to be rebuilt after restart\nwant %+v\ngot  %+v", want, got)
+	}
+	gotTimetable, err := restarted.GetTimetable(ctx, "room-a")
+	if err != nil {
+		t.Fatalf("GetTimetable after restart failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotTimetable, timetable) {
+		t.Fatalf("expected the timetable to be kept, got %+v", gotTimetable)
+	}
+}
+
+func TestSetTimetableRejectsInvalidServices(t *testing.T) {
+	uc := newTestUseCase(t, "room-a")
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		service ServiceDTO
+	}{
+		{name: "unknown station", service: ServiceDTO{ID: "1", Stops: []ServiceStopDTO{{StationID: "S0"}, {StationID: "Z9", ArrivalSimTimeMillis: 10, DepartureSimTimeMillis: 10}}}},
+		{name: "times go backwards", service: ServiceDTO{ID: "1", Stops: []ServiceStopDTO{{StationID: "S0", ArrivalSimTimeMillis: 10, DepartureSimTimeMillis: 10}, {StationID: "S1"}}}},
+		{name: "single stop", service: ServiceDTO{ID: "1", Stops: []ServiceStopDTO{{StationID: "S0"}}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := uc.SetTimetable(ctx, SetTimetableInput{SessionID: "room-a", Timetable: TimetableDTO{Services: []ServiceDTO{tc.service}}})
+			if !errors.Is(err, ErrInvalidTimetable) {
+				t.Fatalf("expected ErrInvalidTimetable, got %v", err)
+			}
+		})
+	}
+	if _, err := uc.GetDiagram(ctx, DiagramInput{SessionID: "room-a", LineID: "NOPE"}); !errors.Is(err, ErrInvalidDiagramQuery) {
+		t.Fatalf("expected ErrInvalidDiagramQuery, got %v", err)
+	}
+}
+
+func TestPlannedRunsSplitWhereServiceLeavesLine(t *testing.T) {
+	network := testNetworkState(t).Network()
+	timetable, err := fromTimetableDTO(TimetableDTO{Services: []ServiceDTO{{
+		ID: "201",
+		Stops: []ServiceStopDTO{
+			{StationID: "A0", ArrivalSimTimeMillis: 0, DepartureSimTimeMillis: 0},
+			{StationID: "X", ArrivalSimTimeMillis: 60_000, DepartureSimTimeMillis: 60_000},
+			{StationID: "B2", ArrivalSimTimeMillis: 120_000, DepartureSimTimeMillis: 120_000},
+			{StationID: "X", ArrivalSimTimeMillis: 180_000, DepartureSimTimeMillis: 180_000},
+			{StationID: "A2", ArrivalSimTimeMillis: 240_000, DepartureSimTimeMillis: 240_000},
+		},
+	}}}, network)
+	if err != nil {
+		t.Fatalf("timetable failed: %v", err)
+	}
+	red, _ := domain.NewLineID("RED")
+	line, _ := network.Line(red)
+	distances, _ := line.StationDistances()
+	stationDistance := map[string]float64{}
+	for i, station := range line.Stations() {
+		stationDistance[station.String()] = distances[i]
+	}
+
+	runs := plannedRuns(timetable.Services()[0], stationDistance)
+	if len(runs) != 2 || len(runs[0].Points) != 2 || runs[1].Points[0].SimTimeMillis != 180_000 {
+		t.Fatalf("expected the service to be split where it leaves RED, got %+v", runs)
+	}
+}
+
+// BenchmarkUseCaseTick は保存・入力ログ・公開まで含めた Tick 1回あたりの費用を測る
+// BenchmarkUseCaseTick は指令1回の Tick を測る。ドメインの Tick（BenchmarkTick）は確保しないが、指令では
+// 状態の複製と保存・入力ログ・公開する SimulationDTO・運行図表の記録の分を毎回確保する
+// （列車 500 本でおよそ 800KB・600 回）。公開する SimulationDTO は列車ごとに網全体と路線ごとの2つを
+// 作り直すので列車の本数に比例し、その分を view として別に示す（同じ規模でおよそ 160KB）。
+func BenchmarkUseCaseTick(b *testing.B) {
+	for _, size := range []struct{ blocks, trains int }{{500, 50}, {5000, 500}} {
+		b.Run(fmt.Sprintf("blocks=%d/trains=%d", size.blocks, size.trains), func(b *testing.B) {
+			uc := newGeneratedUseCase(b, "room-a", size.blocks, size.trains)
+			ctx := context.Background()
+			input := TickInput{SessionID: "room-a", DeltaMillis: 100}
+
+			var dto SimulationDTO
+			b.ReportAllocs()
+			for b.Loop() {
+				var err error
+				if dto, err = uc.Tick(ctx, input); err != nil {
+					b.Fatalf("Tick failed: %v", err)
+				}
+			}
+			// 実時間1秒あたりに進められたシミュレーション時間
+			b.ReportMetric(float64(dto.SimTimeMillis)/1000/b.Elapsed().Seconds(), "sim-s/s")
+		})
+		b.Run(fmt.Sprintf("blocks=%d/trains=%d/view", size.blocks, size.trains), func(b *testing.B) {
+			state := newGeneratedSimulationState(b, "room-a", size.blocks, size.trains)
+			views := NewViewPublisher()
+			views.PublishState(state)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				views.PublishState(state)
+			}
+		})
+	}
+}
+
+// newGeneratedSimulationState は domain.GenerateLine の路線と列車で状態を作る
+func newGeneratedSimulationState(tb testing.TB, raw string, blocks, trains int) *domain.SimulationState {
+	tb.Helper()
+
+	line, placed, err := domain.GenerateLine(blocks, trains)
+	if err != nil {
+		tb.Fatalf("generate line failed: %v", err)
+	}
+	id, _ := domain.NewSimulationID(raw)
+	state, err := domain.NewSimulationState(id, line)
+	if err != nil {
+		tb.Fatalf("new state failed: %v", err)
+	}
+	for _, train := range placed {
+		if err := state.AddTrain(train); err != nil {
+			tb.Fatalf("add train failed: %v", err)
+		}
+	}
+	return state
+}
+
+// newGeneratedUseCase は domain.GenerateLine の路線と列車のセッションを演習中にする
+func newGeneratedUseCase(tb testing.TB, raw string, blocks, trains int) UseCase {
+	tb.Helper()
+
+	line, placed, err := domain.GenerateLine(blocks, trains)
+	if err != nil {
+		tb.Fatalf("generate line failed: %v", err)
+	}
+	network, err := domain.NewSingleLineNetwork(line)
+	if err != nil {
+		tb.Fatalf("new network failed: %v", err)
+	}
+	timetable, err := domain.NewTimetable(nil)
+	if err != nil {
+		tb.Fatalf("new timetable failed: %v", err)
+	}
+
+	repo := memory.NewInMemorySimulationRepository()
+	logs := memory.NewInMemoryInputLogRepository()
+	sessions := memory.NewInMemorySessionRepository()
+	breakpoints := NewBreakpointRegistry(memory.NewInMemoryBreakpointRepository())
+	diagrams := NewDiagramRegistry(memory.NewInMemoryTimetableRepository(), logs)
+	views := NewViewPublisher()
+	actors := NewSimulationActors(repo)
+	provisioner := NewProvisioner(repo, logs, &stubNetworkLoader{network: network}, &stubTimetableLoader{timetable: timetable, trains: placed}, breakpoints, diagrams, views, actors)
+
+	sid, err := sessiondomain.NewSessionID(raw)
+	if err != nil {
+		tb.Fatalf("new session id failed: %v", err)
+	}
+	session := sessiondomain.NewTrainingSession(sid, time.Now())
+	if err := sessions.Create(context.Background(), session); err != nil {
+		tb.Fatalf("create session failed: %v", err)
+	}
+	if err := provisioner.Provision(context.Background(), sid); err != nil {
+		tb.Fatalf("provision failed: %v", err)
+	}
+	if err := session.Start(time.Now()); err != nil {
+		tb.Fatalf("start session failed: %v", err)
+	}
+	if err := sessions.Save(context.Background(), session); err != nil {
+		tb.Fatalf("save session failed: %v", err)
+	}
+	return NewUseCase(repo, logs, sessions, NewNotificationHub(), breakpoints, diagrams, views, actors)
 }
@@ -0,0 +1,141 @@
+package simulation
+
+import (
+	"sync"
+	"sync/atomic"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// viewHistorySize は差分の起点にできる過去の公開の数。これより古い版からの差分は全量で返す。
+const viewHistorySize = 64
+
+// ViewPublisher は状態を変えるたびに作った SimulationDTO をセッションごとに公開する。
+// 読み取りは公開済みの値を atomic に読むだけなので、Tick などの更新とロックを取り合わない。
+// 公開した DTO は以後変更しない（読み手どうしでスライスを共有するため、呼び出し側も変更しないこと）。
+// 直近の公開は差分の起点として残し、次の公開を待つこともできる（長いポーリング用）。
+type ViewPublisher struct {
+	views sync.Map // セッションID -> *viewEntry
+}
+
+type viewEntry struct {
+	latest atomic.Pointer[SimulationDTO]
+
+	mu      sync.Mutex
+	history []*SimulationDTO // 古い順、最大 viewHistorySize 件（最新を含む）
+	changed chan struct{}    // 次の公開か取り下げで閉じる
+	network *networkView     // 直近に公開した網の部分（網が替わるまで使い回す）
+}
+
+func NewViewPublisher() *ViewPublisher {
+	return &ViewPublisher{}
+}
+
+// Publish は dto をそのセッションの最新の状態として公開する
+func (p *ViewPublisher) Publish(dto SimulationDTO) {
+	e := p.entry(dto.SessionID)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.history) == viewHistorySize {
+		e.history = append(e.history[:0:0], e.history[1:]...)
+	}
+	e.history = append(e.history, &dto)
+	e.latest.Store(&dto)
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// PublishState は state を DTO にして公開し、公開した DTO を返す。
+// 路線網の部分は前回の公開と同じ網なら作り直さないが、列車は公開ごとに網全体と路線ごとの DTO を作るので、
+// 確保は列車の本数に比例する（BenchmarkUseCaseTick の view）。
+func (p *ViewPublisher) PublishState(state *domain.SimulationState) SimulationDTO {
+	e := p.entry(state.ID().String())
+
+	e.mu.Lock()
+	view := e.network
+	if view == nil || view.network != state.Network() {
+		view = newNetworkView(state.Network())
+		e.network = view
+	}
+	e.mu.Unlock()
+
+	dto := view.simulationDTO(state)
+	p.Publish(dto)
+	return dto
+}
+
+// Topology は直近に公開した網の構成を返す（Revision は網を公開したときに1度だけ求めたもの）。
+// まだ公開していなければ false。
+func (p *ViewPublisher) Topology(sessionID string) (TopologyDTO, bool) {
+	entry, ok := p.views.Load(sessionID)
+	if !ok {
+		return TopologyDTO{}, false
+	}
+	e := entry.(*viewEntry)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.network == nil {
+		return TopologyDTO{}, false
+	}
+	return e.network.topology, true
+}
+
+func (p *ViewPublisher) entry(sessionID string) *viewEntry {
+	entry, ok := p.views.Load(sessionID)
+	if !ok {
+		entry, _ = p.views.LoadOrStore(sessionID, &viewEntry{changed: make(chan struct{})})
+	}
+	return entry.(*viewEntry)
+}
+
+// Load は公開済みの最新の状態を返す。まだ公開していなければ false。
+func (p *ViewPublisher) Load(sessionID string) (SimulationDTO, bool) {
+	entry, ok := p.views.Load(sessionID)
+	if !ok {
+		return SimulationDTO{}, false
+	}
+	dto := entry.(*viewEntry).latest.Load()
+	if dto == nil {
+		return SimulationDTO{}, false
+	}
+	return *dto, true
+}
+
+// since は version の版として公開した状態（残っていなければ nil）と最新の状態、
+// および次の公開で閉じるチャネルを返す。まだ公開していなければ false。
+func (p *ViewPublisher) since(sessionID string, version int64) (*SimulationDTO, SimulationDTO, <-chan struct{}, bool) {
+	entry, ok := p.views.Load(sessionID)
+	if !ok {
+		return nil, SimulationDTO{}, nil, false
+	}
+	e := entry.(*viewEntry)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.history) == 0 {
+		return nil, SimulationDTO{}, nil, false
+	}
+	var base *SimulationDTO
+	for _, dto := range e.history {
+		if dto.Version == version {
+			base = dto
+		}
+	}
+	return base, *e.history[len(e.history)-1], e.changed, true
+}
+
+// Forget は公開を取り下げる（シミュレーションの破棄時）。次の公開を待っている読み手は起こす。
+func (p *ViewPublisher) Forget(sessionID string) {
+	entry, ok := p.views.LoadAndDelete(sessionID)
+	if !ok {
+		return
+	}
+	e := entry.(*viewEntry)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/right1121/railway-control-center-simulator/pkg/logger"
 )
@@ -15,7 +16,44 @@ type Config struct {
 		Port int    `json:"port"`
 		Host string `json:"host"`
 	} `json:"server"`
-	SecurePath string `json:"securePath,omitempty"`
+	SecurePath string           `json:"securePath,omitempty"`
+	Storage    StorageConfig    `json:"storage"`
+	Simulation SimulationConfig `json:"simulation"`
+}
+
+// ストレージの種類
+const (
+	StorageDriverMemory = "memory" // 既定。再起動で消える
+	StorageDriverFile   = "file"   // Dir 配下にJSONファイルで永続化する
+)
+
+// StorageConfig はセッション・シミュレーションの保存先設定。
+// 既定は memory でプロセスを止めると消える。再起動後も残すには
+// "storage": {"driver": "file", "dir": "data"} を指定する（configs/development/config.file.json を参照）。
+// file のときは dir の下に sessions, simulations, input_logs, timetables, breakpoints を作る
+type StorageConfig struct {
+	// Driver は memory（既定）か file
+	Driver string `json:"driver,omitempty"`
+	// Dir は file のときの保存先ディレクトリ。file では必須
+	Dir string `json:"dir,omitempty"`
+}
+
+// SimulationConfig はシミュレーションの設定
+type SimulationConfig struct {
+	// NetworkPath は路線網の定義（または路線1つのファイル）のパス。空なら既定の路線
+	NetworkPath string `json:"networkPath,omitempty"`
+	// Timetable は演習の計画ダイヤを読む GTFS のフィード。無ければ時刻表なしで既定の列車を1本置く
+	Timetable *TimetableConfig `json:"timetable,omitempty"`
+}
+
+// TimetableConfig は GTFS（静的）のフィードから時刻表と列車を作る設定
+type TimetableConfig struct {
+	// GTFSPath は GTFS のフィード（zip かディレクトリ）のパス
+	GTFSPath string `json:"gtfsPath"`
+	// StopMappingPath は GTFS の停留所と駅IDの対応表のパス
+	StopMappingPath string `json:"stopMappingPath"`
+	// ServiceDate は取り込む運行日（YYYY-MM-DD）
+	ServiceDate string `json:"serviceDate"`
 }
 
 func LoadFromPath(ctx context.Context, configPath string) (*Config, error) {
@@ -33,5 +71,24 @@ func LoadFromPath(ctx context.Context, configPath string) (*Config, error) {
 		return nil, fmt.Errorf("JSONのパースに失敗: %w", err)
 	}
 
+	switch config.Storage.Driver {
+	case "", StorageDriverMemory:
+	case StorageDriverFile:
+		if config.Storage.Dir == "" {
+			return nil, fmt.Errorf("storage.dir を指定してください")
+		}
+	default:
+		return nil, fmt.Errorf("未対応の storage.driver: %s", config.Storage.Driver)
+	}
+
+	if timetable := config.Simulation.Timetable; timetable != nil {
+		if timetable.GTFSPath == "" || timetable.StopMappingPath == "" {
+			return nil, fmt.Errorf("simulation.timetable には gtfsPath と stopMappingPath を指定してください")
+		}
+		if _, err := time.Parse(time.DateOnly, timetable.ServiceDate); err != nil {
+			return nil, fmt.Errorf("simulation.timetable.serviceDate は YYYY-MM-DD で指定してください: %w", err)
+		}
+	}
+
 	return config, nil
 }
@@ -1,13 +1,15 @@
 package di
 
 import (
+	"path/filepath"
+
 	sessionapp "github.com/right1121/railway-control-center-simulator/internal/application/session"
 	simulationapp "github.com/right1121/railway-control-center-simulator/internal/application/simulation"
 	"github.com/right1121/railway-control-center-simulator/internal/config"
 	"github.com/right1121/railway-control-center-simulator/internal/domain/session"
 	"github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
-	lineLoader "github.com/right1121/railway-control-center-simulator/internal/infrastructure/filesystem"
-	sessionRepo "github.com/right1121/railway-control-center-simulator/internal/infrastructure/memory"
+	"github.com/right1121/railway-control-center-simulator/internal/infrastructure/filesystem"
+	"github.com/right1121/railway-control-center-simulator/internal/infrastructure/memory"
 )
 
 // Container は依存の生成・保持を担当する。
@@ -23,6 +25,9 @@ type Container struct {
 type Repositories struct {
 	Session    session.Repository
 	Simulation simulation.Repository
+	InputLog   simulation.InputLogRepository
+	Timetable  simulation.TimetableRepository
+	Breakpoint simulation.BreakpointRepository
 }
 
 type UseCases struct {
@@ -33,18 +38,21 @@ type UseCases struct {
 // NewContainer は DI コンテナを生成する。
 // ここではまだ依存を組み立てず、遅延初期化する（起動を軽くする）。
 func NewContainer(cfg *config.Config) *Container {
-	session := sessionRepo.NewInMemorySessionRepository()
-	simState := sessionRepo.NewInMemorySimulationRepository()
-	loader := lineLoader.NewSimulationLineLoader(lineLoader.DefaultSimulationLinePath)
+	loader := filesystem.NewSimulationNetworkLoader(cfg.Simulation.NetworkPath)
 
-	repos := Repositories{
-		Session:    session,
-		Simulation: simState,
-	}
+	repos := newRepositories(cfg)
+
+	// ブレークポイントと運行図表の記録はシミュレーションの破棄と同時に捨てるので Provisioner とも共有する
+	breakpoints := simulationapp.NewBreakpointRegistry(repos.Breakpoint)
+	diagrams := simulationapp.NewDiagramRegistry(repos.Timetable, repos.InputLog)
+	views := simulationapp.NewViewPublisher()
+	// シミュレーションの actor は Provisioner が起動・停止し、UseCase が指令を送る
+	actors := simulationapp.NewSimulationActors(repos.Simulation)
+	provisioner := simulationapp.NewProvisioner(repos.Simulation, repos.InputLog, loader, newTimetableLoader(cfg), breakpoints, diagrams, views, actors)
 
 	usecase := UseCases{
-		Session:    sessionapp.NewUseCase(repos.Session),
-		Simulation: simulationapp.NewUseCase(repos.Simulation, loader),
+		Session:    sessionapp.NewUseCase(repos.Session, provisioner),
+		Simulation: simulationapp.NewUseCase(repos.Simulation, repos.InputLog, repos.Session, simulationapp.NewNotificationHub(), breakpoints, diagrams, views, actors),
 	}
 
 	return &Container{
@@ -53,3 +61,33 @@ func NewContainer(cfg *config.Config) *Container {
 		UseCases:     usecase,
 	}
 }
+
+// newTimetableLoader は時刻表の設定があれば GTFS の取り込みを返す。
+// 設定が無ければ nil（型付きの nil を interface に入れないよう、ここで interface として返す）。
+func newTimetableLoader(cfg *config.Config) simulationapp.TimetableLoader {
+	timetable := cfg.Simulation.Timetable
+	if timetable == nil {
+		return nil
+	}
+	return filesystem.NewGTFSTimetableLoader(timetable.GTFSPath, timetable.StopMappingPath, timetable.ServiceDate)
+}
+
+// newRepositories は設定に応じてRepository実装を選択する。
+func newRepositories(cfg *config.Config) Repositories {
+	if cfg.Storage.Driver == config.StorageDriverFile {
+		return Repositories{
+			Session:    filesystem.NewFileSessionRepository(filepath.Join(cfg.Storage.Dir, "sessions")),
+			Simulation: filesystem.NewFileSimulationRepository(filepath.Join(cfg.Storage.Dir, "simulations")),
+			InputLog:   filesystem.NewFileInputLogRepository(filepath.Join(cfg.Storage.Dir, "input_logs")),
+			Timetable:  filesystem.NewFileTimetableRepository(filepath.Join(cfg.Storage.Dir, "timetables")),
+			Breakpoint: filesystem.NewFileBreakpointRepository(filepath.Join(cfg.Storage.Dir, "breakpoints")),
+		}
+	}
+	return Repositories{
+		Session:    memory.NewInMemorySessionRepository(),
+		Simulation: memory.NewInMemorySimulationRepository(),
+		InputLog:   memory.NewInMemoryInputLogRepository(),
+		Timetable:  memory.NewInMemoryTimetableRepository(),
+		Breakpoint: memory.NewInMemoryBreakpointRepository(),
+	}
+}
@@ -1,9 +1,21 @@
 package session
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/right1121/railway-control-center-simulator/pkg/apperr"
+)
 
 var (
 	ErrSessionAlreadyExists    = errors.New("session already exists")
+	ErrSessionNotFound         = errors.New("session not found")
 	ErrDispatcherAlreadyExists = errors.New("dispatcher already exists")
 	ErrDispatcherNotFound      = errors.New("dispatcher not found")
+	ErrInvalidStatusTransition = errors.New("invalid session status transition")
+	ErrUnknownSessionStatus    = errors.New("unknown session status")
+
+	// ErrVersionConflict は読み込み後に他の更新が保存された（楽観ロック失敗）ことを表す
+	ErrVersionConflict     = fmt.Errorf("%w: session version mismatch", apperr.ErrConflict)
+	ErrSessionNotAccepting = errors.New("session is not accepting dispatchers")
 )
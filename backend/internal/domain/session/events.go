@@ -48,3 +48,28 @@ func (e DispatcherLeft) EventType() string {
 func (e DispatcherLeft) OccurredAt() time.Time {
 	return e.at
 }
+
+type SessionStatusChanged struct {
+	at   time.Time
+	from SessionStatus
+	to   SessionStatus
+}
+
+func NewSessionStatusChanged(at time.Time, from, to SessionStatus) SessionStatusChanged {
+	return SessionStatusChanged{
+		at:   at,
+		from: from,
+		to:   to,
+	}
+}
+
+func (e SessionStatusChanged) EventType() string {
+	return "SESSION_STATUS_CHANGED"
+}
+
+func (e SessionStatusChanged) OccurredAt() time.Time {
+	return e.at
+}
+
+func (e SessionStatusChanged) From() SessionStatus { return e.from }
+func (e SessionStatusChanged) To() SessionStatus   { return e.to }
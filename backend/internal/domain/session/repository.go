@@ -6,12 +6,18 @@ import (
 
 // Repository はドメイン集約の永続化境界
 type Repository interface {
-	// Get は単一セッションを取得する
-	Get(ctx context.Context) (*TrainingSession, error)
+	// Get は指定IDのセッションを取得する（存在しなければ ErrSessionNotFound）
+	Get(ctx context.Context, id SessionID) (*TrainingSession, error)
+
+	// List はすべてのセッションをID順で取得する
+	List(ctx context.Context) ([]*TrainingSession, error)
 
 	// Create は新規セッションを作成する
 	Create(ctx context.Context, session *TrainingSession) error
 
 	// Save は変更されたセッションを保存する
 	Save(ctx context.Context, s *TrainingSession) error
+
+	// Delete は指定IDのセッションを削除する
+	Delete(ctx context.Context, id SessionID) error
 }
@@ -5,6 +5,8 @@ import "time"
 // TrainingSession は訓練セッションの Aggregate Root
 type TrainingSession struct {
 	id           SessionID
+	version      int64 // 保存のたびに Repository が進める（楽観ロック用）
+	status       SessionStatus
 	lastActiveAt time.Time
 	dispatchers  map[string]Dispatcher // keyは DispatcherID.String()
 	events       []DomainEvent
@@ -13,6 +15,7 @@ type TrainingSession struct {
 func NewTrainingSession(id SessionID, now time.Time) *TrainingSession {
 	return &TrainingSession{
 		id:           id,
+		status:       SessionStatusLobby,
 		lastActiveAt: now,
 		dispatchers:  make(map[string]Dispatcher),
 		events:       make([]DomainEvent, 0),
@@ -23,6 +26,24 @@ func (s *TrainingSession) ID() SessionID {
 	return s.id
 }
 
+// Version は最後に保存された時点の集約バージョンを返す（未保存なら0）
+func (s *TrainingSession) Version() int64 {
+	return s.version
+}
+
+// IncrementVersion は Repository が保存に成功したときに呼び出す
+func (s *TrainingSession) IncrementVersion() {
+	s.version++
+}
+
+func (s *TrainingSession) Status() SessionStatus {
+	return s.status
+}
+
+func (s *TrainingSession) LastActiveAt() time.Time {
+	return s.lastActiveAt
+}
+
 func (s *TrainingSession) Dispatchers() []Dispatcher {
 	out := make([]Dispatcher, 0, len(s.dispatchers))
 	for _, d := range s.dispatchers {
@@ -35,6 +56,10 @@ func (s *TrainingSession) JoinDispatcher(
 	dispatcher Dispatcher,
 	now time.Time,
 ) error {
+	if !s.status.AcceptsDispatchers() {
+		return ErrSessionNotAccepting
+	}
+
 	dispatcher.joined(now)
 
 	key := dispatcher.ID().String()
@@ -70,9 +95,64 @@ func (s *TrainingSession) LeaveDispatcher(
 		s.events,
 		NewDispatcherLeft(now, id),
 	)
+
+	// 全員が退出したら演習を一時停止する
+	if s.status == SessionStatusRunning && len(s.dispatchers) == 0 {
+		s.transition(SessionStatusPaused, now)
+	}
 	return nil
 }
 
+// Start は演習を開始する（LOBBY → RUNNING）
+func (s *TrainingSession) Start(now time.Time) error {
+	if s.status != SessionStatusLobby {
+		return ErrInvalidStatusTransition
+	}
+	return s.changeStatus(SessionStatusRunning, now)
+}
+
+// Pause は演習を一時停止する（RUNNING → PAUSED）
+func (s *TrainingSession) Pause(now time.Time) error {
+	return s.changeStatus(SessionStatusPaused, now)
+}
+
+// Resume は一時停止中の演習を再開する（PAUSED → RUNNING）
+func (s *TrainingSession) Resume(now time.Time) error {
+	if s.status != SessionStatusPaused {
+		return ErrInvalidStatusTransition
+	}
+	return s.changeStatus(SessionStatusRunning, now)
+}
+
+// End は演習を終了し振り返りに移る（RUNNING/PAUSED → DEBRIEF）
+func (s *TrainingSession) End(now time.Time) error {
+	return s.changeStatus(SessionStatusDebrief, now)
+}
+
+// Close はセッションを終了する（→ CLOSED）
+func (s *TrainingSession) Close(now time.Time) error {
+	return s.changeStatus(SessionStatusClosed, now)
+}
+
+func (s *TrainingSession) changeStatus(next SessionStatus, now time.Time) error {
+	if !s.status.CanTransitionTo(next) {
+		return ErrInvalidStatusTransition
+	}
+	s.transition(next, now)
+	return nil
+}
+
+func (s *TrainingSession) transition(next SessionStatus, now time.Time) {
+	prev := s.status
+	s.status = next
+	s.lastActiveAt = now
+
+	s.events = append(
+		s.events,
+		NewSessionStatusChanged(now, prev, next),
+	)
+}
+
 func (s *TrainingSession) PullEvents() []DomainEvent {
 	ev := s.events
 	s.events = make([]DomainEvent, 0)
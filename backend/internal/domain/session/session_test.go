@@ -0,0 +1,88 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrainingSessionLifecycle(t *testing.T) {
+	now := time.Now()
+	s := newTestSession(t)
+
+	if s.Status() != SessionStatusLobby {
+		t.Fatalf("expected LOBBY, got %s", s.Status())
+	}
+	if err := s.Resume(now); err != ErrInvalidStatusTransition {
+		t.Fatalf("expected ErrInvalidStatusTransition on resume from lobby, got %v", err)
+	}
+	if err := s.Start(now); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if err := s.Pause(now); err != nil {
+		t.Fatalf("pause failed: %v", err)
+	}
+	if err := s.Start(now); err != ErrInvalidStatusTransition {
+		t.Fatalf("expected ErrInvalidStatusTransition on start from paused, got %v", err)
+	}
+	if err := s.Resume(now); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if err := s.End(now); err != nil {
+		t.Fatalf("end failed: %v", err)
+	}
+	if err := s.Close(now); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := s.Close(now); err != ErrInvalidStatusTransition {
+		t.Fatalf("expected ErrInvalidStatusTransition on double close, got %v", err)
+	}
+}
+
+func TestLeaveLastDispatcherPausesRunningSession(t *testing.T) {
+	now := time.Now()
+	s := newTestSession(t)
+	d := newTestDispatcher(t, "D0")
+
+	if err := s.JoinDispatcher(d, now); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	if err := s.Start(now); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if err := s.LeaveDispatcher(d.ID(), now); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+
+	if s.Status() != SessionStatusPaused {
+		t.Fatalf("expected PAUSED after last dispatcher left, got %s", s.Status())
+	}
+}
+
+func TestJoinDispatcherRejectedAfterDebrief(t *testing.T) {
+	now := time.Now()
+	s := newTestSession(t)
+	_ = s.Start(now)
+	_ = s.End(now)
+
+	if err := s.JoinDispatcher(newTestDispatcher(t, "D0"), now); err != ErrSessionNotAccepting {
+		t.Fatalf("expected ErrSessionNotAccepting, got %v", err)
+	}
+}
+
+func newTestSession(t *testing.T) *TrainingSession {
+	t.Helper()
+
+	id, err := NewSessionID("room-a")
+	if err != nil {
+		t.Fatalf("new session id failed: %v", err)
+	}
+	return NewTrainingSession(id, time.Now())
+}
+
+func newTestDispatcher(t *testing.T, id string) Dispatcher {
+	t.Helper()
+
+	dispatcherID, _ := NewDispatcherID(id)
+	name, _ := NewDispatcherName("Dispatcher " + id)
+	return NewDispatcher(dispatcherID, name)
+}
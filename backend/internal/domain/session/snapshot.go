@@ -0,0 +1,73 @@
+package session
+
+import "time"
+
+// SessionSnapshot は TrainingSession を永続化・復元するための素朴な表現。
+// 未配信のドメインイベントは含まない。
+type SessionSnapshot struct {
+	ID           string
+	Version      int64
+	Status       string
+	LastActiveAt time.Time
+	Dispatchers  []DispatcherSnapshot
+}
+
+type DispatcherSnapshot struct {
+	ID       string
+	Name     string
+	JoinedAt time.Time
+}
+
+func (s *TrainingSession) Snapshot() SessionSnapshot {
+	dispatchers := make([]DispatcherSnapshot, 0, len(s.dispatchers))
+	for _, d := range s.Dispatchers() {
+		dispatchers = append(dispatchers, DispatcherSnapshot{
+			ID:       d.ID().String(),
+			Name:     d.Name().String(),
+			JoinedAt: d.JoinedAt(),
+		})
+	}
+
+	return SessionSnapshot{
+		ID:           s.id.String(),
+		Version:      s.version,
+		Status:       s.status.String(),
+		LastActiveAt: s.lastActiveAt,
+		Dispatchers:  dispatchers,
+	}
+}
+
+// RestoreTrainingSession はスナップショットから集約を復元する（値オブジェクトの検証を通す）
+func RestoreTrainingSession(snap SessionSnapshot) (*TrainingSession, error) {
+	id, err := NewSessionID(snap.ID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := ParseSessionStatus(snap.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewTrainingSession(id, snap.LastActiveAt)
+	s.version = snap.Version
+	s.status = status
+
+	for _, raw := range snap.Dispatchers {
+		dispatcherID, err := NewDispatcherID(raw.ID)
+		if err != nil {
+			return nil, err
+		}
+		name, err := NewDispatcherName(raw.Name)
+		if err != nil {
+			return nil, err
+		}
+		key := dispatcherID.String()
+		if _, exists := s.dispatchers[key]; exists {
+			return nil, ErrDispatcherAlreadyExists
+		}
+		dispatcher := NewDispatcher(dispatcherID, name)
+		dispatcher.joined(raw.JoinedAt)
+		s.dispatchers[key] = dispatcher
+	}
+	return s, nil
+}
@@ -0,0 +1,51 @@
+package session
+
+// SessionStatus は訓練セッションのライフサイクル状態を表す Value Object
+//
+//	LOBBY → RUNNING ⇄ PAUSED → DEBRIEF → CLOSED
+//
+// CLOSED へはどの状態からでも遷移できる（CLOSED からはどこへも遷移できない）。
+type SessionStatus string
+
+const (
+	SessionStatusLobby   SessionStatus = "LOBBY"
+	SessionStatusRunning SessionStatus = "RUNNING"
+	SessionStatusPaused  SessionStatus = "PAUSED"
+	SessionStatusDebrief SessionStatus = "DEBRIEF"
+	SessionStatusClosed  SessionStatus = "CLOSED"
+)
+
+// ParseSessionStatus は文字列から SessionStatus を生成する
+func ParseSessionStatus(v string) (SessionStatus, error) {
+	status := SessionStatus(v)
+	if _, known := sessionTransitions[status]; !known && status != SessionStatusClosed {
+		return "", ErrUnknownSessionStatus
+	}
+	return status, nil
+}
+
+var sessionTransitions = map[SessionStatus][]SessionStatus{
+	SessionStatusLobby:   {SessionStatusRunning, SessionStatusClosed},
+	SessionStatusRunning: {SessionStatusPaused, SessionStatusDebrief, SessionStatusClosed},
+	SessionStatusPaused:  {SessionStatusRunning, SessionStatusDebrief, SessionStatusClosed},
+	SessionStatusDebrief: {SessionStatusClosed},
+}
+
+// CanTransitionTo は next への遷移が許可されているかを返す
+func (s SessionStatus) CanTransitionTo(next SessionStatus) bool {
+	for _, allowed := range sessionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsDispatchers は管制員の参加を受け付ける状態かを返す
+func (s SessionStatus) AcceptsDispatchers() bool {
+	return s == SessionStatusLobby || s == SessionStatusRunning || s == SessionStatusPaused
+}
+
+func (s SessionStatus) String() string {
+	return string(s)
+}
@@ -0,0 +1,18 @@
+package simulation
+
+// Breakpoint は保存するブレークポイントの定義。
+// 条件は受け取った形のまま持ち、評価するときに StopCondition に組み立てる（StopCondition は保存できる形を持たない）。
+type Breakpoint struct {
+	ID        string
+	Condition StopConditionSpec
+	Enabled   bool
+}
+
+// StopConditionSpec は停止条件の保存形。Type ごとに使う項目はアプリケーション層の StopConditionDTO と同じ。
+type StopConditionSpec struct {
+	Type           string
+	TrainID        string
+	StationID      string
+	DurationMillis int64
+	EventType      string
+}
@@ -0,0 +1,232 @@
+package simulation
+
+import "slices"
+
+type DeadlockKind string
+
+const (
+	// DeadlockHeadOn は単線上で向かい合った2列車が互いの在線を待っている
+	DeadlockHeadOn DeadlockKind = "HEAD_ON"
+	// DeadlockCircular は3列車以上（または同じ向きの列車）が輪になって待っている
+	DeadlockCircular DeadlockKind = "CIRCULAR"
+)
+
+// Deadlock は互いの在線を待ち合って二度と進めなくなった列車の組。
+// Trains は列車IDの最も小さいものから待ちの向きに並べ、Blocks は各列車が在線している区間を同じ順に持つ。
+type Deadlock struct {
+	Kind   DeadlockKind
+	Trains []TrainID
+	Blocks []BlockID
+}
+
+func (d Deadlock) involves(trainID TrainID) bool {
+	for _, id := range d.Trains {
+		if id == trainID {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitFor は区間境界で次の区間の在線を待っている列車から、待っている相手の列車への対応を返す（待ちグラフ）。
+// 列車は前方の1区間しか待たないので、各列車から出る辺は高々1本になる。
+func (s *SimulationState) WaitFor() map[TrainID]TrainID {
+	graph := make(map[TrainID]TrainID)
+	for i := range s.trains {
+		if blockedBy, ok := s.waitingFor(i); ok {
+			graph[s.trains[i].ID()] = s.trains[blockedBy].ID()
+		}
+	}
+	return graph
+}
+
+// waitingFor は添字 index の列車が区間の端で止まっていて、次の区間にいる別の列車を待っているならその列車の添字を返す。
+// 終端で折返しを待つ列車は次の刻みで動けるので待ちに含めない。
+func (s *SimulationState) waitingFor(index int) (int, bool) {
+	train := &s.trains[index]
+	if train.PendingTurnback() {
+		return noTrain, false
+	}
+	progress := train.Progress().Float64()
+	atBoundary := progress <= boundaryEpsilon
+	if train.Forward() {
+		atBoundary = progress >= 1-boundaryEpsilon
+	}
+	if !atBoundary {
+		return noTrain, false
+	}
+
+	next, exists := s.network.nextBlockIndex(train.block, train.Forward())
+	if !exists {
+		return noTrain, false
+	}
+	occupant := s.occupied[next]
+	if occupant == noTrain || occupant == index {
+		return noTrain, false
+	}
+	return occupant, true
+}
+
+// Deadlocks は待ちグラフの閉路をすべて返す（列車IDの小さい順）。
+// 閉路の外から閉路を待っている列車は巻き込まれているだけなので含めない。
+func (s *SimulationState) Deadlocks() []Deadlock {
+	var cycles cycleSet
+	s.findCycles(&cycles, &cycleScratch{})
+
+	var out []Deadlock
+	for i := range cycles.ends {
+		out = append(out, s.newDeadlock(cycles.cycle(i)))
+	}
+	return out
+}
+
+// cycleSet は待ちグラフの閉路の集まり。各閉路の列車の添字を、最も小さい添字から待ちの向きに続けて並べる。
+// 列車から出る辺は高々1本なので、1つの列車は高々1つの閉路にしか入らない。
+type cycleSet struct {
+	members []int
+	ends    []int // 各閉路の members での終わりの位置
+}
+
+func (c *cycleSet) cycle(i int) []int {
+	start := 0
+	if i > 0 {
+		start = c.ends[i-1]
+	}
+	return c.members[start:c.ends[i]]
+}
+
+func (c *cycleSet) reset() {
+	c.members = c.members[:0]
+	c.ends = c.ends[:0]
+}
+
+// cycleScratch は閉路を探すあいだの作業領域（刻みごとに使い回す）
+type cycleScratch struct {
+	graph   []int // 列車ごとの待っている相手の添字（待っていなければ noTrain）
+	visited []bool
+	onPath  []int // たどっている道での位置（道に無ければ -1）
+	path    []int
+	// cycleOf は閉路の先頭の列車ごとの、比べる側の cycleSet での閉路の番号（先頭でなければ -1）
+	cycleOf []int
+}
+
+// findCycles は待ちグラフの閉路を列車IDの小さい順に out へ入れる。
+// 待っている列車がいなければ何も割り当てずに返す（刻みごとに調べるため）。
+func (s *SimulationState) findCycles(out *cycleSet, scratch *cycleScratch) {
+	out.reset()
+	n := len(s.trains)
+	graph := resize(scratch.graph, n)
+	scratch.graph = graph
+	waiting := false
+	for i := range s.trains {
+		blockedBy, ok := s.waitingFor(i)
+		graph[i] = blockedBy
+		waiting = waiting || ok
+	}
+	if !waiting {
+		return
+	}
+
+	visited := resize(scratch.visited, n)
+	clear(visited)
+	onPath := resize(scratch.onPath, n)
+	for i := range onPath {
+		onPath[i] = -1
+	}
+	path := scratch.path[:0]
+	for start := range s.trains {
+		if visited[start] {
+			continue
+		}
+
+		// 出る辺が1本なので、たどった道の途中に戻ってきたらそこから先が閉路
+		path = path[:0]
+		current := start
+		for {
+			if i := onPath[current]; i >= 0 {
+				out.add(path[i:])
+				break
+			}
+			if visited[current] {
+				break
+			}
+			visited[current] = true
+			onPath[current] = len(path)
+			path = append(path, current)
+
+			current = graph[current]
+			if current == noTrain {
+				break
+			}
+		}
+		for _, i := range path {
+			onPath[i] = -1
+		}
+	}
+	scratch.visited, scratch.onPath, scratch.path = visited, onPath, path
+}
+
+// add は閉路を最も小さい添字（列車IDの最も小さい列車）から始まるように回して加える
+func (c *cycleSet) add(cycle []int) {
+	first := 0
+	for i, index := range cycle {
+		if index < cycle[first] {
+			first = i
+		}
+	}
+	for i := range cycle {
+		c.members = append(c.members, cycle[(first+i)%len(cycle)])
+	}
+	c.ends = append(c.ends, len(c.members))
+}
+
+// newDeadlock は正規化した閉路（列車の添字）を Deadlock にする
+func (s *SimulationState) newDeadlock(cycle []int) Deadlock {
+	trains := make([]TrainID, 0, len(cycle))
+	blocks := make([]BlockID, 0, len(cycle))
+	for _, index := range cycle {
+		train := &s.trains[index]
+		trains = append(trains, train.ID())
+		blocks = append(blocks, train.BlockID())
+	}
+
+	kind := DeadlockCircular
+	if len(cycle) == 2 && s.trains[cycle[0]].Forward() != s.trains[cycle[1]].Forward() {
+		kind = DeadlockHeadOn
+	}
+	return Deadlock{Kind: kind, Trains: trains, Blocks: blocks}
+}
+
+// emitNewDeadlocks は刻みの前の閉路 before には無かった待ち合いについて DEADLOCK_DETECTED を発生させる
+func (s *SimulationState) emitNewDeadlocks(before *cycleSet, trace *tickTrace) {
+	after := &s.scratch.deadlocksAfter
+	s.findCycles(after, &s.scratch.cycles)
+	if len(after.ends) == 0 {
+		return
+	}
+
+	cycleOf := resize(s.scratch.cycles.cycleOf, len(s.trains))
+	s.scratch.cycles.cycleOf = cycleOf
+	for i := range cycleOf {
+		cycleOf[i] = -1
+	}
+	for i := range before.ends {
+		cycleOf[before.cycle(i)[0]] = i
+	}
+	for i := range after.ends {
+		cycle := after.cycle(i)
+		if known := cycleOf[cycle[0]]; known >= 0 && slices.Equal(before.cycle(known), cycle) {
+			continue
+		}
+		d := s.newDeadlock(cycle)
+		trace.emit(Event{Type: EventDeadlockDetected, At: s.simTime, TrainID: d.Trains[0], BlockID: d.Blocks[0], Deadlock: &d})
+	}
+}
+
+// resize は buf を長さ n にして返す（足りなければ割り当て直す。中身は不定）
+func resize[T any](buf []T, n int) []T {
+	if cap(buf) < n {
+		return make([]T, n)
+	}
+	return buf[:n]
+}
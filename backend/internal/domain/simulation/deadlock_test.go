@@ -0,0 +1,76 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickWithEventsDetectsHeadOnDeadlockOnce(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "B1", 0.5, false, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	events, err := state.TickWithEvents(delta)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	var detected []Event
+	for _, e := range events {
+		if e.Type == EventDeadlockDetected {
+			detected = append(detected, e)
+		}
+	}
+	if len(detected) != 1 {
+		t.Fatalf("expected one deadlock event, got %+v", events)
+	}
+	d := detected[0].Deadlock
+	if d == nil || d.Kind != DeadlockHeadOn || len(d.Trains) != 2 {
+		t.Fatalf("unexpected deadlock: %+v", d)
+	}
+	if d.Trains[0].String() != "T0" || d.Trains[1].String() != "T1" || d.Blocks[0].String() != "B0" || d.Blocks[1].String() != "B1" {
+		t.Fatalf("unexpected deadlock members: %+v", d)
+	}
+
+	// 待ち合いが続いている間は改めて知らせない
+	events, err = state.TickWithEvents(delta)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	for _, e := range events {
+		if e.Type == EventDeadlockDetected {
+			t.Fatalf("expected no repeated deadlock event, got %+v", e)
+		}
+	}
+	if got := state.Deadlocks(); len(got) != 1 {
+		t.Fatalf("expected deadlock to persist, got %+v", got)
+	}
+}
+
+func TestDeadlocksIgnoresTrainWaitingBehindMovingTrain(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.9, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "B1", 0.1, true, 0.1)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	waitFor := state.WaitFor()
+	if len(waitFor) != 1 || waitFor[mustTrainID(t, "T0")].String() != "T1" {
+		t.Fatalf("unexpected wait-for graph: %+v", waitFor)
+	}
+	if got := state.Deadlocks(); len(got) != 0 {
+		t.Fatalf("expected no deadlock, got %+v", got)
+	}
+}
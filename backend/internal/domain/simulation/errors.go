@@ -1,23 +1,58 @@
 package simulation
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/right1121/railway-control-center-simulator/pkg/apperr"
+)
 
 var (
 	ErrTrainIDEmpty               = errors.New("train id is empty")
+	ErrSimulationIDEmpty          = errors.New("simulation id is empty")
 	ErrBlockIDEmpty               = errors.New("block id is empty")
 	ErrStationIDEmpty             = errors.New("station id is empty")
+	ErrLineIDEmpty                = errors.New("line id is empty")
+	ErrServiceIDEmpty             = errors.New("service id is empty")
 	ErrBlockProgressOutOfRange    = errors.New("block progress must be in range [0,1]")
 	ErrTickDeltaNotPositive       = errors.New("tick delta must be greater than zero")
+	ErrSimTimeNegative            = errors.New("sim time must not be negative")
 	ErrTrainSpeedNotPositive      = errors.New("train speed must be greater than zero")
 	ErrLineHasNoBlocks            = errors.New("line must have at least one block")
 	ErrLineStationsBlocksMismatch = errors.New("line must have blocks+1 stations")
 	ErrLineDuplicateStationID     = errors.New("line has duplicate station id")
 	ErrLineDuplicateBlockID       = errors.New("line has duplicate block id")
 	ErrLineConnectivityInvalid    = errors.New("line connectivity is invalid")
+	ErrLineInfoMismatch           = errors.New("line must have info for every station and block")
+	ErrStationPlatformsNegative   = errors.New("station platforms must not be negative")
+	ErrLineLayoutMismatch         = errors.New("line layout must place every station and block")
+	ErrLineLayoutInvalid          = errors.New("line layout has a non-finite point or a block polyline that does not join its stations")
+	ErrNetworkHasNoLines          = errors.New("network must have at least one line")
+	ErrNetworkDuplicateLineID     = errors.New("network has duplicate line id")
+	ErrTransferStationNotFound    = errors.New("transfer station is not in the network")
+	ErrTransferInvalid            = errors.New("transfer must link two different stations with a non-negative time")
 	ErrBlockNotFound              = errors.New("block not found")
 	ErrTrainAlreadyExists         = errors.New("train already exists")
 	ErrTrainNotFound              = errors.New("train not found")
 	ErrBlockOccupied              = errors.New("block is occupied")
 	ErrSimulationNotFound         = errors.New("simulation not found")
 	ErrSimulationAlreadyExists    = errors.New("simulation already exists")
+	ErrSimulationAlreadyStarted   = errors.New("simulation start time can only be set before it advances")
+	ErrReplayInvalidLog           = errors.New("replay input log is invalid")
+	ErrReplayDiverged             = errors.New("replay diverged from input log")
+	ErrRewindTargetUnavailable    = errors.New("rewind target is not available in history")
+	ErrUnknownEventType           = errors.New("unknown simulation event type")
+	ErrRunUntilTargetInPast       = errors.New("run until target is in the past")
+	ErrEventStepLimitExceeded     = errors.New("event engine step limit exceeded")
+	ErrUnknownPriorityRule        = errors.New("unknown priority rule")
+	ErrDuplicatePriorityRule      = errors.New("duplicate priority rule")
+	ErrUnknownEngine              = errors.New("unknown simulation engine")
+	ErrSectionBoundaryInvalid     = errors.New("section boundary must be an interior station of the line")
+	ErrSectionBoundaryDuplicate   = errors.New("duplicate section boundary")
+	ErrServiceDuplicateID         = errors.New("timetable has duplicate service id")
+	ErrServiceTooFewStops         = errors.New("service must have at least two stops")
+	ErrServiceTimesInvalid        = errors.New("service stop times must not go backwards")
+	ErrServiceStationNotFound     = errors.New("service stop is not a station of the network")
+
+	ErrVersionConflict = fmt.Errorf("%w: simulation version mismatch", apperr.ErrConflict)
 )
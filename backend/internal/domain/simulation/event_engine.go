@@ -0,0 +1,113 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Engine は時間の進め方
+type Engine string
+
+const (
+	// EngineTick は指定の幅を1回の刻みで進める（区間の端に着いた列車の折返しは次の刻みの初めになる）
+	EngineTick Engine = "TICK"
+	// EngineEvent は次にいずれかの列車が区間の端に着く時刻ごとに刻みを区切って進める。
+	// 区切りの時刻では、同じ幅で Tick したのと全く同じ状態になる。
+	EngineEvent Engine = "EVENT"
+)
+
+func ParseEngine(v string) (Engine, error) {
+	switch e := Engine(v); e {
+	case EngineTick, EngineEvent:
+		return e, nil
+	case "":
+		return EngineTick, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownEngine, v)
+	}
+}
+
+// NextEventDelay は次にいずれかの列車が区間の端（駅）に着くまでの時間を、ミリ秒に切り上げて返す。
+// 折返し待ちの列車は折り返した向きで、区間の端で待っている列車は次の区間に入れたものとして数える。
+// 列車がいなければ false を返す。
+func (s *SimulationState) NextEventDelay() (time.Duration, bool) {
+	found := false
+	var next time.Duration
+	for i := range s.trains {
+		train := &s.trains[i]
+		forward := train.Forward() != train.PendingTurnback()
+
+		progress := train.Progress().Float64()
+		remaining := progress
+		if forward {
+			remaining = 1.0 - progress
+		}
+		if remaining < boundaryEpsilon {
+			remaining = 1
+		}
+
+		millis := max(int64(math.Ceil(remaining/train.Speed()*1000-boundaryEpsilon)), 1)
+		delay := time.Duration(millis) * time.Millisecond
+		if !found || delay < next {
+			next = delay
+			found = true
+		}
+	}
+	if delay, ok := s.nextScheduledDelay(); ok && (!found || delay < next) {
+		next, found = delay, true
+	}
+	return next, found
+}
+
+// AdvanceByEvents は dt のあいだを EngineEvent で進め、区切った刻みの数を返す
+func (s *SimulationState) AdvanceByEvents(dt TickDelta) (int, error) {
+	return s.advanceByEvents(context.Background(), dt, 0, nil)
+}
+
+// AdvanceByEventsWithEvents は AdvanceByEvents と同じく進め、その間に起きた出来事を発生順に返す。
+// 区切った刻みが maxSteps（0 なら上限なし）を超えそうなら ErrEventStepLimitExceeded を、ctx が終わればそのエラーを返す
+// （途中まで進んだ状態は残るので、呼び出し側は複製で進めて捨てる）。
+func (s *SimulationState) AdvanceByEventsWithEvents(ctx context.Context, dt TickDelta, maxSteps int) ([]Event, error) {
+	var events []Event
+	trace := &tickTrace{onEvent: func(e Event) { events = append(events, e) }}
+	if _, err := s.advanceByEvents(ctx, dt, maxSteps, trace); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *SimulationState) advanceByEvents(ctx context.Context, dt TickDelta, maxSteps int, trace *tickTrace) (int, error) {
+	until := s.simTime.Add(dt.Duration())
+	steps := 0
+	for s.simTime.Millis() < until.Millis() {
+		if err := ctx.Err(); err != nil {
+			return steps, err
+		}
+		if maxSteps > 0 && steps >= maxSteps {
+			return steps, fmt.Errorf("%w: more than %d steps before %dms", ErrEventStepLimitExceeded, maxSteps, until.Millis())
+		}
+		step, err := s.nextEventStep(until, dt)
+		if err != nil {
+			return steps, err
+		}
+		if err := s.advance(step, trace); err != nil {
+			return steps, err
+		}
+		steps++
+	}
+	return steps, nil
+}
+
+// nextEventStep は until と limit を越えない範囲で、次の出来事の時刻までの刻みを返す
+func (s *SimulationState) nextEventStep(until SimTime, limit TickDelta) (TickDelta, error) {
+	step, err := stepTowards(s.simTime, until, limit)
+	if err != nil {
+		return TickDelta{}, err
+	}
+	if delay, ok := s.NextEventDelay(); ok && delay < step.Duration() {
+		return NewTickDelta(delay)
+	}
+	return step, nil
+}
@@ -0,0 +1,108 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdvanceByEventsMatchesTickEngineAtEventTimes(t *testing.T) {
+	byEvents := newTestState(t)
+	if err := byEvents.AddTrain(newTestTrain(t, "T0", "B0", 0.1, true, 0.3)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := byEvents.AddTrain(newTestTrain(t, "T1", "B1", 0.8, true, 0.45)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	byTicks := byEvents.Clone()
+
+	delta, _ := NewTickDelta(20 * time.Second)
+	steps, err := byEvents.AdvanceByEvents(delta)
+	if err != nil {
+		t.Fatalf("advance by events failed: %v", err)
+	}
+
+	// 同じ状態から、次の出来事の時刻ごとに Tick する
+	ticks := 0
+	for byTicks.SimTime().Millis() < 20_000 {
+		delay, ok := byTicks.NextEventDelay()
+		remaining := time.Duration(20_000-byTicks.SimTime().Millis()) * time.Millisecond
+		if !ok || delay > remaining {
+			delay = remaining
+		}
+		dt, _ := NewTickDelta(delay)
+		if err := byTicks.Tick(dt); err != nil {
+			t.Fatalf("tick failed: %v", err)
+		}
+		ticks++
+	}
+
+	if steps != ticks || steps < 5 {
+		t.Fatalf("expected the same number of event steps, got %d and %d", steps, ticks)
+	}
+	if diffs := DiffSnapshots(byTicks.Snapshot(), byEvents.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected identical state, got %v", diffs)
+	}
+}
+
+func TestAdvanceByEventsTurnsBackWithoutWaitingForNextTick(t *testing.T) {
+	byEvents := newTestState(t)
+	if err := byEvents.AddTrain(newTestTrain(t, "T0", "B1", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	byTick := byEvents.Clone()
+
+	delta, _ := NewTickDelta(3 * time.Second)
+	if _, err := byEvents.AdvanceByEvents(delta); err != nil {
+		t.Fatalf("advance by events failed: %v", err)
+	}
+	if err := byTick.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	// 1 秒で S2 に着いてすぐ折り返し、残り 2 秒で B1 を戻りきって B0 に入る
+	got := byEvents.Trains()[0]
+	if got.BlockID().String() != "B0" || got.Forward() || got.Progress().Float64() != 1 {
+		t.Fatalf("unexpected position with event engine: %s forward=%v %f", got.BlockID(), got.Forward(), got.Progress().Float64())
+	}
+	// 1 回の Tick では終端で次の刻みまで折返しを待つ
+	if got := byTick.Trains()[0]; got.BlockID().String() != "B1" || !got.PendingTurnback() {
+		t.Fatalf("unexpected position with tick engine: %s pending=%v", got.BlockID(), got.PendingTurnback())
+	}
+}
+
+func TestAdvanceByEventsRejectsTooManySteps(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(time.Hour)
+	if _, err := state.AdvanceByEventsWithEvents(context.Background(), delta, 10); !errors.Is(err, ErrEventStepLimitExceeded) {
+		t.Fatalf("expected %v, got %v", ErrEventStepLimitExceeded, err)
+	}
+}
+
+func TestReplayUsesRecordedEngine(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B1", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	records := []InputRecord{NewInitializedInput(state, time.Now())}
+
+	before := state.SimTime()
+	delta, _ := NewTickDelta(3 * time.Second)
+	if _, err := state.AdvanceByEvents(delta); err != nil {
+		t.Fatalf("advance by events failed: %v", err)
+	}
+	records = append(records, NewTickInput(before, delta, EngineEvent, state, time.Now()))
+
+	replayed, err := Replay(records)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if diffs := DiffSnapshots(state.Snapshot(), replayed.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected identical state, got %v", diffs)
+	}
+}
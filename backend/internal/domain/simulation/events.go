@@ -0,0 +1,48 @@
+package simulation
+
+import "fmt"
+
+type EventType string
+
+const (
+	// EventTrainArrived は列車が区間を進みきって駅に着いた
+	EventTrainArrived EventType = "TRAIN_ARRIVED"
+	// EventTrainBlocked は列車が次の区間の在線で進めなかった（進めない間は刻みごとに発生する）
+	EventTrainBlocked EventType = "TRAIN_BLOCKED"
+	// EventTrainTurnedBack は列車が終端で折り返した
+	EventTrainTurnedBack EventType = "TRAIN_TURNED_BACK"
+	// EventDeadlockDetected は列車どうしの待ち合いが新たに生じた（続いている間は再び発生しない）
+	EventDeadlockDetected EventType = "DEADLOCK_DETECTED"
+	// EventTrainEntered は予約した列車が始発の時刻を迎えて網に入った
+	EventTrainEntered EventType = "TRAIN_ENTERED"
+)
+
+func ParseEventType(v string) (EventType, error) {
+	switch t := EventType(v); t {
+	case EventTrainArrived, EventTrainBlocked, EventTrainTurnedBack, EventDeadlockDetected, EventTrainEntered:
+		return t, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownEventType, v)
+	}
+}
+
+// Event は進行中に起きた出来事。At は発生した刻みの終わりのシミュレーション時刻。
+// StationID は到着・折返し、BlockID は抑止と入線、BlockedBy は抑止のときだけ持つ。
+// 待ち合いでは Deadlock に関わる列車と区間を持ち、TrainID と BlockID はその先頭を指す。
+type Event struct {
+	Type      EventType
+	At        SimTime
+	TrainID   TrainID
+	StationID StationID
+	BlockID   BlockID
+	BlockedBy TrainID
+	Deadlock  *Deadlock
+}
+
+// involves は出来事に列車が関わっているかを返す
+func (e Event) involves(trainID TrainID) bool {
+	if e.Deadlock != nil {
+		return e.Deadlock.involves(trainID)
+	}
+	return e.TrainID == trainID
+}
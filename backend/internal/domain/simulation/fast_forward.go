@@ -0,0 +1,237 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type StopReasonKind string
+
+const (
+	StopTargetReached       StopReasonKind = "TARGET_REACHED"
+	StopTrainReachedStation StopReasonKind = "TRAIN_REACHED_STATION"
+	StopTrainBlocked        StopReasonKind = "TRAIN_BLOCKED"
+	StopEventOccurred       StopReasonKind = "EVENT_OCCURRED"
+	StopTrainsFacing        StopReasonKind = "TRAINS_FACING"
+	StopStepLimitReached    StopReasonKind = "STEP_LIMIT_REACHED"
+)
+
+// StopCondition は進行を途中で止める条件。刻みごとに、その刻みで起きた出来事と刻み終わりの状態で判定する。
+type StopCondition interface {
+	satisfied(e Event, w *ConditionWatcher, state *SimulationState) (StopReasonKind, bool)
+}
+
+// TrainReachesStation は列車が駅に着いたら止める
+type TrainReachesStation struct {
+	TrainID   TrainID
+	StationID StationID
+}
+
+// TrainBlockedFor は列車が Duration 以上続けて進めなかったら止める（1回の待ちにつき1度だけ成立する）。
+// TrainID が空ならどの列車でもよい。待ち時間は ConditionWatcher が観測を始めてから数える。
+type TrainBlockedFor struct {
+	TrainID  TrainID
+	Duration time.Duration
+}
+
+// TrainsFaceEachOther は列車が、向かってくる列車の在線で進めなくなったら止める（単線での正面衝突の手前）。
+// 進めなくなった最初の刻みでだけ成立する。
+type TrainsFaceEachOther struct{}
+
+// EventOccurs は指定した種類の出来事が起きたら止める。TrainID が空ならどの列車でもよい。
+type EventOccurs struct {
+	Type    EventType
+	TrainID TrainID
+}
+
+// RunUntilSpec は早送りの条件。Until に達するか、いずれかの条件が成立するまで Step 刻みで進める。
+// Engine が EngineEvent なら、Step を上限に次の出来事の時刻ごとに区切る（空なら EngineTick）。
+// MaxSteps は進める刻みの数の上限（0 なら上限なし）。達したら Until の手前でも止める。
+type RunUntilSpec struct {
+	Until      SimTime
+	Step       TickDelta
+	Engine     Engine
+	Conditions []StopCondition
+	MaxSteps   int
+}
+
+// StopReason は早送りが止まった理由。目標時刻・刻みの上限に達した場合は ConditionIndex が -1 で Event は nil。
+type StopReason struct {
+	Kind           StopReasonKind
+	ConditionIndex int
+	Event          *Event
+}
+
+type RunUntilResult struct {
+	From   SimTime
+	To     SimTime
+	Steps  int
+	Reason StopReason
+	// Deadlocks は進める間に新たに生じた待ち合い（EventDeadlockDetected）。他の出来事は刻みごとに捨てる
+	Deadlocks []Event
+}
+
+// RunUntil は状態を Step 刻みで進め、止まった理由を返す。
+// 条件は刻みの終わりで判定するので、止まる時刻は刻みの粒度になる。
+func (s *SimulationState) RunUntil(spec RunUntilSpec) (RunUntilResult, error) {
+	return s.RunUntilContext(context.Background(), spec)
+}
+
+// RunUntilContext は RunUntil と同じく進め、ctx が終われば刻みの合間でやめてそのエラーを返す
+// （途中まで進んだ状態は残るので、呼び出し側は複製で進めて捨てる）。
+func (s *SimulationState) RunUntilContext(ctx context.Context, spec RunUntilSpec) (RunUntilResult, error) {
+	if spec.Step.Duration() <= 0 {
+		return RunUntilResult{}, ErrTickDeltaNotPositive
+	}
+	if spec.Until.Millis() < s.simTime.Millis() {
+		return RunUntilResult{}, fmt.Errorf("%w: until %dms is before %dms", ErrRunUntilTargetInPast, spec.Until.Millis(), s.simTime.Millis())
+	}
+
+	watcher := NewConditionWatcher()
+	var events []Event
+	trace := &tickTrace{onEvent: func(e Event) { events = append(events, e) }}
+
+	result := RunUntilResult{From: s.simTime}
+	for s.simTime.Millis() < spec.Until.Millis() {
+		if err := ctx.Err(); err != nil {
+			return RunUntilResult{}, err
+		}
+		if spec.MaxSteps > 0 && result.Steps >= spec.MaxSteps {
+			result.To = s.simTime
+			result.Reason = StopReason{Kind: StopStepLimitReached, ConditionIndex: -1}
+			return result, nil
+		}
+		dt, err := stepTowards(s.simTime, spec.Until, spec.Step)
+		if spec.Engine == EngineEvent {
+			dt, err = s.nextEventStep(spec.Until, spec.Step)
+		}
+		if err != nil {
+			return RunUntilResult{}, err
+		}
+
+		events = events[:0]
+		from := s.simTime
+		if err := s.advance(dt, trace); err != nil {
+			return RunUntilResult{}, err
+		}
+		result.Steps++
+		for _, e := range events {
+			if e.Type == EventDeadlockDetected {
+				result.Deadlocks = append(result.Deadlocks, e)
+			}
+		}
+
+		if reason, stop := watcher.Step(from, events, s, spec.Conditions); stop {
+			result.To = s.simTime
+			result.Reason = reason
+			return result, nil
+		}
+	}
+
+	result.To = s.simTime
+	result.Reason = StopReason{Kind: StopTargetReached, ConditionIndex: -1}
+	return result, nil
+}
+
+// stepTowards は until を越えない刻みを返す（最後の刻みだけ短くなる）
+func stepTowards(now, until SimTime, step TickDelta) (TickDelta, error) {
+	remaining := time.Duration(until.Millis()-now.Millis()) * time.Millisecond
+	if remaining >= step.Duration() {
+		return step, nil
+	}
+	return NewTickDelta(remaining)
+}
+
+// ConditionWatcher は停止条件を刻みごとに判定する。
+// 列車が続けて進めずにいる時間を刻みをまたいで覚えておくので、同じ状態の進行には同じ watcher を使い続ける。
+type ConditionWatcher struct {
+	// blockedSince は列車ごとに、続けて進めなくなった最初の刻みの始まりの時刻
+	blockedSince map[string]SimTime
+	// previous は直前に判定した刻みの終わりの時刻（未判定なら observed が false）
+	previous SimTime
+	observed bool
+}
+
+func NewConditionWatcher() *ConditionWatcher {
+	return &ConditionWatcher{blockedSince: make(map[string]SimTime)}
+}
+
+// Step は from に始まる1刻みぶんの出来事を受け取り、最初に成立した条件を返す。
+// 同じ刻みで複数成立した場合は、先に起きた出来事・先に並んだ条件を優先する。
+func (w *ConditionWatcher) Step(from SimTime, events []Event, state *SimulationState, conditions []StopCondition) (StopReason, bool) {
+	blockedNow := make(map[string]struct{})
+	for _, e := range events {
+		if e.Type != EventTrainBlocked {
+			continue
+		}
+		key := e.TrainID.String()
+		blockedNow[key] = struct{}{}
+		// 刻みの途中で止まった列車も、その刻みの始まりから待っていたものとして数える
+		if _, ok := w.blockedSince[key]; !ok {
+			w.blockedSince[key] = from
+		}
+	}
+	for key := range w.blockedSince {
+		if _, ok := blockedNow[key]; !ok {
+			delete(w.blockedSince, key)
+		}
+	}
+
+	defer func() {
+		w.previous = state.SimTime()
+		w.observed = true
+	}()
+	for _, e := range events {
+		for i, c := range conditions {
+			if kind, ok := c.satisfied(e, w, state); ok {
+				event := e
+				return StopReason{Kind: kind, ConditionIndex: i, Event: &event}, true
+			}
+		}
+	}
+	return StopReason{}, false
+}
+
+// blockedFor は列車が続けて進めずにいる時間を、この刻みと直前の刻みの時点で返す。
+// 直前の刻みでは進めていた（この刻みで進めなくなった）場合 before は負になる。
+func (w *ConditionWatcher) blockedFor(e Event) (now, before time.Duration) {
+	since := w.blockedSince[e.TrainID.String()]
+	now = time.Duration(e.At.Millis()-since.Millis()) * time.Millisecond
+	if !w.observed || w.previous.Millis() <= since.Millis() {
+		return now, -1
+	}
+	return now, time.Duration(w.previous.Millis()-since.Millis()) * time.Millisecond
+}
+
+func (c TrainReachesStation) satisfied(e Event, _ *ConditionWatcher, _ *SimulationState) (StopReasonKind, bool) {
+	return StopTrainReachedStation, e.Type == EventTrainArrived && e.TrainID == c.TrainID && e.StationID == c.StationID
+}
+
+func (c TrainBlockedFor) satisfied(e Event, w *ConditionWatcher, _ *SimulationState) (StopReasonKind, bool) {
+	if e.Type != EventTrainBlocked || !matchesTrain(c.TrainID, e) {
+		return StopTrainBlocked, false
+	}
+	// 待ち時間が Duration を越えた刻みでだけ成立させる（再開直後に同じ待ちで止め直さない）
+	now, before := w.blockedFor(e)
+	return StopTrainBlocked, now >= c.Duration && before < c.Duration
+}
+
+func (c TrainsFaceEachOther) satisfied(e Event, w *ConditionWatcher, state *SimulationState) (StopReasonKind, bool) {
+	if e.Type != EventTrainBlocked {
+		return StopTrainsFacing, false
+	}
+	// 進めなくなった最初の刻みでだけ成立させる
+	if _, before := w.blockedFor(e); before >= 0 {
+		return StopTrainsFacing, false
+	}
+	return StopTrainsFacing, state.approaching(e.BlockedBy, e.TrainID)
+}
+
+func (c EventOccurs) satisfied(e Event, _ *ConditionWatcher, _ *SimulationState) (StopReasonKind, bool) {
+	return StopEventOccurred, e.Type == c.Type && matchesTrain(c.TrainID, e)
+}
+
+func matchesTrain(want TrainID, e Event) bool {
+	return want.String() == "" || e.involves(want)
+}
@@ -0,0 +1,192 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunUntilStopsWhenTrainReachesStation(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	t0, _ := NewTrainID("T0")
+	s2, _ := NewStationID("S2")
+	step, _ := NewTickDelta(500 * time.Millisecond)
+	result, err := state.RunUntil(RunUntilSpec{
+		Until:      mustSimTime(t, 60_000),
+		Step:       step,
+		Conditions: []StopCondition{TrainReachesStation{TrainID: t0, StationID: s2}},
+	})
+	if err != nil {
+		t.Fatalf("run until failed: %v", err)
+	}
+
+	if result.Reason.Kind != StopTrainReachedStation || result.Reason.ConditionIndex != 0 {
+		t.Fatalf("unexpected stop reason: %+v", result.Reason)
+	}
+	if result.To.Millis() != 4000 || state.SimTime().Millis() != 4000 || result.Steps != 8 {
+		t.Fatalf("expected stop at 4000ms after 8 steps, got %+v", result)
+	}
+}
+
+func TestRunUntilStopsWhenTrainBlockedTooLong(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "B1", 0.0, true, 0.1)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	step, _ := NewTickDelta(time.Second)
+	result, err := state.RunUntil(RunUntilSpec{
+		Until: mustSimTime(t, 60_000),
+		Step:  step,
+		Conditions: []StopCondition{
+			EventOccurs{Type: EventTrainTurnedBack},
+			TrainBlockedFor{Duration: 3 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("run until failed: %v", err)
+	}
+
+	// T0 は 1 秒で S1 に着き、B1 の T1 を待つ。待ちはその刻みの始まり（0ms）から数える
+	if result.Reason.Kind != StopTrainBlocked || result.Reason.ConditionIndex != 1 {
+		t.Fatalf("unexpected stop reason: %+v", result.Reason)
+	}
+	if result.To.Millis() != 3000 || result.Reason.Event.TrainID != mustTrainID(t, "T0") {
+		t.Fatalf("expected T0 blocked until 3000ms, got %+v at %d", result.Reason.Event, result.To.Millis())
+	}
+}
+
+func TestRunUntilReachesTargetAndReplays(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.3)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	records := []InputRecord{NewInitializedInput(state, time.Now())}
+
+	step, _ := NewTickDelta(700 * time.Millisecond)
+	spec := RunUntilSpec{Until: mustSimTime(t, 10_000), Step: step}
+	result, err := state.RunUntil(spec)
+	if err != nil {
+		t.Fatalf("run until failed: %v", err)
+	}
+	if result.Reason.Kind != StopTargetReached || result.To.Millis() != 10_000 {
+		t.Fatalf("expected target reached at 10000ms, got %+v", result)
+	}
+	records = append(records, NewFastForwardInput(result, spec, state, time.Now()))
+
+	replayed, err := Replay(records)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if diffs := DiffSnapshots(state.Snapshot(), replayed.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected identical state, got %v", diffs)
+	}
+
+	// 早送りの途中も刻みの区切りなら同じ刻みで再現する
+	mid, err := StateAt(records, mustSimTime(t, 2100))
+	if err != nil {
+		t.Fatalf("state at failed: %v", err)
+	}
+	if got := mid.Trains()[0].Progress().Float64(); got < 0.629 || got > 0.631 {
+		t.Fatalf("expected progress 0.63 at 2.1s, got %v", got)
+	}
+	// 刻みの途中には戻れない
+	if _, err := StateAt(records, mustSimTime(t, 2000)); !errors.Is(err, ErrRewindTargetUnavailable) {
+		t.Fatalf("expected ErrRewindTargetUnavailable, got %v", err)
+	}
+}
+
+func mustTrainID(t *testing.T, v string) TrainID {
+	t.Helper()
+
+	id, err := NewTrainID(v)
+	if err != nil {
+		t.Fatalf("new train id failed: %v", err)
+	}
+	return id
+}
+
+func TestConditionWatcherDetectsFacingTrainsAcrossTicks(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "B1", 0.5, false, 0.1)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	watcher := NewConditionWatcher()
+	conditions := []StopCondition{TrainsFaceEachOther{}}
+	dt, _ := NewTickDelta(500 * time.Millisecond)
+
+	var reason StopReason
+	for !stopped(t, state, watcher, conditions, dt, &reason) {
+		if state.SimTime().Millis() > 5000 {
+			t.Fatalf("expected facing trains to be detected")
+		}
+	}
+	// T0 は 1 秒で S1 に着き、B1 の T1 に阻まれる
+	if reason.Kind != StopTrainsFacing || state.SimTime().Millis() != 1000 {
+		t.Fatalf("expected facing trains at 1000ms, got %+v at %d", reason, state.SimTime().Millis())
+	}
+	if reason.Event.TrainID != mustTrainID(t, "T0") || reason.Event.BlockedBy != mustTrainID(t, "T1") {
+		t.Fatalf("unexpected event: %+v", reason.Event)
+	}
+}
+
+func stopped(t *testing.T, state *SimulationState, watcher *ConditionWatcher, conditions []StopCondition, dt TickDelta, reason *StopReason) bool {
+	t.Helper()
+
+	from := state.SimTime()
+	events, err := state.TickWithEvents(dt)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	r, stop := watcher.Step(from, events, state, conditions)
+	*reason = r
+	return stop
+}
+
+func TestRunUntilStopsAtStepLimit(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	step, _ := NewTickDelta(time.Hour)
+	result, err := state.RunUntil(RunUntilSpec{Until: mustSimTime(t, 3_600_000), Step: step, Engine: EngineEvent, MaxSteps: 3})
+	if err != nil {
+		t.Fatalf("run until failed: %v", err)
+	}
+	if result.Reason.Kind != StopStepLimitReached || result.Reason.ConditionIndex != -1 || result.Steps != 3 {
+		t.Fatalf("expected to stop at the step limit, got %+v", result)
+	}
+	if result.To != state.SimTime() || result.To.Millis() >= 3_600_000 {
+		t.Fatalf("expected to stop before the target, got %+v", result)
+	}
+}
+
+func TestRunUntilContextStopsWhenCancelled(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	step, _ := NewTickDelta(time.Second)
+	if _, err := state.RunUntilContext(ctx, RunUntilSpec{Until: mustSimTime(t, 60_000), Step: step}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if _, err := state.AdvanceByEventsWithEvents(ctx, step, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
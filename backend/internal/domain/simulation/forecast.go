@@ -0,0 +1,258 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+)
+
+// HypotheticalCommand は予測でだけ適用する仮の指令
+type HypotheticalCommand interface {
+	applyTo(run *forecastRun) error
+}
+
+// HoldTrain は列車をその場で Duration のあいだ抑止する
+type HoldTrain struct {
+	TrainID  TrainID
+	Duration time.Duration
+}
+
+// SetTrainSpeed は列車の速度（区間/秒）を変える
+type SetTrainSpeed struct {
+	TrainID TrainID
+	Speed   float64
+}
+
+// ForecastSpec は予測の条件
+type ForecastSpec struct {
+	Commands []HypotheticalCommand
+	Horizon  time.Duration
+	Step     TickDelta
+}
+
+// PredictedArrival は列車が駅に着く予測時刻
+type PredictedArrival struct {
+	TrainID   TrainID
+	StationID StationID
+	At        SimTime
+}
+
+// PredictedConflict は列車が先行列車の在線で進めない区間と期間
+type PredictedConflict struct {
+	TrainID   TrainID
+	BlockID   BlockID
+	BlockedBy TrainID
+	From      SimTime
+	Until     SimTime
+}
+
+// PredictedDelay は仮の指令がない場合と比べた着時刻の遅れ。
+// 予測の範囲内に着かなくなった場合は Reached が false で、遅れは範囲の終わりまでの下限値になる。
+type PredictedDelay struct {
+	TrainID   TrainID
+	StationID StationID
+	Baseline  SimTime
+	Expected  SimTime
+	Reached   bool
+	Delay     time.Duration
+}
+
+type ForecastResult struct {
+	From      SimTime
+	Until     SimTime
+	Arrivals  []PredictedArrival
+	Conflicts []PredictedConflict
+	Delays    []PredictedDelay
+	Final     *SimulationState
+}
+
+// Forecast は状態を複製し、仮の指令を適用して Horizon だけ先まで Step 刻みで進めた結果を返す。
+// 元の状態は変更しない。遅れは指令を適用しない複製との比較で求める。
+func Forecast(state *SimulationState, spec ForecastSpec) (ForecastResult, error) {
+	if spec.Horizon <= 0 || spec.Step.Duration() <= 0 {
+		return ForecastResult{}, ErrTickDeltaNotPositive
+	}
+
+	baseline := newForecastRun(state)
+	if err := baseline.runFor(spec.Horizon, spec.Step); err != nil {
+		return ForecastResult{}, err
+	}
+
+	run := newForecastRun(state)
+	for _, command := range spec.Commands {
+		if err := command.applyTo(run); err != nil {
+			return ForecastResult{}, err
+		}
+	}
+	if err := run.runFor(spec.Horizon, spec.Step); err != nil {
+		return ForecastResult{}, err
+	}
+
+	return ForecastResult{
+		From:      state.SimTime(),
+		Until:     run.state.SimTime(),
+		Arrivals:  run.arrivals,
+		Conflicts: run.closeConflicts(),
+		Delays:    compareArrivals(baseline.arrivals, run.arrivals, run.state.SimTime()),
+		Final:     run.state,
+	}, nil
+}
+
+func (c HoldTrain) applyTo(run *forecastRun) error {
+	if _, ok := run.state.train(c.TrainID); !ok {
+		return fmt.Errorf("%w: %s", ErrTrainNotFound, c.TrainID.String())
+	}
+	if c.Duration <= 0 {
+		return ErrTickDeltaNotPositive
+	}
+	run.trace.holdUntil[c.TrainID.String()] = run.state.SimTime().Add(c.Duration)
+	return nil
+}
+
+func (c SetTrainSpeed) applyTo(run *forecastRun) error {
+	train, ok := run.state.train(c.TrainID)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTrainNotFound, c.TrainID.String())
+	}
+	return train.setSpeed(c.Speed)
+}
+
+type forecastRun struct {
+	state    *SimulationState
+	trace    *tickTrace
+	arrivals []PredictedArrival
+
+	conflicts []PredictedConflict
+	// waiting は進めずにいる列車ごとの、継続中の conflicts の添字
+	waiting map[string]int
+	// blockedThisStep は直近の1刻みで進めなかった列車
+	blockedThisStep map[string]struct{}
+}
+
+func newForecastRun(state *SimulationState) *forecastRun {
+	run := &forecastRun{
+		state:           state.Clone(),
+		waiting:         make(map[string]int),
+		blockedThisStep: make(map[string]struct{}),
+	}
+	run.trace = &tickTrace{
+		holdUntil: make(map[string]SimTime),
+		onEvent:   run.record,
+	}
+	return run
+}
+
+func (r *forecastRun) runFor(horizon time.Duration, step TickDelta) error {
+	end := r.state.SimTime().Add(horizon)
+	for r.state.SimTime().Millis() < end.Millis() {
+		dt, err := stepTowards(r.state.SimTime(), end, step)
+		if err != nil {
+			return err
+		}
+
+		before := r.state.SimTime()
+		clear(r.blockedThisStep)
+		if err := r.state.advance(dt, r.trace); err != nil {
+			return err
+		}
+		r.endWaitsNotBlockedSince(before)
+	}
+	return nil
+}
+
+func (r *forecastRun) record(e Event) {
+	switch e.Type {
+	case EventTrainArrived:
+		r.arrivals = append(r.arrivals, PredictedArrival{TrainID: e.TrainID, StationID: e.StationID, At: e.At})
+	case EventTrainBlocked:
+		r.recordBlocked(e)
+	}
+}
+
+func (r *forecastRun) recordBlocked(e Event) {
+	key := e.TrainID.String()
+	r.blockedThisStep[key] = struct{}{}
+
+	if i, ok := r.waiting[key]; ok {
+		c := &r.conflicts[i]
+		if c.BlockID == e.BlockID && c.BlockedBy == e.BlockedBy {
+			c.Until = e.At
+			return
+		}
+	}
+	r.waiting[key] = len(r.conflicts)
+	r.conflicts = append(r.conflicts, PredictedConflict{
+		TrainID:   e.TrainID,
+		BlockID:   e.BlockID,
+		BlockedBy: e.BlockedBy,
+		From:      e.At,
+		Until:     e.At,
+	})
+}
+
+// endWaitsNotBlockedSince は直近の刻みで進めた列車の待ちを、刻みの開始時刻で閉じる
+func (r *forecastRun) endWaitsNotBlockedSince(stepStart SimTime) {
+	for key, i := range r.waiting {
+		if _, still := r.blockedThisStep[key]; still {
+			continue
+		}
+		if r.conflicts[i].Until.Millis() < stepStart.Millis() {
+			r.conflicts[i].Until = stepStart
+		}
+		delete(r.waiting, key)
+	}
+}
+
+// closeConflicts は予測の終わりまで続いた待ちを終端時刻で閉じて返す
+func (r *forecastRun) closeConflicts() []PredictedConflict {
+	for _, i := range r.waiting {
+		r.conflicts[i].Until = r.state.SimTime()
+	}
+	return r.conflicts
+}
+
+// compareArrivals は同じ列車・駅の n 回目の到着どうしを比べる
+func compareArrivals(baseline, predicted []PredictedArrival, until SimTime) []PredictedDelay {
+	type visit struct {
+		train, station string
+		n              int
+	}
+	index := func(arrivals []PredictedArrival) (map[visit]PredictedArrival, []visit) {
+		counts := make(map[[2]string]int)
+		byVisit := make(map[visit]PredictedArrival, len(arrivals))
+		order := make([]visit, 0, len(arrivals))
+		for _, a := range arrivals {
+			pair := [2]string{a.TrainID.String(), a.StationID.String()}
+			v := visit{train: pair[0], station: pair[1], n: counts[pair]}
+			counts[pair]++
+			byVisit[v] = a
+			order = append(order, v)
+		}
+		return byVisit, order
+	}
+
+	baseByVisit, order := index(baseline)
+	predByVisit, _ := index(predicted)
+
+	var delays []PredictedDelay
+	for _, v := range order {
+		base := baseByVisit[v]
+		pred, reached := predByVisit[v]
+		expected := until
+		if reached {
+			expected = pred.At
+		}
+		delay := time.Duration(expected.Millis()-base.At.Millis()) * time.Millisecond
+		if reached && delay == 0 {
+			continue
+		}
+		delays = append(delays, PredictedDelay{
+			TrainID:   base.TrainID,
+			StationID: base.StationID,
+			Baseline:  base.At,
+			Expected:  expected,
+			Reached:   reached,
+			Delay:     delay,
+		})
+	}
+	return delays
+}
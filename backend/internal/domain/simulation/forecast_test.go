@@ -0,0 +1,96 @@
+package simulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestForecastPredictsDelayAndConflictWithoutTouchingState(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "B1", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	before := state.Snapshot()
+
+	t1, _ := NewTrainID("T1")
+	step, _ := NewTickDelta(time.Second)
+	result, err := Forecast(state, ForecastSpec{
+		Commands: []HypotheticalCommand{HoldTrain{TrainID: t1, Duration: time.Minute}},
+		Horizon:  2 * time.Minute,
+		Step:     step,
+	})
+	if err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+
+	if diffs := DiffSnapshots(before, state.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected live state untouched, got %v", diffs)
+	}
+	if result.Until.Millis() != 120_000 {
+		t.Fatalf("expected forecast until 120000, got %d", result.Until.Millis())
+	}
+
+	// T1 は抑止がなければ 2 秒で S2 に着く
+	var found bool
+	for _, d := range result.Delays {
+		if d.TrainID.String() == "T1" && d.StationID.String() == "S2" && d.Baseline.Millis() == 2000 {
+			found = true
+			if !d.Reached || d.Delay != time.Minute {
+				t.Fatalf("expected T1 delayed by 1m at S2, got %+v", d)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected delay for T1 at S2, got %+v", result.Delays)
+	}
+
+	if len(result.Conflicts) == 0 {
+		t.Fatalf("expected conflicts, got none")
+	}
+	c := result.Conflicts[0]
+	if c.TrainID.String() != "T0" || c.BlockID.String() != "B1" || c.BlockedBy.String() != "T1" {
+		t.Fatalf("unexpected conflict: %+v", c)
+	}
+	if c.From.Millis() != 1000 || c.Until.Millis() < 60_000 {
+		t.Fatalf("expected T0 to wait from 1s past the hold, got %d..%d", c.From.Millis(), c.Until.Millis())
+	}
+}
+
+func TestForecastRejectsCommandForUnknownTrain(t *testing.T) {
+	state := newTestState(t)
+	unknown, _ := NewTrainID("T9")
+	step, _ := NewTickDelta(time.Second)
+
+	_, err := Forecast(state, ForecastSpec{
+		Commands: []HypotheticalCommand{SetTrainSpeed{TrainID: unknown, Speed: 1}},
+		Horizon:  time.Minute,
+		Step:     step,
+	})
+	if !errors.Is(err, ErrTrainNotFound) {
+		t.Fatalf("expected ErrTrainNotFound, got %v", err)
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	clone := state.Clone()
+	dt, _ := NewTickDelta(3 * time.Second)
+	if err := clone.Tick(dt); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	if state.SimTime().Millis() != 0 || state.Trains()[0].BlockID().String() != "B0" {
+		t.Fatalf("expected original untouched, got %+v", state.Snapshot())
+	}
+	if clone.Trains()[0].BlockID().String() != "B1" {
+		t.Fatalf("expected clone train on B1, got %s", clone.Trains()[0].BlockID().String())
+	}
+}
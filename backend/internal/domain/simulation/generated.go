@@ -0,0 +1,42 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GenerateLine は blocks 区間の路線（駅は S0..、区間は B0..）と、それに等間隔に置く trains 本の列車（T0..）を作る。
+// 向きと速さを交互に変えて、追い付き・行き違い・折返しが起きるようにする。
+// 実際の路線データに依らず、路線の大きさごとに Tick の負荷を測るためのもの（ベンチマークなど）。
+func GenerateLine(blocks, trains int) (*Line, []*Train, error) {
+	if trains < 0 || trains > blocks {
+		return nil, nil, errors.New("generated trains must be between 0 and the number of blocks")
+	}
+
+	stationIDs := make([]StationID, 0, blocks+1)
+	for i := 0; i <= blocks; i++ {
+		id, _ := NewStationID(fmt.Sprintf("S%d", i))
+		stationIDs = append(stationIDs, id)
+	}
+	blockIDs := make([]BlockID, 0, blocks)
+	for i := 0; i < blocks; i++ {
+		id, _ := NewBlockID(fmt.Sprintf("B%d", i))
+		blockIDs = append(blockIDs, id)
+	}
+	line, err := NewLine(stationIDs, blockIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	placed := make([]*Train, 0, trains)
+	for i := 0; i < trains; i++ {
+		id, _ := NewTrainID(fmt.Sprintf("T%d", i))
+		progress, _ := NewBlockProgress(0.5)
+		train, err := NewTrain(id, blockIDs[i*(blocks/trains)], progress, i%3 != 0, 0.4+0.1*float64(i%4))
+		if err != nil {
+			return nil, nil, err
+		}
+		placed = append(placed, train)
+	}
+	return line, placed, nil
+}
@@ -19,6 +19,20 @@ func (id TrainID) String() string {
 	return id.value
 }
 
+type SimulationID struct{ value string }
+
+func NewSimulationID(v string) (SimulationID, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return SimulationID{}, ErrSimulationIDEmpty
+	}
+	return SimulationID{value: v}, nil
+}
+
+func (id SimulationID) String() string {
+	return id.value
+}
+
 type BlockID struct{ value string }
 type StationID struct{ value string }
 
@@ -46,8 +60,44 @@ func (id StationID) String() string {
 	return id.value
 }
 
+type LineID struct{ value string }
+
+func NewLineID(v string) (LineID, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return LineID{}, ErrLineIDEmpty
+	}
+	return LineID{value: v}, nil
+}
+
+func (id LineID) String() string {
+	return id.value
+}
+
+// ServiceID は時刻表の列車（運行）の番号
+type ServiceID struct{ value string }
+
+func NewServiceID(v string) (ServiceID, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return ServiceID{}, ErrServiceIDEmpty
+	}
+	return ServiceID{value: v}, nil
+}
+
+func (id ServiceID) String() string {
+	return id.value
+}
+
 type SimTime struct{ millis int64 }
 
+func NewSimTime(millis int64) (SimTime, error) {
+	if millis < 0 {
+		return SimTime{}, ErrSimTimeNegative
+	}
+	return SimTime{millis: millis}, nil
+}
+
 func (t SimTime) Add(dt time.Duration) SimTime {
 	return SimTime{millis: t.millis + dt.Milliseconds()}
 }
@@ -0,0 +1,146 @@
+package simulation
+
+import (
+	"context"
+	"time"
+)
+
+// InputKind は状態を変える入力の種類
+type InputKind string
+
+const (
+	// InputInitialized はシミュレーション生成時の初期状態（State を持つ）
+	InputInitialized InputKind = "INITIALIZED"
+	// InputRestored はスナップショット取り込みによる状態の置き換え（State を持つ）
+	InputRestored InputKind = "RESTORED"
+	// InputTick は時間の進行（DeltaMillis を持つ）
+	InputTick InputKind = "TICK"
+	// InputCheckpoint は定期的に残す状態の控え（State を持つ）。状態は変えない
+	InputCheckpoint InputKind = "CHECKPOINT"
+	// InputRewound は過去の時刻への巻き戻し（State に巻き戻し後の状態を持つ）
+	InputRewound InputKind = "REWOUND"
+	// InputFastForward は StepMillis 刻みでの DeltaMillis だけの早送り
+	InputFastForward InputKind = "FAST_FORWARD"
+	// InputPriorityRulesChanged は区間の競合に使う規則の変更（State に変更後の状態を持つ）
+	InputPriorityRulesChanged InputKind = "PRIORITY_RULES_CHANGED"
+)
+
+// InputRecord はシミュレーションに加えられた入力1件。
+// 初期状態からこれを順に適用すれば同じ SimulationState が再現できる。
+type InputRecord struct {
+	Seq           int64 // 1始まりの連番（InputLogRepository が採番する）
+	Kind          InputKind
+	RecordedAt    time.Time
+	SimTimeMillis int64 // 入力を適用する直前のシミュレーション時刻
+	DeltaMillis   int64
+	StepMillis    int64  // 早送りの刻み（FAST_FORWARD のみ）
+	Engine        Engine // 時間の進め方（TICK・FAST_FORWARD のみ。空なら EngineTick）
+	State         *StateSnapshot
+	Version       int64 // 入力を適用・保存した後の集約バージョン
+}
+
+func NewInitializedInput(state *SimulationState, now time.Time) InputRecord {
+	snap := state.Snapshot()
+	return InputRecord{
+		Kind:          InputInitialized,
+		RecordedAt:    now,
+		SimTimeMillis: snap.SimTimeMillis,
+		State:         &snap,
+		Version:       state.Version(),
+	}
+}
+
+func NewRestoredInput(before SimTime, state *SimulationState, now time.Time) InputRecord {
+	snap := state.Snapshot()
+	return InputRecord{
+		Kind:          InputRestored,
+		RecordedAt:    now,
+		SimTimeMillis: before.Millis(),
+		State:         &snap,
+		Version:       state.Version(),
+	}
+}
+
+func NewCheckpointInput(state *SimulationState, now time.Time) InputRecord {
+	snap := state.Snapshot()
+	return InputRecord{
+		Kind:          InputCheckpoint,
+		RecordedAt:    now,
+		SimTimeMillis: snap.SimTimeMillis,
+		State:         &snap,
+		Version:       state.Version(),
+	}
+}
+
+func NewRewoundInput(before SimTime, state *SimulationState, now time.Time) InputRecord {
+	snap := state.Snapshot()
+	return InputRecord{
+		Kind:          InputRewound,
+		RecordedAt:    now,
+		SimTimeMillis: before.Millis(),
+		State:         &snap,
+		Version:       state.Version(),
+	}
+}
+
+func NewFastForwardInput(result RunUntilResult, spec RunUntilSpec, state *SimulationState, now time.Time) InputRecord {
+	return InputRecord{
+		Kind:          InputFastForward,
+		RecordedAt:    now,
+		SimTimeMillis: result.From.Millis(),
+		DeltaMillis:   result.To.Millis() - result.From.Millis(),
+		StepMillis:    spec.Step.Duration().Milliseconds(),
+		Engine:        recordedEngine(spec.Engine),
+		Version:       state.Version(),
+	}
+}
+
+func NewPriorityRulesChangedInput(state *SimulationState, now time.Time) InputRecord {
+	snap := state.Snapshot()
+	return InputRecord{
+		Kind:          InputPriorityRulesChanged,
+		RecordedAt:    now,
+		SimTimeMillis: snap.SimTimeMillis,
+		State:         &snap,
+		Version:       state.Version(),
+	}
+}
+
+func NewTickInput(before SimTime, dt TickDelta, engine Engine, state *SimulationState, now time.Time) InputRecord {
+	return InputRecord{
+		Kind:          InputTick,
+		RecordedAt:    now,
+		SimTimeMillis: before.Millis(),
+		DeltaMillis:   dt.Duration().Milliseconds(),
+		Engine:        recordedEngine(engine),
+		Version:       state.Version(),
+	}
+}
+
+// SavedAs は保存後の集約バージョンを version とした記録を返す（状態を保存する前にログへ追記するため）
+func (r InputRecord) SavedAs(version int64) InputRecord {
+	r.Version = version
+	if r.State != nil {
+		snap := *r.State
+		snap.Version = version
+		r.State = &snap
+	}
+	return r
+}
+
+// recordedEngine は既定の EngineTick を空にする（従来の記録と同じ形で残すため）
+func recordedEngine(engine Engine) Engine {
+	if engine == EngineTick {
+		return ""
+	}
+	return engine
+}
+
+// InputLogRepository はシミュレーションごとの追記専用の入力ログ
+type InputLogRepository interface {
+	Append(ctx context.Context, id SimulationID, record InputRecord) (InputRecord, error)
+	List(ctx context.Context, id SimulationID) ([]InputRecord, error)
+	// TruncateAfter は連番 seq より後の記録を取り消す（状態を保存できなかった入力の取り消しにだけ使う）
+	TruncateAfter(ctx context.Context, id SimulationID, seq int64) error
+	Delete(ctx context.Context, id SimulationID) error
+}
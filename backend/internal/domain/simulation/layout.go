@@ -0,0 +1,210 @@
+package simulation
+
+import (
+	"math"
+	"slices"
+)
+
+// 自動の配置で使う路線図の寸法（座標の単位は描画側で決める）
+const (
+	// schematicStationSpacing は駅の間隔（キロ程があれば 1km あたりの長さ）
+	schematicStationSpacing = 100.0
+	// schematicRowSpacing は路線どうしの縦の間隔
+	schematicRowSpacing = 80.0
+	// schematicLabelOffset は駅名や信号の札を線路から離す距離
+	schematicLabelOffset = 12.0
+)
+
+// Point は路線図（スケマティック）の上の座標。y は下向き。
+type Point struct {
+	X float64
+	Y float64
+}
+
+// StationLayout は路線図の駅の位置と、駅名の札を置く位置
+type StationLayout struct {
+	Position Point
+	Label    Point
+}
+
+// BlockLayout は路線図の区間の線（駅の並びの向きに、手前の駅から先の駅まで）と、区間に入る信号の札を置く位置
+type BlockLayout struct {
+	Polyline    []Point
+	SignalLabel Point
+}
+
+// LineLayout は路線の路線図での配置。Stations / Blocks は路線の駅と区間と同じ並び。
+type LineLayout struct {
+	Stations []StationLayout
+	Blocks   []BlockLayout
+}
+
+// NetworkLineLayout は網の路線の配置。Generated なら路線に配置が無く自動で決めたもの。
+type NetworkLineLayout struct {
+	ID        LineID
+	Layout    LineLayout
+	Generated bool
+}
+
+// NewStationLayout は駅名の札を駅の上に置いた駅の配置を作る
+func NewStationLayout(position Point) StationLayout {
+	return StationLayout{
+		Position: position,
+		Label:    Point{X: position.X, Y: position.Y - schematicLabelOffset},
+	}
+}
+
+// NewBlockLayout は polyline を通る区間の配置を作る。信号の札は区間の入口の右下に置く。
+func NewBlockLayout(polyline ...Point) BlockLayout {
+	layout := BlockLayout{Polyline: slices.Clone(polyline)}
+	if len(polyline) > 0 {
+		layout.SignalLabel = Point{X: polyline[0].X + schematicLabelOffset, Y: polyline[0].Y + schematicLabelOffset}
+	}
+	return layout
+}
+
+// WithLayout は路線図での配置を持たせた路線を返す（l は変えない）。
+// 区間の線は2点以上で、両端が区間の両側の駅の位置に一致しなければならない。
+func (l *Line) WithLayout(layout LineLayout) (*Line, error) {
+	if len(layout.Stations) != len(l.stations) || len(layout.Blocks) != len(l.blocks) {
+		return nil, ErrLineLayoutMismatch
+	}
+	for _, station := range layout.Stations {
+		if !finitePoint(station.Position) || !finitePoint(station.Label) {
+			return nil, ErrLineLayoutInvalid
+		}
+	}
+	for i, block := range layout.Blocks {
+		polyline := block.Polyline
+		if len(polyline) < 2 || !finitePoint(block.SignalLabel) {
+			return nil, ErrLineLayoutInvalid
+		}
+		if polyline[0] != layout.Stations[i].Position || polyline[len(polyline)-1] != layout.Stations[i+1].Position {
+			return nil, ErrLineLayoutInvalid
+		}
+		for _, p := range polyline {
+			if !finitePoint(p) {
+				return nil, ErrLineLayoutInvalid
+			}
+		}
+	}
+
+	cloned := l.clone()
+	copied := layout.clone()
+	cloned.layout = &copied
+	return cloned, nil
+}
+
+// Layout は路線図での配置を返す。配置を持たなければ false。
+func (l *Line) Layout() (LineLayout, bool) {
+	if l.layout == nil {
+		return LineLayout{}, false
+	}
+	return l.layout.clone(), true
+}
+
+// Layouts は網の路線ごとの路線図での配置を路線の順に返す。配置の無い路線は自動で並べる。
+// 自動の配置では路線を1本ずつ横一列に置き、配置済みの路線の下の段に順に積む。
+// 既に置いた駅（乗換駅）を通る路線はその駅に横の位置を揃え、駅はその位置のまま区間の線を斜めに折って段をつなぐ。
+// 駅の間隔は、キロ程が駅の順に単調に変わればキロ程に比例させ、そうでなければ等間隔にする。
+func (n *Network) Layouts() []NetworkLineLayout {
+	out := make([]NetworkLineLayout, 0, len(n.lines))
+	placed := make(map[string]Point, len(n.stations))
+	nextRow := 0.0
+	for _, l := range n.lines {
+		layout, ok := l.Line.Layout()
+		if ok {
+			for i, station := range l.Line.stations {
+				if _, seen := placed[station.String()]; !seen {
+					placed[station.String()] = layout.Stations[i].Position
+				}
+			}
+			for _, p := range layout.points() {
+				nextRow = math.Max(nextRow, p.Y+schematicRowSpacing)
+			}
+		}
+		out = append(out, NetworkLineLayout{ID: l.ID, Layout: layout, Generated: !ok})
+	}
+
+	for i, l := range n.lines {
+		if !out[i].Generated {
+			continue
+		}
+		out[i].Layout = autoLineLayout(l.Line, nextRow, placed)
+		nextRow += schematicRowSpacing
+	}
+	return out
+}
+
+// autoLineLayout は路線を高さ row に横一列に並べる。placed に既にある駅はその位置を使い、新しく置いた駅を placed に加える。
+func autoLineLayout(line *Line, row float64, placed map[string]Point) LineLayout {
+	offsets := stationOffsets(line)
+
+	// 既に置いた駅があれば、最初のものに横の位置を揃える
+	origin := 0.0
+	for i, station := range line.stations {
+		if p, ok := placed[station.String()]; ok {
+			origin = p.X - offsets[i]
+			break
+		}
+	}
+
+	layout := LineLayout{
+		Stations: make([]StationLayout, 0, len(line.stations)),
+		Blocks:   make([]BlockLayout, 0, len(line.blocks)),
+	}
+	for i, station := range line.stations {
+		position, ok := placed[station.String()]
+		if !ok {
+			position = Point{X: origin + offsets[i], Y: row}
+			placed[station.String()] = position
+		}
+		layout.Stations = append(layout.Stations, NewStationLayout(position))
+	}
+	for i := range line.blocks {
+		from, to := layout.Stations[i].Position, layout.Stations[i+1].Position
+		if from.Y == to.Y || (from.Y != row && to.Y != row) {
+			layout.Blocks = append(layout.Blocks, NewBlockLayout(from, to))
+			continue
+		}
+		// 段の違う駅のあいだは、区間の中ほどまでこの路線の段を進み、そこから斜めに結ぶ（ほかの路線の線に重ねない）
+		layout.Blocks = append(layout.Blocks, NewBlockLayout(from, Point{X: (from.X + to.X) / 2, Y: row}, to))
+	}
+	return layout
+}
+
+// stationOffsets は路線の先頭の駅からの駅ごとの横の距離
+func stationOffsets(line *Line) []float64 {
+	offsets, _ := line.StationDistances()
+	for i := range offsets {
+		offsets[i] *= schematicStationSpacing
+	}
+	return offsets
+}
+
+func (l LineLayout) points() []Point {
+	var out []Point
+	for _, station := range l.Stations {
+		out = append(out, station.Position, station.Label)
+	}
+	for _, block := range l.Blocks {
+		out = append(out, block.Polyline...)
+		out = append(out, block.SignalLabel)
+	}
+	return out
+}
+
+func (l LineLayout) clone() LineLayout {
+	out := LineLayout{
+		Stations: slices.Clone(l.Stations),
+		Blocks:   make([]BlockLayout, 0, len(l.Blocks)),
+	}
+	for _, block := range l.Blocks {
+		out.Blocks = append(out.Blocks, BlockLayout{Polyline: slices.Clone(block.Polyline), SignalLabel: block.SignalLabel})
+	}
+	return out
+}
+
+func finitePoint(p Point) bool {
+	return !math.IsNaN(p.X) && !math.IsInf(p.X, 0) && !math.IsNaN(p.Y) && !math.IsInf(p.Y, 0)
+}
@@ -0,0 +1,139 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLayoutsPlaceLinesWithoutLayoutInRows(t *testing.T) {
+	layouts := newTwoLineNetwork(t).Layouts()
+	if len(layouts) != 2 || !layouts[0].Generated || !layouts[1].Generated {
+		t.Fatalf("expected two generated layouts, got %+v", layouts)
+	}
+
+	red, blue := layouts[0].Layout, layouts[1].Layout
+	if got := stationPositions(red); !reflect.DeepEqual(got, []Point{{0, 0}, {100, 0}, {200, 0}}) {
+		t.Fatalf("unexpected RED positions: %v", got)
+	}
+	// BLUE は次の段に置き、乗換駅 X は RED での位置のまま横の位置を揃える
+	if got := stationPositions(blue); !reflect.DeepEqual(got, []Point{{0, 80}, {100, 0}, {200, 80}}) {
+		t.Fatalf("unexpected BLUE positions: %v", got)
+	}
+	want := []Point{{0, 80}, {50, 80}, {100, 0}}
+	if got := blue.Blocks[0].Polyline; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected BB0 to run along its row and then up to X, got %v", got)
+	}
+	if got := red.Stations[0].Label; got != (Point{0, -schematicLabelOffset}) {
+		t.Fatalf("expected the station label above the station, got %v", got)
+	}
+}
+
+func TestLayoutsSpaceStationsByKilometerPost(t *testing.T) {
+	base := newLineFromIDs(t, []string{"S0", "S1", "S2"}, []string{"B0", "B1"})
+	line, err := NewLineWithInfo(base.Stations(), base.Blocks(), []StationInfo{{KilometerPost: 10, HasKilometerPost: true}, {KilometerPost: 10.5, HasKilometerPost: true}, {KilometerPost: 12, HasKilometerPost: true}}, nil)
+	if err != nil {
+		t.Fatalf("new line failed: %v", err)
+	}
+	network, err := NewSingleLineNetwork(line)
+	if err != nil {
+		t.Fatalf("new network failed: %v", err)
+	}
+
+	if got := stationPositions(network.Layouts()[0].Layout); !reflect.DeepEqual(got, []Point{{0, 0}, {50, 0}, {200, 0}}) {
+		t.Fatalf("expected spacing by kilometer post, got %v", got)
+	}
+}
+
+func TestLayoutsKeepGivenLayoutAndStackGeneratedBelow(t *testing.T) {
+	redID, _ := NewLineID("RED")
+	blueID, _ := NewLineID("BLUE")
+	network := newTwoLineNetwork(t)
+	red, _ := network.Line(redID)
+	given := LineLayout{
+		Stations: []StationLayout{NewStationLayout(Point{0, 0}), NewStationLayout(Point{300, 0}), NewStationLayout(Point{600, 40})},
+		Blocks:   []BlockLayout{NewBlockLayout(Point{0, 0}, Point{300, 0}), NewBlockLayout(Point{300, 0}, Point{500, 40}, Point{600, 40})},
+	}
+	laidOut, err := red.WithLayout(given)
+	if err != nil {
+		t.Fatalf("with layout failed: %v", err)
+	}
+	blue, _ := network.Line(blueID)
+	network, err = NewNetwork([]NetworkLine{{ID: redID, Line: laidOut}, {ID: blueID, Line: blue}}, nil)
+	if err != nil {
+		t.Fatalf("new network failed: %v", err)
+	}
+
+	layouts := network.Layouts()
+	if layouts[0].Generated || !reflect.DeepEqual(layouts[0].Layout, given) {
+		t.Fatalf("expected RED to keep its layout, got %+v", layouts[0])
+	}
+	// 配置のある路線のいちばん下（y=40 の R2）より1段下に置く
+	if got := stationPositions(layouts[1].Layout); !reflect.DeepEqual(got, []Point{{200, 120}, {300, 0}, {400, 120}}) {
+		t.Fatalf("unexpected BLUE positions: %v", got)
+	}
+}
+
+func TestWithLayoutRejectsInvalidLayout(t *testing.T) {
+	line := newLineFromIDs(t, []string{"S0", "S1"}, []string{"B0"})
+	stations := []StationLayout{NewStationLayout(Point{0, 0}), NewStationLayout(Point{100, 0})}
+
+	cases := []struct {
+		name    string
+		layout  LineLayout
+		wantErr error
+	}{
+		{name: "missing block", layout: LineLayout{Stations: stations}, wantErr: ErrLineLayoutMismatch},
+		{name: "single point", layout: LineLayout{Stations: stations, Blocks: []BlockLayout{NewBlockLayout(Point{0, 0})}}, wantErr: ErrLineLayoutInvalid},
+		{name: "detached", layout: LineLayout{Stations: stations, Blocks: []BlockLayout{NewBlockLayout(Point{0, 0}, Point{90, 0})}}, wantErr: ErrLineLayoutInvalid},
+		{name: "not finite", layout: LineLayout{Stations: stations, Blocks: []BlockLayout{NewBlockLayout(Point{0, 0}, Point{50, math.NaN()}, Point{100, 0})}}, wantErr: ErrLineLayoutInvalid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := line.WithLayout(tc.layout); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+	if _, ok := line.Layout(); ok {
+		t.Fatalf("expected the line to stay without a layout")
+	}
+}
+
+func TestSnapshotRestoresLayout(t *testing.T) {
+	line := newLineFromIDs(t, []string{"S0", "S1"}, []string{"B0"})
+	line, err := line.WithLayout(LineLayout{
+		Stations: []StationLayout{NewStationLayout(Point{0, 0}), NewStationLayout(Point{100, 20})},
+		Blocks:   []BlockLayout{NewBlockLayout(Point{0, 0}, Point{50, 20}, Point{100, 20})},
+	})
+	if err != nil {
+		t.Fatalf("with layout failed: %v", err)
+	}
+	id, _ := NewSimulationID("SIM0")
+	state, err := NewSimulationState(id, line)
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+
+	snap := state.Snapshot()
+	if snap.Layout == nil {
+		t.Fatalf("expected the snapshot to carry the layout")
+	}
+	restored, err := RestoreSimulationState(snap)
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if diffs := DiffSnapshots(snap, restored.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected identical snapshots, got %v", diffs)
+	}
+}
+
+func stationPositions(layout LineLayout) []Point {
+	out := make([]Point, 0, len(layout.Stations))
+	for _, station := range layout.Stations {
+		out = append(out, station.Position)
+	}
+	return out
+}
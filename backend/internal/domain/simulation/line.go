@@ -1,12 +1,55 @@
 package simulation
 
+import (
+	"maps"
+	"math"
+	"slices"
+)
+
 type Line struct {
 	stations   []StationID
 	blocks     []BlockID
 	blockIndex map[string]int
+	// stationIndex は駅IDから stations の添字を引く
+	stationIndex map[string]int
+	// stationInfo と blockInfo は stations / blocks と同じ並びの案内用の情報
+	stationInfo []StationInfo
+	blockInfo   []BlockInfo
+	// layout は路線図での配置（無ければ nil で、描くときに自動で並べる）
+	layout *LineLayout
+}
+
+// StationInfo は駅の案内用の情報。列車の動きには使わない（空でもよい）。
+type StationInfo struct {
+	// Name は日本語の駅名、NameRomaji はそのローマ字表記
+	Name       string
+	NameRomaji string
+	// Code は駅ナンバリングなどの駅の記号
+	Code string
+	// KilometerPost は起点からのキロ程（km）。HasKilometerPost が false なら不明（0 km と区別する）
+	KilometerPost    float64
+	HasKilometerPost bool
+	// Platforms はのりばの数（0 なら不明）
+	Platforms int
+}
+
+// BlockInfo は区間の線路の情報。列車の動きには使わない（空でもよい）。
+type BlockInfo struct {
+	// TrackNumber は線路の番号（上り1番線など）
+	TrackNumber string
+	// Electrification は電化方式（DC1500V、AC20kV など。非電化は NONE、不明なら空）
+	Electrification string
+	// GradientPermille は進む向き（駅の並びの順）に見た勾配（‰、上りが正）
+	GradientPermille float64
 }
 
 func NewLine(stations []StationID, blocks []BlockID) (*Line, error) {
+	return NewLineWithInfo(stations, blocks, nil, nil)
+}
+
+// NewLineWithInfo は駅と区間の案内用の情報を持つ路線を作る。
+// stationInfo と blockInfo は stations / blocks と同じ並びで、nil なら情報なしとする。
+func NewLineWithInfo(stations []StationID, blocks []BlockID, stationInfo []StationInfo, blockInfo []BlockInfo) (*Line, error) {
 	if len(blocks) == 0 {
 		return nil, ErrLineHasNoBlocks
 	}
@@ -14,13 +57,13 @@ func NewLine(stations []StationID, blocks []BlockID) (*Line, error) {
 		return nil, ErrLineStationsBlocksMismatch
 	}
 
-	stationSeen := make(map[string]struct{}, len(stations))
-	for _, station := range stations {
+	stationIndex := make(map[string]int, len(stations))
+	for i, station := range stations {
 		key := station.String()
-		if _, exists := stationSeen[key]; exists {
+		if _, exists := stationIndex[key]; exists {
 			return nil, ErrLineDuplicateStationID
 		}
-		stationSeen[key] = struct{}{}
+		stationIndex[key] = i
 	}
 
 	blockSeen := make(map[string]struct{}, len(blocks))
@@ -40,10 +83,28 @@ func NewLine(stations []StationID, blocks []BlockID) (*Line, error) {
 	blocksCopy := make([]BlockID, len(blocks))
 	copy(blocksCopy, blocks)
 
+	if stationInfo == nil {
+		stationInfo = make([]StationInfo, len(stations))
+	}
+	if blockInfo == nil {
+		blockInfo = make([]BlockInfo, len(blocks))
+	}
+	if len(stationInfo) != len(stations) || len(blockInfo) != len(blocks) {
+		return nil, ErrLineInfoMismatch
+	}
+	for _, info := range stationInfo {
+		if info.Platforms < 0 {
+			return nil, ErrStationPlatformsNegative
+		}
+	}
+
 	return &Line{
-		stations:   stationsCopy,
-		blocks:     blocksCopy,
-		blockIndex: blockIndex,
+		stations:     stationsCopy,
+		blocks:       blocksCopy,
+		blockIndex:   blockIndex,
+		stationIndex: stationIndex,
+		stationInfo:  slices.Clone(stationInfo),
+		blockInfo:    slices.Clone(blockInfo),
 	}, nil
 }
 
@@ -59,6 +120,57 @@ func (l *Line) Blocks() []BlockID {
 	return out
 }
 
+// StationInfos は駅の案内用の情報を Stations と同じ並びで返す
+func (l *Line) StationInfos() []StationInfo {
+	return slices.Clone(l.stationInfo)
+}
+
+// BlockInfos は区間の情報を Blocks と同じ並びで返す
+func (l *Line) BlockInfos() []BlockInfo {
+	return slices.Clone(l.blockInfo)
+}
+
+// StationDistances は先頭の駅からの駅ごとの距離を Stations と同じ並びで返す。
+// 全駅のキロ程が分かり、駅の順に単調に変われば km で表して true を、そうでなければ駅の数で数えて false を返す。
+func (l *Line) StationDistances() ([]float64, bool) {
+	distances := make([]float64, len(l.stations))
+	useKilometers := len(l.stationInfo) > 1
+	direction := 0.0
+	for i := 1; i < len(l.stationInfo); i++ {
+		step := l.stationInfo[i].KilometerPost - l.stationInfo[i-1].KilometerPost
+		if !l.stationInfo[i].HasKilometerPost || !l.stationInfo[i-1].HasKilometerPost || step == 0 || direction*step < 0 {
+			useKilometers = false
+			break
+		}
+		direction = step
+	}
+
+	for i := 1; i < len(distances); i++ {
+		step := 1.0
+		if useKilometers {
+			step = math.Abs(l.stationInfo[i].KilometerPost - l.stationInfo[i-1].KilometerPost)
+		}
+		distances[i] = distances[i-1] + step
+	}
+	return distances, useKilometers
+}
+
+func (l *Line) StationInfo(id StationID) (StationInfo, bool) {
+	index, ok := l.stationIndex[id.String()]
+	if !ok {
+		return StationInfo{}, false
+	}
+	return l.stationInfo[index], true
+}
+
+func (l *Line) BlockInfo(id BlockID) (BlockInfo, bool) {
+	index, ok := l.IndexOfBlock(id)
+	if !ok {
+		return BlockInfo{}, false
+	}
+	return l.blockInfo[index], true
+}
+
 func (l *Line) BlockAt(index int) (BlockID, bool) {
 	if index < 0 || index >= len(l.blocks) {
 		return BlockID{}, false
@@ -86,3 +198,39 @@ func (l *Line) NextBlock(id BlockID, forward bool) (BlockID, bool, error) {
 	}
 	return l.blocks[next], true, nil
 }
+
+// nextBlockIndex は添字 index の区間から進む向きの隣の区間の添字。終端なら false。
+func (l *Line) nextBlockIndex(index int, forward bool) (int, bool) {
+	next := index + 1
+	if !forward {
+		next = index - 1
+	}
+	if next < 0 || next >= len(l.blocks) {
+		return 0, false
+	}
+	return next, true
+}
+
+// stationAhead は添字 index の区間を進みきったときに着く駅
+func (l *Line) stationAhead(index int, forward bool) StationID {
+	if forward {
+		return l.stations[index+1]
+	}
+	return l.stations[index]
+}
+
+func (l *Line) clone() *Line {
+	cloned := &Line{
+		stations:     l.Stations(),
+		blocks:       l.Blocks(),
+		blockIndex:   maps.Clone(l.blockIndex),
+		stationIndex: maps.Clone(l.stationIndex),
+		stationInfo:  l.StationInfos(),
+		blockInfo:    l.BlockInfos(),
+	}
+	if l.layout != nil {
+		layout := l.layout.clone()
+		cloned.layout = &layout
+	}
+	return cloned
+}
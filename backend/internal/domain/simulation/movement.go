@@ -0,0 +1,304 @@
+package simulation
+
+import (
+	"cmp"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// timeEpsilon は刻みの中の時刻（秒）の刻み幅。時刻はこの格子に丸めてから比べる。
+const timeEpsilon = 1e-9
+
+// onGrid は刻み内の時刻を timeEpsilon の格子に丸める。
+// 同時かどうかを格子の上でちょうど等しいかで決めるので、計算を途中で区切っても結果が揺れない。
+func onGrid(seconds float64) float64 {
+	return math.Round(seconds/timeEpsilon) * timeEpsilon
+}
+
+// tickScratch は刻みごとに作り直さずに使い回す作業領域。
+// 列車の多い路線を速く回すため、刻みの計算では新たに割り当てない。
+type tickScratch struct {
+	movers []mover
+	groups []moverGroup
+	// cuts と cutActive は区切りのうち刻みの境目にするもの（planGroups）
+	cuts      []int
+	cutActive []bool
+	events    []timedEvent
+	// 刻みの前後の待ち合い（新たに生じたものだけを知らせるため）
+	cycles          cycleScratch
+	deadlocksBefore cycleSet
+	deadlocksAfter  cycleSet
+}
+
+// claim は区間の端にいる列車（movers の添字）が次の区間（路線上の添字）を求めていること
+type claim struct {
+	block int
+	mover int
+}
+
+// mover は1刻みの移動を解決するあいだの列車ごとの途中経過
+type mover struct {
+	// index は列車の trains での添字（列車ID順なので、規則で決まらないときの順位にも使う）
+	index int
+	train *Train
+	// from は今の区間で動き始めた（抑止が解けた、区間に入った）刻み内の時刻、origin はそのときの進み具合。
+	// 位置は from からの経過時間で決めるので、途中で何度区切って計算しても同じ値になる。
+	from   float64
+	origin float64
+	// arrival は区間の端に着く刻み内の時刻（初めから端にいれば動き始める時刻）
+	arrival float64
+	// arrivedAt は区間の端に着いて次の区間を求め始めた刻み内の時刻。求めていなければ負。
+	arrivedAt float64
+	blockedBy TrainID
+	// done は終端に着いて、この刻みではもう動かない
+	done bool
+}
+
+func (m *mover) claiming() bool {
+	return m.arrivedAt >= 0
+}
+
+// remaining は from の時点での区間の端までの距離
+func (m *mover) remaining() float64 {
+	remaining := m.origin
+	if m.train.Forward() {
+		remaining = 1.0 - m.origin
+	}
+	if remaining < boundaryEpsilon {
+		remaining = 0
+	}
+	return remaining
+}
+
+// startAt は列車が刻み内の時刻 from から今の位置で動き始めたものとする
+func (m *mover) startAt(from float64) {
+	m.from = from
+	m.origin = m.train.Progress().Float64()
+	m.arrival = onGrid(from + m.remaining()/m.train.Speed())
+}
+
+// moveTrains は刻みのあいだの全列車の移動を、刻み内の時刻順にまとめて解決する。
+// 列車は同時に動き、区間の端に着いた時刻で次の区間を求める。同じ時刻に同じ区間を求めた列車は
+// PriorityRules で順位を決めるので、結果は列車の処理順や名前に左右されない。
+// 区間が空くのを待つあいだも時間は過ぎ、刻みの途中で空けば残りの時間で進む。
+// 並行区切り（SetParallelSections）があれば、互いに届かない列車の組を並行に解決する。
+func (s *SimulationState) moveTrains(dt TickDelta, start SimTime, trace *tickTrace) error {
+	total := dt.Duration().Seconds()
+	end := onGrid(total)
+	movers := s.scratch.movers[:0]
+	for i := range s.trains {
+		train := &s.trains[i]
+		m := mover{index: i, train: train, arrivedAt: -1, done: train.PendingTurnback()}
+		m.startAt(onGrid(total - trace.movableDuration(train.ID().String(), dt, start).Seconds()))
+		movers = append(movers, m)
+	}
+	s.scratch.movers = movers
+
+	groups := s.planGroups(movers, total, end)
+	busy := 0
+	for i := range groups {
+		if len(groups[i].members) > 0 {
+			busy++
+		}
+	}
+	if busy <= 1 {
+		for i := range groups {
+			if err := s.moveGroup(&groups[i], movers, total, end, trace); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 1つのCPUでは並行にしても速くならないので順に解決する（出来事の並べ直しはどちらも同じにする）
+	return s.moveGroups(groups, movers, total, end, trace, runtime.GOMAXPROCS(0) > 1)
+}
+
+// moveGroups は複数の組を進める（parallel なら並行に）。組どうしは別の区間と列車しか触らないので、
+// 出来事だけを貯めておき、あとで刻み内の時刻（同時なら列車ID）の順に並べ直して知らせる。
+// 出来事の順は並行に解決したかどうか（CPUの数）に左右されない。
+func (s *SimulationState) moveGroups(groups []moverGroup, movers []mover, total, end float64, trace *tickTrace, parallel bool) error {
+	var wg sync.WaitGroup
+	for i := range groups {
+		g := &groups[i]
+		if len(g.members) == 0 {
+			continue
+		}
+		g.buffered = trace != nil
+		if parallel {
+			wg.Go(func() { g.err = s.moveGroup(g, movers, total, end, trace) })
+			continue
+		}
+		g.err = s.moveGroup(g, movers, total, end, trace)
+	}
+	wg.Wait()
+
+	events := s.scratch.events[:0]
+	for i := range groups {
+		if err := groups[i].err; err != nil {
+			return err
+		}
+		events = append(events, groups[i].events...)
+	}
+	slices.SortStableFunc(events, func(a, b timedEvent) int {
+		if c := cmp.Compare(a.at, b.at); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.mover, b.mover)
+	})
+	for _, e := range events {
+		trace.emit(e.event)
+	}
+	clear(events)
+	s.scratch.events = events[:0]
+	return nil
+}
+
+// moveGroup は組の列車の刻みの移動を解決する
+func (s *SimulationState) moveGroup(g *moverGroup, movers []mover, total, end float64, trace *tickTrace) error {
+	now := 0.0
+	for {
+		if err := s.arriveAt(g, movers, now, end, trace); err != nil {
+			return err
+		}
+		if err := s.resolveClaims(g, movers, now); err != nil {
+			return err
+		}
+		if now >= end {
+			break
+		}
+		now = s.nextMovementTime(g, movers, end)
+	}
+
+	for _, i := range g.members {
+		m := &movers[i]
+		if m.claiming() {
+			g.emit(trace, math.Inf(1), m.index, Event{Type: EventTrainBlocked, At: s.simTime, TrainID: m.train.ID(), BlockID: s.claimedBlock(m), BlockedBy: m.blockedBy})
+			continue
+		}
+		if m.done || m.from >= end || m.remaining() == 0 {
+			continue
+		}
+		distance := m.train.Speed() * (total - m.from)
+		progress := min(m.origin+distance, 1)
+		if !m.train.Forward() {
+			progress = max(m.origin-distance, 0)
+		}
+		if err := m.train.setProgress(progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextMovementTime は次にいずれかの列車が区間の端に着くか動き始める時刻（無ければ刻みの終わり）
+func (s *SimulationState) nextMovementTime(g *moverGroup, movers []mover, end float64) float64 {
+	next := end
+	for _, i := range g.members {
+		m := &movers[i]
+		if m.done || m.claiming() || m.from >= end {
+			continue
+		}
+		next = min(next, m.arrival)
+	}
+	return next
+}
+
+// arriveAt は刻み内の時刻 now までに区間の端に着いた（端で動き始めた）列車を端で止め、次の区間を求めさせる
+func (s *SimulationState) arriveAt(g *moverGroup, movers []mover, now, end float64, trace *tickTrace) error {
+	for _, i := range g.members {
+		m := &movers[i]
+		if m.done || m.claiming() || m.from >= end || m.arrival > now {
+			continue
+		}
+		m.arrivedAt = m.arrival
+		if m.remaining() == 0 {
+			continue
+		}
+
+		boundary := 0.0
+		if m.train.Forward() {
+			boundary = 1
+		}
+		if err := m.train.setProgress(boundary); err != nil {
+			return err
+		}
+		m.origin = boundary
+		g.emit(trace, m.arrival, m.index, Event{Type: EventTrainArrived, At: s.simTime, TrainID: m.train.ID(), StationID: s.network.stationAhead(m.train.block, m.train.Forward())})
+	}
+	return nil
+}
+
+// resolveClaims は区間の端で次の区間を求めている列車に、空いている区間を順位の高い順に渡す。
+// 区間を渡すと元の区間が空くので、後ろで待っていた列車も同じ時刻のうちに入れる。
+// 入った列車はその時刻 now から次の区間を進み始める。
+func (s *SimulationState) resolveClaims(g *moverGroup, movers []mover, now float64) error {
+	rules := s.rules()
+	for {
+		claims := g.claims[:0]
+		for _, i := range g.members {
+			m := &movers[i]
+			if m.done || !m.claiming() {
+				continue
+			}
+			next, exists := s.network.nextBlockIndex(m.train.block, m.train.Forward())
+			if !exists {
+				m.train.setPendingTurnback(true)
+				m.arrivedAt = -1
+				m.done = true
+				continue
+			}
+			if occupant := s.occupied[next]; occupant != noTrain && occupant != m.index {
+				m.blockedBy = s.trains[occupant].ID()
+				continue
+			}
+			claims = append(claims, claim{block: next, mover: i})
+		}
+		g.claims = claims
+		if len(claims) == 0 {
+			return nil
+		}
+
+		// 区間ごとにまとめ、求めた列車のうち最も順位の高い列車を入れる
+		slices.SortFunc(claims, func(a, b claim) int { return cmp.Compare(a.block, b.block) })
+		for first := 0; first < len(claims); {
+			winner := &movers[claims[first].mover]
+			last := first + 1
+			for ; last < len(claims) && claims[last].block == claims[first].block; last++ {
+				if contender := &movers[claims[last].mover]; precedes(rules, contender, winner) {
+					winner = contender
+				}
+			}
+			if err := s.enterNextBlock(winner, now); err != nil {
+				return err
+			}
+			winner.arrivedAt = -1
+			first = last
+		}
+	}
+}
+
+func (s *SimulationState) enterNextBlock(m *mover, now float64) error {
+	train := m.train
+	next, _ := s.network.nextBlockIndex(train.block, train.Forward())
+	s.occupied[train.block] = noTrain
+	train.setBlock(next, s.network.blocks[next])
+	s.occupied[next] = m.index
+
+	edge := 1.0
+	if train.Forward() {
+		edge = 0
+	}
+	if err := train.setProgress(edge); err != nil {
+		return err
+	}
+	m.startAt(now)
+	return nil
+}
+
+func (s *SimulationState) claimedBlock(m *mover) BlockID {
+	next, _ := s.network.nextBlockIndex(m.train.block, m.train.Forward())
+	return s.network.blocks[next]
+}
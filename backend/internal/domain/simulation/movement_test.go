@@ -0,0 +1,223 @@
+package simulation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTickGrantsContestedBlockByPriorityNotByName(t *testing.T) {
+	cases := []struct {
+		name        string
+		forwardID   string
+		backwardID  string
+		rules       []PriorityRule
+		wantInBlock string
+	}{
+		{name: "default favors forward", forwardID: "A", backwardID: "Z", wantInBlock: "A"},
+		{name: "default ignores naming", forwardID: "Z", backwardID: "A", wantInBlock: "Z"},
+		{name: "backward first", forwardID: "A", backwardID: "Z", rules: []PriorityRule{PriorityBackwardFirst}, wantInBlock: "Z"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := newThreeBlockState(t)
+			if err := state.SetPriorityRules(tc.rules); err != nil {
+				t.Fatalf("set priority rules failed: %v", err)
+			}
+			// 両側から同じ時刻に B1 の端へ着く
+			if err := state.AddTrain(newTestTrain(t, tc.forwardID, "B0", 0.5, true, 0.5)); err != nil {
+				t.Fatalf("add train failed: %v", err)
+			}
+			if err := state.AddTrain(newTestTrain(t, tc.backwardID, "B2", 0.5, false, 0.5)); err != nil {
+				t.Fatalf("add train failed: %v", err)
+			}
+
+			delta, _ := NewTickDelta(time.Second)
+			if err := state.Tick(delta); err != nil {
+				t.Fatalf("tick failed: %v", err)
+			}
+
+			if got := occupantOf(t, state, "B1"); got != tc.wantInBlock {
+				t.Fatalf("expected %s to enter B1, got %s", tc.wantInBlock, got)
+			}
+		})
+	}
+}
+
+func TestTickEarliestArrivalWinsContestedBlock(t *testing.T) {
+	state := newThreeBlockState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	// 逆方向の列車のほうが先に B1 の端へ着く
+	if err := state.AddTrain(newTestTrain(t, "T1", "B2", 0.4, false, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	events, err := state.TickWithEvents(delta)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	if got := occupantOf(t, state, "B1"); got != "T1" {
+		t.Fatalf("expected T1 to enter B1, got %s", got)
+	}
+	last := events[len(events)-1]
+	if last.Type != EventTrainBlocked || last.TrainID.String() != "T0" || last.BlockedBy.String() != "T1" {
+		t.Fatalf("expected T0 to be blocked by T1, got %+v", events)
+	}
+}
+
+func TestTickFollowsTrainIntoBlockVacatedWithinTick(t *testing.T) {
+	state := newThreeBlockState(t)
+	// 先行列車の後ろで待つ列車が、名前順では先に処理される
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.9, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "B1", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(2 * time.Second)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	trains := state.Trains()
+	// T0 は 0.2 秒で端に着き、T1 が B1 を出る 1 秒まで待ってから残りの 1 秒で進む
+	if trains[0].BlockID().String() != "B1" || trains[0].Progress().Float64() != 0.5 {
+		t.Fatalf("unexpected T0 position: %s %f", trains[0].BlockID(), trains[0].Progress().Float64())
+	}
+	if trains[1].BlockID().String() != "B2" || trains[1].Progress().Float64() != 0.5 {
+		t.Fatalf("unexpected T1 position: %s %f", trains[1].BlockID(), trains[1].Progress().Float64())
+	}
+}
+
+func TestSetPriorityRulesRejectsUnknownAndDuplicateRules(t *testing.T) {
+	state := newTestState(t)
+	if err := state.SetPriorityRules([]PriorityRule{"NOPE"}); err == nil {
+		t.Fatalf("expected unknown rule to be rejected")
+	}
+	if err := state.SetPriorityRules([]PriorityRule{PriorityFasterFirst, PriorityFasterFirst}); err == nil {
+		t.Fatalf("expected duplicate rule to be rejected")
+	}
+
+	if err := state.SetPriorityRules([]PriorityRule{PriorityFasterFirst}); err != nil {
+		t.Fatalf("set priority rules failed: %v", err)
+	}
+	restored, err := RestoreSimulationState(state.Snapshot())
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if got := restored.PriorityRules(); len(got) != 1 || got[0] != PriorityFasterFirst {
+		t.Fatalf("expected priority rules to survive restore, got %v", got)
+	}
+}
+
+func occupantOf(t *testing.T, state *SimulationState, block string) string {
+	t.Helper()
+
+	id, err := NewBlockID(block)
+	if err != nil {
+		t.Fatalf("new block id failed: %v", err)
+	}
+	occupant, _ := state.Occupant(id)
+	return occupant.String()
+}
+
+func newThreeBlockState(t *testing.T) *SimulationState {
+	t.Helper()
+
+	var stations []StationID
+	for _, raw := range []string{"S0", "S1", "S2", "S3"} {
+		id, _ := NewStationID(raw)
+		stations = append(stations, id)
+	}
+	var blocks []BlockID
+	for _, raw := range []string{"B0", "B1", "B2"} {
+		id, _ := NewBlockID(raw)
+		blocks = append(blocks, id)
+	}
+
+	line, err := NewLine(stations, blocks)
+	if err != nil {
+		t.Fatalf("new line failed: %v", err)
+	}
+	id, _ := NewSimulationID("SIM0")
+	state, err := NewSimulationState(id, line)
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+	return state
+}
+
+func TestTickDoesNotAllocateOnLargeLine(t *testing.T) {
+	state := newGeneratedState(t, 2000, 200)
+	delta, _ := NewTickDelta(100 * time.Millisecond)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := state.Tick(delta); err != nil {
+			t.Fatalf("tick failed: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations per tick, got %.1f", allocs)
+	}
+}
+
+func BenchmarkTick(b *testing.B) {
+	for _, size := range []struct{ blocks, trains int }{{500, 50}, {5000, 500}} {
+		b.Run(fmt.Sprintf("blocks=%d/trains=%d", size.blocks, size.trains), func(b *testing.B) {
+			state := newGeneratedState(b, size.blocks, size.trains)
+			delta, _ := NewTickDelta(100 * time.Millisecond)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := state.Tick(delta); err != nil {
+					b.Fatalf("tick failed: %v", err)
+				}
+			}
+			// 実時間1秒あたりに進められたシミュレーション時間（20 以上なら 20 倍速で回せる）
+			b.ReportMetric(float64(state.SimTime().Millis())/1000/b.Elapsed().Seconds(), "sim-s/s")
+		})
+	}
+}
+
+func BenchmarkTickWithEvents(b *testing.B) {
+	state := newGeneratedState(b, 5000, 500)
+	delta, _ := NewTickDelta(100 * time.Millisecond)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := state.TickWithEvents(delta); err != nil {
+			b.Fatalf("tick failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(state.SimTime().Millis())/1000/b.Elapsed().Seconds(), "sim-s/s")
+}
+
+// newGeneratedState は GenerateLine の路線と列車で状態を作る
+func newGeneratedState(tb testing.TB, blocks, trains int) *SimulationState {
+	tb.Helper()
+
+	line, placed, err := GenerateLine(blocks, trains)
+	if err != nil {
+		tb.Fatalf("generate line failed: %v", err)
+	}
+	id, _ := NewSimulationID("SIM0")
+	state, err := NewSimulationState(id, line)
+	if err != nil {
+		tb.Fatalf("new state failed: %v", err)
+	}
+	for _, train := range placed {
+		if err := state.AddTrain(train); err != nil {
+			tb.Fatalf("add train failed: %v", err)
+		}
+	}
+	return state
+}
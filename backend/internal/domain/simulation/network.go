@@ -0,0 +1,228 @@
+package simulation
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultLineID は路線を1つだけ持つ網（NewSimulationState や旧い形式のスナップショット）の路線のID
+var DefaultLineID = LineID{value: "MAIN"}
+
+// NetworkLine は網の中の名前付きの路線
+type NetworkLine struct {
+	ID   LineID
+	Line *Line
+}
+
+// TransferLink は別々の駅のあいだの乗換の連絡。MinTransfer は乗り換えにかかる最短の時間。
+// 同じ駅を複数の路線が通る乗換駅には要らない（駅IDで共有する）。
+type TransferLink struct {
+	From        StationID
+	To          StationID
+	MinTransfer time.Duration
+}
+
+// Network は複数の路線からなる網。駅は駅IDで路線をまたいで共有し、区間のIDは網の中で一意にする。
+// 列車は自分の路線の中だけを走り、路線どうしは乗換駅と乗換の連絡でつながる。
+// 区間には全路線を路線の順につなげた通し番号を振る（路線ごとに連続した範囲になる）。
+type Network struct {
+	lines     []NetworkLine
+	transfers []TransferLink
+	// stations は網の駅（初めて現れた順、重複なし）
+	stations   []StationID
+	blocks     []BlockID
+	blockIndex map[string]int
+	// blockLine は区間の通し番号ごとの路線の添字、starts は路線ごとの先頭の区間の通し番号
+	blockLine []int
+	starts    []int
+}
+
+func NewNetwork(lines []NetworkLine, transfers []TransferLink) (*Network, error) {
+	if len(lines) == 0 {
+		return nil, ErrNetworkHasNoLines
+	}
+
+	n := &Network{
+		lines:      make([]NetworkLine, 0, len(lines)),
+		transfers:  slices.Clone(transfers),
+		blockIndex: make(map[string]int),
+	}
+	lineSeen := make(map[string]struct{}, len(lines))
+	stationSeen := make(map[string]struct{})
+	for i, l := range lines {
+		if l.ID.String() == "" {
+			return nil, ErrLineIDEmpty
+		}
+		if l.Line == nil {
+			return nil, ErrLineHasNoBlocks
+		}
+		if _, dup := lineSeen[l.ID.String()]; dup {
+			return nil, ErrNetworkDuplicateLineID
+		}
+		lineSeen[l.ID.String()] = struct{}{}
+
+		n.lines = append(n.lines, l)
+		n.starts = append(n.starts, len(n.blocks))
+		for _, block := range l.Line.blocks {
+			if _, dup := n.blockIndex[block.String()]; dup {
+				return nil, ErrLineDuplicateBlockID
+			}
+			n.blockIndex[block.String()] = len(n.blocks)
+			n.blocks = append(n.blocks, block)
+			n.blockLine = append(n.blockLine, i)
+		}
+		for _, station := range l.Line.stations {
+			if _, seen := stationSeen[station.String()]; !seen {
+				stationSeen[station.String()] = struct{}{}
+				n.stations = append(n.stations, station)
+			}
+		}
+	}
+
+	for _, t := range transfers {
+		if t.From == t.To || t.MinTransfer < 0 {
+			return nil, ErrTransferInvalid
+		}
+		_, fromOK := stationSeen[t.From.String()]
+		_, toOK := stationSeen[t.To.String()]
+		if !fromOK || !toOK {
+			return nil, ErrTransferStationNotFound
+		}
+	}
+	return n, nil
+}
+
+// NewSingleLineNetwork は line だけの網を作る（路線のIDは DefaultLineID）
+func NewSingleLineNetwork(line *Line) (*Network, error) {
+	return NewNetwork([]NetworkLine{{ID: DefaultLineID, Line: line}}, nil)
+}
+
+// Lines は網の路線を定義の順に返す
+func (n *Network) Lines() []NetworkLine {
+	return slices.Clone(n.lines)
+}
+
+func (n *Network) Line(id LineID) (*Line, bool) {
+	for _, l := range n.lines {
+		if l.ID == id {
+			return l.Line, true
+		}
+	}
+	return nil, false
+}
+
+func (n *Network) Transfers() []TransferLink {
+	return slices.Clone(n.transfers)
+}
+
+// Stations は網の駅を重複なく、初めて現れた順に返す
+func (n *Network) Stations() []StationID {
+	return slices.Clone(n.stations)
+}
+
+// StationInfo は駅の案内用の情報を返す。乗換駅なら情報を持つ最初の路線のものを使う。
+func (n *Network) StationInfo(id StationID) (StationInfo, bool) {
+	var found bool
+	for _, l := range n.lines {
+		info, ok := l.Line.StationInfo(id)
+		if ok && info != (StationInfo{}) {
+			return info, true
+		}
+		found = found || ok
+	}
+	return StationInfo{}, found
+}
+
+// StationInfos は駅の案内用の情報を Stations と同じ並びで返す（StationInfo と同じく、情報を持つ最初の路線のものを使う）
+func (n *Network) StationInfos() []StationInfo {
+	index := make(map[string]int, len(n.stations))
+	for i, station := range n.stations {
+		index[station.String()] = i
+	}
+	out := make([]StationInfo, len(n.stations))
+	for _, l := range n.lines {
+		for i, station := range l.Line.stations {
+			at := index[station.String()]
+			if out[at] == (StationInfo{}) {
+				out[at] = l.Line.stationInfo[i]
+			}
+		}
+	}
+	return out
+}
+
+// BlockInfos は区間の情報を Blocks と同じ並びで返す
+func (n *Network) BlockInfos() []BlockInfo {
+	out := make([]BlockInfo, 0, len(n.blocks))
+	for _, l := range n.lines {
+		out = append(out, l.Line.blockInfo...)
+	}
+	return out
+}
+
+// Blocks は全路線の区間を路線の順に返す
+func (n *Network) Blocks() []BlockID {
+	return slices.Clone(n.blocks)
+}
+
+// Interchanges は複数の路線が通る駅を網の駅の順に返す
+func (n *Network) Interchanges() []StationID {
+	count := make(map[string]int, len(n.stations))
+	for _, l := range n.lines {
+		for _, station := range l.Line.stations {
+			count[station.String()]++
+		}
+	}
+	var out []StationID
+	for _, station := range n.stations {
+		if count[station.String()] > 1 {
+			out = append(out, station)
+		}
+	}
+	return out
+}
+
+// singleLine は既定のIDの路線1つだけで乗換の連絡も無い網か（旧い形式で表せる）
+func (n *Network) singleLine() bool {
+	return len(n.lines) == 1 && n.lines[0].ID == DefaultLineID && len(n.transfers) == 0
+}
+
+func (n *Network) BlockAt(index int) (BlockID, bool) {
+	if index < 0 || index >= len(n.blocks) {
+		return BlockID{}, false
+	}
+	return n.blocks[index], true
+}
+
+func (n *Network) IndexOfBlock(id BlockID) (int, bool) {
+	i, ok := n.blockIndex[id.String()]
+	return i, ok
+}
+
+// LineOf は区間の属する路線を返す
+func (n *Network) LineOf(block BlockID) (LineID, bool) {
+	index, ok := n.IndexOfBlock(block)
+	if !ok {
+		return LineID{}, false
+	}
+	return n.lines[n.blockLine[index]].ID, true
+}
+
+// nextBlockIndex は通し番号 index の区間から進む向きの、同じ路線の隣の区間の通し番号。路線の終端なら false。
+func (n *Network) nextBlockIndex(index int, forward bool) (int, bool) {
+	line := n.blockLine[index]
+	start := n.starts[line]
+	next, ok := n.lines[line].Line.nextBlockIndex(index-start, forward)
+	return start + next, ok
+}
+
+// stationAhead は通し番号 index の区間を進みきったときに着く駅
+func (n *Network) stationAhead(index int, forward bool) StationID {
+	line := n.blockLine[index]
+	return n.lines[line].Line.stationAhead(index-n.starts[line], forward)
+}
+
+// stationAt は路線の添字 line の、路線の中で boundary 番目の駅
+func (n *Network) stationAt(line, boundary int) StationID {
+	return n.lines[line].Line.stations[boundary]
+}
@@ -0,0 +1,216 @@
+package simulation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewNetworkSharesStationsAcrossLines(t *testing.T) {
+	network := newTwoLineNetwork(t)
+
+	if got := stationStrings(network.Stations()); !reflect.DeepEqual(got, []string{"A0", "X", "A2", "B0", "B2"}) {
+		t.Fatalf("unexpected stations: %v", got)
+	}
+	if got := stationStrings(network.Interchanges()); !reflect.DeepEqual(got, []string{"X"}) {
+		t.Fatalf("expected X to be the only interchange, got %v", got)
+	}
+	if len(network.Blocks()) != 4 {
+		t.Fatalf("expected 4 blocks, got %d", len(network.Blocks()))
+	}
+	b1, _ := NewBlockID("BB1")
+	if line, ok := network.LineOf(b1); !ok || line.String() != "BLUE" {
+		t.Fatalf("expected BB1 on BLUE, got %v (found=%v)", line, ok)
+	}
+}
+
+func TestNewNetworkRejectsInvalidNetworks(t *testing.T) {
+	red := newLineFromIDs(t, []string{"A0", "A1"}, []string{"R0"})
+	redAgain := newLineFromIDs(t, []string{"B0", "B1"}, []string{"R0"})
+	blue := newLineFromIDs(t, []string{"B0", "B1"}, []string{"U0"})
+	redID, _ := NewLineID("RED")
+	blueID, _ := NewLineID("BLUE")
+	a0, _ := NewStationID("A0")
+	b0, _ := NewStationID("B0")
+	unknown, _ := NewStationID("Z")
+
+	cases := []struct {
+		name      string
+		lines     []NetworkLine
+		transfers []TransferLink
+		wantErr   error
+	}{
+		{name: "no lines", wantErr: ErrNetworkHasNoLines},
+		{name: "duplicate line", lines: []NetworkLine{{ID: redID, Line: red}, {ID: redID, Line: blue}}, wantErr: ErrNetworkDuplicateLineID},
+		{name: "block shared by lines", lines: []NetworkLine{{ID: redID, Line: red}, {ID: blueID, Line: redAgain}}, wantErr: ErrLineDuplicateBlockID},
+		{name: "transfer to unknown station", lines: []NetworkLine{{ID: redID, Line: red}}, transfers: []TransferLink{{From: a0, To: unknown}}, wantErr: ErrTransferStationNotFound},
+		{name: "transfer to itself", lines: []NetworkLine{{ID: redID, Line: red}}, transfers: []TransferLink{{From: a0, To: a0}}, wantErr: ErrTransferInvalid},
+		{name: "negative transfer time", lines: []NetworkLine{{ID: redID, Line: red}, {ID: blueID, Line: blue}}, transfers: []TransferLink{{From: a0, To: b0, MinTransfer: -time.Second}}, wantErr: ErrTransferInvalid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewNetwork(tc.lines, tc.transfers); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTickKeepsTrainsOnTheirLine(t *testing.T) {
+	id, _ := NewSimulationID("SIM0")
+	state, err := NewNetworkSimulationState(id, newTwoLineNetwork(t))
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+	// RED の終端 A2 に着く列車は、通し番号で隣の BLUE の区間へは進まずに折り返す
+	if err := state.AddTrain(newTestTrain(t, "T0", "RB1", 0.75, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T1", "BB0", 0.75, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	events, err := state.TickWithEvents(delta)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	if got := occupantOf(t, state, "RB1"); got != "T0" {
+		t.Fatalf("expected T0 to stay in RB1, got %q", got)
+	}
+	if got := occupantOf(t, state, "BB1"); got != "T1" {
+		t.Fatalf("expected T1 to move into BB1, got %q", got)
+	}
+	trains := state.Trains()
+	if !trains[0].PendingTurnback() {
+		t.Fatalf("expected T0 to wait for turnback at A2")
+	}
+	if len(events) != 2 || events[0].StationID.String() != "A2" || events[1].StationID.String() != "X" {
+		t.Fatalf("expected arrivals at A2 and X, got %+v", events)
+	}
+}
+
+func TestSnapshotRestoresNetwork(t *testing.T) {
+	id, _ := NewSimulationID("SIM0")
+	state, err := NewNetworkSimulationState(id, newTwoLineNetwork(t))
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T0", "BB0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	snap := state.Snapshot()
+	if len(snap.Lines) != 2 || len(snap.Transfers) != 1 || snap.Stations != nil {
+		t.Fatalf("expected the network form, got %+v", snap)
+	}
+	restored, err := RestoreSimulationState(snap)
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if diffs := DiffSnapshots(snap, restored.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected identical snapshots, got %v", diffs)
+	}
+	if got := stationStrings(restored.Network().Interchanges()); !reflect.DeepEqual(got, []string{"X"}) {
+		t.Fatalf("expected X to stay an interchange, got %v", got)
+	}
+}
+
+func TestNetworkStationInfoPrefersLineWithInfo(t *testing.T) {
+	a0, _ := NewStationID("A0")
+	x, _ := NewStationID("X")
+	a2, _ := NewStationID("A2")
+	b0, _ := NewStationID("B0")
+	b2, _ := NewStationID("B2")
+	rb0, _ := NewBlockID("RB0")
+	rb1, _ := NewBlockID("RB1")
+	red, _ := NewLineID("RED")
+	blue, _ := NewLineID("BLUE")
+
+	// RED は X の情報を持たず、BLUE が持つ
+	redLine, err := NewLine([]StationID{a0, x, a2}, []BlockID{rb0, rb1})
+	if err != nil {
+		t.Fatalf("new line failed: %v", err)
+	}
+	bb0, _ := NewBlockID("BB0")
+	bb1, _ := NewBlockID("BB1")
+	blueLine, err := NewLineWithInfo([]StationID{b0, x, b2}, []BlockID{bb0, bb1},
+		[]StationInfo{{}, {Name: "交差町", Platforms: 4}, {}}, nil)
+	if err != nil {
+		t.Fatalf("new line failed: %v", err)
+	}
+	network, err := NewNetwork([]NetworkLine{{ID: red, Line: redLine}, {ID: blue, Line: blueLine}}, nil)
+	if err != nil {
+		t.Fatalf("new network failed: %v", err)
+	}
+
+	if info, ok := network.StationInfo(x); !ok || info.Name != "交差町" {
+		t.Fatalf("expected X to take BLUE's info, got %+v (found=%v)", info, ok)
+	}
+	if infos := network.StationInfos(); len(infos) != len(network.Stations()) || infos[1].Platforms != 4 {
+		t.Fatalf("expected station info aligned with stations, got %+v", infos)
+	}
+}
+
+func TestNewLineWithInfoRejectsInvalidInfo(t *testing.T) {
+	s0, _ := NewStationID("S0")
+	s1, _ := NewStationID("S1")
+	b0, _ := NewBlockID("B0")
+	stations, blocks := []StationID{s0, s1}, []BlockID{b0}
+
+	if _, err := NewLineWithInfo(stations, blocks, []StationInfo{{}}, nil); !errors.Is(err, ErrLineInfoMismatch) {
+		t.Fatalf("expected %v, got %v", ErrLineInfoMismatch, err)
+	}
+	if _, err := NewLineWithInfo(stations, blocks, []StationInfo{{Platforms: -1}, {}}, nil); !errors.Is(err, ErrStationPlatformsNegative) {
+		t.Fatalf("expected %v, got %v", ErrStationPlatformsNegative, err)
+	}
+}
+
+// newTwoLineNetwork は X で交わる RED（A0-X-A2）と BLUE（B0-X-B2）に、B0 と A0 の乗換の連絡を加えた網
+func newTwoLineNetwork(t *testing.T) *Network {
+	t.Helper()
+
+	red, _ := NewLineID("RED")
+	blue, _ := NewLineID("BLUE")
+	a0, _ := NewStationID("A0")
+	b0, _ := NewStationID("B0")
+	network, err := NewNetwork([]NetworkLine{
+		{ID: red, Line: newLineFromIDs(t, []string{"A0", "X", "A2"}, []string{"RB0", "RB1"})},
+		{ID: blue, Line: newLineFromIDs(t, []string{"B0", "X", "B2"}, []string{"BB0", "BB1"})},
+	}, []TransferLink{{From: b0, To: a0, MinTransfer: 3 * time.Minute}})
+	if err != nil {
+		t.Fatalf("new network failed: %v", err)
+	}
+	return network
+}
+
+func newLineFromIDs(t *testing.T, stationIDs, blockIDs []string) *Line {
+	t.Helper()
+
+	stations := make([]StationID, 0, len(stationIDs))
+	for _, raw := range stationIDs {
+		id, _ := NewStationID(raw)
+		stations = append(stations, id)
+	}
+	blocks := make([]BlockID, 0, len(blockIDs))
+	for _, raw := range blockIDs {
+		id, _ := NewBlockID(raw)
+		blocks = append(blocks, id)
+	}
+	line, err := NewLine(stations, blocks)
+	if err != nil {
+		t.Fatalf("new line failed: %v", err)
+	}
+	return line
+}
+
+func stationStrings(ids []StationID) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, id.String())
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package simulation
+
+import "fmt"
+
+// PriorityRule は同じ時刻に同じ区間を求めた列車のどれを先に通すかを決める規則。
+// 規則は並べた順に適用し、決まらなければ次の規則で比べる。
+type PriorityRule string
+
+const (
+	// PriorityEarliestArrival はその刻みで先に区間の端へ着いた列車を通す（刻みの初めから待っていた列車が最も先）
+	PriorityEarliestArrival PriorityRule = "EARLIEST_ARRIVAL"
+	// PriorityForwardFirst は下り方向（路線の順方向）の列車を通す
+	PriorityForwardFirst PriorityRule = "FORWARD_FIRST"
+	// PriorityBackwardFirst は上り方向（路線の逆方向）の列車を通す
+	PriorityBackwardFirst PriorityRule = "BACKWARD_FIRST"
+	// PriorityFasterFirst は速い列車を通す
+	PriorityFasterFirst PriorityRule = "FASTER_FIRST"
+)
+
+// DefaultPriorityRules は規則を指定していない状態で使う規則。
+// 単線では1つの区間を両側から求めるので、方向まで比べれば列車の名前で決まることはない。
+var DefaultPriorityRules = []PriorityRule{PriorityEarliestArrival, PriorityForwardFirst}
+
+func ParsePriorityRule(v string) (PriorityRule, error) {
+	switch r := PriorityRule(v); r {
+	case PriorityEarliestArrival, PriorityForwardFirst, PriorityBackwardFirst, PriorityFasterFirst:
+		return r, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownPriorityRule, v)
+	}
+}
+
+// PriorityRules は区間の競合に使う規則を返す（未指定なら DefaultPriorityRules）
+func (s *SimulationState) PriorityRules() []PriorityRule {
+	rules := s.rules()
+	out := make([]PriorityRule, len(rules))
+	copy(out, rules)
+	return out
+}
+
+// rules は PriorityRules と同じ規則を複製せずに返す（刻みの計算用。変更しないこと）
+func (s *SimulationState) rules() []PriorityRule {
+	if len(s.priorityRules) == 0 {
+		return DefaultPriorityRules
+	}
+	return s.priorityRules
+}
+
+// SetPriorityRules は区間の競合に使う規則を置き換える。空なら DefaultPriorityRules に戻す。
+func (s *SimulationState) SetPriorityRules(rules []PriorityRule) error {
+	seen := make(map[PriorityRule]struct{}, len(rules))
+	for _, rule := range rules {
+		if _, err := ParsePriorityRule(string(rule)); err != nil {
+			return err
+		}
+		if _, dup := seen[rule]; dup {
+			return fmt.Errorf("%w: %s", ErrDuplicatePriorityRule, rule)
+		}
+		seen[rule] = struct{}{}
+	}
+
+	if len(rules) == 0 {
+		s.priorityRules = nil
+		return nil
+	}
+	s.priorityRules = make([]PriorityRule, len(rules))
+	copy(s.priorityRules, rules)
+	return nil
+}
+
+// precedes は同じ区間を求めた a を b より先に通すかを返す。
+// どの規則でも決まらないときだけ列車IDで決める（結果を再現できるようにするため）。
+func precedes(rules []PriorityRule, a, b *mover) bool {
+	for _, rule := range rules {
+		switch rule {
+		case PriorityEarliestArrival:
+			if a.arrivedAt != b.arrivedAt {
+				return a.arrivedAt < b.arrivedAt
+			}
+		case PriorityForwardFirst:
+			if a.train.Forward() != b.train.Forward() {
+				return a.train.Forward()
+			}
+		case PriorityBackwardFirst:
+			if a.train.Forward() != b.train.Forward() {
+				return !a.train.Forward()
+			}
+		case PriorityFasterFirst:
+			if a.train.Speed() != b.train.Speed() {
+				return a.train.Speed() > b.train.Speed()
+			}
+		}
+	}
+	return a.index < b.index
+}
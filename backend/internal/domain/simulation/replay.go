@@ -0,0 +1,275 @@
+package simulation
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Replay は入力ログを先頭から適用して SimulationState を再構築する。
+// Tick は刻み内の時刻順に移動を解決し、競合は規則で決めるため、同じ入力列からは常に同じ状態が得られる。
+func Replay(records []InputRecord) (*SimulationState, error) {
+	return ReplayEach(records, nil)
+}
+
+// ReplayEach は Replay と同じく再構築し、記録を1件適用するたびに visit を呼ぶ（nil なら呼ばない）。
+// visit に渡す状態は再構築の途中のものなので、呼び出しの外に持ち出さないこと。
+func ReplayEach(records []InputRecord, visit func(record InputRecord, state *SimulationState)) (*SimulationState, error) {
+	var state *SimulationState
+	for _, record := range records {
+		switch record.Kind {
+		case InputInitialized, InputRestored, InputRewound:
+			if record.State == nil {
+				return nil, fmt.Errorf("%w: seq %d has no state", ErrReplayInvalidLog, record.Seq)
+			}
+			restored, err := RestoreSimulationState(*record.State)
+			if err != nil {
+				return nil, fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+			}
+			state = restored
+		case InputTick:
+			if state == nil {
+				return nil, fmt.Errorf("%w: seq %d precedes initial state", ErrReplayInvalidLog, record.Seq)
+			}
+			if state.SimTime().Millis() != record.SimTimeMillis {
+				return nil, fmt.Errorf("%w: seq %d expected sim time %d, replayed %d",
+					ErrReplayDiverged, record.Seq, record.SimTimeMillis, state.SimTime().Millis())
+			}
+			if err := replayTick(state, record, record.DeltaMillis); err != nil {
+				return nil, err
+			}
+		case InputFastForward:
+			if state == nil {
+				return nil, fmt.Errorf("%w: seq %d precedes initial state", ErrReplayInvalidLog, record.Seq)
+			}
+			if state.SimTime().Millis() != record.SimTimeMillis {
+				return nil, fmt.Errorf("%w: seq %d expected sim time %d, replayed %d",
+					ErrReplayDiverged, record.Seq, record.SimTimeMillis, state.SimTime().Millis())
+			}
+			if err := replayFastForward(state, record, record.SimTimeMillis+record.DeltaMillis); err != nil {
+				return nil, err
+			}
+		case InputPriorityRulesChanged:
+			if state == nil || record.State == nil {
+				return nil, fmt.Errorf("%w: seq %d has no state", ErrReplayInvalidLog, record.Seq)
+			}
+			if err := replayPriorityRules(state, record); err != nil {
+				return nil, err
+			}
+		case InputCheckpoint:
+			if state == nil || record.State == nil {
+				return nil, fmt.Errorf("%w: seq %d has no state", ErrReplayInvalidLog, record.Seq)
+			}
+			if diffs := DiffSnapshots(*record.State, state.Snapshot()); len(diffs) != 0 {
+				return nil, fmt.Errorf("%w: seq %d checkpoint mismatch: %v", ErrReplayDiverged, record.Seq, diffs)
+			}
+		default:
+			return nil, fmt.Errorf("%w: seq %d has unknown kind %q", ErrReplayInvalidLog, record.Seq, record.Kind)
+		}
+		state.version = record.Version
+		if visit != nil {
+			visit(record, state)
+		}
+	}
+
+	if state == nil {
+		return nil, fmt.Errorf("%w: log is empty", ErrReplayInvalidLog)
+	}
+	return state, nil
+}
+
+// StateAt は入力ログから、現在のタイムライン上で target 時点の状態を再構築する。
+// 直近の状態付き記録（初期状態・取り込み・チェックポイント・巻き戻し）から Tick を再適用する。
+// 戻れるのは記録した Tick の区切り（TICK 方式の早送りなら刻みの区切り）だけで、Tick の途中の時刻は
+// 記録と違う進め方になり同じ状態を再現できないので受け付けない。
+// 巻き戻しやチェックポイントより前の時刻は、その記録以前のログが同じタイムラインを成すので遡って探す。
+// 初期状態や取り込みより前の時刻には戻れない。
+func StateAt(records []InputRecord, target SimTime) (*SimulationState, error) {
+	end := len(records)
+	for {
+		k := lastStateRecord(records[:end])
+		if k < 0 {
+			return nil, fmt.Errorf("%w: no state recorded before %dms", ErrRewindTargetUnavailable, target.Millis())
+		}
+		base := records[k]
+
+		if target.Millis() >= base.State.SimTimeMillis {
+			return replayTicksUntil(base, records[k+1:end], target)
+		}
+		if base.Kind == InputInitialized || base.Kind == InputRestored {
+			return nil, fmt.Errorf("%w: %dms precedes the start of the current timeline", ErrRewindTargetUnavailable, target.Millis())
+		}
+		end = k
+	}
+}
+
+func lastStateRecord(records []InputRecord) int {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].State != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func replayTicksUntil(base InputRecord, ticks []InputRecord, target SimTime) (*SimulationState, error) {
+	state, err := RestoreSimulationState(*base.State)
+	if err != nil {
+		return nil, fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, base.Seq, err)
+	}
+
+	for _, record := range ticks {
+		remaining := target.Millis() - state.SimTime().Millis()
+		if remaining <= 0 {
+			break
+		}
+		switch record.Kind {
+		case InputTick:
+			if remaining < record.DeltaMillis {
+				return nil, withinTickError(target, record)
+			}
+			if err := replayTick(state, record, record.DeltaMillis); err != nil {
+				return nil, err
+			}
+		case InputFastForward:
+			// TICK 方式の早送りは開始から刻みごとに Tick を重ねたものなので、刻みの区切りには戻れる
+			if remaining < record.DeltaMillis {
+				engine, err := ParseEngine(string(record.Engine))
+				if err != nil {
+					return nil, fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+				}
+				if engine != EngineTick || record.StepMillis <= 0 || remaining%record.StepMillis != 0 {
+					return nil, withinTickError(target, record)
+				}
+			}
+			until := state.SimTime().Millis() + min(record.DeltaMillis, remaining)
+			if err := replayFastForward(state, record, until); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if state.SimTime().Millis() != target.Millis() {
+		return nil, fmt.Errorf("%w: %dms is beyond the recorded history", ErrRewindTargetUnavailable, target.Millis())
+	}
+	return state, nil
+}
+
+func withinTickError(target SimTime, record InputRecord) error {
+	return fmt.Errorf("%w: %dms falls inside the step from %dms to %dms (seq %d)",
+		ErrRewindTargetUnavailable, target.Millis(), record.SimTimeMillis, record.SimTimeMillis+record.DeltaMillis, record.Seq)
+}
+
+// replayTick は Tick の記録を、記録と同じ進め方で deltaMillis だけ再適用する
+func replayTick(state *SimulationState, record InputRecord, deltaMillis int64) error {
+	dt, err := NewTickDelta(time.Duration(deltaMillis) * time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	engine, err := ParseEngine(string(record.Engine))
+	if err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	if engine == EngineEvent {
+		_, err := state.AdvanceByEvents(dt)
+		return err
+	}
+	return state.Tick(dt)
+}
+
+// replayFastForward は早送りの記録を、記録と同じ刻みで untilMillis まで再適用する
+func replayFastForward(state *SimulationState, record InputRecord, untilMillis int64) error {
+	step, err := NewTickDelta(time.Duration(record.StepMillis) * time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	until, err := NewSimTime(untilMillis)
+	if err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	engine, err := ParseEngine(string(record.Engine))
+	if err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	if _, err := state.RunUntil(RunUntilSpec{Until: until, Step: step, Engine: engine}); err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	return nil
+}
+
+// replayPriorityRules は記録の規則を適用し、列車の状態が記録と一致することを確かめる
+func replayPriorityRules(state *SimulationState, record InputRecord) error {
+	rules := make([]PriorityRule, 0, len(record.State.PriorityRules))
+	for _, raw := range record.State.PriorityRules {
+		rule, err := ParsePriorityRule(raw)
+		if err != nil {
+			return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := state.SetPriorityRules(rules); err != nil {
+		return fmt.Errorf("%w: seq %d: %v", ErrReplayInvalidLog, record.Seq, err)
+	}
+	if diffs := DiffSnapshots(*record.State, state.Snapshot()); len(diffs) != 0 {
+		return fmt.Errorf("%w: seq %d state mismatch: %v", ErrReplayDiverged, record.Seq, diffs)
+	}
+	return nil
+}
+
+// DiffSnapshots は2つのスナップショットの相違点を列挙する（一致すれば空）。
+// IDとバージョンは比較しない。
+func DiffSnapshots(expected, actual StateSnapshot) []string {
+	diffs := make([]string, 0)
+
+	if expected.SimTimeMillis != actual.SimTimeMillis {
+		diffs = append(diffs, fmt.Sprintf("simTime: expected %d, got %d", expected.SimTimeMillis, actual.SimTimeMillis))
+	}
+	if !slices.Equal(expected.Stations, actual.Stations) {
+		diffs = append(diffs, "line stations differ")
+	}
+	if !slices.Equal(expected.Blocks, actual.Blocks) {
+		diffs = append(diffs, "line blocks differ")
+	}
+	if !slices.Equal(expected.StationInfo, actual.StationInfo) || !slices.Equal(expected.BlockInfo, actual.BlockInfo) {
+		diffs = append(diffs, "line info differs")
+	}
+	if !reflect.DeepEqual(expected.Layout, actual.Layout) {
+		diffs = append(diffs, "line layout differs")
+	}
+	if !slices.EqualFunc(expected.Lines, actual.Lines, func(a, b LineSnapshot) bool {
+		return a.ID == b.ID && slices.Equal(a.Stations, b.Stations) && slices.Equal(a.Blocks, b.Blocks) &&
+			slices.Equal(a.StationInfo, b.StationInfo) && slices.Equal(a.BlockInfo, b.BlockInfo) &&
+			reflect.DeepEqual(a.Layout, b.Layout)
+	}) {
+		diffs = append(diffs, "network lines differ")
+	}
+	if !slices.Equal(expected.Transfers, actual.Transfers) {
+		diffs = append(diffs, "network transfers differ")
+	}
+	if !slices.Equal(expected.PriorityRules, actual.PriorityRules) {
+		diffs = append(diffs, fmt.Sprintf("priority rules: expected %v, got %v", expected.PriorityRules, actual.PriorityRules))
+	}
+
+	actualTrains := make(map[string]TrainSnapshot, len(actual.Trains))
+	for _, t := range actual.Trains {
+		actualTrains[t.ID] = t
+	}
+	for _, want := range expected.Trains {
+		got, ok := actualTrains[want.ID]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("train %s: missing", want.ID))
+			continue
+		}
+		delete(actualTrains, want.ID)
+		if got != want {
+			diffs = append(diffs, fmt.Sprintf("train %s: expected %+v, got %+v", want.ID, want, got))
+		}
+	}
+	for _, t := range actual.Trains {
+		if _, extra := actualTrains[t.ID]; extra {
+			diffs = append(diffs, fmt.Sprintf("train %s: unexpected", t.ID))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,111 @@
+package simulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplayRebuildsStateFromInputs(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	records := []InputRecord{NewInitializedInput(state, time.Now())}
+
+	for _, d := range []time.Duration{700 * time.Millisecond, 3 * time.Second, 1100 * time.Millisecond} {
+		delta, _ := NewTickDelta(d)
+		before := state.SimTime()
+		if err := state.Tick(delta); err != nil {
+			t.Fatalf("tick failed: %v", err)
+		}
+		records = append(records, NewTickInput(before, delta, EngineTick, state, time.Now()))
+	}
+
+	replayed, err := Replay(records)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if diffs := DiffSnapshots(state.Snapshot(), replayed.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected identical state, got %v", diffs)
+	}
+}
+
+func TestReplayDetectsMissingInput(t *testing.T) {
+	state := newTestState(t)
+	delta, _ := NewTickDelta(time.Second)
+	records := []InputRecord{
+		NewInitializedInput(state, time.Now()),
+		{Seq: 2, Kind: InputTick, SimTimeMillis: 5000, DeltaMillis: delta.Duration().Milliseconds()},
+	}
+
+	if _, err := Replay(records); !errors.Is(err, ErrReplayDiverged) {
+		t.Fatalf("expected ErrReplayDiverged, got %v", err)
+	}
+}
+
+func TestStateAtFollowsRewoundTimeline(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	records := []InputRecord{NewInitializedInput(state, time.Now())}
+	tick := func(d time.Duration) {
+		t.Helper()
+		delta, _ := NewTickDelta(d)
+		before := state.SimTime()
+		if err := state.Tick(delta); err != nil {
+			t.Fatalf("tick failed: %v", err)
+		}
+		records = append(records, NewTickInput(before, delta, EngineTick, state, time.Now()))
+	}
+	tick(time.Second)
+	tick(time.Second)
+	records = append(records, NewCheckpointInput(state, time.Now()))
+	tick(time.Second)
+
+	// Tick の途中の時刻には戻れない
+	if _, err := StateAt(records, mustSimTime(t, 2500)); !errors.Is(err, ErrRewindTargetUnavailable) {
+		t.Fatalf("expected ErrRewindTargetUnavailable, got %v", err)
+	}
+
+	// 2秒へ巻き戻して、別の刻みで進め直す
+	rewound, err := StateAt(records, mustSimTime(t, 2000))
+	if err != nil {
+		t.Fatalf("state at failed: %v", err)
+	}
+	before := state.SimTime()
+	state = rewound
+	records = append(records, NewRewoundInput(before, state, time.Now()))
+	tick(2 * time.Second)
+
+	// 巻き戻し前の時刻は元のタイムラインから、後の時刻は新しいタイムラインから求まる
+	early, err := StateAt(records, mustSimTime(t, 1000))
+	if err != nil {
+		t.Fatalf("state at failed: %v", err)
+	}
+	if got := early.Trains()[0].Progress().Float64(); got != 0.5 {
+		t.Fatalf("expected progress 0.5 at 1s, got %v", got)
+	}
+	latest, err := StateAt(records, mustSimTime(t, 4000))
+	if err != nil {
+		t.Fatalf("state at failed: %v", err)
+	}
+	if diffs := DiffSnapshots(state.Snapshot(), latest.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected current state, got %v", diffs)
+	}
+
+	if _, err := StateAt(records, mustSimTime(t, 4001)); !errors.Is(err, ErrRewindTargetUnavailable) {
+		t.Fatalf("expected ErrRewindTargetUnavailable, got %v", err)
+	}
+}
+
+func mustSimTime(t *testing.T, millis int64) SimTime {
+	t.Helper()
+
+	st, err := NewSimTime(millis)
+	if err != nil {
+		t.Fatalf("new sim time failed: %v", err)
+	}
+	return st
+}
@@ -2,8 +2,28 @@ package simulation
 
 import "context"
 
+// Repository は SimulationState を保持する。
+// Get は呼び出しごとに保存内容から復元した独立な状態を返し、保持している値そのものは渡さない
+// （変更は Save するまで他の呼び出し側から見えない）。
 type Repository interface {
-	Get(ctx context.Context) (*SimulationState, error)
+	Get(ctx context.Context, id SimulationID) (*SimulationState, error)
 	Create(ctx context.Context, state *SimulationState) error
 	Save(ctx context.Context, state *SimulationState) error
+	Delete(ctx context.Context, id SimulationID) error
+}
+
+// TimetableRepository はシミュレーションの計画ダイヤ（運行図表に重ねる時刻表）を保持する。
+// Get は計画ダイヤが無ければ nil を返し、Save に nil を渡せば計画ダイヤを消す。
+type TimetableRepository interface {
+	Get(ctx context.Context, id SimulationID) (*Timetable, error)
+	Save(ctx context.Context, id SimulationID, timetable *Timetable) error
+	Delete(ctx context.Context, id SimulationID) error
+}
+
+// BreakpointRepository はシミュレーションのブレークポイントの定義を登録順に保持する。
+// Save は定義の一覧をまとめて置き換え、空の一覧を渡せば定義を消す。
+type BreakpointRepository interface {
+	List(ctx context.Context, id SimulationID) ([]Breakpoint, error)
+	Save(ctx context.Context, id SimulationID, breakpoints []Breakpoint) error
+	Delete(ctx context.Context, id SimulationID) error
 }
@@ -0,0 +1,88 @@
+package simulation
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// ScheduledTrain は時刻 At に網へ入る列車（時刻表の始発を待つ列車）。
+// At を過ぎても置く区間に在線があれば、空くまで待ってから入る。
+type ScheduledTrain struct {
+	Train Train
+	At    SimTime
+}
+
+// ScheduleTrain は列車を at に網へ入れるよう予約する。at が既に来ていて区間が空いていればすぐに入る。
+func (s *SimulationState) ScheduleTrain(train *Train, at SimTime) error {
+	if err := s.schedule(train, at); err != nil {
+		return err
+	}
+	return s.enterDueTrains(nil)
+}
+
+// ScheduledTrains はまだ網に入っていない列車を入る順に複製して返す
+func (s *SimulationState) ScheduledTrains() []ScheduledTrain {
+	return slices.Clone(s.scheduled)
+}
+
+// schedule は列車を入る順（時刻、同じ時刻なら列車ID）の位置に予約する
+func (s *SimulationState) schedule(train *Train, at SimTime) error {
+	if s.hasTrain(train.ID()) {
+		return ErrTrainAlreadyExists
+	}
+	if _, ok := s.network.IndexOfBlock(train.BlockID()); !ok {
+		return ErrBlockNotFound
+	}
+	entry := ScheduledTrain{Train: *train, At: at}
+	index, _ := slices.BinarySearchFunc(s.scheduled, entry, compareScheduledTrains)
+	s.scheduled = slices.Insert(s.scheduled, index, entry)
+	return nil
+}
+
+// enterDueTrains は時刻の来た予約の列車を、置く区間が空いていれば網に入れる（空かなければ予約に残す）
+func (s *SimulationState) enterDueTrains(trace *tickTrace) error {
+	kept := s.scheduled[:0]
+	for _, entry := range s.scheduled {
+		block, _ := s.network.IndexOfBlock(entry.Train.BlockID())
+		if entry.At.Millis() > s.simTime.Millis() || s.occupied[block] != noTrain {
+			kept = append(kept, entry)
+			continue
+		}
+		if err := s.addTrain(&entry.Train); err != nil {
+			return err
+		}
+		trace.emit(Event{Type: EventTrainEntered, At: s.simTime, TrainID: entry.Train.ID(), BlockID: entry.Train.BlockID()})
+	}
+	clear(s.scheduled[len(kept):])
+	s.scheduled = kept
+	return nil
+}
+
+// nextScheduledDelay は次に時刻の来る予約までの時間。時刻の来た予約や予約が無ければ false。
+func (s *SimulationState) nextScheduledDelay() (time.Duration, bool) {
+	for _, entry := range s.scheduled {
+		if entry.At.Millis() > s.simTime.Millis() {
+			return time.Duration(entry.At.Millis()-s.simTime.Millis()) * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
+
+// hasTrain は網の中か予約に同じIDの列車があるかを返す
+func (s *SimulationState) hasTrain(id TrainID) bool {
+	if _, ok := s.trainIndex[id.String()]; ok {
+		return true
+	}
+	return slices.ContainsFunc(s.scheduled, func(entry ScheduledTrain) bool { return entry.Train.ID() == id })
+}
+
+func compareScheduledTrains(a, b ScheduledTrain) int {
+	if a.At.Millis() != b.At.Millis() {
+		if a.At.Millis() < b.At.Millis() {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.Train.ID().String(), b.Train.ID().String())
+}
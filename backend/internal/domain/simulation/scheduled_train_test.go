@@ -0,0 +1,105 @@
+package simulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduledTrainEntersAtItsTime(t *testing.T) {
+	state := newTestState(t)
+	at, _ := NewSimTime((2 * time.Second).Milliseconds())
+	if err := state.ScheduleTrain(newTestTrain(t, "T0", "B0", 0.0, true, 0.5), at); err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "T0", "B1", 0.0, true, 0.5)); !errors.Is(err, ErrTrainAlreadyExists) {
+		t.Fatalf("expected %v, got %v", ErrTrainAlreadyExists, err)
+	}
+
+	// 時刻が来るまでは網に居ない
+	delta, _ := NewTickDelta(time.Second)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	if len(state.Trains()) != 0 {
+		t.Fatalf("expected no train before its time, got %v", state.Trains())
+	}
+
+	// 時刻の来た刻みの終わりに入り、次の刻みから動く
+	events, err := state.TickWithEvents(delta)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventTrainEntered || events[0].TrainID.String() != "T0" {
+		t.Fatalf("expected T0 to enter, got %+v", events)
+	}
+	if got := state.Trains()[0]; got.BlockID().String() != "B0" || got.Progress().Float64() != 0 {
+		t.Fatalf("expected T0 at the start of B0, got %s %f", got.BlockID(), got.Progress().Float64())
+	}
+	if len(state.ScheduledTrains()) != 0 {
+		t.Fatalf("expected no scheduled train left")
+	}
+}
+
+func TestScheduledTrainWaitsForOccupiedBlock(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B0", 0.5, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.ScheduleTrain(newTestTrain(t, "T1", "B0", 0.0, true, 0.5), SimTime{}); err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	// 時刻は来ていても区間がふさがっているので待つ
+	if len(state.Trains()) != 1 || len(state.ScheduledTrains()) != 1 {
+		t.Fatalf("expected T1 to wait, got trains=%v", state.Trains())
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	trains := state.Trains()
+	if len(trains) != 2 || trains[0].BlockID().String() != "B1" || trains[1].BlockID().String() != "B0" {
+		t.Fatalf("expected T1 to enter B0 once T0 left, got %v", trains)
+	}
+}
+
+func TestNextEventDelayStopsAtScheduledTrain(t *testing.T) {
+	state := newTestState(t)
+	at, _ := NewSimTime((90 * time.Second).Milliseconds())
+	if err := state.ScheduleTrain(newTestTrain(t, "T0", "B0", 0.0, true, 1.0/60), at); err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	if delay, ok := state.NextEventDelay(); !ok || delay != 90*time.Second {
+		t.Fatalf("expected next event in 90s, got %v %v", delay, ok)
+	}
+
+	delta, _ := NewTickDelta(2 * time.Minute)
+	if _, err := state.AdvanceByEvents(delta); err != nil {
+		t.Fatalf("advance failed: %v", err)
+	}
+	// 90 秒で入り、残りの 30 秒で半区間進む
+	if got := state.Trains(); len(got) != 1 || got[0].BlockID().String() != "B0" || got[0].Progress().Float64() != 0.5 {
+		t.Fatalf("expected T0 to enter and move, got %v", got)
+	}
+}
+
+func TestSnapshotKeepsScheduledTrains(t *testing.T) {
+	state := newTestState(t)
+	at, _ := NewSimTime((time.Minute).Milliseconds())
+	if err := state.ScheduleTrain(newTestTrain(t, "T0", "B1", 0.0, true, 0.5), at); err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+
+	restored, err := RestoreSimulationState(state.Snapshot())
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	scheduled := restored.ScheduledTrains()
+	if len(scheduled) != 1 || scheduled[0].Train.ID().String() != "T0" || scheduled[0].At != at {
+		t.Fatalf("expected T0 to stay scheduled, got %+v", scheduled)
+	}
+	if len(restored.Trains()) != 0 {
+		t.Fatalf("expected no train on the network, got %v", restored.Trains())
+	}
+}
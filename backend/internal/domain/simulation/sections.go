@@ -0,0 +1,151 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// moverGroup は1刻みのあいだ互いに影響しない列車の組。組ごとに別々の（並行の）計算で解決できる。
+type moverGroup struct {
+	// members は組の列車の movers での添字（列車ID順）
+	members []int
+	claims  []claim
+	// buffered なら出来事をすぐ知らせずに events に貯める（並行に解決したあとで時刻順に並べ直すため）
+	buffered bool
+	events   []timedEvent
+	err      error
+}
+
+// timedEvent は刻み内の時刻 at に列車（movers の添字）に起きた出来事
+type timedEvent struct {
+	at    float64
+	mover int
+	event Event
+}
+
+func (g *moverGroup) emit(trace *tickTrace, at float64, mover int, e Event) {
+	if trace == nil {
+		return
+	}
+	if !g.buffered {
+		trace.emit(e)
+		return
+	}
+	g.events = append(g.events, timedEvent{at: at, mover: mover, event: e})
+}
+
+// SetParallelSections は路線を駅 boundaries で区切り、刻みの移動を区切りごとに並行に解決させる。
+// 刻みのうちに列車が届きうる区切りはその刻みだけ両側をまとめて解決するので、区切りを越える列車の受け渡しを含め、
+// 結果（状態と出来事の順序）は区切らずに解決したのと同じになる。空なら区切らない。
+// 路線どうしは互いに影響しないので、区切りが無くても路線ごとには並行に解決する。
+// 乗換駅を区切りにすると、その駅を途中に持つすべての路線を区切る。
+// 区切りは計算の進め方だけを変えるので、スナップショットには含めない。
+func (s *SimulationState) SetParallelSections(boundaries []StationID) error {
+	var sections []int
+	for i, boundary := range boundaries {
+		if slices.Contains(boundaries[:i], boundary) {
+			return fmt.Errorf("%w: %s", ErrSectionBoundaryDuplicate, boundary)
+		}
+		found := false
+		for line, l := range s.network.lines {
+			index, ok := l.Line.stationIndex[boundary.String()]
+			if !ok || index <= 0 || index >= len(l.Line.blocks) {
+				continue
+			}
+			sections = append(sections, s.network.starts[line]+index)
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", ErrSectionBoundaryInvalid, boundary)
+		}
+	}
+	slices.Sort(sections)
+	s.sections = sections
+	return nil
+}
+
+// ParallelSections は並行区切りの駅を路線の順に重複なく返す
+func (s *SimulationState) ParallelSections() []StationID {
+	out := make([]StationID, 0, len(s.sections))
+	for _, index := range s.sections {
+		line := s.network.blockLine[index]
+		station := s.network.stationAt(line, index-s.network.starts[line])
+		if !slices.Contains(out, station) {
+			out = append(out, station)
+		}
+	}
+	return out
+}
+
+// planGroups は刻みの列車を組に分ける。区切りのうち、この刻みでどの列車も届かないものだけを組の境目にする。
+// 届くかは抑止を除いた持ち時間で進める距離から多めに見積もる（多めならまとめて解決するだけで結果は変わらない）。
+func (s *SimulationState) planGroups(movers []mover, total, end float64) []moverGroup {
+	if len(s.sections) == 0 && len(s.network.lines) == 1 {
+		groups := resize(s.scratch.groups, 1)
+		groups[0].members = groups[0].members[:0]
+		for i := range movers {
+			groups[0].members = append(groups[0].members, i)
+		}
+		s.scratch.groups = groups
+		return groups
+	}
+
+	active := resize(s.scratch.cutActive, len(s.sections))
+	clear(active)
+	for i := range movers {
+		m := &movers[i]
+		if m.done || m.from >= end {
+			continue
+		}
+		reach := m.train.Speed()*(total-m.from) + boundaryEpsilon
+		remaining := m.remaining()
+		if reach < remaining {
+			continue
+		}
+		// 届きうる駅の範囲 [lo, hi]（区間の通し番号で、区間 i の手前の駅を i とする）
+		lo, hi := m.train.block+1, m.train.block+1+int(math.Floor(reach-remaining))
+		if !m.train.Forward() {
+			lo, hi = m.train.block-int(math.Floor(reach-remaining)), m.train.block
+		}
+		at, _ := slices.BinarySearch(s.sections, lo)
+		for ; at < len(s.sections) && s.sections[at] <= hi; at++ {
+			active[at] = true
+		}
+	}
+	s.scratch.cutActive = active
+
+	// 路線の境目はどの列車も越えないので、いつも組の境目にする
+	cuts := s.scratch.cuts[:0]
+	next := 0
+	for _, start := range s.network.starts[1:] {
+		for ; next < len(s.sections) && s.sections[next] < start; next++ {
+			if !active[next] {
+				cuts = append(cuts, s.sections[next])
+			}
+		}
+		cuts = append(cuts, start)
+	}
+	for ; next < len(s.sections); next++ {
+		if !active[next] {
+			cuts = append(cuts, s.sections[next])
+		}
+	}
+	s.scratch.cuts = cuts
+
+	groups := resize(s.scratch.groups, len(cuts)+1)
+	for i := range groups {
+		groups[i].members = groups[i].members[:0]
+		groups[i].buffered = false
+		clear(groups[i].events)
+		groups[i].events = groups[i].events[:0]
+		groups[i].err = nil
+	}
+	for i := range movers {
+		// 区間の添字より後ろにない境目の数が組の番号
+		group, _ := slices.BinarySearch(cuts, movers[i].train.block+1)
+		groups[group].members = append(groups[group].members, i)
+	}
+	s.scratch.groups = groups
+	return groups
+}
@@ -0,0 +1,180 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParallelSectionsMatchUnpartitionedTicks(t *testing.T) {
+	// CPUが1つのとき（組を順に解決する）も複数のとき（並行に解決する）も、区切らない刻みと同じ結果・出来事の順になる
+	for _, procs := range []int{1, 4} {
+		t.Run(fmt.Sprintf("GOMAXPROCS=%d", procs), func(t *testing.T) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+			assertParallelSectionsMatchUnpartitionedTicks(t)
+		})
+	}
+}
+
+func assertParallelSectionsMatchUnpartitionedTicks(t *testing.T) {
+	t.Helper()
+
+	sequential := newGeneratedState(t, 400, 40)
+	if err := sequential.SetPriorityRules([]PriorityRule{PriorityEarliestArrival, PriorityFasterFirst}); err != nil {
+		t.Fatalf("set priority rules failed: %v", err)
+	}
+	parallel := sequential.Clone()
+	if err := parallel.SetParallelSections(stationsEvery(t, parallel, 25)); err != nil {
+		t.Fatalf("set parallel sections failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(700 * time.Millisecond)
+	split, crossed := 0, 0
+	for tick := 0; tick < 400; tick++ {
+		before := sectionOf(parallel)
+		want, err := sequential.TickWithEvents(delta)
+		if err != nil {
+			t.Fatalf("sequential tick failed: %v", err)
+		}
+		got, err := parallel.TickWithEvents(delta)
+		if err != nil {
+			t.Fatalf("parallel tick failed: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("tick %d: events differ\nparallel:   %+v\nsequential: %+v", tick, got, want)
+		}
+		if !reflect.DeepEqual(parallel.Snapshot(), sequential.Snapshot()) {
+			t.Fatalf("tick %d: states differ", tick)
+		}
+
+		if busyGroups(parallel) > 1 {
+			split++
+		}
+		for id, section := range sectionOf(parallel) {
+			if before[id] != section {
+				crossed++
+			}
+		}
+	}
+	// 並行に解決した刻みと、区切りを越えた受け渡しの両方を確かめている
+	if split == 0 || crossed == 0 {
+		t.Fatalf("expected parallel ticks and cross-section handoffs, got split=%d crossed=%d", split, crossed)
+	}
+}
+
+func TestParallelSectionsHandOffTrainAcrossBoundary(t *testing.T) {
+	state := newThreeBlockState(t)
+	s1, _ := NewStationID("S1")
+	if err := state.SetParallelSections([]StationID{s1}); err != nil {
+		t.Fatalf("set parallel sections failed: %v", err)
+	}
+	// A は区切りの S1 を越えて B1 に入り、逆向きの Z は B2 の中で止まらずに進む
+	if err := state.AddTrain(newTestTrain(t, "A", "B0", 0.75, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	if err := state.AddTrain(newTestTrain(t, "Z", "B2", 0.5, false, 0.25)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	events, err := state.TickWithEvents(delta)
+	if err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	if got := occupantOf(t, state, "B1"); got != "A" {
+		t.Fatalf("expected A in B1, got %q", got)
+	}
+	if len(events) != 1 || events[0].Type != EventTrainArrived || events[0].StationID.String() != "S1" {
+		t.Fatalf("expected A to arrive at S1, got %+v", events)
+	}
+}
+
+func TestSetParallelSectionsRejectsInvalidBoundaries(t *testing.T) {
+	cases := []struct {
+		name       string
+		boundaries []string
+		wantErr    error
+	}{
+		{name: "line end", boundaries: []string{"S0"}, wantErr: ErrSectionBoundaryInvalid},
+		{name: "unknown station", boundaries: []string{"X"}, wantErr: ErrSectionBoundaryInvalid},
+		{name: "duplicate", boundaries: []string{"S2", "S2"}, wantErr: ErrSectionBoundaryDuplicate},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := newThreeBlockState(t)
+			boundaries := make([]StationID, 0, len(tc.boundaries))
+			for _, raw := range tc.boundaries {
+				id, _ := NewStationID(raw)
+				boundaries = append(boundaries, id)
+			}
+
+			if err := state.SetParallelSections(boundaries); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+			if len(state.ParallelSections()) != 0 {
+				t.Fatalf("expected sections to stay unset, got %v", state.ParallelSections())
+			}
+		})
+	}
+}
+
+func BenchmarkTickParallelSections(b *testing.B) {
+	for _, sections := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("sections=%d", sections), func(b *testing.B) {
+			state := newGeneratedState(b, 5000, 500)
+			if err := state.SetParallelSections(stationsEvery(b, state, 5000/sections)); err != nil {
+				b.Fatalf("set parallel sections failed: %v", err)
+			}
+			delta, _ := NewTickDelta(100 * time.Millisecond)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := state.Tick(delta); err != nil {
+					b.Fatalf("tick failed: %v", err)
+				}
+			}
+			b.ReportMetric(float64(state.SimTime().Millis())/1000/b.Elapsed().Seconds(), "sim-s/s")
+		})
+	}
+}
+
+// stationsEvery は路線の途中の駅を every 区間ごとに返す
+func stationsEvery(tb testing.TB, state *SimulationState, every int) []StationID {
+	tb.Helper()
+	stations := state.Network().Stations()
+	var out []StationID
+	for i := every; i < len(stations)-1; i += every {
+		out = append(out, stations[i])
+	}
+	return out
+}
+
+// sectionOf は列車ごとに、いる区間が並行区切りのいくつ目の区切りにあるかを返す
+func sectionOf(state *SimulationState) map[string]int {
+	out := make(map[string]int, len(state.trains))
+	for _, train := range state.trains {
+		section := 0
+		for section < len(state.sections) && state.sections[section] <= train.block {
+			section++
+		}
+		out[train.ID().String()] = section
+	}
+	return out
+}
+
+// busyGroups は直前の刻みで列車のいた組の数
+func busyGroups(state *SimulationState) int {
+	busy := 0
+	for _, g := range state.scratch.groups {
+		if len(g.members) > 0 {
+			busy++
+		}
+	}
+	return busy
+}
@@ -0,0 +1,299 @@
+package simulation
+
+import (
+	"slices"
+	"time"
+)
+
+// StateSnapshot は SimulationState を永続化・復元するための素朴な表現。
+// 復元時は NewLine / NewNetwork / NewTrain / AddTrain と同じ不変条件で検証される。
+// 既定のIDの路線1つだけの網は旧い形式のまま Stations / Blocks で表し、それ以外は Lines で表す。
+type StateSnapshot struct {
+	ID       string
+	Version  int64
+	Stations []string
+	Blocks   []string
+	// StationInfo と BlockInfo は Stations / Blocks と同じ並びの案内用の情報（無ければ nil）
+	StationInfo []StationInfo
+	BlockInfo   []BlockInfo
+	// Layout は路線図での配置（無ければ nil）
+	Layout        *LineLayout
+	Lines         []LineSnapshot
+	Transfers     []TransferSnapshot
+	SimTimeMillis int64
+	Trains        []TrainSnapshot
+	// Scheduled はまだ網に入っていない列車（入る順）
+	Scheduled []ScheduledTrainSnapshot
+	// PriorityRules は区間の競合に使う規則（空なら既定の規則）
+	PriorityRules []string
+}
+
+type LineSnapshot struct {
+	ID          string
+	Stations    []string
+	Blocks      []string
+	StationInfo []StationInfo
+	BlockInfo   []BlockInfo
+	Layout      *LineLayout
+}
+
+type TransferSnapshot struct {
+	From              string
+	To                string
+	MinTransferMillis int64
+}
+
+type TrainSnapshot struct {
+	ID              string
+	BlockID         string
+	Progress        float64
+	Forward         bool
+	Speed           float64
+	PendingTurnback bool
+}
+
+type ScheduledTrainSnapshot struct {
+	TrainSnapshot
+	AtMillis int64
+}
+
+func (s *SimulationState) Snapshot() StateSnapshot {
+	snap := StateSnapshot{
+		ID:            s.id.String(),
+		Version:       s.version,
+		SimTimeMillis: s.simTime.Millis(),
+	}
+	if s.network.singleLine() {
+		line := s.network.lines[0].Line
+		snap.Stations, snap.Blocks = lineSnapshotIDs(line)
+		snap.StationInfo, snap.BlockInfo = lineSnapshotInfo(line)
+		snap.Layout = lineSnapshotLayout(line)
+	} else {
+		for _, l := range s.network.lines {
+			stations, blocks := lineSnapshotIDs(l.Line)
+			stationInfo, blockInfo := lineSnapshotInfo(l.Line)
+			snap.Lines = append(snap.Lines, LineSnapshot{
+				ID:          l.ID.String(),
+				Stations:    stations,
+				Blocks:      blocks,
+				StationInfo: stationInfo,
+				BlockInfo:   blockInfo,
+				Layout:      lineSnapshotLayout(l.Line),
+			})
+		}
+		for _, t := range s.network.transfers {
+			snap.Transfers = append(snap.Transfers, TransferSnapshot{From: t.From.String(), To: t.To.String(), MinTransferMillis: t.MinTransfer.Milliseconds()})
+		}
+	}
+
+	trains := make([]TrainSnapshot, 0, len(s.trains))
+	for i := range s.trains {
+		trains = append(trains, trainSnapshot(&s.trains[i]))
+	}
+	var scheduled []ScheduledTrainSnapshot
+	for i := range s.scheduled {
+		entry := &s.scheduled[i]
+		scheduled = append(scheduled, ScheduledTrainSnapshot{TrainSnapshot: trainSnapshot(&entry.Train), AtMillis: entry.At.Millis()})
+	}
+
+	var rules []string
+	for _, rule := range s.priorityRules {
+		rules = append(rules, string(rule))
+	}
+
+	snap.Trains = trains
+	snap.Scheduled = scheduled
+	snap.PriorityRules = rules
+	return snap
+}
+
+func trainSnapshot(train *Train) TrainSnapshot {
+	return TrainSnapshot{
+		ID:              train.ID().String(),
+		BlockID:         train.BlockID().String(),
+		Progress:        train.Progress().Float64(),
+		Forward:         train.Forward(),
+		Speed:           train.Speed(),
+		PendingTurnback: train.PendingTurnback(),
+	}
+}
+
+func lineSnapshotIDs(line *Line) ([]string, []string) {
+	stations := make([]string, 0, len(line.stations))
+	for _, station := range line.stations {
+		stations = append(stations, station.String())
+	}
+	blocks := make([]string, 0, len(line.blocks))
+	for _, block := range line.blocks {
+		blocks = append(blocks, block.String())
+	}
+	return stations, blocks
+}
+
+func RestoreSimulationState(snap StateSnapshot) (*SimulationState, error) {
+	id, err := NewSimulationID(snap.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := restoreNetwork(snap)
+	if err != nil {
+		return nil, err
+	}
+	state, err := NewNetworkSimulationState(id, network)
+	if err != nil {
+		return nil, err
+	}
+	simTime, err := NewSimTime(snap.SimTimeMillis)
+	if err != nil {
+		return nil, err
+	}
+	state.simTime = simTime
+	state.version = snap.Version
+
+	rules := make([]PriorityRule, 0, len(snap.PriorityRules))
+	for _, raw := range snap.PriorityRules {
+		rule, err := ParsePriorityRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := state.SetPriorityRules(rules); err != nil {
+		return nil, err
+	}
+
+	for _, raw := range snap.Trains {
+		train, err := restoreTrain(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := state.AddTrain(train); err != nil {
+			return nil, err
+		}
+	}
+	// 予約は保存したまま戻す（入れるかどうかは次の刻みで判定する）
+	for _, raw := range snap.Scheduled {
+		train, err := restoreTrain(raw.TrainSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		at, err := NewSimTime(raw.AtMillis)
+		if err != nil {
+			return nil, err
+		}
+		if err := state.schedule(train, at); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// lineSnapshotInfo は路線の案内用の情報を返す。どの駅・区間にも情報が無ければ nil（旧い形式のまま残すため）。
+func lineSnapshotInfo(line *Line) ([]StationInfo, []BlockInfo) {
+	var stations []StationInfo
+	if slices.ContainsFunc(line.stationInfo, func(info StationInfo) bool { return info != StationInfo{} }) {
+		stations = line.StationInfos()
+	}
+	var blocks []BlockInfo
+	if slices.ContainsFunc(line.blockInfo, func(info BlockInfo) bool { return info != BlockInfo{} }) {
+		blocks = line.BlockInfos()
+	}
+	return stations, blocks
+}
+
+func lineSnapshotLayout(line *Line) *LineLayout {
+	layout, ok := line.Layout()
+	if !ok {
+		return nil
+	}
+	return &layout
+}
+
+func restoreNetwork(snap StateSnapshot) (*Network, error) {
+	if len(snap.Lines) == 0 {
+		line, err := restoreLine(LineSnapshot{
+			Stations:    snap.Stations,
+			Blocks:      snap.Blocks,
+			StationInfo: snap.StationInfo,
+			BlockInfo:   snap.BlockInfo,
+			Layout:      snap.Layout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewSingleLineNetwork(line)
+	}
+
+	lines := make([]NetworkLine, 0, len(snap.Lines))
+	for _, raw := range snap.Lines {
+		id, err := NewLineID(raw.ID)
+		if err != nil {
+			return nil, err
+		}
+		line, err := restoreLine(raw)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, NetworkLine{ID: id, Line: line})
+	}
+	transfers := make([]TransferLink, 0, len(snap.Transfers))
+	for _, raw := range snap.Transfers {
+		from, err := NewStationID(raw.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := NewStationID(raw.To)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, TransferLink{From: from, To: to, MinTransfer: time.Duration(raw.MinTransferMillis) * time.Millisecond})
+	}
+	return NewNetwork(lines, transfers)
+}
+
+func restoreLine(snap LineSnapshot) (*Line, error) {
+	stations := make([]StationID, 0, len(snap.Stations))
+	for _, raw := range snap.Stations {
+		station, err := NewStationID(raw)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+
+	blocks := make([]BlockID, 0, len(snap.Blocks))
+	for _, raw := range snap.Blocks {
+		block, err := NewBlockID(raw)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	line, err := NewLineWithInfo(stations, blocks, snap.StationInfo, snap.BlockInfo)
+	if err != nil || snap.Layout == nil {
+		return line, err
+	}
+	return line.WithLayout(*snap.Layout)
+}
+
+func restoreTrain(snap TrainSnapshot) (*Train, error) {
+	id, err := NewTrainID(snap.ID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := NewBlockID(snap.BlockID)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := NewBlockProgress(snap.Progress)
+	if err != nil {
+		return nil, err
+	}
+	train, err := NewTrain(id, block, progress, snap.Forward, snap.Speed)
+	if err != nil {
+		return nil, err
+	}
+	train.setPendingTurnback(snap.PendingTurnback)
+	return train, nil
+}
@@ -0,0 +1,53 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	state := newTestState(t)
+	if err := state.AddTrain(newTestTrain(t, "T0", "B1", 0.9, true, 0.5)); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	delta, _ := NewTickDelta(time.Second)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+
+	restored, err := RestoreSimulationState(state.Snapshot())
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	if restored.ID() != state.ID() {
+		t.Fatalf("expected id %s, got %s", state.ID(), restored.ID())
+	}
+	if restored.SimTime() != state.SimTime() {
+		t.Fatalf("expected sim time %d, got %d", state.SimTime().Millis(), restored.SimTime().Millis())
+	}
+	got := restored.Trains()[0]
+	if !got.PendingTurnback() {
+		t.Fatalf("expected pending turnback to survive restore")
+	}
+	if got.Progress().Float64() != 1.0 {
+		t.Fatalf("expected progress 1.0, got %f", got.Progress().Float64())
+	}
+
+	// 占有状態も復元されていること
+	if err := restored.AddTrain(newTestTrain(t, "T1", "B1", 0.0, true, 0.5)); err != ErrBlockOccupied {
+		t.Fatalf("expected ErrBlockOccupied, got %v", err)
+	}
+}
+
+func TestRestoreRejectsInvalidSnapshot(t *testing.T) {
+	snap := newTestState(t).Snapshot()
+	snap.Trains = []TrainSnapshot{
+		{ID: "T0", BlockID: "B0", Progress: 0.1, Forward: true, Speed: 0.5},
+		{ID: "T1", BlockID: "B0", Progress: 0.2, Forward: true, Speed: 0.5},
+	}
+
+	if _, err := RestoreSimulationState(snap); err != ErrBlockOccupied {
+		t.Fatalf("expected ErrBlockOccupied, got %v", err)
+	}
+}
@@ -1,154 +1,259 @@
 package simulation
 
 import (
-	"sort"
+	"slices"
+	"strings"
+	"time"
 )
 
 type SimulationState struct {
-	line     *Line
-	simTime  SimTime
-	trains   map[string]*Train
-	occupied map[string]TrainID
+	id      SimulationID
+	version int64
+	network *Network
+	simTime SimTime
+	// trains は列車ID順に並べた列車。添字を列車の番号として刻みの計算に使う。
+	trains []Train
+	// trainIndex は列車IDから trains の添字を引く
+	trainIndex map[string]int
+	// scheduled はまだ網に入っていない列車（入る順、ScheduleTrain）
+	scheduled []ScheduledTrain
+	// occupied は区間の添字ごとの在線列車の添字（空いていれば noTrain）
+	occupied []int
+	// priorityRules は区間の競合に使う規則（nil なら DefaultPriorityRules）
+	priorityRules []PriorityRule
+	// sections は並行区切りの駅の添字（昇順、SetParallelSections）
+	sections []int
+	// scratch は刻みの計算で使い回す作業領域（複製には引き継がない）
+	scratch tickScratch
 }
 
-func NewSimulationState(line *Line) (*SimulationState, error) {
+// noTrain は空いている区間の occupied の値
+const noTrain = -1
+
+// NewSimulationState は路線 line だけの網でシミュレーションを作る
+func NewSimulationState(id SimulationID, line *Line) (*SimulationState, error) {
 	if line == nil {
 		return nil, ErrLineHasNoBlocks
 	}
+	network, err := NewSingleLineNetwork(line)
+	if err != nil {
+		return nil, err
+	}
+	return NewNetworkSimulationState(id, network)
+}
+
+// NewNetworkSimulationState は複数の路線からなる網でシミュレーションを作る
+func NewNetworkSimulationState(id SimulationID, network *Network) (*SimulationState, error) {
+	if network == nil {
+		return nil, ErrNetworkHasNoLines
+	}
+	occupied := make([]int, len(network.blocks))
+	for i := range occupied {
+		occupied[i] = noTrain
+	}
 	return &SimulationState{
-		line:     line,
-		trains:   make(map[string]*Train),
-		occupied: make(map[string]TrainID),
+		id:         id,
+		network:    network,
+		trainIndex: make(map[string]int),
+		occupied:   occupied,
 	}, nil
 }
 
-func (s *SimulationState) Line() *Line {
-	return s.line
+func (s *SimulationState) ID() SimulationID {
+	return s.id
+}
+
+func (s *SimulationState) Version() int64 {
+	return s.version
+}
+
+// IncrementVersion は Repository が保存に成功したときに呼び出す
+func (s *SimulationState) IncrementVersion() {
+	s.version++
+}
+
+func (s *SimulationState) Network() *Network {
+	return s.network
 }
 
 func (s *SimulationState) SimTime() SimTime {
 	return s.simTime
 }
 
-func (s *SimulationState) Trains() []Train {
-	keys := s.sortedTrainKeys()
-	out := make([]Train, 0, len(keys))
-	for _, key := range keys {
-		out = append(out, *s.trains[key])
+// StartAt は演習を始める時刻を t にする（時刻表の始発に合わせるときなど）。
+// 時刻を進めたり保存したりした後の状態には使えない。
+func (s *SimulationState) StartAt(t SimTime) error {
+	if s.simTime.Millis() != 0 || s.version != 0 {
+		return ErrSimulationAlreadyStarted
 	}
+	s.simTime = t
+	return nil
+}
+
+// Trains は列車を列車ID順に複製して返す
+func (s *SimulationState) Trains() []Train {
+	out := make([]Train, len(s.trains))
+	copy(out, s.trains)
 	return out
 }
 
+// Occupant は区間に在線している列車を返す。空いていれば false。
+func (s *SimulationState) Occupant(block BlockID) (TrainID, bool) {
+	index, ok := s.network.IndexOfBlock(block)
+	if !ok || s.occupied[index] == noTrain {
+		return TrainID{}, false
+	}
+	return s.trains[s.occupied[index]].ID(), true
+}
+
 func (s *SimulationState) AddTrain(train *Train) error {
-	trainKey := train.ID().String()
-	if _, exists := s.trains[trainKey]; exists {
+	if s.hasTrain(train.ID()) {
 		return ErrTrainAlreadyExists
 	}
-	if !s.line.HasBlock(train.BlockID()) {
+	return s.addTrain(train)
+}
+
+func (s *SimulationState) addTrain(train *Train) error {
+	block, ok := s.network.IndexOfBlock(train.BlockID())
+	if !ok {
 		return ErrBlockNotFound
 	}
-
-	blockKey := train.BlockID().String()
-	if _, occupied := s.occupied[blockKey]; occupied {
+	if s.occupied[block] != noTrain {
 		return ErrBlockOccupied
 	}
 
-	s.trains[trainKey] = train
-	s.occupied[blockKey] = train.ID()
+	added := *train
+	added.block = block
+	at, _ := slices.BinarySearchFunc(s.trains, added.ID(), func(t Train, id TrainID) int {
+		return strings.Compare(t.ID().String(), id.String())
+	})
+	s.trains = slices.Insert(s.trains, at, added)
+	// 挿入した位置より後ろの列車は添字がずれるので引き直す
+	for i := at; i < len(s.trains); i++ {
+		s.trainIndex[s.trains[i].ID().String()] = i
+		s.occupied[s.trains[i].block] = i
+	}
 	return nil
 }
 
+// train は列車IDの列車を返す（状態の中の値を指すので、変更はそのまま状態に反映される）
+func (s *SimulationState) train(id TrainID) (*Train, bool) {
+	index, ok := s.trainIndex[id.String()]
+	if !ok {
+		return nil, false
+	}
+	return &s.trains[index], true
+}
+
+// Clone は列車・在線まで複製した独立な状態を返す（予測など、本番の状態に触れずに進めるため）。
+// 路線網は生成後に変わらないので複製せずに共有する。
+func (s *SimulationState) Clone() *SimulationState {
+	trainIndex := make(map[string]int, len(s.trainIndex))
+	for key, i := range s.trainIndex {
+		trainIndex[key] = i
+	}
+
+	return &SimulationState{
+		id:            s.id,
+		version:       s.version,
+		network:       s.network,
+		simTime:       s.simTime,
+		trains:        slices.Clone(s.trains),
+		trainIndex:    trainIndex,
+		scheduled:     slices.Clone(s.scheduled),
+		occupied:      slices.Clone(s.occupied),
+		priorityRules: append([]PriorityRule(nil), s.priorityRules...),
+		sections:      slices.Clone(s.sections),
+	}
+}
+
 func (s *SimulationState) Tick(dt TickDelta) error {
+	return s.advance(dt, nil)
+}
+
+// TickWithEvents は Tick と同じく進め、その間に起きた出来事を発生順に返す
+func (s *SimulationState) TickWithEvents(dt TickDelta) ([]Event, error) {
+	var events []Event
+	trace := &tickTrace{onEvent: func(e Event) { events = append(events, e) }}
+	if err := s.advance(dt, trace); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// tickTrace は予測実行で進行を観測・操作するためのフック（通常の Tick では nil）
+type tickTrace struct {
+	// holdUntil の時刻まで列車を停める（キーは列車ID）
+	holdUntil map[string]SimTime
+	onEvent   func(Event)
+}
+
+func (s *SimulationState) advance(dt TickDelta, trace *tickTrace) error {
+	start := s.simTime
 	s.simTime = s.simTime.Add(dt.Duration())
 
-	keys := s.sortedTrainKeys()
-	for _, key := range keys {
-		train := s.trains[key]
+	// 待ち合いは刻みの前後で比べ、新たに生じたものだけを知らせる（観測しないときは調べない）
+	if trace != nil {
+		s.findCycles(&s.scratch.deadlocksBefore, &s.scratch.cycles)
+	}
+
+	for i := range s.trains {
+		train := &s.trains[i]
 		if train.PendingTurnback() {
+			trace.emit(Event{Type: EventTrainTurnedBack, At: s.simTime, TrainID: train.ID(), StationID: s.network.stationAhead(train.block, train.Forward())})
 			train.reverseDirection()
 			train.setPendingTurnback(false)
 		}
 	}
 
-	for _, key := range keys {
-		train := s.trains[key]
-		distance := train.Speed() * dt.Duration().Seconds()
-
-		for distance > 0 {
-			progress := train.Progress().Float64()
-			remaining := progress
-			if train.Forward() {
-				remaining = 1.0 - progress
-			}
-			if remaining < boundaryEpsilon {
-				remaining = 0
-			}
-
-			if distance+boundaryEpsilon < remaining {
-				nextProgress := progress - distance
-				if train.Forward() {
-					nextProgress = progress + distance
-				}
-				if err := train.setProgress(nextProgress); err != nil {
-					return err
-				}
-				distance = 0
-				continue
-			}
-
-			if train.Forward() {
-				if err := train.setProgress(1); err != nil {
-					return err
-				}
-			} else {
-				if err := train.setProgress(0); err != nil {
-					return err
-				}
-			}
-			distance -= remaining
-			if distance < boundaryEpsilon {
-				distance = 0
-			}
-
-			nextBlock, exists, err := s.line.NextBlock(train.BlockID(), train.Forward())
-			if err != nil {
-				return err
-			}
-			if !exists {
-				train.setPendingTurnback(true)
-				break
-			}
-
-			if occupiedBy, occupied := s.occupied[nextBlock.String()]; occupied && occupiedBy.String() != train.ID().String() {
-				break
-			}
-
-			delete(s.occupied, train.BlockID().String())
-			train.setBlockID(nextBlock)
-			s.occupied[nextBlock.String()] = train.ID()
-
-			if train.Forward() {
-				if err := train.setProgress(0); err != nil {
-					return err
-				}
-			} else {
-				if err := train.setProgress(1); err != nil {
-					return err
-				}
-			}
-		}
+	if err := s.moveTrains(dt, start, trace); err != nil {
+		return err
+	}
+	// 始発の時刻が来た列車は刻みの終わりに網へ入り、次の刻みから動く
+	if err := s.enterDueTrains(trace); err != nil {
+		return err
 	}
 
+	if trace != nil {
+		s.emitNewDeadlocks(&s.scratch.deadlocksBefore, trace)
+	}
 	return nil
 }
 
-func (s *SimulationState) sortedTrainKeys() []string {
-	keys := make([]string, 0, len(s.trains))
-	for key := range s.trains {
-		keys = append(keys, key)
+// movableDuration は start から dt 進めるあいだ、列車が抑止されていない時間
+func (t *tickTrace) movableDuration(trainKey string, dt TickDelta, start SimTime) time.Duration {
+	if t == nil {
+		return dt.Duration()
+	}
+	until, held := t.holdUntil[trainKey]
+	if !held || until.Millis() <= start.Millis() {
+		return dt.Duration()
+	}
+	heldFor := time.Duration(until.Millis()-start.Millis()) * time.Millisecond
+	return max(dt.Duration()-heldFor, 0)
+}
+
+func (t *tickTrace) emit(e Event) {
+	if t != nil && t.onEvent != nil {
+		t.onEvent(e)
+	}
+}
+
+// approaching は列車 mover が列車 target のいる側へ向かっているかを返す（折返し待ちは折返し後の向きで見る）
+func (s *SimulationState) approaching(mover, target TrainID) bool {
+	m, ok := s.train(mover)
+	if !ok {
+		return false
+	}
+	t, ok := s.train(target)
+	if !ok {
+		return false
+	}
+	forward := m.Forward() != m.PendingTurnback()
+	if forward {
+		return t.block > m.block
 	}
-	sort.Strings(keys)
-	return keys
+	return t.block < m.block
 }
 
 func (l *Line) HasBlock(id BlockID) bool {
@@ -1,6 +1,7 @@
 package simulation
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -116,6 +117,25 @@ func TestTickBlocksOccupiedNextBlock(t *testing.T) {
 	}
 }
 
+func TestStartAtOnlyBeforeAdvancing(t *testing.T) {
+	state := newTestState(t)
+	start, _ := NewSimTime((5*time.Hour + 30*time.Minute).Milliseconds())
+	if err := state.StartAt(start); err != nil {
+		t.Fatalf("start at failed: %v", err)
+	}
+	if state.SimTime() != start {
+		t.Fatalf("expected sim time %d, got %d", start.Millis(), state.SimTime().Millis())
+	}
+
+	delta, _ := NewTickDelta(time.Second)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	if err := state.StartAt(start); !errors.Is(err, ErrSimulationAlreadyStarted) {
+		t.Fatalf("expected %v, got %v", ErrSimulationAlreadyStarted, err)
+	}
+}
+
 func newTestState(t *testing.T) *SimulationState {
 	t.Helper()
 
@@ -130,7 +150,8 @@ func newTestState(t *testing.T) *SimulationState {
 		t.Fatalf("new line failed: %v", err)
 	}
 
-	state, err := NewSimulationState(line)
+	id, _ := NewSimulationID("SIM0")
+	state, err := NewSimulationState(id, line)
 	if err != nil {
 		t.Fatalf("new state failed: %v", err)
 	}
@@ -0,0 +1,94 @@
+package simulation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ServiceStop は時刻表の1つの駅での着時刻と発時刻。通過や始発・終着の駅では着と発を同じ時刻にする。
+type ServiceStop struct {
+	Station   StationID
+	Arrival   SimTime
+	Departure SimTime
+}
+
+// Service は時刻表の1本の列車（運行）。Train はこの運行を受け持つ列車（決まっていなければ空）。
+type Service struct {
+	ID    ServiceID
+	Train TrainID
+	Stops []ServiceStop
+}
+
+// Timetable は演習の計画ダイヤ。運行は与えた順に持つ。
+type Timetable struct {
+	services []Service
+}
+
+// NewTimetable は運行を検証して時刻表を作る。
+// 運行のIDは重複せず、停車駅は2つ以上で、時刻は駅の順に戻らない（着 ≦ 発 ≦ 次の駅の着）こと。
+func NewTimetable(services []Service) (*Timetable, error) {
+	t := &Timetable{services: make([]Service, 0, len(services))}
+	seen := make(map[string]struct{}, len(services))
+	for _, service := range services {
+		if service.ID.String() == "" {
+			return nil, ErrServiceIDEmpty
+		}
+		if _, dup := seen[service.ID.String()]; dup {
+			return nil, fmt.Errorf("%w: %s", ErrServiceDuplicateID, service.ID)
+		}
+		seen[service.ID.String()] = struct{}{}
+		if len(service.Stops) < 2 {
+			return nil, fmt.Errorf("%w: %s", ErrServiceTooFewStops, service.ID)
+		}
+		for i, stop := range service.Stops {
+			if stop.Station.String() == "" {
+				return nil, ErrStationIDEmpty
+			}
+			if stop.Departure.Millis() < stop.Arrival.Millis() || (i > 0 && stop.Arrival.Millis() < service.Stops[i-1].Departure.Millis()) {
+				return nil, fmt.Errorf("%w: %s at %s", ErrServiceTimesInvalid, service.ID, stop.Station)
+			}
+		}
+		t.services = append(t.services, Service{ID: service.ID, Train: service.Train, Stops: slices.Clone(service.Stops)})
+	}
+	return t, nil
+}
+
+// Services は運行を与えた順に返す
+func (t *Timetable) Services() []Service {
+	out := make([]Service, 0, len(t.services))
+	for _, service := range t.services {
+		out = append(out, Service{ID: service.ID, Train: service.Train, Stops: slices.Clone(service.Stops)})
+	}
+	return out
+}
+
+// FirstDeparture は列車 train が受け持つ運行のうち、最も早い始発の発時刻を返す。受け持つ運行が無ければ false。
+func (t *Timetable) FirstDeparture(train TrainID) (SimTime, bool) {
+	var first SimTime
+	found := false
+	for _, service := range t.services {
+		if service.Train != train {
+			continue
+		}
+		if departure := service.Stops[0].Departure; !found || departure.Millis() < first.Millis() {
+			first, found = departure, true
+		}
+	}
+	return first, found
+}
+
+// CheckStations は時刻表の停車駅がすべて網の駅かを確かめる
+func (t *Timetable) CheckStations(network *Network) error {
+	stations := make(map[string]struct{}, len(network.stations))
+	for _, station := range network.stations {
+		stations[station.String()] = struct{}{}
+	}
+	for _, service := range t.services {
+		for _, stop := range service.Stops {
+			if _, ok := stations[stop.Station.String()]; !ok {
+				return fmt.Errorf("%w: %s at %s", ErrServiceStationNotFound, service.ID, stop.Station)
+			}
+		}
+	}
+	return nil
+}
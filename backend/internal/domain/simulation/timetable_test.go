@@ -0,0 +1,84 @@
+package simulation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewTimetableRejectsInvalidServices(t *testing.T) {
+	valid := newService(t, "S1", "A0", 0, 60_000, "X", 120_000, 150_000, "A2", 240_000, 240_000)
+
+	cases := []struct {
+		name     string
+		services []Service
+		wantErr  error
+	}{
+		{name: "duplicate id", services: []Service{valid, valid}, wantErr: ErrServiceDuplicateID},
+		{name: "single stop", services: []Service{{ID: valid.ID, Stops: valid.Stops[:1]}}, wantErr: ErrServiceTooFewStops},
+		{name: "departs before arrival", services: []Service{newService(t, "S2", "A0", 60_000, 0, "X", 120_000, 120_000)}, wantErr: ErrServiceTimesInvalid},
+		{name: "arrives before previous departure", services: []Service{newService(t, "S2", "A0", 0, 60_000, "X", 30_000, 30_000)}, wantErr: ErrServiceTimesInvalid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewTimetable(tc.services); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTimetableCheckStations(t *testing.T) {
+	network := newTwoLineNetwork(t)
+	timetable, err := NewTimetable([]Service{
+		newService(t, "S1", "A0", 0, 0, "X", 60_000, 90_000, "B2", 150_000, 150_000),
+	})
+	if err != nil {
+		t.Fatalf("new timetable failed: %v", err)
+	}
+	if err := timetable.CheckStations(network); err != nil {
+		t.Fatalf("expected stations of both lines to be accepted, got %v", err)
+	}
+
+	unknown, err := NewTimetable([]Service{newService(t, "S2", "A0", 0, 0, "Z9", 60_000, 60_000)})
+	if err != nil {
+		t.Fatalf("new timetable failed: %v", err)
+	}
+	if err := unknown.CheckStations(network); !errors.Is(err, ErrServiceStationNotFound) {
+		t.Fatalf("expected %v, got %v", ErrServiceStationNotFound, err)
+	}
+}
+
+func TestLineStationDistances(t *testing.T) {
+	base := newLineFromIDs(t, []string{"S0", "S1", "S2"}, []string{"B0", "B1"})
+	if got, km := base.StationDistances(); km || !reflect.DeepEqual(got, []float64{0, 1, 2}) {
+		t.Fatalf("expected distances counted in stations, got %v (km=%v)", got, km)
+	}
+
+	line, err := NewLineWithInfo(base.Stations(), base.Blocks(), []StationInfo{{KilometerPost: 12, HasKilometerPost: true}, {KilometerPost: 10.5, HasKilometerPost: true}, {KilometerPost: 10, HasKilometerPost: true}}, nil)
+	if err != nil {
+		t.Fatalf("new line failed: %v", err)
+	}
+	if got, km := line.StationDistances(); !km || !reflect.DeepEqual(got, []float64{0, 1.5, 2}) {
+		t.Fatalf("expected distances by kilometer post, got %v (km=%v)", got, km)
+	}
+}
+
+// newService は 駅, 着, 発 の並びから運行を作る
+func newService(t *testing.T, id string, stops ...any) Service {
+	t.Helper()
+
+	serviceID, err := NewServiceID(id)
+	if err != nil {
+		t.Fatalf("new service id failed: %v", err)
+	}
+	service := Service{ID: serviceID}
+	for i := 0; i+2 < len(stops); i += 3 {
+		station, _ := NewStationID(stops[i].(string))
+		arrival, _ := NewSimTime(int64(stops[i+1].(int)))
+		departure, _ := NewSimTime(int64(stops[i+2].(int)))
+		service.Stops = append(service.Stops, ServiceStop{Station: station, Arrival: arrival, Departure: departure})
+	}
+	return service
+}
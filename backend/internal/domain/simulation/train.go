@@ -11,6 +11,9 @@ type Train struct {
 	forward         bool
 	speed           float64
 	pendingTurnback bool
+
+	// block は blockID の路線上の添字（AddTrain で路線に載せたときに決まる）
+	block int
 }
 
 func NewTrain(id TrainID, blockID BlockID, progress BlockProgress, forward bool, speed float64) (*Train, error) {
@@ -65,7 +68,8 @@ func (t *Train) setProgress(v float64) error {
 	return nil
 }
 
-func (t *Train) setBlockID(blockID BlockID) {
+func (t *Train) setBlock(index int, blockID BlockID) {
+	t.block = index
 	t.blockID = blockID
 }
 
@@ -76,3 +80,11 @@ func (t *Train) reverseDirection() {
 func (t *Train) setPendingTurnback(v bool) {
 	t.pendingTurnback = v
 }
+
+func (t *Train) setSpeed(v float64) error {
+	if v <= 0 {
+		return ErrTrainSpeedNotPositive
+	}
+	t.speed = v
+	return nil
+}
@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// schemaVersion は永続化ファイル形式のバージョン。互換性のない変更時に上げる。
+const schemaVersion = 1
+
+const aggregateFileExt = ".json"
+
+// aggregateLockExt は集約ファイルの排他に使うロックファイルの拡張子
+const aggregateLockExt = ".lock"
+
+// aggregateBaseName は集約IDを安全なファイル名（拡張子なし）に変換する（パス区切り等を含むIDへの対策）
+func aggregateBaseName(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func aggregateFileName(id string) string {
+	return aggregateBaseName(id) + aggregateFileExt
+}
+
+func lockFilePath(path string) string {
+	return strings.TrimSuffix(path, aggregateFileExt) + aggregateLockExt
+}
+
+func aggregateIDFromFileName(name string) (string, bool) {
+	if !strings.HasSuffix(name, aggregateFileExt) {
+		return "", false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(name, aggregateFileExt))
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// writeFileAtomic は一時ファイルに書き込んでから rename することで、
+// 途中でプロセスが落ちても壊れたファイルが残らないようにする。
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create dir failed: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file failed: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		// rename 済みなら既に存在しないので無視される
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp file failed: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("sync temp file failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file failed: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename temp file failed: %w", err)
+	}
+	return nil
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
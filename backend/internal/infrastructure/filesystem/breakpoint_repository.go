@@ -0,0 +1,138 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// FileBreakpointRepository はシミュレーションごとのブレークポイントの定義をJSONファイルとして保持するRepository実装。
+// ファイルは <dir>/<base64url(シミュレーションID)>.json に置き、保存・削除は SimulationRepository と同じく直列にする。
+type FileBreakpointRepository struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFileBreakpointRepository(dir string) domain.BreakpointRepository {
+	return &FileBreakpointRepository{dir: dir}
+}
+
+type breakpointsFileJSON struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	Breakpoints   []breakpointFileJSON `json:"breakpoints"`
+}
+
+type breakpointFileJSON struct {
+	ID        string                `json:"id"`
+	Condition stopConditionFileJSON `json:"condition"`
+	Enabled   bool                  `json:"enabled"`
+}
+
+type stopConditionFileJSON struct {
+	Type           string `json:"type"`
+	TrainID        string `json:"trainId,omitempty"`
+	StationID      string `json:"stationId,omitempty"`
+	DurationMillis int64  `json:"durationMillis,omitempty"`
+	EventType      string `json:"eventType,omitempty"`
+}
+
+func (r *FileBreakpointRepository) List(ctx context.Context, id domain.SimulationID) ([]domain.Breakpoint, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.path(id.String()))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("breakpoint file read failed: %w", err)
+	}
+	var raw breakpointsFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("breakpoint file parse failed: %w", err)
+	}
+	if raw.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("%w: breakpoint file version %d", ErrUnsupportedSchemaVersion, raw.SchemaVersion)
+	}
+	out := make([]domain.Breakpoint, 0, len(raw.Breakpoints))
+	for _, bp := range raw.Breakpoints {
+		out = append(out, domain.Breakpoint{
+			ID: bp.ID,
+			Condition: domain.StopConditionSpec{
+				Type:           bp.Condition.Type,
+				TrainID:        bp.Condition.TrainID,
+				StationID:      bp.Condition.StationID,
+				DurationMillis: bp.Condition.DurationMillis,
+				EventType:      bp.Condition.EventType,
+			},
+			Enabled: bp.Enabled,
+		})
+	}
+	return out, nil
+}
+
+func (r *FileBreakpointRepository) Save(ctx context.Context, id domain.SimulationID, breakpoints []domain.Breakpoint) error {
+	if len(breakpoints) == 0 {
+		return r.Delete(ctx, id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(id.String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	raw := breakpointsFileJSON{SchemaVersion: schemaVersion, Breakpoints: make([]breakpointFileJSON, 0, len(breakpoints))}
+	for _, bp := range breakpoints {
+		raw.Breakpoints = append(raw.Breakpoints, breakpointFileJSON{
+			ID: bp.ID,
+			Condition: stopConditionFileJSON{
+				Type:           bp.Condition.Type,
+				TrainID:        bp.Condition.TrainID,
+				StationID:      bp.Condition.StationID,
+				DurationMillis: bp.Condition.DurationMillis,
+				EventType:      bp.Condition.EventType,
+			},
+			Enabled: bp.Enabled,
+		})
+	}
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return fmt.Errorf("breakpoint encode failed: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (r *FileBreakpointRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(id.String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("breakpoint file remove failed: %w", err)
+	}
+	return nil
+}
+
+func (r *FileBreakpointRepository) path(id string) string {
+	return filepath.Join(r.dir, aggregateFileName(id))
+}
@@ -0,0 +1,7 @@
+package filesystem
+
+import "errors"
+
+var (
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+)
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package filesystem
+
+// lockAggregate は flock の無い環境ではプロセス内の排他（各リポジトリの mutex）だけに頼る。
+// 別プロセスとの同時書き込みは検出できないので、ディレクトリを共有しないこと。
+func lockAggregate(path string) (func(), error) {
+	_ = path
+	return func() {}, nil
+}
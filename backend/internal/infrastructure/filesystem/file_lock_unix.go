@@ -0,0 +1,37 @@
+//go:build unix
+
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockAggregate は集約ファイル path に対応する <集約>.lock を排他ロックし、解放する関数を返す。
+// 同じディレクトリを共有する別プロセスとのあいだでも、読み込み→版の比較→rename を直列にするために使う。
+// ロックファイルは削除しない（削除すると、待っている側が消えたファイルのロックを取ってしまう）。
+func lockAggregate(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create dir failed: %w", err)
+	}
+	file, err := os.OpenFile(lockFilePath(path), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file failed: %w", err)
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("lock file failed: %w", err)
+	}
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		_ = file.Close()
+	}, nil
+}
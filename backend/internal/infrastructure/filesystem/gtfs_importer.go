@@ -0,0 +1,585 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+	"github.com/right1121/railway-control-center-simulator/pkg/logger"
+)
+
+// gtfsFallbackSpeed は始発駅の発車から次の駅の着までの時間が無いときの列車の速さ（区間/秒）
+const gtfsFallbackSpeed = 1.0 / 120
+
+var errGTFSNoCalendar = errors.New("GTFS feed has neither calendar.txt nor calendar_dates.txt")
+
+// GTFSImporter は GTFS（静的）のフィード（zip かディレクトリ）を読み、運行日の時刻表と列車の初期配置を作る。
+// GTFS の停留所は対応表（stop_id → 駅ID）で網の駅に結びつけ、次のようにまとめる。
+//   - 運行: 運行日に走る trip。1つの路線の上で続けて駅に結びつく停車駅の最も長いまとまりを取り込む。
+//   - 時刻: 運行日の 0 時からの時刻（24 時を過ぎた時刻もそのまま）をシミュレーション時刻にする。時刻の無い停車駅は通過として飛ばす。
+//   - 列車: block_id（無ければ trip）ごとに1本。その日の最初の運行の始発駅に、次の停車駅の方向へ置く。
+//     列車はその始発の発時刻に網へ入る（始発駅の区間がふさがっていれば空くまで待つ）。
+//
+// 対応表に無い停留所と、網の路線から外れる trip は取り込みの結果に並べる。
+type GTFSImporter struct {
+	feedPath    string
+	mappingPath string
+}
+
+func NewGTFSImporter(feedPath, mappingPath string) *GTFSImporter {
+	return &GTFSImporter{feedPath: strings.TrimSpace(feedPath), mappingPath: strings.TrimSpace(mappingPath)}
+}
+
+// GTFSImport は GTFS の取り込みの結果
+type GTFSImport struct {
+	ServiceDate   time.Time
+	Timetable     *domain.Timetable
+	Trains        []*domain.Train
+	UnmappedStops []GTFSUnmappedStop
+	TripIssues    []GTFSTripIssue
+}
+
+// GTFSUnmappedStop は対応表に無い停留所。Trips はそこに停まる運行日の trip の数。
+type GTFSUnmappedStop struct {
+	StopID string
+	Name   string
+	Trips  int
+}
+
+// GTFSTripIssue はそのままでは取り込めなかった trip。Imported なら一部（または列車の無い運行）として取り込んだ。
+type GTFSTripIssue struct {
+	TripID   string
+	Reason   string
+	Imported bool
+}
+
+// gtfsStopMappingJSON は停留所の対応表。親の駅（parent_station）を書けば、その中の乗り場もまとめて結びつく。
+type gtfsStopMappingJSON struct {
+	Stops []struct {
+		StopID    string `json:"stopId"`
+		StationID string `json:"stationId"`
+	} `json:"stops"`
+}
+
+type gtfsStop struct {
+	name   string
+	parent string
+}
+
+type gtfsTrip struct {
+	id        string
+	shortName string
+	block     string
+}
+
+type gtfsStopTime struct {
+	sequence  int
+	stopID    string
+	arrival   string
+	departure string
+}
+
+// gtfsService は取り込んだ運行と、列車を置くための路線の上の位置
+type gtfsService struct {
+	trip    gtfsTrip
+	service domain.Service
+	line    *domain.Line
+	// first / second は始発駅と次の停車駅の路線の中の駅の添字
+	first, second int
+}
+
+func (i *GTFSImporter) Import(ctx context.Context, network *domain.Network, serviceDate time.Time) (*GTFSImport, error) {
+	_ = ctx
+
+	mapping, err := readGTFSStopMapping(i.mappingPath)
+	if err != nil {
+		return nil, err
+	}
+	feed, closeFeed, err := openGTFSFeed(i.feedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFeed()
+
+	stops := make(map[string]gtfsStop)
+	if err := eachGTFSRow(feed, "stops.txt", func(row gtfsRow) error {
+		stops[row.get("stop_id")] = gtfsStop{name: row.get("stop_name"), parent: row.get("parent_station")}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	active, err := gtfsActiveServices(feed, serviceDate)
+	if err != nil {
+		return nil, err
+	}
+	trips := make(map[string]gtfsTrip)
+	if err := eachGTFSRow(feed, "trips.txt", func(row gtfsRow) error {
+		if _, ok := active[row.get("service_id")]; ok {
+			id := row.get("trip_id")
+			trips[id] = gtfsTrip{id: id, shortName: row.get("trip_short_name"), block: row.get("block_id")}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	stopTimes := make(map[string][]gtfsStopTime)
+	if err := eachGTFSRow(feed, "stop_times.txt", func(row gtfsRow) error {
+		tripID := row.get("trip_id")
+		if _, ok := trips[tripID]; !ok {
+			return nil
+		}
+		sequence, err := strconv.Atoi(row.get("stop_sequence"))
+		if err != nil {
+			return fmt.Errorf("trip %s: stop_sequence: %w", tripID, err)
+		}
+		stopTimes[tripID] = append(stopTimes[tripID], gtfsStopTime{
+			sequence:  sequence,
+			stopID:    row.get("stop_id"),
+			arrival:   row.get("arrival_time"),
+			departure: row.get("departure_time"),
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	resolver := newGTFSStationResolver(network, mapping, stops)
+	result := &GTFSImport{ServiceDate: serviceDate}
+	tripIDs := make([]string, 0, len(trips))
+	for id := range trips {
+		tripIDs = append(tripIDs, id)
+	}
+	sort.Strings(tripIDs)
+
+	var services []gtfsService
+	for _, id := range tripIDs {
+		service, issue, err := resolver.service(trips[id], stopTimes[id])
+		if err != nil {
+			return nil, err
+		}
+		if issue != "" {
+			result.TripIssues = append(result.TripIssues, GTFSTripIssue{TripID: id, Reason: issue, Imported: service != nil})
+		}
+		if service != nil {
+			services = append(services, *service)
+		}
+	}
+	result.UnmappedStops = resolver.unmappedStops()
+
+	sort.SliceStable(services, func(a, b int) bool {
+		return services[a].service.Stops[0].Departure.Millis() < services[b].service.Stops[0].Departure.Millis()
+	})
+	result.Trains, err = placeGTFSTrains(services)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := make([]domain.Service, 0, len(services))
+	for _, s := range services {
+		planned = append(planned, s.service)
+	}
+	result.Timetable, err = domain.NewTimetable(planned)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readGTFSStopMapping(path string) (map[string]domain.StationID, error) {
+	data, _, err := readFixture(path)
+	if err != nil {
+		return nil, fmt.Errorf("GTFS stop mapping read failed: %w", err)
+	}
+	var raw gtfsStopMappingJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("GTFS stop mapping parse failed: %w", err)
+	}
+	mapping := make(map[string]domain.StationID, len(raw.Stops))
+	for _, stop := range raw.Stops {
+		station, err := domain.NewStationID(stop.StationID)
+		if err != nil {
+			return nil, fmt.Errorf("GTFS stop mapping %s: %w", stop.StopID, err)
+		}
+		mapping[strings.TrimSpace(stop.StopID)] = station
+	}
+	return mapping, nil
+}
+
+// openGTFSFeed はフィードのディレクトリか zip を開く。返した関数で閉じる。
+func openGTFSFeed(path string) (fs.FS, func() error, error) {
+	info, err := os.Stat(path)
+	if err != nil && strings.HasPrefix(path, "backend/") {
+		path = strings.TrimPrefix(path, "backend/")
+		info, err = os.Stat(path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("GTFS feed open failed: %w", err)
+	}
+	if info.IsDir() {
+		return os.DirFS(path), func() error { return nil }, nil
+	}
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GTFS feed open failed: %w", err)
+	}
+	return archive, archive.Close, nil
+}
+
+// gtfsRow は見出しの列名で値を引ける CSV の1行
+type gtfsRow struct {
+	columns map[string]int
+	record  []string
+}
+
+func (r gtfsRow) get(column string) string {
+	index, ok := r.columns[column]
+	if !ok || index >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[index])
+}
+
+// eachGTFSRow はフィードの表を1行ずつ fn に渡す。表が無ければ fs.ErrNotExist を包んだエラーを返す。
+func eachGTFSRow(feed fs.FS, name string, fn func(gtfsRow) error) error {
+	file, err := feed.Open(name)
+	if err != nil {
+		return fmt.Errorf("GTFS %s: %w", name, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("GTFS %s: %w", name, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		columns[strings.TrimSpace(column)] = i
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("GTFS %s: %w", name, err)
+		}
+		if err := fn(gtfsRow{columns: columns, record: record}); err != nil {
+			return fmt.Errorf("GTFS %s: %w", name, err)
+		}
+	}
+}
+
+// gtfsActiveServices は運行日に走る service_id。calendar.txt の曜日と期間に calendar_dates.txt の追加（1）と運休（2）を重ねる。
+func gtfsActiveServices(feed fs.FS, serviceDate time.Time) (map[string]struct{}, error) {
+	date := serviceDate.Format("20060102")
+	weekday := strings.ToLower(serviceDate.Weekday().String())
+	active := make(map[string]struct{})
+
+	err := eachGTFSRow(feed, "calendar.txt", func(row gtfsRow) error {
+		if row.get("start_date") <= date && date <= row.get("end_date") && row.get(weekday) == "1" {
+			active[row.get("service_id")] = struct{}{}
+		}
+		return nil
+	})
+	hasCalendar := !errors.Is(err, fs.ErrNotExist)
+	if err != nil && hasCalendar {
+		return nil, err
+	}
+	err = eachGTFSRow(feed, "calendar_dates.txt", func(row gtfsRow) error {
+		if row.get("date") != date {
+			return nil
+		}
+		switch row.get("exception_type") {
+		case "1":
+			active[row.get("service_id")] = struct{}{}
+		case "2":
+			delete(active, row.get("service_id"))
+		}
+		return nil
+	})
+	hasDates := !errors.Is(err, fs.ErrNotExist)
+	if err != nil && hasDates {
+		return nil, err
+	}
+	if !hasCalendar && !hasDates {
+		return nil, errGTFSNoCalendar
+	}
+	return active, nil
+}
+
+// parseGTFSTime は HH:MM:SS（時は 24 を超えてもよい）を運行日の 0 時からの時刻にする。空なら false。
+func parseGTFSTime(value string) (domain.SimTime, bool, error) {
+	if value == "" {
+		return domain.SimTime{}, false, nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return domain.SimTime{}, false, fmt.Errorf("invalid GTFS time %q", value)
+	}
+	var seconds int64
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return domain.SimTime{}, false, fmt.Errorf("invalid GTFS time %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	t, err := domain.NewSimTime((time.Duration(seconds) * time.Second).Milliseconds())
+	return t, true, err
+}
+
+// gtfsStationResolver は停留所を網の駅と路線に結びつけ、結びつかなかった停留所を数える
+type gtfsStationResolver struct {
+	lines    []*domain.Line
+	indexes  []map[string]int
+	mapping  map[string]domain.StationID
+	stops    map[string]gtfsStop
+	unmapped map[string]map[string]struct{}
+}
+
+func newGTFSStationResolver(network *domain.Network, mapping map[string]domain.StationID, stops map[string]gtfsStop) *gtfsStationResolver {
+	r := &gtfsStationResolver{mapping: mapping, stops: stops, unmapped: make(map[string]map[string]struct{})}
+	for _, l := range network.Lines() {
+		index := make(map[string]int, len(l.Line.Stations()))
+		for i, station := range l.Line.Stations() {
+			index[station.String()] = i
+		}
+		r.lines = append(r.lines, l.Line)
+		r.indexes = append(r.indexes, index)
+	}
+	return r
+}
+
+// station は停留所（無ければ親の駅）の対応する駅IDを返す
+func (r *gtfsStationResolver) station(stopID string) (domain.StationID, bool) {
+	if station, ok := r.mapping[stopID]; ok {
+		return station, true
+	}
+	if parent := r.stops[stopID].parent; parent != "" {
+		station, ok := r.mapping[parent]
+		return station, ok
+	}
+	return domain.StationID{}, false
+}
+
+// gtfsCall は trip の停車駅（駅に結びつかなければ ok が false）
+type gtfsCall struct {
+	station   domain.StationID
+	ok        bool
+	arrival   domain.SimTime
+	departure domain.SimTime
+}
+
+// service は trip を運行にする。取り込めなければ nil と理由を、一部だけ取り込めば運行と理由を返す。
+func (r *gtfsStationResolver) service(trip gtfsTrip, stopTimes []gtfsStopTime) (*gtfsService, string, error) {
+	slices.SortFunc(stopTimes, func(a, b gtfsStopTime) int { return a.sequence - b.sequence })
+
+	calls := make([]gtfsCall, 0, len(stopTimes))
+	for _, stopTime := range stopTimes {
+		station, ok := r.station(stopTime.stopID)
+		if !ok {
+			if r.unmapped[stopTime.stopID] == nil {
+				r.unmapped[stopTime.stopID] = make(map[string]struct{})
+			}
+			r.unmapped[stopTime.stopID][trip.id] = struct{}{}
+		}
+		arrival, hasArrival, err := parseGTFSTime(stopTime.arrival)
+		if err != nil {
+			return nil, "", fmt.Errorf("trip %s: %w", trip.id, err)
+		}
+		departure, hasDeparture, err := parseGTFSTime(stopTime.departure)
+		if err != nil {
+			return nil, "", fmt.Errorf("trip %s: %w", trip.id, err)
+		}
+		switch {
+		case !hasArrival && !hasDeparture:
+			continue
+		case !hasArrival:
+			arrival = departure
+		case !hasDeparture:
+			departure = arrival
+		}
+		// 同じ駅の別の乗り場に続けて停まるものは1つの停車にまとめる
+		if last := len(calls) - 1; ok && last >= 0 && calls[last].ok && calls[last].station == station {
+			calls[last].departure = departure
+			continue
+		}
+		calls = append(calls, gtfsCall{station: station, ok: ok, arrival: arrival, departure: departure})
+	}
+
+	from, until, line := r.longestRun(calls)
+	if until-from < 2 {
+		return nil, "no two consecutive stops are on one modeled line", nil
+	}
+	stops := make([]domain.ServiceStop, 0, until-from)
+	for _, call := range calls[from:until] {
+		stops = append(stops, domain.ServiceStop{Station: call.station, Arrival: call.arrival, Departure: call.departure})
+	}
+	for i, stop := range stops {
+		if stop.Departure.Millis() < stop.Arrival.Millis() || (i > 0 && stop.Arrival.Millis() < stops[i-1].Departure.Millis()) {
+			return nil, "stop times go backwards", nil
+		}
+	}
+
+	id, err := domain.NewServiceID(trip.id)
+	if err != nil {
+		return nil, "", err
+	}
+	service := &gtfsService{
+		trip:    trip,
+		service: domain.Service{ID: id, Stops: stops},
+		line:    r.lines[line],
+		first:   r.indexes[line][stops[0].Station.String()],
+		second:  r.indexes[line][stops[1].Station.String()],
+	}
+	if until-from < len(calls) {
+		return service, fmt.Sprintf("leaves the modeled line; only %s to %s is imported", stops[0].Station, stops[len(stops)-1].Station), nil
+	}
+	return service, "", nil
+}
+
+// longestRun は1つの路線の上で続けて駅に結びつく停車駅の最も長いまとまり [from, until) とその路線の添字
+func (r *gtfsStationResolver) longestRun(calls []gtfsCall) (int, int, int) {
+	bestFrom, bestUntil, bestLine := 0, 0, 0
+	for from := range calls {
+		for line, index := range r.indexes {
+			until := from
+			for until < len(calls) && calls[until].ok {
+				if _, ok := index[calls[until].station.String()]; !ok {
+					break
+				}
+				until++
+			}
+			if until-from > bestUntil-bestFrom {
+				bestFrom, bestUntil, bestLine = from, until, line
+			}
+		}
+	}
+	return bestFrom, bestUntil, bestLine
+}
+
+func (r *gtfsStationResolver) unmappedStops() []GTFSUnmappedStop {
+	out := make([]GTFSUnmappedStop, 0, len(r.unmapped))
+	for stopID, trips := range r.unmapped {
+		out = append(out, GTFSUnmappedStop{StopID: stopID, Name: r.stops[stopID].name, Trips: len(trips)})
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].StopID < out[b].StopID })
+	return out
+}
+
+// placeGTFSTrains は車両（block_id、無ければ trip）ごとに列車を1本、その日の最初の運行の始発駅に置き、運行に列車を割り当てる。
+// 列車IDは block_id、無ければ列車番号（trip_short_name）、それも無いか重なれば trip_id にする。
+// services は発車の早い順に並べておく。始発駅の区間が同じ列車もそのまま作る（網へ入るのは始発の発時刻で、区間が空くまで待つ）。
+func placeGTFSTrains(services []gtfsService) ([]*domain.Train, error) {
+	vehicles := make(map[string][]int)
+	var order []string
+	for i, s := range services {
+		key := s.trip.block
+		if key == "" {
+			key = s.trip.id
+		}
+		if _, ok := vehicles[key]; !ok {
+			order = append(order, key)
+		}
+		vehicles[key] = append(vehicles[key], i)
+	}
+
+	var trains []*domain.Train
+	named := make(map[string]struct{})
+	for _, key := range order {
+		first := services[vehicles[key][0]]
+		name := first.trip.block
+		if name == "" {
+			name = first.trip.shortName
+		}
+		if _, dup := named[name]; dup || name == "" {
+			name = first.trip.id
+		}
+		trainID, err := domain.NewTrainID(name)
+		if err != nil {
+			return nil, err
+		}
+
+		forward := first.second > first.first
+		index, at := first.first, 0.0
+		if !forward {
+			index, at = first.first-1, 1.0
+		}
+		block, _ := first.line.BlockAt(index)
+
+		progress, err := domain.NewBlockProgress(at)
+		if err != nil {
+			return nil, err
+		}
+		speed := gtfsFallbackSpeed
+		blocks := first.second - first.first
+		if blocks < 0 {
+			blocks = -blocks
+		}
+		if leg := first.service.Stops[1].Arrival.Millis() - first.service.Stops[0].Departure.Millis(); leg > 0 {
+			speed = float64(blocks) / (time.Duration(leg) * time.Millisecond).Seconds()
+		}
+		train, err := domain.NewTrain(trainID, block, progress, forward, speed)
+		if err != nil {
+			return nil, err
+		}
+		trains = append(trains, train)
+		named[name] = struct{}{}
+		for _, i := range vehicles[key] {
+			services[i].service.Train = trainID
+		}
+	}
+	return trains, nil
+}
+
+// GTFSTimetableLoader は設定の運行日で GTFS のフィードを取り込み、取り込めなかった停留所や trip をログに残す
+type GTFSTimetableLoader struct {
+	importer    *GTFSImporter
+	serviceDate string
+}
+
+// NewGTFSTimetableLoader は運行日を YYYY-MM-DD で受け取る
+func NewGTFSTimetableLoader(feedPath, mappingPath, serviceDate string) *GTFSTimetableLoader {
+	return &GTFSTimetableLoader{importer: NewGTFSImporter(feedPath, mappingPath), serviceDate: strings.TrimSpace(serviceDate)}
+}
+
+func (l *GTFSTimetableLoader) Load(ctx context.Context, network *domain.Network) (*domain.Timetable, []*domain.Train, error) {
+	serviceDate, err := time.Parse(time.DateOnly, l.serviceDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GTFS service date: %w", err)
+	}
+	imported, err := l.importer.Import(ctx, network, serviceDate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log := logger.GetDefault()
+	for _, stop := range imported.UnmappedStops {
+		log.Warn("GTFS stop is not mapped to a station", "stopId", stop.StopID, "name", stop.Name, "trips", stop.Trips)
+	}
+	for _, issue := range imported.TripIssues {
+		log.Warn("GTFS trip is not fully imported", "tripId", issue.TripID, "reason", issue.Reason, "imported", issue.Imported)
+	}
+	log.Info("GTFS timetable imported", "serviceDate", l.serviceDate, "services", len(imported.Timetable.Services()), "trains", len(imported.Trains))
+	return imported.Timetable, imported.Trains, nil
+}
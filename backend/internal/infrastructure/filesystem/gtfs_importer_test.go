@@ -0,0 +1,225 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+func TestGTFSImporterBuildsTimetableForServiceDate(t *testing.T) {
+	imported, err := NewGTFSImporter(filepath.Join("testdata", "gtfs"), filepath.Join("testdata", "gtfs_stop_mapping.json")).
+		Import(context.Background(), gtfsTestNetwork(t), gtfsTestServiceDate)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	// 運休（WD2）と休日（HD）の trip は入らず、臨時（EX）の trip は入る。発車の早い順に並ぶ。
+	type plannedService struct {
+		ID, Train string
+		Stations  []string
+		Times     []string
+	}
+	var got []plannedService
+	for _, service := range imported.Timetable.Services() {
+		planned := plannedService{ID: service.ID.String(), Train: service.Train.String()}
+		for _, stop := range service.Stops {
+			planned.Stations = append(planned.Stations, stop.Station.String())
+			arrival := time.Duration(stop.Arrival.Millis()) * time.Millisecond
+			departure := time.Duration(stop.Departure.Millis()) * time.Millisecond
+			planned.Times = append(planned.Times, arrival.String()+"/"+departure.String())
+		}
+		got = append(got, planned)
+	}
+	want := []plannedService{
+		{ID: "t3", Train: "301M", Stations: []string{"S2", "S1", "S0"}, Times: []string{"5h50m0s/5h50m0s", "5h55m0s/5h55m0s", "6h1m0s/6h1m0s"}},
+		// 時刻の無い S2 は通過として飛ばし、乗り場（STA_A_1）は親の駅で結びつく
+		{ID: "t1", Train: "blk1", Stations: []string{"S0", "S1", "S3"}, Times: []string{"6h0m0s/6h0m0s", "6h4m0s/6h5m0s", "6h12m0s/6h12m0s"}},
+		{ID: "t2", Train: "blk1", Stations: []string{"S3", "S2", "S1", "S0"}, Times: []string{"6h20m0s/6h20m0s", "6h25m0s/6h26m0s", "6h30m0s/6h30m0s", "6h35m0s/6h35m0s"}},
+		// 始発の区間が blk1 と同じでも列車を割り当てる（網へ入るのは始発の時刻）
+		{ID: "t4", Train: "401M", Stations: []string{"S0", "S1", "S2"}, Times: []string{"7h10m0s/7h10m0s", "7h15m0s/7h15m0s", "7h20m0s/7h21m0s"}},
+		{ID: "t7", Train: "701M", Stations: []string{"X1", "S2"}, Times: []string{"24h50m0s/24h50m0s", "25h0m0s/25h0m0s"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected services:\n%+v", got)
+	}
+
+	type placedTrain struct {
+		ID, Block string
+		Progress  float64
+		Forward   bool
+		Speed     float64
+	}
+	var trains []placedTrain
+	for _, train := range imported.Trains {
+		trains = append(trains, placedTrain{train.ID().String(), train.BlockID().String(), train.Progress().Float64(), train.Forward(), train.Speed()})
+	}
+	wantTrains := []placedTrain{
+		{ID: "301M", Block: "B1", Progress: 1, Forward: false, Speed: 1.0 / 300},
+		{ID: "blk1", Block: "B0", Progress: 0, Forward: true, Speed: 1.0 / 240},
+		{ID: "401M", Block: "B0", Progress: 0, Forward: true, Speed: 1.0 / 300},
+		{ID: "701M", Block: "C0", Progress: 1, Forward: false, Speed: 1.0 / 600},
+	}
+	if !reflect.DeepEqual(trains, wantTrains) {
+		t.Fatalf("unexpected trains:\n%+v", trains)
+	}
+
+	if want := []GTFSUnmappedStop{{StopID: "STA_Z", Name: "Zulu", Trips: 2}}; !reflect.DeepEqual(imported.UnmappedStops, want) {
+		t.Fatalf("unexpected unmapped stops: %+v", imported.UnmappedStops)
+	}
+	wantIssues := []GTFSTripIssue{
+		{TripID: "t4", Reason: "leaves the modeled line; only S0 to S2 is imported", Imported: true},
+		{TripID: "t5", Reason: "no two consecutive stops are on one modeled line"},
+	}
+	if !reflect.DeepEqual(imported.TripIssues, wantIssues) {
+		t.Fatalf("unexpected trip issues:\n%+v", imported.TripIssues)
+	}
+
+	// 列車をそれぞれ始発の時刻に網へ入れるシミュレーションを作れる
+	id, _ := domain.NewSimulationID("gtfs")
+	state, err := domain.NewNetworkSimulationState(id, gtfsTestNetwork(t))
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+	if err := state.StartAt(imported.Timetable.Services()[0].Stops[0].Departure); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	for _, train := range imported.Trains {
+		departure, ok := imported.Timetable.FirstDeparture(train.ID())
+		if !ok {
+			t.Fatalf("train %s has no service", train.ID())
+		}
+		if err := state.ScheduleTrain(train, departure); err != nil {
+			t.Fatalf("schedule train %s failed: %v", train.ID(), err)
+		}
+	}
+	// 最も早い始発の 301M だけがすぐに入り、残りは始発の時刻を待つ
+	var waiting []string
+	for _, entry := range state.ScheduledTrains() {
+		waiting = append(waiting, entry.Train.ID().String())
+	}
+	if len(state.Trains()) != 1 || state.Trains()[0].ID().String() != "301M" || !reflect.DeepEqual(waiting, []string{"blk1", "401M", "701M"}) {
+		t.Fatalf("unexpected placement: trains=%v waiting=%v", state.Trains(), waiting)
+	}
+}
+
+func TestGTFSImporterReadsZipFeed(t *testing.T) {
+	mapping := filepath.Join("testdata", "gtfs_stop_mapping.json")
+	fromDir, err := NewGTFSImporter(filepath.Join("testdata", "gtfs"), mapping).Import(context.Background(), gtfsTestNetwork(t), gtfsTestServiceDate)
+	if err != nil {
+		t.Fatalf("import directory failed: %v", err)
+	}
+	fromZip, err := NewGTFSImporter(zipGTFSFeed(t, filepath.Join("testdata", "gtfs")), mapping).Import(context.Background(), gtfsTestNetwork(t), gtfsTestServiceDate)
+	if err != nil {
+		t.Fatalf("import zip failed: %v", err)
+	}
+	if !reflect.DeepEqual(fromZip.Timetable.Services(), fromDir.Timetable.Services()) || !reflect.DeepEqual(fromZip.TripIssues, fromDir.TripIssues) {
+		t.Fatalf("zip feed differs from directory feed")
+	}
+}
+
+func TestGTFSImporterRequiresCalendar(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"stops.txt", "trips.txt", "stop_times.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "gtfs", name))
+		if err != nil {
+			t.Fatalf("read %s failed: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("write %s failed: %v", name, err)
+		}
+	}
+	_, err := NewGTFSImporter(dir, filepath.Join("testdata", "gtfs_stop_mapping.json")).Import(context.Background(), gtfsTestNetwork(t), gtfsTestServiceDate)
+	if !errors.Is(err, errGTFSNoCalendar) {
+		t.Fatalf("expected %v, got %v", errGTFSNoCalendar, err)
+	}
+}
+
+func TestParseGTFSTime(t *testing.T) {
+	got, ok, err := parseGTFSTime("25:10:30")
+	if err != nil || !ok || got.Millis() != (25*time.Hour+10*time.Minute+30*time.Second).Milliseconds() {
+		t.Fatalf("unexpected time: %d %v %v", got.Millis(), ok, err)
+	}
+	if _, ok, err := parseGTFSTime(""); ok || err != nil {
+		t.Fatalf("expected empty time to be absent, got %v %v", ok, err)
+	}
+	if _, _, err := parseGTFSTime("6:75:00"); err == nil {
+		t.Fatalf("expected error for invalid minutes")
+	}
+}
+
+// gtfsTestServiceDate は月曜日（臨時の EX が走り、WD2 が運休する日）
+var gtfsTestServiceDate = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// gtfsTestNetwork は S0-S1-S2-S3 の RED と、S2 で分かれる S2-X1 の BLUE からなる網
+func gtfsTestNetwork(t *testing.T) *domain.Network {
+	t.Helper()
+
+	line := func(stations []string, blocks []string) *domain.Line {
+		stationIDs := make([]domain.StationID, 0, len(stations))
+		for _, raw := range stations {
+			id, _ := domain.NewStationID(raw)
+			stationIDs = append(stationIDs, id)
+		}
+		blockIDs := make([]domain.BlockID, 0, len(blocks))
+		for _, raw := range blocks {
+			id, _ := domain.NewBlockID(raw)
+			blockIDs = append(blockIDs, id)
+		}
+		l, err := domain.NewLine(stationIDs, blockIDs)
+		if err != nil {
+			t.Fatalf("new line failed: %v", err)
+		}
+		return l
+	}
+	red, _ := domain.NewLineID("RED")
+	blue, _ := domain.NewLineID("BLUE")
+	network, err := domain.NewNetwork([]domain.NetworkLine{
+		{ID: red, Line: line([]string{"S0", "S1", "S2", "S3"}, []string{"B0", "B1", "B2"})},
+		{ID: blue, Line: line([]string{"S2", "X1"}, []string{"C0"})},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new network failed: %v", err)
+	}
+	return network
+}
+
+// zipGTFSFeed はディレクトリのフィードを zip にまとめる
+func zipGTFSFeed(t *testing.T, dir string) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read feed failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "feed.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip failed: %v", err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("read %s failed: %v", entry.Name(), err)
+		}
+		w, err := archive.Create(entry.Name())
+		if err != nil {
+			t.Fatalf("zip %s failed: %v", entry.Name(), err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("zip %s failed: %v", entry.Name(), err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("close zip failed: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,229 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// FileInputLogRepository は入力ログを JSON Lines 形式で追記するRepository実装。
+// ファイルは <dir>/<base64url(シミュレーションID)>.jsonl に置き、既存行は書き換えない
+// （保存できなかった入力を TruncateAfter で取り消すときだけ末尾を切り詰める）。
+type FileInputLogRepository struct {
+	mu   sync.Mutex
+	dir  string
+	seqs map[string]int64 // 最後に採番した連番のキャッシュ
+}
+
+func NewFileInputLogRepository(dir string) domain.InputLogRepository {
+	return &FileInputLogRepository{
+		dir:  dir,
+		seqs: make(map[string]int64),
+	}
+}
+
+type inputRecordJSON struct {
+	SchemaVersion int        `json:"schemaVersion"`
+	Seq           int64      `json:"seq"`
+	Kind          string     `json:"kind"`
+	RecordedAt    time.Time  `json:"recordedAt"`
+	SimTimeMillis int64      `json:"simTimeMillis"`
+	DeltaMillis   int64      `json:"deltaMillis,omitempty"`
+	StepMillis    int64      `json:"stepMillis,omitempty"`
+	Engine        string     `json:"engine,omitempty"`
+	State         *stateJSON `json:"state,omitempty"`
+	Version       int64      `json:"version"`
+}
+
+func (r *FileInputLogRepository) Append(ctx context.Context, id domain.SimulationID, record domain.InputRecord) (domain.InputRecord, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	path := r.path(key)
+	last, ok := r.seqs[key]
+	if !ok {
+		if err := truncateTornTail(path); err != nil {
+			return domain.InputRecord{}, err
+		}
+		records, err := r.read(path)
+		if err != nil {
+			return domain.InputRecord{}, err
+		}
+		last = int64(len(records))
+	}
+	record.Seq = last + 1
+
+	raw := inputRecordJSON{
+		SchemaVersion: schemaVersion,
+		Seq:           record.Seq,
+		Kind:          string(record.Kind),
+		RecordedAt:    record.RecordedAt,
+		SimTimeMillis: record.SimTimeMillis,
+		DeltaMillis:   record.DeltaMillis,
+		StepMillis:    record.StepMillis,
+		Engine:        string(record.Engine),
+		Version:       record.Version,
+	}
+	if record.State != nil {
+		state := newStateJSON(*record.State)
+		raw.State = &state
+	}
+	line, err := json.Marshal(raw)
+	if err != nil {
+		return domain.InputRecord{}, fmt.Errorf("input record encode failed: %w", err)
+	}
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return domain.InputRecord{}, fmt.Errorf("create dir failed: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return domain.InputRecord{}, fmt.Errorf("input log open failed: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return domain.InputRecord{}, fmt.Errorf("input log write failed: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return domain.InputRecord{}, fmt.Errorf("input log close failed: %w", err)
+	}
+
+	r.seqs[key] = record.Seq
+	return record, nil
+}
+
+func (r *FileInputLogRepository) List(ctx context.Context, id domain.SimulationID) ([]domain.InputRecord, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.read(r.path(id.String()))
+}
+
+func (r *FileInputLogRepository) TruncateAfter(ctx context.Context, id domain.SimulationID, seq int64) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	path := r.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("input log read failed: %w", err)
+	}
+	// 先頭から seq 行（完全な行だけ）を残す
+	size, kept := 0, int64(0)
+	for kept < seq {
+		i := bytes.IndexByte(data[size:], '\n')
+		if i < 0 {
+			break
+		}
+		size += i + 1
+		kept++
+	}
+	if err := os.Truncate(path, int64(size)); err != nil {
+		return fmt.Errorf("input log truncate failed: %w", err)
+	}
+	r.seqs[key] = kept
+	return nil
+}
+
+func (r *FileInputLogRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	delete(r.seqs, key)
+	if err := os.Remove(r.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("input log remove failed: %w", err)
+	}
+	return nil
+}
+
+func (r *FileInputLogRepository) path(id string) string {
+	return filepath.Join(r.dir, aggregateBaseName(id)+".jsonl")
+}
+
+// read はログを読み込む。書き込み途中で落ちた末尾の不完全な行（改行なし）は無視する。
+func (r *FileInputLogRepository) read(path string) ([]domain.InputRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []domain.InputRecord{}, nil
+		}
+		return nil, fmt.Errorf("input log read failed: %w", err)
+	}
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[:i+1]
+	} else {
+		data = nil
+	}
+
+	records := make([]domain.InputRecord, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var raw inputRecordJSON
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			return nil, fmt.Errorf("input log parse failed at line %d: %w", len(records)+1, err)
+		}
+		if raw.SchemaVersion != schemaVersion {
+			return nil, fmt.Errorf("%w: input log version %d", ErrUnsupportedSchemaVersion, raw.SchemaVersion)
+		}
+		record := domain.InputRecord{
+			Seq:           raw.Seq,
+			Kind:          domain.InputKind(raw.Kind),
+			RecordedAt:    raw.RecordedAt,
+			SimTimeMillis: raw.SimTimeMillis,
+			DeltaMillis:   raw.DeltaMillis,
+			StepMillis:    raw.StepMillis,
+			Engine:        domain.Engine(raw.Engine),
+			Version:       raw.Version,
+		}
+		if raw.State != nil {
+			snap := raw.State.toSnapshot()
+			record.State = &snap
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("input log read failed: %w", err)
+	}
+	return records, nil
+}
+
+// truncateTornTail は末尾の不完全な行を切り詰め、次の追記が壊れた行に連結されないようにする
+func truncateTornTail(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("input log read failed: %w", err)
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	if err := os.Truncate(path, int64(bytes.LastIndexByte(data, '\n')+1)); err != nil {
+		return fmt.Errorf("input log truncate failed: %w", err)
+	}
+	return nil
+}
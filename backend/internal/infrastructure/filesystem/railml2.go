@@ -0,0 +1,290 @@
+package filesystem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// railML 2.x の設備のうち、路線の形式に変換するもの。位置（pos / absPos）はメートル。
+type railML2Document struct {
+	Infrastructure struct {
+		Tracks []railML2Track `xml:"tracks>track"`
+		OCPs   []railML2OCP   `xml:"operationControlPoints>ocp"`
+	} `xml:"infrastructure"`
+}
+
+type railML2Track struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"name,attr"`
+	Topology struct {
+		Begin         railML2Position       `xml:"trackBegin"`
+		Switches      []railML2Switch       `xml:"connections>switch"`
+		Crossings     []railMLElement       `xml:"connections>crossing"`
+		CrossSections []railML2CrossSection `xml:"crossSections>crossSection"`
+	} `xml:"trackTopology"`
+	Elements struct {
+		Gradients        []railML2Gradient        `xml:"gradientChanges>gradientChange"`
+		Electrifications []railML2Electrification `xml:"electrificationChanges>electrificationChange"`
+		PlatformEdges    []railML2PlatformEdge    `xml:"platformEdges>platformEdge"`
+		Other            []railMLElement          `xml:",any"`
+	} `xml:"trackElements"`
+	OCS struct {
+		Signals []railML2Signal `xml:"signals>signal"`
+		Other   []railMLElement `xml:",any"`
+	} `xml:"ocsElements"`
+}
+
+type railML2Position struct {
+	Pos    string `xml:"pos,attr"`
+	AbsPos string `xml:"absPos,attr"`
+}
+
+// railML2Origin は線路の中の位置 pos を路線の起点からの位置に直すための、線路の始点の位置
+type railML2Origin struct {
+	offset float64
+	known  bool
+	// unplaced は absPos の無い要素を、始点の位置が分からず置けなかったか
+	unplaced bool
+}
+
+// railML2TrackOrigin は線路の始点（trackBegin）の absPos から pos との差を求める。
+// 始点に absPos が無くても線路が1本だけなら pos をそのまま路線の位置とみなせるが、
+// 複数の線路では線路ごとに pos の起点が違うので並べられない。
+func railML2TrackOrigin(track railML2Track, single bool) (*railML2Origin, error) {
+	abs, ok, err := parseRailMLNumber(track.Topology.Begin.AbsPos)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &railML2Origin{known: single}, nil
+	}
+	pos, _, err := parseRailMLNumber(track.Topology.Begin.Pos)
+	if err != nil {
+		return nil, err
+	}
+	return &railML2Origin{offset: abs - pos, known: true}, nil
+}
+
+// position は路線の起点からの位置。absPos が無ければ線路の中の位置 pos を線路の始点の位置でずらして使う。
+func (p railML2Position) position(origin *railML2Origin) (float64, bool, error) {
+	if abs, ok, err := parseRailMLNumber(p.AbsPos); ok || err != nil {
+		return abs, ok, err
+	}
+	pos, ok, err := parseRailMLNumber(p.Pos)
+	if !ok || err != nil {
+		return 0, false, err
+	}
+	if !origin.known {
+		origin.unplaced = true
+		return 0, false, nil
+	}
+	return origin.offset + pos, true, nil
+}
+
+type railML2Switch struct {
+	ID            string `xml:"id,attr"`
+	OCPStationRef string `xml:"ocpStationRef,attr"`
+	railML2Position
+}
+
+type railML2CrossSection struct {
+	ID     string `xml:"id,attr"`
+	OCPRef string `xml:"ocpRef,attr"`
+	railML2Position
+}
+
+type railML2Gradient struct {
+	ID    string `xml:"id,attr"`
+	Slope string `xml:"slope,attr"`
+	railML2Position
+}
+
+type railML2Electrification struct {
+	ID        string `xml:"id,attr"`
+	Type      string `xml:"type,attr"`
+	Voltage   string `xml:"voltage,attr"`
+	Frequency string `xml:"frequency,attr"`
+	railML2Position
+}
+
+type railML2PlatformEdge struct {
+	ID     string `xml:"id,attr"`
+	OCPRef string `xml:"ocpRef,attr"`
+}
+
+type railML2Signal struct {
+	ID  string `xml:"id,attr"`
+	Dir string `xml:"dir,attr"`
+	railML2Position
+}
+
+type railML2OCP struct {
+	ID              string       `xml:"id,attr"`
+	Name            string       `xml:"name,attr"`
+	Code            string       `xml:"code,attr"`
+	AdditionalNames []railMLName `xml:"additionalName"`
+	Designator      struct {
+		Entry string `xml:"entry,attr"`
+	} `xml:"designator"`
+	PropOperational struct {
+		OperationalType string `xml:"operationalType,attr"`
+	} `xml:"propOperational"`
+}
+
+func parseRailML2(data []byte) (*railMLTerritory, error) {
+	var doc railML2Document
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("railML parse failed: %w", err)
+	}
+
+	t := &railMLTerritory{}
+	ocps := make(map[string]railML2OCP, len(doc.Infrastructure.OCPs))
+	for _, ocp := range doc.Infrastructure.OCPs {
+		ocps[ocp.ID] = ocp
+	}
+	platforms := make(map[string]int)
+	located := make(map[string]bool)
+
+	for _, track := range doc.Infrastructure.Tracks {
+		origin, err := railML2TrackOrigin(track, len(doc.Infrastructure.Tracks) == 1)
+		if err != nil {
+			return nil, fmt.Errorf("track %s: trackBegin: %w", track.ID, err)
+		}
+		for _, cs := range track.Topology.CrossSections {
+			ocp, ok := ocps[cs.OCPRef]
+			if !ok || !isRailMLStationType(ocp.PropOperational.OperationalType) {
+				continue
+			}
+			position, ok, err := cs.position(origin)
+			if err != nil {
+				return nil, fmt.Errorf("crossSection %s: %w", cs.ID, err)
+			}
+			if !ok {
+				continue
+			}
+			located[ocp.ID] = true
+			name, romaji := splitRailMLNames(ocp.AdditionalNames)
+			if strings.TrimSpace(ocp.Name) != "" {
+				name = strings.TrimSpace(ocp.Name)
+			}
+			code := strings.TrimSpace(ocp.Code)
+			if code == "" {
+				code = strings.TrimSpace(ocp.Designator.Entry)
+			}
+			t.addStation(railMLStation{
+				id:         ocp.ID,
+				name:       name,
+				nameRomaji: romaji,
+				code:       code,
+				position:   position,
+				tracks:     []string{railML2TrackName(track)},
+			})
+		}
+		for _, edge := range track.Elements.PlatformEdges {
+			platforms[edge.OCPRef]++
+		}
+
+		for _, sw := range track.Topology.Switches {
+			position, ok, err := sw.position(origin)
+			if err != nil {
+				return nil, fmt.Errorf("switch %s: %w", sw.ID, err)
+			}
+			if !ok {
+				t.report("switch", sw.ID, "switch has no position")
+				continue
+			}
+			t.switches = append(t.switches, railMLSwitch{element: "switch", id: sw.ID, position: position, station: sw.OCPStationRef})
+		}
+		for _, signal := range track.OCS.Signals {
+			position, ok, err := signal.position(origin)
+			if err != nil {
+				return nil, fmt.Errorf("signal %s: %w", signal.ID, err)
+			}
+			if !ok {
+				t.report("signal", signal.ID, "signal has no position")
+				continue
+			}
+			t.signals = append(t.signals, railMLSignal{element: "signal", id: signal.ID, position: position, forward: signal.Dir != "down"})
+		}
+		for _, change := range track.Elements.Gradients {
+			position, ok, err := change.position(origin)
+			if err != nil {
+				return nil, fmt.Errorf("gradientChange %s: %w", change.ID, err)
+			}
+			if !ok {
+				t.report("gradientChange", change.ID, "gradient change has no position")
+				continue
+			}
+			slope, _, err := parseRailMLNumber(change.Slope)
+			if err != nil {
+				return nil, fmt.Errorf("gradientChange %s: %w", change.ID, err)
+			}
+			t.gradients = append(t.gradients, railMLChange[float64]{position: position, value: slope})
+		}
+		for _, change := range track.Elements.Electrifications {
+			position, ok, err := change.position(origin)
+			if err != nil {
+				return nil, fmt.Errorf("electrificationChange %s: %w", change.ID, err)
+			}
+			if !ok {
+				t.report("electrificationChange", change.ID, "electrification change has no position")
+				continue
+			}
+			label, err := railML2ElectrificationLabel(change)
+			if err != nil {
+				return nil, fmt.Errorf("electrificationChange %s: %w", change.ID, err)
+			}
+			t.electrifications = append(t.electrifications, railMLChange[string]{position: position, value: label})
+		}
+
+		for _, crossing := range track.Topology.Crossings {
+			t.report("crossing", crossing.ID, "diamond crossings are not modeled")
+		}
+		t.reportElements(track.Elements.Other, "track element is not modeled")
+		t.reportElements(track.OCS.Other, "operation and control element is not modeled")
+		if origin.unplaced {
+			t.report("track", track.ID, "positions without absPos cannot be placed on the line because trackBegin has no absPos")
+		}
+	}
+
+	for i := range t.stations {
+		t.stations[i].platforms = platforms[t.stations[i].id]
+	}
+	for _, ocp := range doc.Infrastructure.OCPs {
+		switch {
+		case !isRailMLStationType(ocp.PropOperational.OperationalType):
+			t.report("ocp", ocp.ID, fmt.Sprintf("operational point of type %q is not a station", ocp.PropOperational.OperationalType))
+		case !located[ocp.ID]:
+			t.report("ocp", ocp.ID, "operational point is not located on any track")
+		}
+	}
+	return t, nil
+}
+
+// railML2TrackName は線路の名前（無ければ ID）を番線として使う
+func railML2TrackName(track railML2Track) string {
+	if name := strings.TrimSpace(track.Name); name != "" {
+		return name
+	}
+	return track.ID
+}
+
+// railML2ElectrificationLabel は電化方式を DC1500V / AC20000V50Hz / NONE のように書く
+func railML2ElectrificationLabel(change railML2Electrification) (string, error) {
+	if change.Type == "none" {
+		return "NONE", nil
+	}
+	voltage, ok, err := parseRailMLNumber(change.Voltage)
+	if err != nil || !ok {
+		return "", err
+	}
+	frequency, _, err := parseRailMLNumber(change.Frequency)
+	if err != nil {
+		return "", err
+	}
+	if frequency == 0 {
+		return fmt.Sprintf("DC%gV", voltage), nil
+	}
+	return fmt.Sprintf("AC%gV%gHz", voltage, frequency), nil
+}
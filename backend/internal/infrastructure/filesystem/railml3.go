@@ -0,0 +1,219 @@
+package filesystem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// railML 3.x の設備のうち、路線の形式に変換するもの。
+// 位置は spotLocation の linearCoordinate の measure（メートル）を使い、無ければ netElement の起点の measure と長さから求める。
+type railML3Document struct {
+	Infrastructure struct {
+		NetElements []railML3NetElement `xml:"topology>netElements>netElement"`
+		Functional  struct {
+			Tracks            []railML3Track            `xml:"tracks>track"`
+			Switches          []railML3Located          `xml:"switchesIS>switchIS"`
+			Crossings         []railMLElement           `xml:"crossings>crossing"`
+			Signals           []railML3Located          `xml:"signalsIS>signalIS"`
+			OperationalPoints []railML3OperationalPoint `xml:"operationalPoints>operationalPoint"`
+			// Platforms は駅の番線の数として数え（ownsPlatform）、Lines は路線の説明なので読み飛ばす
+			Platforms []railMLElement `xml:"platforms>platform"`
+			Lines     []railMLElement `xml:"lines>line"`
+			Other     []railMLElement `xml:",any"`
+		} `xml:"functionalInfrastructure"`
+	} `xml:"infrastructure"`
+}
+
+type railML3NetElement struct {
+	ID     string `xml:"id,attr"`
+	Length string `xml:"length,attr"`
+	// Coordinates は netElement の中の位置（0〜1）と路線の起点からの位置の対応
+	Coordinates []struct {
+		IntrinsicCoord string `xml:"intrinsicCoord,attr"`
+		Linear         struct {
+			Measure string `xml:"measure,attr"`
+		} `xml:"linearCoordinate"`
+	} `xml:"associatedPositioningSystem>intrinsicCoordinate"`
+}
+
+type railML3Track struct {
+	ID          string       `xml:"id,attr"`
+	Names       []railMLName `xml:"name"`
+	NetElements []struct {
+		Ref string `xml:"netElementRef,attr"`
+	} `xml:"linearLocation>associatedNetElement"`
+}
+
+type railML3SpotLocation struct {
+	NetElementRef        string `xml:"netElementRef,attr"`
+	IntrinsicCoord       string `xml:"intrinsicCoord,attr"`
+	ApplicationDirection string `xml:"applicationDirection,attr"`
+	Pos                  string `xml:"pos,attr"`
+	Linear               struct {
+		Measure string `xml:"measure,attr"`
+	} `xml:"linearCoordinate"`
+}
+
+// railML3Located は spotLocation で位置を示す要素（switchIS / signalIS）
+type railML3Located struct {
+	ID        string                `xml:"id,attr"`
+	Locations []railML3SpotLocation `xml:"spotLocation"`
+}
+
+type railML3OperationalPoint struct {
+	ID          string       `xml:"id,attr"`
+	Names       []railMLName `xml:"name"`
+	Designators []struct {
+		Entry string `xml:"entry,attr"`
+	} `xml:"designator"`
+	Operations []struct {
+		OperationalType string `xml:"operationalType,attr"`
+	} `xml:"opOperations>opOperation"`
+	Platforms []struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"opEquipment>ownsPlatform"`
+	Locations []railML3SpotLocation `xml:"spotLocation"`
+}
+
+// railML3Positions は netElement から路線の起点からの位置を求める
+type railML3Positions map[string]railML3NetElement
+
+// position は spotLocation の路線の起点からの位置。求められなければ false。
+func (p railML3Positions) position(location railML3SpotLocation) (float64, bool, error) {
+	if measure, ok, err := parseRailMLNumber(location.Linear.Measure); ok || err != nil {
+		return measure, ok, err
+	}
+	if pos, ok, err := parseRailMLNumber(location.Pos); ok || err != nil {
+		return pos, ok, err
+	}
+
+	element, ok := p[location.NetElementRef]
+	intrinsic, hasIntrinsic, err := parseRailMLNumber(location.IntrinsicCoord)
+	if !ok || !hasIntrinsic || err != nil {
+		return 0, false, err
+	}
+	length, hasLength, err := parseRailMLNumber(element.Length)
+	if !hasLength || err != nil {
+		return 0, false, err
+	}
+	for _, coordinate := range element.Coordinates {
+		at, ok, err := parseRailMLNumber(coordinate.IntrinsicCoord)
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok || at != 0 {
+			continue
+		}
+		begin, ok, err := parseRailMLNumber(coordinate.Linear.Measure)
+		if !ok || err != nil {
+			return 0, false, err
+		}
+		return begin + intrinsic*length, true, nil
+	}
+	return 0, false, nil
+}
+
+func parseRailML3(data []byte) (*railMLTerritory, error) {
+	var doc railML3Document
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("railML parse failed: %w", err)
+	}
+	infra := doc.Infrastructure
+	functional := infra.Functional
+
+	positions := make(railML3Positions, len(infra.NetElements))
+	for _, element := range infra.NetElements {
+		positions[element.ID] = element
+	}
+	// 線路の名前を netElement ごとに引く（駅を通る線路を番線にする）
+	tracksByElement := make(map[string][]string)
+	for _, track := range functional.Tracks {
+		name, romaji := splitRailMLNames(track.Names)
+		switch {
+		case name != "":
+		case romaji != "":
+			name = romaji
+		default:
+			name = track.ID
+		}
+		for _, element := range track.NetElements {
+			tracksByElement[element.Ref] = append(tracksByElement[element.Ref], name)
+		}
+	}
+
+	t := &railMLTerritory{}
+	for _, op := range functional.OperationalPoints {
+		operationalType := ""
+		if len(op.Operations) > 0 {
+			operationalType = op.Operations[0].OperationalType
+		}
+		if !isRailMLStationType(operationalType) {
+			t.report("operationalPoint", op.ID, fmt.Sprintf("operational point of type %q is not a station", operationalType))
+			continue
+		}
+
+		name, romaji := splitRailMLNames(op.Names)
+		station := railMLStation{id: op.ID, name: name, nameRomaji: romaji, platforms: len(op.Platforms)}
+		if len(op.Designators) > 0 {
+			station.code = strings.TrimSpace(op.Designators[0].Entry)
+		}
+		located := false
+		for _, location := range op.Locations {
+			position, ok, err := positions.position(location)
+			if err != nil {
+				return nil, fmt.Errorf("operationalPoint %s: %w", op.ID, err)
+			}
+			if !ok {
+				continue
+			}
+			located = true
+			station.position = position
+			station.tracks = tracksByElement[location.NetElementRef]
+			t.addStation(station)
+		}
+		if !located {
+			t.report("operationalPoint", op.ID, "operational point is not located on any track")
+		}
+	}
+
+	for _, sw := range functional.Switches {
+		position, ok, err := railML3FirstPosition(positions, sw)
+		if err != nil {
+			return nil, fmt.Errorf("switchIS %s: %w", sw.ID, err)
+		}
+		if !ok {
+			t.report("switchIS", sw.ID, "switch has no position")
+			continue
+		}
+		t.switches = append(t.switches, railMLSwitch{element: "switchIS", id: sw.ID, position: position})
+	}
+	for _, signal := range functional.Signals {
+		position, ok, err := railML3FirstPosition(positions, signal)
+		if err != nil {
+			return nil, fmt.Errorf("signalIS %s: %w", signal.ID, err)
+		}
+		if !ok {
+			t.report("signalIS", signal.ID, "signal has no position")
+			continue
+		}
+		forward := signal.Locations[0].ApplicationDirection != "reverse"
+		t.signals = append(t.signals, railMLSignal{element: "signalIS", id: signal.ID, position: position, forward: forward})
+	}
+
+	for _, crossing := range functional.Crossings {
+		t.report("crossing", crossing.ID, "diamond crossings are not modeled")
+	}
+	t.reportElements(functional.Other, "functional infrastructure element is not modeled")
+	return t, nil
+}
+
+func railML3FirstPosition(positions railML3Positions, element railML3Located) (float64, bool, error) {
+	for _, location := range element.Locations {
+		position, ok, err := positions.position(location)
+		if ok || err != nil {
+			return position, ok, err
+		}
+	}
+	return 0, false, nil
+}
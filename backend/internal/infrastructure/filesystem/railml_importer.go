@@ -0,0 +1,373 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// railMLStationAreaMetres は駅の構内とみなす範囲。駅からこの距離の中の分岐器は駅の配線として路線にまとめる。
+const railMLStationAreaMetres = 500.0
+
+var errRailMLTooFewStations = errors.New("railML has fewer than two stations on its tracks")
+
+// RailMLImporter は railML 2.x / 3.x の設備（infrastructure）のファイルを読み、この路線の形式に変換する。
+// 路線の形式は駅を一列に並べ、隣り合う駅のあいだを1つの区間にするので、次のようにまとめる。
+//   - 駅: 線路上に位置を持つ駅・停留所の運転取扱点（ocp / operationalPoint）。キロ程の順に並べる。
+//   - 区間: 隣り合う駅のあいだ。区間に入る信号機（順方向を優先）があればその ID を区間の ID にする。
+//   - 線路: 両側の駅を通る線路の名前を番線に、勾配（最も急なもの）と電化方式を区間の情報にする。
+//   - 分岐器: 駅の構内のものは駅の配線としてまとめる。
+//
+// 表せない要素（駅の外の分岐器、区間の途中の信号機、対応していない要素など）は取り込みの結果に並べる。
+type RailMLImporter struct {
+	path string
+}
+
+func NewRailMLImporter(path string) *RailMLImporter {
+	return &RailMLImporter{path: strings.TrimSpace(path)}
+}
+
+// RailMLImport は railML の取り込みの結果。Document は路線の形式（SimulationLineLoader で読める JSON）。
+type RailMLImport struct {
+	Version     string
+	Line        *domain.Line
+	Document    []byte
+	Unsupported []RailMLUnsupportedElement
+}
+
+// RailMLUnsupportedElement は路線の形式に取り込めなかった railML の要素
+type RailMLUnsupportedElement struct {
+	Element string
+	ID      string
+	Reason  string
+}
+
+func (i *RailMLImporter) Import(ctx context.Context) (*RailMLImport, error) {
+	_ = ctx
+
+	data, _, err := readFixture(i.path)
+	if err != nil {
+		return nil, fmt.Errorf("railML read failed: %w", err)
+	}
+	return importRailML(data)
+}
+
+// isRailMLFile は路線網の定義から参照するファイルが railML か（拡張子で見分ける）
+func isRailMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml", ".railml":
+		return true
+	default:
+		return false
+	}
+}
+
+func importRailML(data []byte) (*RailMLImport, error) {
+	var root struct {
+		XMLName xml.Name
+		Version string `xml:"version,attr"`
+	}
+	data = trimmedBytes(data)
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("railML parse failed: %w", err)
+	}
+
+	var (
+		territory *railMLTerritory
+		err       error
+	)
+	switch {
+	case root.XMLName.Local == "railml" && strings.HasPrefix(root.Version, "2."):
+		territory, err = parseRailML2(data)
+	case root.XMLName.Local == "railML" && strings.HasPrefix(root.Version, "3."):
+		territory, err = parseRailML3(data)
+	default:
+		return nil, fmt.Errorf("%w: <%s version=%q>", ErrUnsupportedSchemaVersion, root.XMLName.Local, root.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, unsupported, err := territory.lineJSON()
+	if err != nil {
+		return nil, err
+	}
+	document, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	line, err := parseLine(document)
+	if err != nil {
+		return nil, fmt.Errorf("railML conversion failed: %w", err)
+	}
+	return &RailMLImport{
+		Version:     root.Version,
+		Line:        line,
+		Document:    append(document, '\n'),
+		Unsupported: unsupported,
+	}, nil
+}
+
+// railMLTerritory は railML の版によらない、路線の形式に変換する前の設備。位置はすべて路線の起点からのメートル。
+type railMLTerritory struct {
+	stations         []railMLStation
+	signals          []railMLSignal
+	switches         []railMLSwitch
+	gradients        []railMLChange[float64]
+	electrifications []railMLChange[string]
+	unsupported      []RailMLUnsupportedElement
+}
+
+type railMLStation struct {
+	id, name, nameRomaji, code string
+	position                   float64
+	platforms                  int
+	// tracks は駅を通る線路の名前
+	tracks []string
+}
+
+type railMLSignal struct {
+	element, id string
+	position    float64
+	forward     bool
+}
+
+// railMLSwitch の station は分岐器が属すると書かれた駅（書かれていなければ空で、位置で駅の構内かを決める）
+type railMLSwitch struct {
+	element, id string
+	position    float64
+	station     string
+}
+
+// railMLChange は線路の position から先で value に変わること（勾配・電化方式）
+type railMLChange[T any] struct {
+	position float64
+	value    T
+}
+
+func (t *railMLTerritory) report(element, id, reason string) {
+	t.unsupported = append(t.unsupported, RailMLUnsupportedElement{Element: element, ID: id, Reason: reason})
+}
+
+// addStation は駅を加える。同じ駅を別の線路でも通るなら、最も手前の位置を使い、線路の名前を足す。
+func (t *railMLTerritory) addStation(station railMLStation) {
+	for i := range t.stations {
+		if t.stations[i].id != station.id {
+			continue
+		}
+		t.stations[i].position = math.Min(t.stations[i].position, station.position)
+		t.stations[i].platforms = max(t.stations[i].platforms, station.platforms)
+		for _, track := range station.tracks {
+			if track != "" && !slices.Contains(t.stations[i].tracks, track) {
+				t.stations[i].tracks = append(t.stations[i].tracks, track)
+			}
+		}
+		return
+	}
+	t.stations = append(t.stations, station)
+}
+
+// lineJSON は駅をキロ程の順に並べ、隣り合う駅のあいだを区間にした路線の形式を作る
+func (t *railMLTerritory) lineJSON() (simulationLineJSON, []RailMLUnsupportedElement, error) {
+	stations := append([]railMLStation(nil), t.stations...)
+	sort.SliceStable(stations, func(i, j int) bool { return stations[i].position < stations[j].position })
+	if len(stations) < 2 {
+		return simulationLineJSON{}, nil, errRailMLTooFewStations
+	}
+
+	raw := simulationLineJSON{
+		Stations: make([]stationJSON, 0, len(stations)),
+		Blocks:   make([]blockJSON, 0, len(stations)-1),
+	}
+	for _, s := range stations {
+		kilometerPost := math.Round(s.position) / 1000
+		raw.Stations = append(raw.Stations, stationJSON{
+			ID:            s.id,
+			Name:          s.name,
+			NameRomaji:    s.nameRomaji,
+			Code:          s.code,
+			KilometerPost: &kilometerPost,
+			Platforms:     s.platforms,
+		})
+	}
+
+	signals := append([]railMLSignal(nil), t.signals...)
+	sort.SliceStable(signals, func(i, j int) bool { return signals[i].position < signals[j].position })
+	used := make(map[string]bool, len(signals))
+	for i := 0; i+1 < len(stations); i++ {
+		from, to := stations[i], stations[i+1]
+		block := blockJSON{
+			ID:               from.id + "-" + to.id,
+			FromStationID:    from.id,
+			ToStationID:      to.id,
+			TrackNumber:      commonTrack(from.tracks, to.tracks),
+			Electrification:  valueAt(t.electrifications, from.position),
+			GradientPermille: steepestGradient(t.gradients, from.position, to.position),
+		}
+		if signal, ok := blockSignal(signals, from.position, to.position); ok {
+			block.ID = signal.id
+			used[signal.id] = true
+		}
+		raw.Blocks = append(raw.Blocks, block)
+	}
+
+	unsupported := append([]RailMLUnsupportedElement(nil), t.unsupported...)
+	for _, signal := range signals {
+		if !used[signal.id] {
+			unsupported = append(unsupported, RailMLUnsupportedElement{Element: signal.element, ID: signal.id, Reason: "the line has one block between adjacent stations, so only the first signal of each block is kept"})
+		}
+	}
+	for _, sw := range t.switches {
+		if !switchInStation(sw, stations) {
+			unsupported = append(unsupported, RailMLUnsupportedElement{Element: sw.element, ID: sw.id, Reason: "switch outside a station; junctions and branches are not modeled"})
+		}
+	}
+	return raw, unsupported, nil
+}
+
+// blockSignal は from から to の区間に入る信号機。順方向のものを優先し、無ければ逆方向のうち to に最も近いもの。
+func blockSignal(signals []railMLSignal, from, to float64) (railMLSignal, bool) {
+	var backward *railMLSignal
+	for i, signal := range signals {
+		if signal.position < from || signal.position >= to {
+			continue
+		}
+		if signal.forward {
+			return signal, true
+		}
+		backward = &signals[i]
+	}
+	if backward != nil {
+		return *backward, true
+	}
+	return railMLSignal{}, false
+}
+
+func switchInStation(sw railMLSwitch, stations []railMLStation) bool {
+	for _, station := range stations {
+		if sw.station != "" && sw.station == station.id {
+			return true
+		}
+		if sw.station == "" && math.Abs(sw.position-station.position) <= railMLStationAreaMetres {
+			return true
+		}
+	}
+	return false
+}
+
+func commonTrack(from, to []string) string {
+	for _, track := range from {
+		if slices.Contains(to, track) {
+			return track
+		}
+	}
+	return ""
+}
+
+// valueAt は position で有効な値（position より手前で最後に変わった値）
+func valueAt[T any](changes []railMLChange[T], position float64) T {
+	var value T
+	best := math.Inf(-1)
+	for _, change := range changes {
+		if change.position <= position && change.position >= best {
+			value, best = change.value, change.position
+		}
+	}
+	return value
+}
+
+// steepestGradient は from から to のあいだで最も急な勾配（‰、路線の順方向の上りが正）
+func steepestGradient(changes []railMLChange[float64], from, to float64) float64 {
+	steepest := valueAt(changes, from)
+	for _, change := range changes {
+		if change.position > from && change.position < to && math.Abs(change.value) > math.Abs(steepest) {
+			steepest = change.value
+		}
+	}
+	return steepest
+}
+
+// railMLElement は対応していない要素を報告するために読む任意の要素
+type railMLElement struct {
+	XMLName  xml.Name
+	ID       string          `xml:"id,attr"`
+	Children []railMLElement `xml:",any"`
+}
+
+// reportElements は対応していない要素を報告する。入れ物（speedChanges など）なら中の要素を1つずつ報告する。
+func (t *railMLTerritory) reportElements(elements []railMLElement, reason string) {
+	for _, element := range elements {
+		if len(element.Children) == 0 {
+			t.report(element.XMLName.Local, element.ID, reason)
+			continue
+		}
+		for _, child := range element.Children {
+			t.report(child.XMLName.Local, child.ID, reason)
+		}
+	}
+}
+
+// railMLName は railML 3.x の name、railML 2.x の additionalName
+type railMLName struct {
+	Name     string `xml:"name,attr"`
+	Language string `xml:"language,attr"`
+	Lang     string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+}
+
+// splitRailMLNames は名前のうち、ラテン文字の表記（言語が *-Latn か en）をローマ字の駅名に、それ以外の最初のものを駅名にする
+func splitRailMLNames(names []railMLName) (name, romaji string) {
+	for _, n := range names {
+		value := strings.TrimSpace(n.Name)
+		language := strings.ToLower(n.Language + n.Lang)
+		if value == "" {
+			continue
+		}
+		if strings.HasSuffix(language, "-latn") || language == "en" {
+			if romaji == "" {
+				romaji = value
+			}
+		} else if name == "" {
+			name = value
+		}
+	}
+	return name, romaji
+}
+
+// parseRailMLNumber は数の属性を読む。空なら false。
+func parseRailMLNumber(v string) (float64, bool, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false, fmt.Errorf("railML number %q is invalid", v)
+	}
+	return f, true, nil
+}
+
+// isRailMLStationType は運転取扱点の種類が駅・停留所か（種類が無ければ駅とみなす）
+func isRailMLStationType(operationalType string) bool {
+	switch operationalType {
+	case "", "station", "stoppingPoint", "halt", "passengerStop":
+		return true
+	default:
+		return false
+	}
+}
+
+// trimmedBytes は BOM や前後の空白を除いた XML（decoder に渡す前に揃える）
+func trimmedBytes(data []byte) []byte {
+	return bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+}
@@ -0,0 +1,186 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+func TestRailMLImporterConvertsRailML2(t *testing.T) {
+	imported, err := NewRailMLImporter(filepath.Join("testdata", "railml2_line.xml")).Import(context.Background())
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	line := imported.Line
+	if got := stationIDs(line); !reflect.DeepEqual(got, []string{"ocp_UEN", "ocp_KOS", "ocp_EBI"}) {
+		t.Fatalf("unexpected stations: %v", got)
+	}
+	if got := blockIDs(line); !reflect.DeepEqual(got, []string{"sig_A1", "sig_B1"}) {
+		t.Fatalf("unexpected blocks: %v", got)
+	}
+	wantStations := []domain.StationInfo{
+		{Name: "上野原", NameRomaji: "Uenohara", Code: "B01", KilometerPost: 0, HasKilometerPost: true, Platforms: 2},
+		// 2番線（副本線）のホームも数える
+		{Name: "交差町", NameRomaji: "Kosamachi", Code: "B02", KilometerPost: 2.1, HasKilometerPost: true, Platforms: 4},
+		{Name: "海老原", Code: "B03", KilometerPost: 4.6, HasKilometerPost: true},
+	}
+	if got := line.StationInfos(); !reflect.DeepEqual(got, wantStations) {
+		t.Fatalf("unexpected station info: %+v", got)
+	}
+	wantBlocks := []domain.BlockInfo{
+		{TrackNumber: "1", Electrification: "DC1500V", GradientPermille: 5},
+		{TrackNumber: "1", Electrification: "DC1500V", GradientPermille: -12},
+	}
+	if got := line.BlockInfos(); !reflect.DeepEqual(got, wantBlocks) {
+		t.Fatalf("unexpected block info: %+v", got)
+	}
+
+	wantUnsupported := []RailMLUnsupportedElement{
+		{Element: "speedChange", ID: "sc1", Reason: "track element is not modeled"},
+		{Element: "trainDetector", ID: "td1", Reason: "operation and control element is not modeled"},
+		{Element: "ocp", ID: "ocp_J", Reason: `operational point of type "junction" is not a station`},
+		{Element: "signal", ID: "sig_A2", Reason: "the line has one block between adjacent stations, so only the first signal of each block is kept"},
+		{Element: "signal", ID: "sig_B2r", Reason: "the line has one block between adjacent stations, so only the first signal of each block is kept"},
+		{Element: "switch", ID: "sw2", Reason: "switch outside a station; junctions and branches are not modeled"},
+	}
+	if !reflect.DeepEqual(imported.Unsupported, wantUnsupported) {
+		t.Fatalf("unexpected unsupported elements:\n%+v", imported.Unsupported)
+	}
+
+	// 変換した文書は路線の形式としてそのまま読める
+	loaded, err := NewSimulationLineLoader(writeFixture(t, string(imported.Document))).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load converted document failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.StationInfos(), wantStations) || !reflect.DeepEqual(loaded.BlockInfos(), wantBlocks) {
+		t.Fatalf("converted document does not round trip: %s", imported.Document)
+	}
+}
+
+func TestRailMLImporterPlacesRailML2PositionsByTrackBegin(t *testing.T) {
+	// absPos の無い位置は線路ごとの始点からの距離なので、始点の absPos でずらして並べる
+	path := writeFixture(t, `<railml version="2.2"><infrastructure>
+  <tracks>
+    <track id="tr1" name="1"><trackTopology>
+      <trackBegin id="tb1" pos="0" absPos="0"/>
+      <crossSections><crossSection id="cs1" ocpRef="o1" pos="0"/><crossSection id="cs2" ocpRef="o2" pos="1000"/></crossSections>
+    </trackTopology>
+    <ocsElements><signals><signal id="s1" pos="100" dir="up"/></signals></ocsElements></track>
+    <track id="tr2" name="1"><trackTopology>
+      <trackBegin id="tb2" pos="0" absPos="1000"/>
+      <connections><switch id="sw1"/></connections>
+      <crossSections><crossSection id="cs3" ocpRef="o3" pos="500"/></crossSections>
+    </trackTopology>
+    <ocsElements><signals><signal id="s2" pos="100" dir="up"/></signals></ocsElements></track>
+    <track id="tr3" name="3"><trackTopology>
+      <trackBegin id="tb3" pos="0"/>
+    </trackTopology>
+    <ocsElements><signals><signal id="s3" pos="10" dir="up"/></signals></ocsElements></track>
+  </tracks>
+  <operationControlPoints>
+    <ocp id="o1" name="A"><propOperational operationalType="station"/></ocp>
+    <ocp id="o2" name="B"><propOperational operationalType="station"/></ocp>
+    <ocp id="o3" name="C"><propOperational operationalType="station"/></ocp>
+  </operationControlPoints>
+</infrastructure></railml>`)
+	imported, err := NewRailMLImporter(path).Import(context.Background())
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	if got := stationIDs(imported.Line); !reflect.DeepEqual(got, []string{"o1", "o2", "o3"}) {
+		t.Fatalf("unexpected stations: %v", got)
+	}
+	if got := imported.Line.StationInfos()[2].KilometerPost; got != 1.5 {
+		t.Fatalf("expected o3 at 1.5km, got %v", got)
+	}
+	if got := blockIDs(imported.Line); !reflect.DeepEqual(got, []string{"s1", "s2"}) {
+		t.Fatalf("unexpected blocks: %v", got)
+	}
+	// 位置の無い分岐器と、始点の位置が分からず並べられない線路は変換できないものとして返す
+	wantUnsupported := []RailMLUnsupportedElement{
+		{Element: "switch", ID: "sw1", Reason: "switch has no position"},
+		{Element: "signal", ID: "s3", Reason: "signal has no position"},
+		{Element: "track", ID: "tr3", Reason: "positions without absPos cannot be placed on the line because trackBegin has no absPos"},
+	}
+	if !reflect.DeepEqual(imported.Unsupported, wantUnsupported) {
+		t.Fatalf("unexpected unsupported elements:\n%+v", imported.Unsupported)
+	}
+}
+
+func TestRailMLImporterConvertsRailML3(t *testing.T) {
+	imported, err := NewRailMLImporter(filepath.Join("testdata", "railml3_line.xml")).Import(context.Background())
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if imported.Version != "3.1" {
+		t.Fatalf("unexpected version: %q", imported.Version)
+	}
+
+	line := imported.Line
+	if got := stationIDs(line); !reflect.DeepEqual(got, []string{"op_AKA", "op_X", "op_AKE"}) {
+		t.Fatalf("unexpected stations: %v", got)
+	}
+	// 逆方向の信号機しか無い区間はそれを区間の ID にする
+	if got := blockIDs(line); !reflect.DeepEqual(got, []string{"sigA", "sigB"}) {
+		t.Fatalf("unexpected blocks: %v", got)
+	}
+	wantStations := []domain.StationInfo{
+		{Name: "赤羽", NameRomaji: "Akabane", Code: "R01", KilometerPost: 0, HasKilometerPost: true, Platforms: 2},
+		// linearCoordinate が無ければ netElement の中の位置から求める
+		{Name: "交差", KilometerPost: 2.3, HasKilometerPost: true},
+		{Name: "明石", KilometerPost: 4.6, HasKilometerPost: true},
+	}
+	if got := line.StationInfos(); !reflect.DeepEqual(got, wantStations) {
+		t.Fatalf("unexpected station info: %+v", got)
+	}
+	if got := line.BlockInfos(); got[0].TrackNumber != "1" || got[1].TrackNumber != "1" {
+		t.Fatalf("unexpected block info: %+v", got)
+	}
+
+	wantUnsupported := []RailMLUnsupportedElement{
+		{Element: "operationalPoint", ID: "op_BP", Reason: `operational point of type "blockPost" is not a station`},
+		{Element: "levelCrossingIS", ID: "lc1", Reason: "functional infrastructure element is not modeled"},
+	}
+	if !reflect.DeepEqual(imported.Unsupported, wantUnsupported) {
+		t.Fatalf("unexpected unsupported elements:\n%+v", imported.Unsupported)
+	}
+}
+
+func TestRailMLImporterRejectsUnsupportedVersion(t *testing.T) {
+	path := writeFixture(t, `<railml version="1.1"><infrastructure/></railml>`)
+	if _, err := NewRailMLImporter(path).Import(context.Background()); !errors.Is(err, ErrUnsupportedSchemaVersion) {
+		t.Fatalf("expected %v, got %v", ErrUnsupportedSchemaVersion, err)
+	}
+}
+
+func TestRailMLImporterRejectsTooFewStations(t *testing.T) {
+	path := writeFixture(t, `<railml version="2.2"><infrastructure>
+  <tracks><track id="tr1"><trackTopology><crossSections><crossSection id="cs1" ocpRef="o1" absPos="0"/></crossSections></trackTopology></track></tracks>
+  <operationControlPoints><ocp id="o1" name="A"/></operationControlPoints>
+</infrastructure></railml>`)
+	if _, err := NewRailMLImporter(path).Import(context.Background()); !errors.Is(err, errRailMLTooFewStations) {
+		t.Fatalf("expected %v, got %v", errRailMLTooFewStations, err)
+	}
+}
+
+func stationIDs(line *domain.Line) []string {
+	out := make([]string, 0, len(line.Stations()))
+	for _, id := range line.Stations() {
+		out = append(out, id.String())
+	}
+	return out
+}
+
+func blockIDs(line *domain.Line) []string {
+	out := make([]string, 0, len(line.Blocks()))
+	for _, id := range line.Blocks() {
+		out = append(out, id.String())
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
+)
+
+// FileSessionRepository は TrainingSession をセッションごとのJSONファイルとして保持するRepository実装。
+// ファイルは <dir>/<base64url(セッションID)>.json に置く。
+// 作成・保存・削除はプロセス内では mu、同じディレクトリを共有する別プロセスとは <集約>.lock のファイルロックで直列にする。
+type FileSessionRepository struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileSessionRepository はリポジトリを生成する。ディレクトリは初回書き込み時に作成する。
+func NewFileSessionRepository(dir string) domain.Repository {
+	return &FileSessionRepository{dir: dir}
+}
+
+type sessionFileJSON struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	ID            string               `json:"id"`
+	Version       int64                `json:"version"`
+	Status        string               `json:"status"`
+	LastActiveAt  time.Time            `json:"lastActiveAt"`
+	Dispatchers   []dispatcherFileJSON `json:"dispatchers"`
+}
+
+type dispatcherFileJSON struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+func (r *FileSessionRepository) Get(ctx context.Context, id domain.SessionID) (*domain.TrainingSession, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.read(r.path(id.String()))
+}
+
+func (r *FileSessionRepository) List(ctx context.Context) ([]*domain.TrainingSession, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*domain.TrainingSession{}, nil
+		}
+		return nil, fmt.Errorf("session dir read failed: %w", err)
+	}
+
+	out := make([]*domain.TrainingSession, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := aggregateIDFromFileName(entry.Name()); !ok {
+			continue
+		}
+		session, err := r.read(filepath.Join(r.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, session)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID().String() < out[j].ID().String()
+	})
+	return out, nil
+}
+
+func (r *FileSessionRepository) Create(ctx context.Context, s *domain.TrainingSession) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(s.ID().String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := fileExists(path)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrSessionAlreadyExists
+	}
+	if err := r.write(path, s); err != nil {
+		return err
+	}
+	s.IncrementVersion()
+	return nil
+}
+
+func (r *FileSessionRepository) Save(ctx context.Context, s *domain.TrainingSession) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(s.ID().String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := r.read(path)
+	if err != nil {
+		return err
+	}
+	if stored.Version() != s.Version() {
+		return domain.ErrVersionConflict
+	}
+	if err := r.write(path, s); err != nil {
+		return err
+	}
+	s.IncrementVersion()
+	return nil
+}
+
+func (r *FileSessionRepository) Delete(ctx context.Context, id domain.SessionID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(id.String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return domain.ErrSessionNotFound
+		}
+		return fmt.Errorf("session file remove failed: %w", err)
+	}
+	return nil
+}
+
+func (r *FileSessionRepository) path(id string) string {
+	return filepath.Join(r.dir, aggregateFileName(id))
+}
+
+func (r *FileSessionRepository) read(path string) (*domain.TrainingSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("session file read failed: %w", err)
+	}
+
+	var raw sessionFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("session file parse failed: %w", err)
+	}
+	if raw.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("%w: session file version %d", ErrUnsupportedSchemaVersion, raw.SchemaVersion)
+	}
+
+	dispatchers := make([]domain.DispatcherSnapshot, 0, len(raw.Dispatchers))
+	for _, d := range raw.Dispatchers {
+		dispatchers = append(dispatchers, domain.DispatcherSnapshot{
+			ID:       d.ID,
+			Name:     d.Name,
+			JoinedAt: d.JoinedAt,
+		})
+	}
+	return domain.RestoreTrainingSession(domain.SessionSnapshot{
+		ID:           raw.ID,
+		Version:      raw.Version,
+		Status:       raw.Status,
+		LastActiveAt: raw.LastActiveAt,
+		Dispatchers:  dispatchers,
+	})
+}
+
+// write は保存後のバージョン（現在+1）でファイルを書き出す。集約側のバージョンは呼び出し元が進める。
+func (r *FileSessionRepository) write(path string, s *domain.TrainingSession) error {
+	snap := s.Snapshot()
+
+	dispatchers := make([]dispatcherFileJSON, 0, len(snap.Dispatchers))
+	for _, d := range snap.Dispatchers {
+		dispatchers = append(dispatchers, dispatcherFileJSON{
+			ID:       d.ID,
+			Name:     d.Name,
+			JoinedAt: d.JoinedAt,
+		})
+	}
+	sort.Slice(dispatchers, func(i, j int) bool {
+		return dispatchers[i].ID < dispatchers[j].ID
+	})
+
+	data, err := json.MarshalIndent(sessionFileJSON{
+		SchemaVersion: schemaVersion,
+		ID:            snap.ID,
+		Version:       snap.Version + 1,
+		Status:        snap.Status,
+		LastActiveAt:  snap.LastActiveAt,
+		Dispatchers:   dispatchers,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("session encode failed: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
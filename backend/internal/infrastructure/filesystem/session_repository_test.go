@@ -0,0 +1,137 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
+)
+
+func TestFileSessionRepositoryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := NewFileSessionRepository(dir)
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	id, _ := domain.NewSessionID("../room-a")
+	session := domain.NewTrainingSession(id, now)
+	dispatcherID, _ := domain.NewDispatcherID("D0")
+	name, _ := domain.NewDispatcherName("指令員A")
+	if err := session.JoinDispatcher(domain.NewDispatcher(dispatcherID, name), now); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	if err := session.Start(now); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	if err := repo.Create(ctx, session); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := repo.Create(ctx, session); !errors.Is(err, domain.ErrSessionAlreadyExists) {
+		t.Fatalf("expected ErrSessionAlreadyExists, got %v", err)
+	}
+
+	// 別インスタンス（再起動相当）から読めること
+	got, err := NewFileSessionRepository(dir).Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Status() != domain.SessionStatusRunning {
+		t.Fatalf("expected RUNNING, got %s", got.Status())
+	}
+	if len(got.Dispatchers()) != 1 || got.Dispatchers()[0].Name().String() != "指令員A" {
+		t.Fatalf("unexpected dispatchers: %+v", got.Dispatchers())
+	}
+	if !got.Dispatchers()[0].JoinedAt().Equal(now) {
+		t.Fatalf("expected joinedAt %v, got %v", now, got.Dispatchers()[0].JoinedAt())
+	}
+
+	// IDにパス区切りを含んでもディレクトリ外に書き出さないこと
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "room-a.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file outside repository dir")
+	}
+
+	list, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(list))
+	}
+
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, id); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFileSessionRepositoryDetectsStaleWriterFromAnotherInstance(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	id, _ := domain.NewSessionID("room-a")
+	if err := NewFileSessionRepository(dir).Create(ctx, domain.NewTrainingSession(id, time.Now())); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	// 同じディレクトリを使う別々のインスタンス（別プロセス相当）が同じ版から同時に保存しても、通るのは1つだけ
+	const writers = 8
+	for round := 0; round < 20; round++ {
+		sessions := make([]*domain.TrainingSession, writers)
+		repos := make([]domain.Repository, writers)
+		for i := range writers {
+			repos[i] = NewFileSessionRepository(dir)
+			session, err := repos[i].Get(ctx, id)
+			if err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			sessions[i] = session
+		}
+
+		errs := make(chan error, writers)
+		var start sync.WaitGroup
+		start.Add(1)
+		for i := range writers {
+			go func() {
+				start.Wait()
+				errs <- repos[i].Save(ctx, sessions[i])
+			}()
+		}
+		start.Done()
+
+		saved := 0
+		for range writers {
+			err := <-errs
+			switch {
+			case err == nil:
+				saved++
+			case !errors.Is(err, domain.ErrVersionConflict):
+				t.Fatalf("expected ErrVersionConflict, got %v", err)
+			}
+		}
+		if saved != 1 {
+			t.Fatalf("round %d: expected exactly one save to win, got %d", round, saved)
+		}
+	}
+}
+
+func TestFileSessionRepositoryRejectsUnknownSchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+	id, _ := domain.NewSessionID("room-a")
+	path := filepath.Join(dir, aggregateFileName(id.String()))
+	if err := os.WriteFile(path, []byte(`{"schemaVersion":999,"id":"room-a","status":"LOBBY"}`), 0o644); err != nil {
+		t.Fatalf("write fixture failed: %v", err)
+	}
+
+	_, err := NewFileSessionRepository(dir).Get(context.Background(), id)
+	if !errors.Is(err, ErrUnsupportedSchemaVersion) {
+		t.Fatalf("expected ErrUnsupportedSchemaVersion, got %v", err)
+	}
+}
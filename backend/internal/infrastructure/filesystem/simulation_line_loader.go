@@ -29,52 +29,102 @@ type simulationLineJSON struct {
 	Blocks   []blockJSON   `json:"blocks"`
 }
 
+// stationJSON と blockJSON の id 以外の項目は案内用の情報で、省略できる（id だけの旧い形式も読める）
 type stationJSON struct {
-	ID string `json:"id"`
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	NameRomaji    string   `json:"nameRomaji,omitempty"`
+	Code          string   `json:"code,omitempty"`
+	KilometerPost *float64 `json:"kilometerPost,omitempty"` // 省けば不明（0 は起点の 0 km）
+	Platforms     int      `json:"platforms,omitempty"`
+	// Position は路線図での駅の位置。どの駅にも無ければ描くときに自動で並べる（あるなら全駅に要る）。
+	// LabelAnchor を省けば駅名の札は駅の上に置く。
+	Position    *pointJSON `json:"position,omitempty"`
+	LabelAnchor *pointJSON `json:"labelAnchor,omitempty"`
 }
 
 type blockJSON struct {
-	ID            string `json:"id"`
-	FromStationID string `json:"fromStationId"`
-	ToStationID   string `json:"toStationId"`
+	ID               string  `json:"id"`
+	FromStationID    string  `json:"fromStationId"`
+	ToStationID      string  `json:"toStationId"`
+	TrackNumber      string  `json:"trackNumber,omitempty"`
+	Electrification  string  `json:"electrification,omitempty"`
+	GradientPermille float64 `json:"gradientPermille,omitempty"`
+	// Polyline は路線図での区間の線（両端は駅の位置）。省けば両側の駅を直線で結ぶ。
+	Polyline          []pointJSON `json:"polyline,omitempty"`
+	SignalLabelAnchor *pointJSON  `json:"signalLabelAnchor,omitempty"`
+}
+
+type pointJSON struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
 func (l *SimulationLineLoader) Load(ctx context.Context) (*domain.Line, error) {
 	_ = ctx
 
-	data, err := os.ReadFile(l.path)
-	if err != nil && strings.HasPrefix(l.path, "backend/") {
-		altPath := strings.TrimPrefix(l.path, "backend/")
-		data, err = os.ReadFile(altPath)
-	}
+	data, _, err := readFixture(l.path)
 	if err != nil {
 		return nil, fmt.Errorf("line fixture read failed: %w", err)
 	}
+	return parseLine(data)
+}
+
+// readFixture は path のファイルを読む。リポジトリのルートからの path（backend/...）は backend から起動したときにも読めるようにする。
+// 実際に読んだパスも返す（路線網の定義から路線のファイルを相対パスで引くため）。
+func readFixture(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && strings.HasPrefix(path, "backend/") {
+		altPath := strings.TrimPrefix(path, "backend/")
+		if altData, altErr := os.ReadFile(altPath); altErr == nil {
+			return altData, altPath, nil
+		}
+	}
+	return data, path, err
+}
 
+func parseLine(data []byte) (*domain.Line, error) {
 	var raw simulationLineJSON
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("line fixture parse failed: %w", err)
 	}
 
 	stations := make([]domain.StationID, 0, len(raw.Stations))
+	stationInfo := make([]domain.StationInfo, 0, len(raw.Stations))
 	for _, s := range raw.Stations {
 		id, err := domain.NewStationID(s.ID)
 		if err != nil {
 			return nil, err
 		}
 		stations = append(stations, id)
+		info := domain.StationInfo{
+			Name:       strings.TrimSpace(s.Name),
+			NameRomaji: strings.TrimSpace(s.NameRomaji),
+			Code:       strings.TrimSpace(s.Code),
+			Platforms:  s.Platforms,
+		}
+		if s.KilometerPost != nil {
+			info.KilometerPost, info.HasKilometerPost = *s.KilometerPost, true
+		}
+		stationInfo = append(stationInfo, info)
 	}
 
 	blocks := make([]domain.BlockID, 0, len(raw.Blocks))
+	blockInfo := make([]domain.BlockInfo, 0, len(raw.Blocks))
 	for _, b := range raw.Blocks {
 		id, err := domain.NewBlockID(b.ID)
 		if err != nil {
 			return nil, err
 		}
 		blocks = append(blocks, id)
+		blockInfo = append(blockInfo, domain.BlockInfo{
+			TrackNumber:      strings.TrimSpace(b.TrackNumber),
+			Electrification:  strings.TrimSpace(b.Electrification),
+			GradientPermille: b.GradientPermille,
+		})
 	}
 
-	line, err := domain.NewLine(stations, blocks)
+	line, err := domain.NewLineWithInfo(stations, blocks, stationInfo, blockInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -91,5 +141,57 @@ func (l *SimulationLineLoader) Load(ctx context.Context) (*domain.Line, error) {
 		}
 	}
 
-	return line, nil
+	return withFixtureLayout(line, raw)
+}
+
+// withFixtureLayout は定義に路線図での配置があれば路線に持たせる
+func withFixtureLayout(line *domain.Line, raw simulationLineJSON) (*domain.Line, error) {
+	positioned := 0
+	for _, s := range raw.Stations {
+		if s.Position != nil {
+			positioned++
+		}
+	}
+	if positioned == 0 {
+		for _, b := range raw.Blocks {
+			if len(b.Polyline) > 0 || b.SignalLabelAnchor != nil {
+				return nil, fmt.Errorf("%w: block %s has a layout but the stations have no position", domain.ErrLineLayoutMismatch, b.ID)
+			}
+		}
+		return line, nil
+	}
+	if positioned != len(raw.Stations) {
+		return nil, fmt.Errorf("%w: every station needs a position", domain.ErrLineLayoutMismatch)
+	}
+
+	layout := domain.LineLayout{
+		Stations: make([]domain.StationLayout, 0, len(raw.Stations)),
+		Blocks:   make([]domain.BlockLayout, 0, len(raw.Blocks)),
+	}
+	for _, s := range raw.Stations {
+		station := domain.NewStationLayout(s.Position.point())
+		if s.LabelAnchor != nil {
+			station.Label = s.LabelAnchor.point()
+		}
+		layout.Stations = append(layout.Stations, station)
+	}
+	for i, b := range raw.Blocks {
+		polyline := []domain.Point{layout.Stations[i].Position, layout.Stations[i+1].Position}
+		if len(b.Polyline) > 0 {
+			polyline = make([]domain.Point, 0, len(b.Polyline))
+			for _, p := range b.Polyline {
+				polyline = append(polyline, p.point())
+			}
+		}
+		block := domain.NewBlockLayout(polyline...)
+		if b.SignalLabelAnchor != nil {
+			block.SignalLabel = b.SignalLabelAnchor.point()
+		}
+		layout.Blocks = append(layout.Blocks, block)
+	}
+	return line.WithLayout(layout)
+}
+
+func (p pointJSON) point() domain.Point {
+	return domain.Point{X: p.X, Y: p.Y}
 }
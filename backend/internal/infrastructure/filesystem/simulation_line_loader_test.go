@@ -2,9 +2,12 @@ package filesystem
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
 )
 
 func TestSimulationLineLoaderLoadValidJSON(t *testing.T) {
@@ -28,6 +31,90 @@ func TestSimulationLineLoaderLoadValidJSON(t *testing.T) {
 	if len(line.Blocks()) != 2 {
 		t.Fatalf("expected 2 blocks, got %d", len(line.Blocks()))
 	}
+	// id だけの形式なら案内用の情報は空
+	if info, _ := line.StationInfo(line.Stations()[0]); info != (domain.StationInfo{}) {
+		t.Fatalf("expected no station info, got %+v", info)
+	}
+}
+
+func TestSimulationLineLoaderLoadsMetadata(t *testing.T) {
+	path := writeFixture(t, `{
+  "stations":[
+    {"id":"S0","name":"東京","nameRomaji":"Tokyo","code":"T01","kilometerPost":0,"platforms":4},
+    {"id":"S1"}
+  ],
+  "blocks":[
+    {"id":"B0","fromStationId":"S0","toStationId":"S1","trackNumber":"上り1","electrification":"DC1500V","gradientPermille":-2.5}
+  ]
+}`)
+
+	line, err := NewSimulationLineLoader(path).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	// 0 km と書いた起点はキロ程が分かる駅で、省いた駅は不明のまま
+	want := domain.StationInfo{Name: "東京", NameRomaji: "Tokyo", Code: "T01", HasKilometerPost: true, Platforms: 4}
+	if got := line.StationInfos(); got[0] != want || got[1] != (domain.StationInfo{}) {
+		t.Fatalf("unexpected station info: %+v", got)
+	}
+	wantBlock := domain.BlockInfo{TrackNumber: "上り1", Electrification: "DC1500V", GradientPermille: -2.5}
+	if got := line.BlockInfos(); got[0] != wantBlock {
+		t.Fatalf("unexpected block info: %+v", got)
+	}
+}
+
+func TestSimulationLineLoaderRejectsNegativePlatforms(t *testing.T) {
+	path := writeFixture(t, `{
+  "stations":[{"id":"S0","platforms":-1},{"id":"S1"}],
+  "blocks":[{"id":"B0","fromStationId":"S0","toStationId":"S1"}]
+}`)
+
+	if _, err := NewSimulationLineLoader(path).Load(context.Background()); !errors.Is(err, domain.ErrStationPlatformsNegative) {
+		t.Fatalf("expected %v, got %v", domain.ErrStationPlatformsNegative, err)
+	}
+}
+
+func TestSimulationLineLoaderLoadsLayout(t *testing.T) {
+	path := writeFixture(t, `{
+  "stations":[
+    {"id":"S0","position":{"x":0,"y":0}},
+    {"id":"S1","position":{"x":100,"y":40},"labelAnchor":{"x":100,"y":60}}
+  ],
+  "blocks":[
+    {"id":"B0","fromStationId":"S0","toStationId":"S1","polyline":[{"x":0,"y":0},{"x":60,"y":0},{"x":100,"y":40}]}
+  ]
+}`)
+
+	line, err := NewSimulationLineLoader(path).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	layout, ok := line.Layout()
+	if !ok {
+		t.Fatalf("expected the line to have a layout")
+	}
+	// 省いた札の位置は既定の置き方になる
+	if got := layout.Stations[0]; got != domain.NewStationLayout(domain.Point{}) {
+		t.Fatalf("unexpected S0 layout: %+v", got)
+	}
+	if got := layout.Stations[1].Label; got != (domain.Point{X: 100, Y: 60}) {
+		t.Fatalf("unexpected S1 label anchor: %+v", got)
+	}
+	if got := layout.Blocks[0].Polyline; len(got) != 3 || got[1] != (domain.Point{X: 60}) {
+		t.Fatalf("unexpected B0 polyline: %+v", got)
+	}
+}
+
+func TestSimulationLineLoaderRejectsPartialLayout(t *testing.T) {
+	path := writeFixture(t, `{
+  "stations":[{"id":"S0","position":{"x":0,"y":0}},{"id":"S1"}],
+  "blocks":[{"id":"B0","fromStationId":"S0","toStationId":"S1"}]
+}`)
+
+	if _, err := NewSimulationLineLoader(path).Load(context.Background()); !errors.Is(err, domain.ErrLineLayoutMismatch) {
+		t.Fatalf("expected %v, got %v", domain.ErrLineLayoutMismatch, err)
+	}
 }
 
 func TestSimulationLineLoaderLoadInvalidConnectivity(t *testing.T) {
@@ -0,0 +1,122 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// SimulationNetworkLoader は路線網の定義（マニフェスト）と、そこから参照する路線ごとのファイルを読み込む。
+// 路線1つのファイル（SimulationLineLoader と同じ形式）を指していれば、その路線だけの網として読む。
+// 路線のファイルが railML（.xml / .railml）なら RailMLImporter と同じく変換して読む（取り込めない要素は無視する）。
+type SimulationNetworkLoader struct {
+	path string
+}
+
+func NewSimulationNetworkLoader(path string) *SimulationNetworkLoader {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = DefaultSimulationLinePath
+	}
+	return &SimulationNetworkLoader{path: path}
+}
+
+// networkManifestJSON は路線網の定義。路線のファイルはマニフェストのあるディレクトリからの相対パス。
+type networkManifestJSON struct {
+	Lines     []networkLineJSON     `json:"lines"`
+	Transfers []networkTransferJSON `json:"transfers"`
+	// Stations は路線1つのファイルを見分けるためだけに読む
+	Stations []stationJSON `json:"stations"`
+}
+
+type networkLineJSON struct {
+	ID   string `json:"id"`
+	File string `json:"file"`
+}
+
+type networkTransferJSON struct {
+	FromStationID      string `json:"fromStationId"`
+	ToStationID        string `json:"toStationId"`
+	MinTransferSeconds int64  `json:"minTransferSeconds"`
+}
+
+func (l *SimulationNetworkLoader) Load(ctx context.Context) (*domain.Network, error) {
+	_ = ctx
+
+	data, path, err := readFixture(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("network manifest read failed: %w", err)
+	}
+	var raw networkManifestJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("network manifest parse failed: %w", err)
+	}
+
+	if len(raw.Lines) == 0 && len(raw.Stations) > 0 {
+		line, err := parseLine(data)
+		if err != nil {
+			return nil, err
+		}
+		return domain.NewSingleLineNetwork(line)
+	}
+
+	dir := filepath.Dir(path)
+	lines := make([]domain.NetworkLine, 0, len(raw.Lines))
+	for _, rawLine := range raw.Lines {
+		id, err := domain.NewLineID(rawLine.ID)
+		if err != nil {
+			return nil, err
+		}
+		file := strings.TrimSpace(rawLine.File)
+		if file == "" {
+			return nil, fmt.Errorf("network line %s has no file", id)
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		lineData, _, err := readFixture(file)
+		if err != nil {
+			return nil, fmt.Errorf("network line %s read failed: %w", id, err)
+		}
+		line, err := parseNetworkLine(file, lineData)
+		if err != nil {
+			return nil, fmt.Errorf("network line %s: %w", id, err)
+		}
+		lines = append(lines, domain.NetworkLine{ID: id, Line: line})
+	}
+
+	transfers := make([]domain.TransferLink, 0, len(raw.Transfers))
+	for _, rawTransfer := range raw.Transfers {
+		from, err := domain.NewStationID(rawTransfer.FromStationID)
+		if err != nil {
+			return nil, err
+		}
+		to, err := domain.NewStationID(rawTransfer.ToStationID)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, domain.TransferLink{
+			From:        from,
+			To:          to,
+			MinTransfer: time.Duration(rawTransfer.MinTransferSeconds) * time.Second,
+		})
+	}
+
+	return domain.NewNetwork(lines, transfers)
+}
+
+func parseNetworkLine(path string, data []byte) (*domain.Line, error) {
+	if !isRailMLFile(path) {
+		return parseLine(data)
+	}
+	imported, err := importRailML(data)
+	if err != nil {
+		return nil, err
+	}
+	return imported.Line, nil
+}
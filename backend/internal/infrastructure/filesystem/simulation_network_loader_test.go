@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testNetworkManifestPath = "../../domain/simulation/fixtures/network/network.json"
+
+func TestSimulationNetworkLoaderLoadsManifest(t *testing.T) {
+	network, err := NewSimulationNetworkLoader(testNetworkManifestPath).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	lines := network.Lines()
+	if len(lines) != 2 || lines[0].ID.String() != "RED" || lines[1].ID.String() != "BLUE" {
+		t.Fatalf("unexpected lines: %+v", lines)
+	}
+	if interchanges := network.Interchanges(); len(interchanges) != 1 || interchanges[0].String() != "X1" {
+		t.Fatalf("expected X1 to be the interchange, got %v", interchanges)
+	}
+	transfers := network.Transfers()
+	if len(transfers) != 1 || transfers[0].From.String() != "R0" || transfers[0].MinTransfer != 3*time.Minute {
+		t.Fatalf("unexpected transfers: %+v", transfers)
+	}
+}
+
+func TestSimulationNetworkLoaderLoadsSingleLineFile(t *testing.T) {
+	path := writeFixture(t, `{
+  "stations":[{"id":"S0"},{"id":"S1"}],
+  "blocks":[{"id":"B0","fromStationId":"S0","toStationId":"S1"}]
+}`)
+
+	network, err := NewSimulationNetworkLoader(path).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if lines := network.Lines(); len(lines) != 1 || len(network.Blocks()) != 1 {
+		t.Fatalf("expected a single line network, got %+v", lines)
+	}
+}
+
+func TestSimulationNetworkLoaderRejectsMissingLineFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "network.json")
+	manifest := `{"lines":[{"id":"RED","file":"red.json"}]}`
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest failed: %v", err)
+	}
+
+	if _, err := NewSimulationNetworkLoader(path).Load(context.Background()); err == nil {
+		t.Fatalf("expected missing line file error")
+	}
+}
+
+func TestSimulationNetworkLoaderImportsRailMLLine(t *testing.T) {
+	railML, err := filepath.Abs(filepath.Join("testdata", "railml3_line.xml"))
+	if err != nil {
+		t.Fatalf("abs failed: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "network.json")
+	manifest := `{"lines":[{"id":"AKA","file":` + strconv.Quote(railML) + `}]}`
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest failed: %v", err)
+	}
+
+	network, err := NewSimulationNetworkLoader(path).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if blocks := network.Blocks(); len(blocks) != 2 || blocks[0].String() != "sigA" {
+		t.Fatalf("expected the railML line to be converted, got %v", blocks)
+	}
+}
@@ -0,0 +1,409 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// FileSimulationRepository は SimulationState をシミュレーションごとのJSONファイルとして保持するRepository実装。
+// ファイルは <dir>/<base64url(シミュレーションID)>.json に置く。
+// 作成・保存・削除はプロセス内では mu、同じディレクトリを共有する別プロセスとは <集約>.lock のファイルロックで直列にする。
+type FileSimulationRepository struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFileSimulationRepository(dir string) domain.Repository {
+	return &FileSimulationRepository{dir: dir}
+}
+
+type simulationFileJSON struct {
+	SchemaVersion int `json:"schemaVersion"`
+	stateJSON
+}
+
+// stateJSON は StateSnapshot のJSON表現（入力ログからも使う）
+type stateJSON struct {
+	ID       string   `json:"id"`
+	Version  int64    `json:"version"`
+	Stations []string `json:"stations"`
+	Blocks   []string `json:"blocks"`
+	// StationInfo と BlockInfo は案内用の情報があるときだけ持つ（Stations / Blocks と同じ並び）
+	StationInfo []stationInfoFileJSON `json:"stationInfo,omitempty"`
+	BlockInfo   []blockInfoFileJSON   `json:"blockInfo,omitempty"`
+	// Layout は路線図での配置があるときだけ持つ
+	Layout *layoutFileJSON `json:"layout,omitempty"`
+	// Lines と Transfers は複数の路線の網のときだけ持つ（路線1つなら Stations と Blocks）
+	Lines         []lineFileJSON     `json:"lines,omitempty"`
+	Transfers     []transferFileJSON `json:"transfers,omitempty"`
+	SimTimeMillis int64              `json:"simTimeMillis"`
+	Trains        []trainFileJSON    `json:"trains"`
+	// Scheduled はまだ網に入っていない列車があるときだけ持つ
+	Scheduled     []scheduledTrainFileJSON `json:"scheduled,omitempty"`
+	PriorityRules []string                 `json:"priorityRules,omitempty"`
+}
+
+type lineFileJSON struct {
+	ID          string                `json:"id"`
+	Stations    []string              `json:"stations"`
+	Blocks      []string              `json:"blocks"`
+	StationInfo []stationInfoFileJSON `json:"stationInfo,omitempty"`
+	BlockInfo   []blockInfoFileJSON   `json:"blockInfo,omitempty"`
+	Layout      *layoutFileJSON       `json:"layout,omitempty"`
+}
+
+type layoutFileJSON struct {
+	Stations []stationLayoutFileJSON `json:"stations"`
+	Blocks   []blockLayoutFileJSON   `json:"blocks"`
+}
+
+type stationLayoutFileJSON struct {
+	Position pointJSON `json:"position"`
+	Label    pointJSON `json:"label"`
+}
+
+type blockLayoutFileJSON struct {
+	Polyline    []pointJSON `json:"polyline"`
+	SignalLabel pointJSON   `json:"signalLabel"`
+}
+
+type stationInfoFileJSON struct {
+	Name          string   `json:"name,omitempty"`
+	NameRomaji    string   `json:"nameRomaji,omitempty"`
+	Code          string   `json:"code,omitempty"`
+	KilometerPost *float64 `json:"kilometerPost,omitempty"`
+	Platforms     int      `json:"platforms,omitempty"`
+}
+
+type blockInfoFileJSON struct {
+	TrackNumber      string  `json:"trackNumber,omitempty"`
+	Electrification  string  `json:"electrification,omitempty"`
+	GradientPermille float64 `json:"gradientPermille,omitempty"`
+}
+
+type transferFileJSON struct {
+	From              string `json:"from"`
+	To                string `json:"to"`
+	MinTransferMillis int64  `json:"minTransferMillis"`
+}
+
+type trainFileJSON struct {
+	ID              string  `json:"id"`
+	BlockID         string  `json:"blockId"`
+	Progress        float64 `json:"progress"`
+	Forward         bool    `json:"forward"`
+	Speed           float64 `json:"speed"`
+	PendingTurnback bool    `json:"pendingTurnback"`
+}
+
+type scheduledTrainFileJSON struct {
+	trainFileJSON
+	AtMillis int64 `json:"atMillis"`
+}
+
+func (r *FileSimulationRepository) Get(ctx context.Context, id domain.SimulationID) (*domain.SimulationState, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.read(r.path(id.String()))
+}
+
+func (r *FileSimulationRepository) Create(ctx context.Context, state *domain.SimulationState) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(state.ID().String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := fileExists(path)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrSimulationAlreadyExists
+	}
+	if err := r.write(path, state); err != nil {
+		return err
+	}
+	state.IncrementVersion()
+	return nil
+}
+
+func (r *FileSimulationRepository) Save(ctx context.Context, state *domain.SimulationState) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(state.ID().String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := r.read(path)
+	if err != nil {
+		return err
+	}
+	if stored.Version() != state.Version() {
+		return domain.ErrVersionConflict
+	}
+	if err := r.write(path, state); err != nil {
+		return err
+	}
+	state.IncrementVersion()
+	return nil
+}
+
+func (r *FileSimulationRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(id.String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return domain.ErrSimulationNotFound
+		}
+		return fmt.Errorf("simulation file remove failed: %w", err)
+	}
+	return nil
+}
+
+func (r *FileSimulationRepository) path(id string) string {
+	return filepath.Join(r.dir, aggregateFileName(id))
+}
+
+func (r *FileSimulationRepository) read(path string) (*domain.SimulationState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, domain.ErrSimulationNotFound
+		}
+		return nil, fmt.Errorf("simulation file read failed: %w", err)
+	}
+
+	var raw simulationFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("simulation file parse failed: %w", err)
+	}
+	if raw.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("%w: simulation file version %d", ErrUnsupportedSchemaVersion, raw.SchemaVersion)
+	}
+
+	return domain.RestoreSimulationState(raw.stateJSON.toSnapshot())
+}
+
+// write は保存後のバージョン（現在+1）でファイルを書き出す。集約側のバージョンは呼び出し元が進める。
+func (r *FileSimulationRepository) write(path string, state *domain.SimulationState) error {
+	snap := state.Snapshot()
+	snap.Version++
+
+	data, err := json.MarshalIndent(simulationFileJSON{
+		SchemaVersion: schemaVersion,
+		stateJSON:     newStateJSON(snap),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("simulation encode failed: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func newStateJSON(snap domain.StateSnapshot) stateJSON {
+	trains := make([]trainFileJSON, 0, len(snap.Trains))
+	for _, t := range snap.Trains {
+		trains = append(trains, trainFileJSON(t))
+	}
+	var scheduled []scheduledTrainFileJSON
+	for _, t := range snap.Scheduled {
+		scheduled = append(scheduled, scheduledTrainFileJSON{trainFileJSON: trainFileJSON(t.TrainSnapshot), AtMillis: t.AtMillis})
+	}
+	var lines []lineFileJSON
+	for _, l := range snap.Lines {
+		lines = append(lines, lineFileJSON{
+			ID:          l.ID,
+			Stations:    l.Stations,
+			Blocks:      l.Blocks,
+			StationInfo: newStationInfoFileJSON(l.StationInfo),
+			BlockInfo:   newBlockInfoFileJSON(l.BlockInfo),
+			Layout:      newLayoutFileJSON(l.Layout),
+		})
+	}
+	var transfers []transferFileJSON
+	for _, t := range snap.Transfers {
+		transfers = append(transfers, transferFileJSON{From: t.From, To: t.To, MinTransferMillis: t.MinTransferMillis})
+	}
+	return stateJSON{
+		ID:            snap.ID,
+		Version:       snap.Version,
+		Stations:      snap.Stations,
+		Blocks:        snap.Blocks,
+		StationInfo:   newStationInfoFileJSON(snap.StationInfo),
+		BlockInfo:     newBlockInfoFileJSON(snap.BlockInfo),
+		Layout:        newLayoutFileJSON(snap.Layout),
+		Lines:         lines,
+		Transfers:     transfers,
+		SimTimeMillis: snap.SimTimeMillis,
+		Trains:        trains,
+		Scheduled:     scheduled,
+		PriorityRules: snap.PriorityRules,
+	}
+}
+
+func (raw stateJSON) toSnapshot() domain.StateSnapshot {
+	trains := make([]domain.TrainSnapshot, 0, len(raw.Trains))
+	for _, t := range raw.Trains {
+		trains = append(trains, domain.TrainSnapshot(t))
+	}
+	var scheduled []domain.ScheduledTrainSnapshot
+	for _, t := range raw.Scheduled {
+		scheduled = append(scheduled, domain.ScheduledTrainSnapshot{TrainSnapshot: domain.TrainSnapshot(t.trainFileJSON), AtMillis: t.AtMillis})
+	}
+	var lines []domain.LineSnapshot
+	for _, l := range raw.Lines {
+		lines = append(lines, domain.LineSnapshot{
+			ID:          l.ID,
+			Stations:    l.Stations,
+			Blocks:      l.Blocks,
+			StationInfo: toStationInfos(l.StationInfo),
+			BlockInfo:   toBlockInfos(l.BlockInfo),
+			Layout:      l.Layout.toLayout(),
+		})
+	}
+	var transfers []domain.TransferSnapshot
+	for _, t := range raw.Transfers {
+		transfers = append(transfers, domain.TransferSnapshot{From: t.From, To: t.To, MinTransferMillis: t.MinTransferMillis})
+	}
+	return domain.StateSnapshot{
+		ID:            raw.ID,
+		Version:       raw.Version,
+		Stations:      raw.Stations,
+		Blocks:        raw.Blocks,
+		StationInfo:   toStationInfos(raw.StationInfo),
+		BlockInfo:     toBlockInfos(raw.BlockInfo),
+		Layout:        raw.Layout.toLayout(),
+		Lines:         lines,
+		Transfers:     transfers,
+		SimTimeMillis: raw.SimTimeMillis,
+		Trains:        trains,
+		Scheduled:     scheduled,
+		PriorityRules: raw.PriorityRules,
+	}
+}
+
+func newStationInfoFileJSON(infos []domain.StationInfo) []stationInfoFileJSON {
+	if infos == nil {
+		return nil
+	}
+	out := make([]stationInfoFileJSON, 0, len(infos))
+	for _, info := range infos {
+		raw := stationInfoFileJSON{Name: info.Name, NameRomaji: info.NameRomaji, Code: info.Code, Platforms: info.Platforms}
+		if info.HasKilometerPost {
+			kilometerPost := info.KilometerPost
+			raw.KilometerPost = &kilometerPost
+		}
+		out = append(out, raw)
+	}
+	return out
+}
+
+func newBlockInfoFileJSON(infos []domain.BlockInfo) []blockInfoFileJSON {
+	if infos == nil {
+		return nil
+	}
+	out := make([]blockInfoFileJSON, 0, len(infos))
+	for _, info := range infos {
+		out = append(out, blockInfoFileJSON(info))
+	}
+	return out
+}
+
+func toStationInfos(raw []stationInfoFileJSON) []domain.StationInfo {
+	if raw == nil {
+		return nil
+	}
+	out := make([]domain.StationInfo, 0, len(raw))
+	for _, r := range raw {
+		info := domain.StationInfo{Name: r.Name, NameRomaji: r.NameRomaji, Code: r.Code, Platforms: r.Platforms}
+		if r.KilometerPost != nil {
+			info.KilometerPost, info.HasKilometerPost = *r.KilometerPost, true
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+func toBlockInfos(raw []blockInfoFileJSON) []domain.BlockInfo {
+	if raw == nil {
+		return nil
+	}
+	out := make([]domain.BlockInfo, 0, len(raw))
+	for _, info := range raw {
+		out = append(out, domain.BlockInfo(info))
+	}
+	return out
+}
+
+func newLayoutFileJSON(layout *domain.LineLayout) *layoutFileJSON {
+	if layout == nil {
+		return nil
+	}
+	out := &layoutFileJSON{
+		Stations: make([]stationLayoutFileJSON, 0, len(layout.Stations)),
+		Blocks:   make([]blockLayoutFileJSON, 0, len(layout.Blocks)),
+	}
+	for _, station := range layout.Stations {
+		out.Stations = append(out.Stations, stationLayoutFileJSON{Position: pointJSON(station.Position), Label: pointJSON(station.Label)})
+	}
+	for _, block := range layout.Blocks {
+		polyline := make([]pointJSON, 0, len(block.Polyline))
+		for _, p := range block.Polyline {
+			polyline = append(polyline, pointJSON(p))
+		}
+		out.Blocks = append(out.Blocks, blockLayoutFileJSON{Polyline: polyline, SignalLabel: pointJSON(block.SignalLabel)})
+	}
+	return out
+}
+
+func (raw *layoutFileJSON) toLayout() *domain.LineLayout {
+	if raw == nil {
+		return nil
+	}
+	out := &domain.LineLayout{
+		Stations: make([]domain.StationLayout, 0, len(raw.Stations)),
+		Blocks:   make([]domain.BlockLayout, 0, len(raw.Blocks)),
+	}
+	for _, station := range raw.Stations {
+		out.Stations = append(out.Stations, domain.StationLayout{Position: station.Position.point(), Label: station.Label.point()})
+	}
+	for _, block := range raw.Blocks {
+		polyline := make([]domain.Point, 0, len(block.Polyline))
+		for _, p := range block.Polyline {
+			polyline = append(polyline, p.point())
+		}
+		out.Blocks = append(out.Blocks, domain.BlockLayout{Polyline: polyline, SignalLabel: block.SignalLabel.point()})
+	}
+	return out
+}
@@ -0,0 +1,326 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+func TestFileSimulationRepositoryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := NewFileSimulationRepository(dir)
+
+	state := newTestSimulationState(t, "room-a")
+	if err := repo.Save(ctx, state); !errors.Is(err, domain.ErrSimulationNotFound) {
+		t.Fatalf("expected ErrSimulationNotFound before create, got %v", err)
+	}
+	if err := repo.Create(ctx, state); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	delta, _ := domain.NewTickDelta(1500 * time.Millisecond)
+	if err := state.Tick(delta); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	if err := repo.Save(ctx, state); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	got, err := NewFileSimulationRepository(dir).Get(ctx, state.ID())
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.SimTime().Millis() != 1500 {
+		t.Fatalf("expected sim time 1500, got %d", got.SimTime().Millis())
+	}
+	train := got.Trains()[0]
+	if train.BlockID().String() != "B0" || train.Progress().Float64() != 0.75 {
+		t.Fatalf("unexpected train position: %s %f", train.BlockID().String(), train.Progress().Float64())
+	}
+
+	// 一時ファイルが残っていないこと（集約ファイルとそのロックファイルだけ）
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected the aggregate and its lock file, got %d entries", len(entries))
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			t.Fatalf("unexpected temp file %s", entry.Name())
+		}
+	}
+}
+
+func TestFileSimulationRepositoryDetectsStaleWriterFromAnotherInstance(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := NewFileSimulationRepository(dir).Create(ctx, newTestSimulationState(t, "room-a")); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	id, _ := domain.NewSimulationID("room-a")
+
+	// 同じディレクトリを使う別々のインスタンス（別プロセス相当）が同じ版から同時に保存しても、通るのは1つだけ
+	const writers = 8
+	for round := 0; round < 20; round++ {
+		states := make([]*domain.SimulationState, writers)
+		repos := make([]domain.Repository, writers)
+		for i := range writers {
+			repos[i] = NewFileSimulationRepository(dir)
+			state, err := repos[i].Get(ctx, id)
+			if err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			states[i] = state
+		}
+
+		errs := make(chan error, writers)
+		var start sync.WaitGroup
+		start.Add(1)
+		for i := range writers {
+			go func() {
+				start.Wait()
+				errs <- repos[i].Save(ctx, states[i])
+			}()
+		}
+		start.Done()
+
+		saved := 0
+		for range writers {
+			err := <-errs
+			switch {
+			case err == nil:
+				saved++
+			case !errors.Is(err, domain.ErrVersionConflict):
+				t.Fatalf("expected ErrVersionConflict, got %v", err)
+			}
+		}
+		if saved != 1 {
+			t.Fatalf("round %d: expected exactly one save to win, got %d", round, saved)
+		}
+	}
+}
+
+func TestFileSimulationRepositoryKeepsNetwork(t *testing.T) {
+	ctx := context.Background()
+	repo := NewFileSimulationRepository(t.TempDir())
+
+	network, err := NewSimulationNetworkLoader(testNetworkManifestPath).Load(ctx)
+	if err != nil {
+		t.Fatalf("load network failed: %v", err)
+	}
+	id, _ := domain.NewSimulationID("room-a")
+	state, err := domain.NewNetworkSimulationState(id, network)
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+	if err := repo.Create(ctx, state); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	got, err := repo.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if diffs := domain.DiffSnapshots(state.Snapshot(), got.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("expected the network to survive a round trip, got %v", diffs)
+	}
+	if len(got.Network().Lines()) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(got.Network().Lines()))
+	}
+	r0, _ := domain.NewStationID("R0")
+	if info, _ := got.Network().StationInfo(r0); info.Name != "赤坂" || info.Platforms != 2 {
+		t.Fatalf("expected station info to survive a round trip, got %+v", info)
+	}
+}
+
+func newTestSimulationState(t *testing.T, id string) *domain.SimulationState {
+	t.Helper()
+
+	s0, _ := domain.NewStationID("S0")
+	s1, _ := domain.NewStationID("S1")
+	s2, _ := domain.NewStationID("S2")
+	b0, _ := domain.NewBlockID("B0")
+	b1, _ := domain.NewBlockID("B1")
+	line, err := domain.NewLine([]domain.StationID{s0, s1, s2}, []domain.BlockID{b0, b1})
+	if err != nil {
+		t.Fatalf("line build failed: %v", err)
+	}
+
+	simID, _ := domain.NewSimulationID(id)
+	state, err := domain.NewSimulationState(simID, line)
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+
+	trainID, _ := domain.NewTrainID("T0")
+	progress, _ := domain.NewBlockProgress(0)
+	train, err := domain.NewTrain(trainID, b0, progress, true, 0.5)
+	if err != nil {
+		t.Fatalf("new train failed: %v", err)
+	}
+	if err := state.AddTrain(train); err != nil {
+		t.Fatalf("add train failed: %v", err)
+	}
+	return state
+}
+
+func TestFileInputLogRepositoryIgnoresTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := NewFileInputLogRepository(dir)
+
+	state := newTestSimulationState(t, "room-a")
+	if _, err := repo.Append(ctx, state.ID(), domain.NewInitializedInput(state, time.Now())); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+
+	// 書き込み途中でプロセスが落ちた状態を再現する
+	path := filepath.Join(dir, aggregateBaseName(state.ID().String())+".jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open log failed: %v", err)
+	}
+	_, _ = f.WriteString(`{"schemaVersion":1,"seq":2,"ki`)
+	_ = f.Close()
+
+	reopened := NewFileInputLogRepository(dir)
+	delta, _ := domain.NewTickDelta(time.Second)
+	rec, err := reopened.Append(ctx, state.ID(), domain.NewTickInput(state.SimTime(), delta, domain.EngineEvent, state, time.Now()))
+	if err != nil {
+		t.Fatalf("append after torn tail failed: %v", err)
+	}
+	if rec.Seq != 2 {
+		t.Fatalf("expected seq 2, got %d", rec.Seq)
+	}
+
+	records, err := reopened.List(ctx, state.ID())
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(records) != 2 || records[0].State == nil || records[1].Kind != domain.InputTick || records[1].Engine != domain.EngineEvent {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestFileInputLogRepositoryTruncatesAfterSeq(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := NewFileInputLogRepository(dir)
+
+	state := newTestSimulationState(t, "room-a")
+	delta, _ := domain.NewTickDelta(time.Second)
+	for _, record := range []domain.InputRecord{
+		domain.NewInitializedInput(state, time.Now()),
+		domain.NewTickInput(state.SimTime(), delta, domain.EngineTick, state, time.Now()),
+		domain.NewCheckpointInput(state, time.Now()),
+	} {
+		if _, err := repo.Append(ctx, state.ID(), record); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	if err := repo.TruncateAfter(ctx, state.ID(), 1); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+	// 取り消した後の追記は続きの連番になる
+	rec, err := repo.Append(ctx, state.ID(), domain.NewTickInput(state.SimTime(), delta, domain.EngineTick, state, time.Now()))
+	if err != nil {
+		t.Fatalf("append after truncate failed: %v", err)
+	}
+	if rec.Seq != 2 {
+		t.Fatalf("expected seq 2, got %d", rec.Seq)
+	}
+	records, err := NewFileInputLogRepository(dir).List(ctx, state.ID())
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Kind != domain.InputInitialized || records[1].Kind != domain.InputTick {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestFileTimetableRepositoryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	id, _ := domain.NewSimulationID("room-a")
+
+	if got, err := NewFileTimetableRepository(dir).Get(ctx, id); err != nil || got != nil {
+		t.Fatalf("expected no timetable yet, got %+v %v", got, err)
+	}
+
+	serviceID, _ := domain.NewServiceID("101")
+	trainID, _ := domain.NewTrainID("T0")
+	s0, _ := domain.NewStationID("S0")
+	s1, _ := domain.NewStationID("S1")
+	depart, _ := domain.NewSimTime(0)
+	arrive, _ := domain.NewSimTime((2 * time.Minute).Milliseconds())
+	leave, _ := domain.NewSimTime((2*time.Minute + 30*time.Second).Milliseconds())
+	timetable, err := domain.NewTimetable([]domain.Service{{ID: serviceID, Train: trainID, Stops: []domain.ServiceStop{
+		{Station: s0, Arrival: depart, Departure: depart},
+		{Station: s1, Arrival: arrive, Departure: leave},
+	}}})
+	if err != nil {
+		t.Fatalf("timetable failed: %v", err)
+	}
+	if err := NewFileTimetableRepository(dir).Save(ctx, id, timetable); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	// 別のインスタンス（再起動後）からも同じ計画ダイヤを読める
+	repo := NewFileTimetableRepository(dir)
+	got, err := repo.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	services := got.Services()
+	if len(services) != 1 || services[0].ID != serviceID || services[0].Train != trainID || len(services[0].Stops) != 2 || services[0].Stops[1].Departure != leave {
+		t.Fatalf("unexpected timetable: %+v", services)
+	}
+
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got, err := repo.Get(ctx, id); err != nil || got != nil {
+		t.Fatalf("expected the timetable to be deleted, got %+v %v", got, err)
+	}
+}
+
+func TestFileBreakpointRepositoryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	id, _ := domain.NewSimulationID("room-a")
+
+	breakpoints := []domain.Breakpoint{
+		{ID: "bp-1", Condition: domain.StopConditionSpec{Type: "TRAIN_BLOCKED_FOR", TrainID: "T0", DurationMillis: 30_000}, Enabled: true},
+		{ID: "bp-2", Condition: domain.StopConditionSpec{Type: "TRAINS_FACE_EACH_OTHER"}},
+	}
+	if err := NewFileBreakpointRepository(dir).Save(ctx, id, breakpoints); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	// 別のインスタンス（再起動後）からも登録順に読める
+	repo := NewFileBreakpointRepository(dir)
+	got, err := repo.List(ctx, id)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, breakpoints) {
+		t.Fatalf("unexpected breakpoints: %+v", got)
+	}
+
+	// 空の一覧を保存すれば定義を消す
+	if err := repo.Save(ctx, id, nil); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if got, err := repo.List(ctx, id); err != nil || len(got) != 0 {
+		t.Fatalf("expected no breakpoints, got %+v %v", got, err)
+	}
+}
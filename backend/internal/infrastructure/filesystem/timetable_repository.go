@@ -0,0 +1,157 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// FileTimetableRepository はシミュレーションごとの計画ダイヤをJSONファイルとして保持するRepository実装。
+// ファイルは <dir>/<base64url(シミュレーションID)>.json に置き、保存・削除は SimulationRepository と同じく直列にする。
+type FileTimetableRepository struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFileTimetableRepository(dir string) domain.TimetableRepository {
+	return &FileTimetableRepository{dir: dir}
+}
+
+type timetableFileJSON struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Services      []serviceFileJSON `json:"services"`
+}
+
+type serviceFileJSON struct {
+	ID    string                `json:"id"`
+	Train string                `json:"train,omitempty"`
+	Stops []serviceStopFileJSON `json:"stops"`
+}
+
+type serviceStopFileJSON struct {
+	Station         string `json:"station"`
+	ArrivalMillis   int64  `json:"arrivalMillis"`
+	DepartureMillis int64  `json:"departureMillis"`
+}
+
+func (r *FileTimetableRepository) Get(ctx context.Context, id domain.SimulationID) (*domain.Timetable, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.path(id.String()))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("timetable file read failed: %w", err)
+	}
+	var raw timetableFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("timetable file parse failed: %w", err)
+	}
+	if raw.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("%w: timetable file version %d", ErrUnsupportedSchemaVersion, raw.SchemaVersion)
+	}
+	return raw.toTimetable()
+}
+
+func (r *FileTimetableRepository) Save(ctx context.Context, id domain.SimulationID, timetable *domain.Timetable) error {
+	if timetable == nil {
+		return r.Delete(ctx, id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(id.String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := json.MarshalIndent(newTimetableFileJSON(timetable), "", "  ")
+	if err != nil {
+		return fmt.Errorf("timetable encode failed: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (r *FileTimetableRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(id.String())
+	unlock, err := lockAggregate(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("timetable file remove failed: %w", err)
+	}
+	return nil
+}
+
+func (r *FileTimetableRepository) path(id string) string {
+	return filepath.Join(r.dir, aggregateFileName(id))
+}
+
+func newTimetableFileJSON(timetable *domain.Timetable) timetableFileJSON {
+	raw := timetableFileJSON{SchemaVersion: schemaVersion, Services: []serviceFileJSON{}}
+	for _, service := range timetable.Services() {
+		stops := make([]serviceStopFileJSON, 0, len(service.Stops))
+		for _, stop := range service.Stops {
+			stops = append(stops, serviceStopFileJSON{
+				Station:         stop.Station.String(),
+				ArrivalMillis:   stop.Arrival.Millis(),
+				DepartureMillis: stop.Departure.Millis(),
+			})
+		}
+		raw.Services = append(raw.Services, serviceFileJSON{ID: service.ID.String(), Train: service.Train.String(), Stops: stops})
+	}
+	return raw
+}
+
+func (raw timetableFileJSON) toTimetable() (*domain.Timetable, error) {
+	services := make([]domain.Service, 0, len(raw.Services))
+	for _, s := range raw.Services {
+		id, err := domain.NewServiceID(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		service := domain.Service{ID: id, Stops: make([]domain.ServiceStop, 0, len(s.Stops))}
+		if s.Train != "" {
+			if service.Train, err = domain.NewTrainID(s.Train); err != nil {
+				return nil, err
+			}
+		}
+		for _, stop := range s.Stops {
+			station, err := domain.NewStationID(stop.Station)
+			if err != nil {
+				return nil, err
+			}
+			arrival, err := domain.NewSimTime(stop.ArrivalMillis)
+			if err != nil {
+				return nil, err
+			}
+			departure, err := domain.NewSimTime(stop.DepartureMillis)
+			if err != nil {
+				return nil, err
+			}
+			service.Stops = append(service.Stops, domain.ServiceStop{Station: station, Arrival: arrival, Departure: departure})
+		}
+		services = append(services, service)
+	}
+	return domain.NewTimetable(services)
+}
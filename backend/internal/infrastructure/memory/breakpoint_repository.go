@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// InMemoryBreakpointRepository はシミュレーションごとのブレークポイントの定義をメモリで保持する。
+// 呼び出し側と一覧を共有しないよう、保存・取得のたびに複製する。
+type InMemoryBreakpointRepository struct {
+	mu          sync.Mutex
+	breakpoints map[string][]domain.Breakpoint
+}
+
+func NewInMemoryBreakpointRepository() domain.BreakpointRepository {
+	return &InMemoryBreakpointRepository{
+		breakpoints: make(map[string][]domain.Breakpoint),
+	}
+}
+
+func (r *InMemoryBreakpointRepository) List(ctx context.Context, id domain.SimulationID) ([]domain.Breakpoint, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]domain.Breakpoint(nil), r.breakpoints[id.String()]...), nil
+}
+
+func (r *InMemoryBreakpointRepository) Save(ctx context.Context, id domain.SimulationID, breakpoints []domain.Breakpoint) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(breakpoints) == 0 {
+		delete(r.breakpoints, id.String())
+		return nil
+	}
+	r.breakpoints[id.String()] = append([]domain.Breakpoint(nil), breakpoints...)
+	return nil
+}
+
+func (r *InMemoryBreakpointRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.breakpoints, id.String())
+	return nil
+}
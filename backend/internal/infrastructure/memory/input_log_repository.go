@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// InMemoryInputLogRepository はシミュレーションごとの入力ログをメモリで保持する。
+type InMemoryInputLogRepository struct {
+	mu   sync.Mutex
+	logs map[string][]domain.InputRecord
+}
+
+func NewInMemoryInputLogRepository() domain.InputLogRepository {
+	return &InMemoryInputLogRepository{
+		logs: make(map[string][]domain.InputRecord),
+	}
+}
+
+func (r *InMemoryInputLogRepository) Append(ctx context.Context, id domain.SimulationID, record domain.InputRecord) (domain.InputRecord, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	record.Seq = int64(len(r.logs[key])) + 1
+	r.logs[key] = append(r.logs[key], record)
+	return record, nil
+}
+
+func (r *InMemoryInputLogRepository) List(ctx context.Context, id domain.SimulationID) ([]domain.InputRecord, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := r.logs[id.String()]
+	out := make([]domain.InputRecord, len(records))
+	copy(out, records)
+	return out, nil
+}
+
+func (r *InMemoryInputLogRepository) TruncateAfter(ctx context.Context, id domain.SimulationID, seq int64) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	if records := r.logs[key]; int64(len(records)) > seq {
+		r.logs[key] = records[:max(seq, 0)]
+	}
+	return nil
+}
+
+func (r *InMemoryInputLogRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.logs, id.String())
+	return nil
+}
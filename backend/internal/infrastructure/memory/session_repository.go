@@ -2,39 +2,61 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
-	"time"
 
 	domain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
 )
 
-// InMemorySessionRepository は単一 TrainingSession をメモリで保持するRepository実装。
+// InMemorySessionRepository は TrainingSession をセッションIDごとにメモリで保持するRepository実装。
+// 呼び出し側が取得した集約を変更しても Save するまで反映されないよう、スナップショットで保持する。
 type InMemorySessionRepository struct {
-	mu      sync.Mutex
-	session *domain.TrainingSession
+	mu       sync.Mutex
+	sessions map[string]domain.SessionSnapshot // keyは SessionID.String()
 }
 
-// InMemorySessionRepository はリポジトリを生成する。
+// NewInMemorySessionRepository はリポジトリを生成する。
 func NewInMemorySessionRepository() domain.Repository {
-	return &InMemorySessionRepository{}
+	return &InMemorySessionRepository{
+		sessions: make(map[string]domain.SessionSnapshot),
+	}
 }
 
-// Get はセッションを取得する。存在しなければ生成する。
-func (r *InMemorySessionRepository) Get(ctx context.Context) (*domain.TrainingSession, error) {
+// Get はセッションを取得する。存在しなければ ErrSessionNotFound を返す。
+func (r *InMemorySessionRepository) Get(ctx context.Context, id domain.SessionID) (*domain.TrainingSession, error) {
 	_ = ctx
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.session == nil {
-		// 単一セッションID（あとでルーム化するならここを差し替える）
-		id, err := domain.NewSessionID("default")
+	snap, ok := r.sessions[id.String()]
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return domain.RestoreTrainingSession(snap)
+}
+
+func (r *InMemorySessionRepository) List(ctx context.Context) ([]*domain.TrainingSession, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.sessions))
+	for key := range r.sessions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]*domain.TrainingSession, 0, len(keys))
+	for _, key := range keys {
+		session, err := domain.RestoreTrainingSession(r.sessions[key])
 		if err != nil {
 			return nil, err
 		}
-		r.session = domain.NewTrainingSession(id, time.Now())
+		out = append(out, session)
 	}
-	return r.session, nil
+	return out, nil
 }
 
 func (r *InMemorySessionRepository) Create(ctx context.Context, s *domain.TrainingSession) error {
@@ -43,19 +65,45 @@ func (r *InMemorySessionRepository) Create(ctx context.Context, s *domain.Traini
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.session != nil {
-		return domain.ErrSessionAlreadyExists // ←後述：エラーを追加する or CONFLICTにする
+	key := s.ID().String()
+	if _, exists := r.sessions[key]; exists {
+		return domain.ErrSessionAlreadyExists
 	}
-	r.session = s
+	s.IncrementVersion()
+	r.sessions[key] = s.Snapshot()
 	return nil
 }
 
+// Save はセッションを保存する。読み込み後に別の保存があった場合は ErrVersionConflict を返す。
 func (r *InMemorySessionRepository) Save(ctx context.Context, s *domain.TrainingSession) error {
 	_ = ctx
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.session = s
+	key := s.ID().String()
+	stored, exists := r.sessions[key]
+	if !exists {
+		return domain.ErrSessionNotFound
+	}
+	if stored.Version != s.Version() {
+		return domain.ErrVersionConflict
+	}
+	s.IncrementVersion()
+	r.sessions[key] = s.Snapshot()
+	return nil
+}
+
+func (r *InMemorySessionRepository) Delete(ctx context.Context, id domain.SessionID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	if _, exists := r.sessions[key]; !exists {
+		return domain.ErrSessionNotFound
+	}
+	delete(r.sessions, key)
 	return nil
 }
@@ -7,25 +7,29 @@ import (
 	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
 )
 
+// InMemorySimulationRepository は SimulationState をスナップショットとしてメモリで保持するRepository実装。
 type InMemorySimulationRepository struct {
-	mu    sync.Mutex
-	state *domain.SimulationState
+	mu     sync.Mutex
+	states map[string]domain.StateSnapshot
 }
 
 func NewInMemorySimulationRepository() domain.Repository {
-	return &InMemorySimulationRepository{}
+	return &InMemorySimulationRepository{
+		states: make(map[string]domain.StateSnapshot),
+	}
 }
 
-func (r *InMemorySimulationRepository) Get(ctx context.Context) (*domain.SimulationState, error) {
+func (r *InMemorySimulationRepository) Get(ctx context.Context, id domain.SimulationID) (*domain.SimulationState, error) {
 	_ = ctx
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.state == nil {
+	snap, ok := r.states[id.String()]
+	if !ok {
 		return nil, domain.ErrSimulationNotFound
 	}
-	return r.state, nil
+	return domain.RestoreSimulationState(snap)
 }
 
 func (r *InMemorySimulationRepository) Create(ctx context.Context, state *domain.SimulationState) error {
@@ -34,10 +38,12 @@ func (r *InMemorySimulationRepository) Create(ctx context.Context, state *domain
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.state != nil {
+	key := state.ID().String()
+	if _, exists := r.states[key]; exists {
 		return domain.ErrSimulationAlreadyExists
 	}
-	r.state = state
+	state.IncrementVersion()
+	r.states[key] = state.Snapshot()
 	return nil
 }
 
@@ -47,9 +53,29 @@ func (r *InMemorySimulationRepository) Save(ctx context.Context, state *domain.S
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.state == nil {
+	key := state.ID().String()
+	stored, exists := r.states[key]
+	if !exists {
+		return domain.ErrSimulationNotFound
+	}
+	if stored.Version != state.Version() {
+		return domain.ErrVersionConflict
+	}
+	state.IncrementVersion()
+	r.states[key] = state.Snapshot()
+	return nil
+}
+
+func (r *InMemorySimulationRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id.String()
+	if _, exists := r.states[key]; !exists {
 		return domain.ErrSimulationNotFound
 	}
-	r.state = state
+	delete(r.states, key)
 	return nil
 }
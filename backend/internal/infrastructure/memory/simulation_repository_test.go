@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+	"github.com/right1121/railway-control-center-simulator/pkg/apperr"
+)
+
+func TestInMemorySimulationRepositoryRejectsStaleSave(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemorySimulationRepository()
+
+	state := newTestSimulationState(t)
+	if err := repo.Create(ctx, state); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	first, _ := repo.Get(ctx, state.ID())
+	second, _ := repo.Get(ctx, state.ID())
+
+	delta, _ := domain.NewTickDelta(time.Second)
+	_ = first.Tick(delta)
+	_ = second.Tick(delta)
+
+	if err := repo.Save(ctx, first); err != nil {
+		t.Fatalf("first save failed: %v", err)
+	}
+	if first.Version() != 2 {
+		t.Fatalf("expected version 2 after save, got %d", first.Version())
+	}
+
+	err := repo.Save(ctx, second)
+	if !errors.Is(err, domain.ErrVersionConflict) || !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("expected version conflict, got %v", err)
+	}
+}
+
+func TestInMemorySimulationRepositoryIsolatesUnsavedChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemorySimulationRepository()
+
+	state := newTestSimulationState(t)
+	if err := repo.Create(ctx, state); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	delta, _ := domain.NewTickDelta(time.Second)
+	_ = state.Tick(delta)
+
+	got, _ := repo.Get(ctx, state.ID())
+	if got.SimTime().Millis() != 0 {
+		t.Fatalf("expected unsaved tick to be invisible, got sim time %d", got.SimTime().Millis())
+	}
+}
+
+func newTestSimulationState(t *testing.T) *domain.SimulationState {
+	t.Helper()
+
+	s0, _ := domain.NewStationID("S0")
+	s1, _ := domain.NewStationID("S1")
+	b0, _ := domain.NewBlockID("B0")
+	line, err := domain.NewLine([]domain.StationID{s0, s1}, []domain.BlockID{b0})
+	if err != nil {
+		t.Fatalf("line build failed: %v", err)
+	}
+	id, _ := domain.NewSimulationID("room-a")
+	state, err := domain.NewSimulationState(id, line)
+	if err != nil {
+		t.Fatalf("new state failed: %v", err)
+	}
+	return state
+}
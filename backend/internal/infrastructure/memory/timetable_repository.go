@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
+)
+
+// InMemoryTimetableRepository はシミュレーションごとの計画ダイヤをメモリで保持する（時刻表は変更されないので共有する）。
+type InMemoryTimetableRepository struct {
+	mu         sync.Mutex
+	timetables map[string]*domain.Timetable
+}
+
+func NewInMemoryTimetableRepository() domain.TimetableRepository {
+	return &InMemoryTimetableRepository{
+		timetables: make(map[string]*domain.Timetable),
+	}
+}
+
+func (r *InMemoryTimetableRepository) Get(ctx context.Context, id domain.SimulationID) (*domain.Timetable, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.timetables[id.String()], nil
+}
+
+func (r *InMemoryTimetableRepository) Save(ctx context.Context, id domain.SimulationID, timetable *domain.Timetable) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timetable == nil {
+		delete(r.timetables, id.String())
+		return nil
+	}
+	r.timetables[id.String()] = timetable
+	return nil
+}
+
+func (r *InMemoryTimetableRepository) Delete(ctx context.Context, id domain.SimulationID) error {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.timetables, id.String())
+	return nil
+}
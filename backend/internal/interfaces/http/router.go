@@ -40,11 +40,37 @@ func setup(mux *http.ServeMux, cfg *config.Config, container *di.Container) *htt
 	mux.Handle("GET /health", http.HandlerFunc(h.HealthCheck))
 
 	// セッション
-	mux.Handle("GET /api/v1/session", http.HandlerFunc(h.sessionHandler.Get))
-	mux.Handle("POST /api/v1/session/join", http.HandlerFunc(h.sessionHandler.Join))
-	mux.Handle("POST /api/v1/session/leave", http.HandlerFunc(h.sessionHandler.Leave))
-	mux.Handle("GET /api/v1/simulation", http.HandlerFunc(h.simulationHandler.Get))
-	mux.Handle("POST /api/v1/simulation/tick", http.HandlerFunc(h.simulationHandler.Tick))
+	mux.Handle("GET /api/v1/sessions", http.HandlerFunc(h.sessionHandler.List))
+	mux.Handle("POST /api/v1/sessions", http.HandlerFunc(h.sessionHandler.Create))
+	mux.Handle("GET /api/v1/sessions/{sessionID}", http.HandlerFunc(h.sessionHandler.Get))
+	mux.Handle("DELETE /api/v1/sessions/{sessionID}", http.HandlerFunc(h.sessionHandler.Delete))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/status", http.HandlerFunc(h.sessionHandler.ChangeStatus))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/join", http.HandlerFunc(h.sessionHandler.Join))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/leave", http.HandlerFunc(h.sessionHandler.Leave))
+
+	// シミュレーション（セッションごとに独立）
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation", http.HandlerFunc(h.simulationHandler.Get))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/changes", http.HandlerFunc(h.simulationHandler.Changes))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/topology", http.HandlerFunc(h.simulationHandler.Topology))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/schematic.svg", http.HandlerFunc(h.simulationHandler.Schematic))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/tick", http.HandlerFunc(h.simulationHandler.Tick))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/snapshot", http.HandlerFunc(h.simulationHandler.ExportSnapshot))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/snapshot", http.HandlerFunc(h.simulationHandler.ImportSnapshot))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/inputs", http.HandlerFunc(h.simulationHandler.InputLog))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/replay", http.HandlerFunc(h.simulationHandler.Replay))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/rewind", http.HandlerFunc(h.simulationHandler.Rewind))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/fast-forward", http.HandlerFunc(h.simulationHandler.FastForward))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/forecast", http.HandlerFunc(h.simulationHandler.Forecast))
+	mux.Handle("PUT /api/v1/sessions/{sessionID}/simulation/priority-rules", http.HandlerFunc(h.simulationHandler.SetPriorityRules))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/timetable", http.HandlerFunc(h.simulationHandler.GetTimetable))
+	mux.Handle("PUT /api/v1/sessions/{sessionID}/simulation/timetable", http.HandlerFunc(h.simulationHandler.SetTimetable))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/diagram", http.HandlerFunc(h.simulationHandler.Diagram))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/diagram.svg", http.HandlerFunc(h.simulationHandler.DiagramSVG))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/breakpoints", http.HandlerFunc(h.simulationHandler.ListBreakpoints))
+	mux.Handle("POST /api/v1/sessions/{sessionID}/simulation/breakpoints", http.HandlerFunc(h.simulationHandler.CreateBreakpoint))
+	mux.Handle("PATCH /api/v1/sessions/{sessionID}/simulation/breakpoints/{breakpointID}", http.HandlerFunc(h.simulationHandler.UpdateBreakpoint))
+	mux.Handle("DELETE /api/v1/sessions/{sessionID}/simulation/breakpoints/{breakpointID}", http.HandlerFunc(h.simulationHandler.DeleteBreakpoint))
+	mux.Handle("GET /api/v1/sessions/{sessionID}/simulation/events", http.HandlerFunc(h.simulationHandler.Events))
 
 	return mux
 }
@@ -15,15 +15,50 @@ func TestSetupRegistersSimulationRoute(t *testing.T) {
 	container := di.NewContainer(cfg)
 	mux := setup(http.NewServeMux(), cfg, container)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/simulation", nil)
+	createSession(t, mux, "room-a")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation", nil)
 	rec := httptest.NewRecorder()
 	mux.ServeHTTP(rec, req)
 
 	if rec.Code == http.StatusNotFound {
 		t.Fatalf("expected simulation route to be registered, got 404")
 	}
-	if rec.Code != http.StatusOK && rec.Code != http.StatusInternalServerError {
-		t.Fatalf("expected status 200 or 500, got %d", rec.Code)
+	// 演習開始前（LOBBY）はシミュレーションが存在しない
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 before start, got %d", rec.Code)
+	}
+}
+
+func TestSetupRegistersSessionStatusRoute(t *testing.T) {
+	cfg := &config.Config{}
+	container := di.NewContainer(cfg)
+	mux := setup(http.NewServeMux(), cfg, container)
+
+	createSession(t, mux, "room-a")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/status", strings.NewReader(`{"status":"DEBRIEF"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 on LOBBY -> DEBRIEF, got %d", rec.Code)
+	}
+}
+
+func TestSetupRejectsUnknownSessionStatus(t *testing.T) {
+	cfg := &config.Config{}
+	container := di.NewContainer(cfg)
+	mux := setup(http.NewServeMux(), cfg, container)
+
+	createSession(t, mux, "room-a")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/status", strings.NewReader(`{"status":"SLEEPING"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 on an unknown status, got %d", rec.Code)
 	}
 }
 
@@ -32,7 +67,7 @@ func TestSetupRegistersSimulationTickRoute(t *testing.T) {
 	container := di.NewContainer(cfg)
 	mux := setup(http.NewServeMux(), cfg, container)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/simulation/tick", strings.NewReader(`{"deltaMillis":`))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":`))
 	rec := httptest.NewRecorder()
 	mux.ServeHTTP(rec, req)
 
@@ -40,3 +75,57 @@ func TestSetupRegistersSimulationTickRoute(t *testing.T) {
 		t.Fatalf("expected status 400, got %d", rec.Code)
 	}
 }
+
+func TestSetupReturnsNotFoundForUnknownSessionSimulation(t *testing.T) {
+	cfg := &config.Config{}
+	container := di.NewContainer(cfg)
+	mux := setup(http.NewServeMux(), cfg, container)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/missing/simulation", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestSetupRegistersSessionLifecycleRoutes(t *testing.T) {
+	cfg := &config.Config{}
+	container := di.NewContainer(cfg)
+	mux := setup(http.NewServeMux(), cfg, container)
+
+	createSession(t, mux, "room-a")
+
+	dup := httptest.NewRequest(http.MethodPost, "/api/v1/sessions", strings.NewReader(`{"sessionId":"room-a"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, dup)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 on duplicate session, got %d", rec.Code)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "/api/v1/sessions/room-a", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, del)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on delete, got %d", rec.Code)
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, get)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 after delete, got %d", rec.Code)
+	}
+}
+
+func createSession(t *testing.T, mux *http.ServeMux, id string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions", strings.NewReader(`{"sessionId":"`+id+`"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 on create session, got %d", rec.Code)
+	}
+}
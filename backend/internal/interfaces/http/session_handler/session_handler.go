@@ -2,7 +2,9 @@ package session
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	domain "github.com/right1121/railway-control-center-simulator/internal/domain/session"
 	"github.com/right1121/railway-control-center-simulator/internal/interfaces/http/utils"
 	"github.com/right1121/railway-control-center-simulator/pkg/appctx"
+	"github.com/right1121/railway-control-center-simulator/pkg/apperr"
 )
 
 type SessionHandler struct {
@@ -20,12 +23,114 @@ func NewSessionHandler(uc sessionapp.UseCase) *SessionHandler {
 	return &SessionHandler{usecase: uc}
 }
 
+type createReq struct {
+	SessionID string `json:"sessionId"`
+}
+
+// Create は訓練セッションを作成する。ボディ省略時はIDを自動採番する。
+func (h *SessionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	dto, err := h.usecase.CreateSession(r.Context(), sessionapp.CreateSessionInput{
+		SessionID: req.SessionID,
+		Now:       time.Now(),
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.SetETag(w, dto.Version)
+	utils.WriteJSON(w, http.StatusCreated, dto)
+}
+
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	dtos, err := h.usecase.ListSessions(r.Context())
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"sessions": dtos})
+}
+
 func (h *SessionHandler) Get(w http.ResponseWriter, r *http.Request) {
-	dto, err := h.usecase.GetSnapshot(r.Context())
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	dto, err := h.usecase.GetSnapshot(r.Context(), sessionID)
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.SetETag(w, dto.Version)
+	utils.WriteJSON(w, http.StatusOK, dto)
+}
+
+func (h *SessionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	expected, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.usecase.DeleteSession(r.Context(), sessionapp.DeleteSessionInput{
+		SessionID:       sessionID,
+		ExpectedVersion: expected,
+	}); err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+type changeStatusReq struct {
+	Status string `json:"status"`
+}
+
+// ChangeStatus はセッションのライフサイクル状態を遷移させる
+// （RUNNING: 開始/再開, PAUSED: 一時停止, DEBRIEF: 演習終了, CLOSED: セッション終了）
+func (h *SessionHandler) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	expected, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var req changeStatusReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+	status, err := domain.ParseSessionStatus(req.Status)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_STATUS", "unknown session status"))
+		return
+	}
+
+	dto, err := h.usecase.ChangeStatus(r.Context(), sessionapp.ChangeStatusInput{
+		SessionID:       sessionID,
+		Status:          status,
+		ExpectedVersion: expected,
+		Now:             time.Now(),
+	})
 	if err != nil {
 		writeUseCaseError(w, err)
 		return
 	}
+	utils.SetETag(w, dto.Version)
 	utils.WriteJSON(w, http.StatusOK, dto)
 }
 
@@ -38,6 +143,15 @@ func (h *SessionHandler) Join(w http.ResponseWriter, r *http.Request) {
 	ctx := appctx.FromRequest(r)
 	logger := ctx.GetLogger()
 
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	expected, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var req joinReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.WithError(fmt.Errorf("JSONの変換に失敗しました: %w", err))
@@ -59,9 +173,11 @@ func (h *SessionHandler) Join(w http.ResponseWriter, r *http.Request) {
 	}
 
 	out, err := h.usecase.JoinDispatcher(r.Context(), sessionapp.JoinDispatcherInput{
-		DispatcherID: dispatcherID,
-		Name:         name,
-		Now:          time.Now(),
+		SessionID:       sessionID,
+		DispatcherID:    dispatcherID,
+		Name:            name,
+		ExpectedVersion: expected,
+		Now:             time.Now(),
 	})
 	if err != nil {
 		logger.WithError(fmt.Errorf("管理者の参加に失敗しました: %w", err))
@@ -69,6 +185,7 @@ func (h *SessionHandler) Join(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	utils.SetETag(w, out.Snapshot.Version)
 	utils.WriteJSON(w, http.StatusOK, out)
 }
 
@@ -77,6 +194,15 @@ type leaveReq struct {
 }
 
 func (h *SessionHandler) Leave(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	expected, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var req leaveReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
@@ -84,8 +210,10 @@ func (h *SessionHandler) Leave(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.usecase.LeaveDispatcher(r.Context(), sessionapp.LeaveDispatcherInput{
-		DispatcherID: req.DispatcherID,
-		Now:          time.Now(),
+		SessionID:       sessionID,
+		DispatcherID:    req.DispatcherID,
+		ExpectedVersion: expected,
+		Now:             time.Now(),
 	}); err != nil {
 		writeUseCaseError(w, err)
 		return
@@ -94,6 +222,43 @@ func (h *SessionHandler) Leave(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
+// sessionIDFromPath はパスの {sessionID} を検証する。不正なら400を書き込んで false を返す。
+func sessionIDFromPath(w http.ResponseWriter, r *http.Request) (domain.SessionID, bool) {
+	id, err := domain.NewSessionID(r.PathValue("sessionID"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_SESSION_ID", "invalid session ID"))
+		return domain.SessionID{}, false
+	}
+	return id, true
+}
+
+// ifMatchVersion は If-Match ヘッダを検証する。不正なら400を書き込んで false を返す。
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (*int64, bool) {
+	expected, err := utils.IfMatchVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.InvalidIfMatch())
+		return nil, false
+	}
+	return expected, true
+}
+
 func writeUseCaseError(w http.ResponseWriter, err error) {
-	utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+	switch {
+	case errors.Is(err, apperr.ErrConflict):
+		utils.WriteJSON(w, http.StatusConflict, utils.VersionConflict())
+	case errors.Is(err, domain.ErrSessionNotFound):
+		utils.WriteJSON(w, http.StatusNotFound, utils.ErrBody("SESSION_NOT_FOUND", "session not found"))
+	case errors.Is(err, domain.ErrSessionAlreadyExists):
+		utils.WriteJSON(w, http.StatusConflict, utils.ErrBody("SESSION_ALREADY_EXISTS", "session already exists"))
+	case errors.Is(err, domain.ErrDispatcherAlreadyExists):
+		utils.WriteJSON(w, http.StatusConflict, utils.ErrBody("DISPATCHER_ALREADY_EXISTS", "dispatcher already exists"))
+	case errors.Is(err, domain.ErrDispatcherNotFound):
+		utils.WriteJSON(w, http.StatusNotFound, utils.ErrBody("DISPATCHER_NOT_FOUND", "dispatcher not found"))
+	case errors.Is(err, domain.ErrInvalidStatusTransition):
+		utils.WriteJSON(w, http.StatusConflict, utils.ErrBody("INVALID_STATUS_TRANSITION", "invalid session status transition"))
+	case errors.Is(err, domain.ErrSessionNotAccepting):
+		utils.WriteJSON(w, http.StatusConflict, utils.ErrBody("SESSION_NOT_ACCEPTING", "session is not accepting dispatchers"))
+	default:
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+	}
 }
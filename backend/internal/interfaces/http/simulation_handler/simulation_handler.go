@@ -1,12 +1,17 @@
 package simulation
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	simulationapp "github.com/right1121/railway-control-center-simulator/internal/application/simulation"
 	"github.com/right1121/railway-control-center-simulator/internal/interfaces/http/utils"
+	"github.com/right1121/railway-control-center-simulator/pkg/apperr"
 )
 
 type SimulationHandler struct {
@@ -18,20 +23,101 @@ func NewSimulationHandler(uc simulationapp.UseCase) *SimulationHandler {
 }
 
 func (h *SimulationHandler) Get(w http.ResponseWriter, r *http.Request) {
-	dto, err := h.usecase.GetSimulation(r.Context())
+	dto, err := h.usecase.GetSimulation(r.Context(), r.PathValue("sessionID"))
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+		writeUseCaseError(w, err)
 		return
 	}
 
+	utils.SetETag(w, dto.Version)
 	utils.WriteJSON(w, http.StatusOK, dto)
 }
 
+// Changes は since の版から変わった列車と区間だけを返す。
+// waitMillis を指定すると、since が最新のあいだは次の更新まで応答を待つ（長いポーリング）。
+func (h *SimulationHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	since, err := strconv.ParseInt(query.Get("since"), 10, 64)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_CHANGES_QUERY", "since must be a version number"))
+		return
+	}
+	var wait int64
+	if raw := query.Get("waitMillis"); raw != "" {
+		if wait, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_CHANGES_QUERY", "waitMillis must be a number"))
+			return
+		}
+	}
+
+	delta, err := h.usecase.GetChanges(r.Context(), simulationapp.ChangesInput{
+		SessionID:    r.PathValue("sessionID"),
+		SinceVersion: since,
+		WaitMillis:   wait,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	utils.SetETag(w, delta.Version)
+	utils.WriteJSON(w, http.StatusOK, delta)
+}
+
+// Topology は路線の構成を返す。演習中は変わらないので If-None-Match が一致すれば 304 を返す。
+func (h *SimulationHandler) Topology(w http.ResponseWriter, r *http.Request) {
+	topology, err := h.usecase.GetTopology(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	utils.SetRevisionETag(w, topology.Revision)
+	if utils.IfNoneMatch(r, topology.Revision) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, topology)
+}
+
+// Schematic は現在の路線図（区間の在線と列車の位置）を SVG で返す。
+// 版が進んでいなければ（If-None-Match が一致すれば）描かずに 304 を返す。
+func (h *SimulationHandler) Schematic(w http.ResponseWriter, r *http.Request) {
+	dto, err := h.usecase.GetSimulation(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	if utils.IfNoneMatch(r, strconv.FormatInt(dto.Version, 10)) {
+		utils.SetETag(w, dto.Version)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var svg bytes.Buffer
+	if err := simulationapp.RenderSchematicSVG(&svg, dto); err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+		return
+	}
+	utils.SetETag(w, dto.Version)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(svg.Bytes())
+}
+
 type tickReq struct {
-	DeltaMillis int64 `json:"deltaMillis"`
+	DeltaMillis int64  `json:"deltaMillis"`
+	Engine      string `json:"engine"`
 }
 
 func (h *SimulationHandler) Tick(w http.ResponseWriter, r *http.Request) {
+	expected, err := utils.IfMatchVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.InvalidIfMatch())
+		return
+	}
+
 	var req tickReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
@@ -39,16 +125,421 @@ func (h *SimulationHandler) Tick(w http.ResponseWriter, r *http.Request) {
 	}
 
 	dto, err := h.usecase.Tick(r.Context(), simulationapp.TickInput{
-		DeltaMillis: req.DeltaMillis,
+		SessionID:       r.PathValue("sessionID"),
+		DeltaMillis:     req.DeltaMillis,
+		Engine:          req.Engine,
+		ExpectedVersion: expected,
 	})
 	if err != nil {
-		if errors.Is(err, simulationapp.ErrInvalidTickDelta) {
-			utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_TICK_DELTA", "invalid tick delta"))
-			return
-		}
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+		writeUseCaseError(w, err)
 		return
 	}
 
+	utils.SetETag(w, dto.Version)
 	utils.WriteJSON(w, http.StatusOK, dto)
 }
+
+// ExportSnapshot は演習の途中状態をスナップショット文書としてダウンロードさせる
+func (h *SimulationHandler) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	doc, err := h.usecase.ExportSnapshot(r.Context(), sessionID)
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="simulation-%s.json"`, url.PathEscape(sessionID)))
+	utils.WriteJSON(w, http.StatusOK, doc)
+}
+
+// ImportSnapshot はスナップショット文書でシミュレーションを置き換える
+func (h *SimulationHandler) ImportSnapshot(w http.ResponseWriter, r *http.Request) {
+	expected, err := utils.IfMatchVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.InvalidIfMatch())
+		return
+	}
+
+	var doc simulationapp.SnapshotDocument
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	dto, err := h.usecase.ImportSnapshot(r.Context(), simulationapp.ImportSnapshotInput{
+		SessionID:       r.PathValue("sessionID"),
+		Document:        doc,
+		ExpectedVersion: expected,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	utils.SetETag(w, dto.Version)
+	utils.WriteJSON(w, http.StatusOK, dto)
+}
+
+// InputLog はシミュレーションに加えられた入力の記録を返す
+func (h *SimulationHandler) InputLog(w http.ResponseWriter, r *http.Request) {
+	records, err := h.usecase.GetInputLog(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"inputs": records})
+}
+
+// Replay は入力ログから状態を再構築し、現在の状態と一致するかを返す
+func (h *SimulationHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	result, err := h.usecase.VerifyReplay(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+type rewindReq struct {
+	TargetSimTimeMillis int64 `json:"targetSimTimeMillis"`
+}
+
+// Rewind はシミュレーションを過去の時刻へ巻き戻す。破棄した時点の状態は abandoned として返す
+func (h *SimulationHandler) Rewind(w http.ResponseWriter, r *http.Request) {
+	expected, err := utils.IfMatchVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.InvalidIfMatch())
+		return
+	}
+
+	var req rewindReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	result, err := h.usecase.Rewind(r.Context(), simulationapp.RewindInput{
+		SessionID:           r.PathValue("sessionID"),
+		TargetSimTimeMillis: req.TargetSimTimeMillis,
+		ExpectedVersion:     expected,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	utils.SetETag(w, result.Simulation.Version)
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+type forecastReq struct {
+	HorizonMillis int64                                  `json:"horizonMillis"`
+	StepMillis    int64                                  `json:"stepMillis"`
+	Commands      []simulationapp.HypotheticalCommandDTO `json:"commands"`
+}
+
+// Forecast は仮の指令を適用した場合の先行きを予測する（シミュレーション本体は変更しない）
+func (h *SimulationHandler) Forecast(w http.ResponseWriter, r *http.Request) {
+	var req forecastReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	result, err := h.usecase.Forecast(r.Context(), simulationapp.ForecastInput{
+		SessionID:     r.PathValue("sessionID"),
+		HorizonMillis: req.HorizonMillis,
+		StepMillis:    req.StepMillis,
+		Commands:      req.Commands,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+type fastForwardReq struct {
+	UntilSimTimeMillis int64                            `json:"untilSimTimeMillis"`
+	StepMillis         int64                            `json:"stepMillis"`
+	Engine             string                           `json:"engine"`
+	Conditions         []simulationapp.StopConditionDTO `json:"conditions"`
+}
+
+// FastForward は目標時刻または停止条件の成立まで一定の刻みで進め、止まった理由を返す
+func (h *SimulationHandler) FastForward(w http.ResponseWriter, r *http.Request) {
+	expected, err := utils.IfMatchVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.InvalidIfMatch())
+		return
+	}
+
+	var req fastForwardReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	result, err := h.usecase.FastForward(r.Context(), simulationapp.FastForwardInput{
+		SessionID:          r.PathValue("sessionID"),
+		UntilSimTimeMillis: req.UntilSimTimeMillis,
+		StepMillis:         req.StepMillis,
+		Engine:             req.Engine,
+		Conditions:         req.Conditions,
+		ExpectedVersion:    expected,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	utils.SetETag(w, result.Simulation.Version)
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+type priorityRulesReq struct {
+	Rules []string `json:"rules"`
+}
+
+// SetPriorityRules は同じ区間を同時に求めた列車の順位を決める規則を置き換える（空なら既定の規則）
+func (h *SimulationHandler) SetPriorityRules(w http.ResponseWriter, r *http.Request) {
+	expected, err := utils.IfMatchVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.InvalidIfMatch())
+		return
+	}
+
+	var req priorityRulesReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	dto, err := h.usecase.SetPriorityRules(r.Context(), simulationapp.SetPriorityRulesInput{
+		SessionID:       r.PathValue("sessionID"),
+		Rules:           req.Rules,
+		ExpectedVersion: expected,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	utils.SetETag(w, dto.Version)
+	utils.WriteJSON(w, http.StatusOK, dto)
+}
+
+// GetTimetable は運行図表に重ねる計画ダイヤを返す
+func (h *SimulationHandler) GetTimetable(w http.ResponseWriter, r *http.Request) {
+	dto, err := h.usecase.GetTimetable(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto)
+}
+
+// SetTimetable は計画ダイヤを置き換える（運行が空なら消す）
+func (h *SimulationHandler) SetTimetable(w http.ResponseWriter, r *http.Request) {
+	var req simulationapp.TimetableDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	dto, err := h.usecase.SetTimetable(r.Context(), simulationapp.SetTimetableInput{
+		SessionID: r.PathValue("sessionID"),
+		Timetable: req,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto)
+}
+
+// Diagram は路線（?line=、省略時は最初の路線）の運行図表を JSON で返す
+func (h *SimulationHandler) Diagram(w http.ResponseWriter, r *http.Request) {
+	dto, err := h.usecase.GetDiagram(r.Context(), simulationapp.DiagramInput{
+		SessionID: r.PathValue("sessionID"),
+		LineID:    r.URL.Query().Get("line"),
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto)
+}
+
+// DiagramSVG は運行図表を SVG で返す。?download=1 なら演習後の記録として保存できるよう添付ファイルにする。
+func (h *SimulationHandler) DiagramSVG(w http.ResponseWriter, r *http.Request) {
+	dto, err := h.usecase.GetDiagram(r.Context(), simulationapp.DiagramInput{
+		SessionID: r.PathValue("sessionID"),
+		LineID:    r.URL.Query().Get("line"),
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+
+	var svg bytes.Buffer
+	if err := simulationapp.RenderDiagramSVG(&svg, dto); err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="diagram-%s-%s.svg"`, url.PathEscape(dto.SessionID), url.PathEscape(dto.LineID)))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(svg.Bytes())
+}
+
+// ListBreakpoints はセッションに設定されたブレークポイントを返す
+func (h *SimulationHandler) ListBreakpoints(w http.ResponseWriter, r *http.Request) {
+	breakpoints, err := h.usecase.ListBreakpoints(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"breakpoints": breakpoints})
+}
+
+type createBreakpointReq struct {
+	Condition simulationapp.StopConditionDTO `json:"condition"`
+	Enabled   *bool                          `json:"enabled"`
+}
+
+// CreateBreakpoint は Tick のたびに評価し、成立したら演習を一時停止させる条件を追加する
+func (h *SimulationHandler) CreateBreakpoint(w http.ResponseWriter, r *http.Request) {
+	var req createBreakpointReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	bp, err := h.usecase.CreateBreakpoint(r.Context(), simulationapp.CreateBreakpointInput{
+		SessionID: r.PathValue("sessionID"),
+		Condition: req.Condition,
+		Enabled:   req.Enabled,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, bp)
+}
+
+type updateBreakpointReq struct {
+	Condition *simulationapp.StopConditionDTO `json:"condition"`
+	Enabled   *bool                           `json:"enabled"`
+}
+
+// UpdateBreakpoint はブレークポイントの条件や有効・無効を変更する（省略した項目はそのまま）
+func (h *SimulationHandler) UpdateBreakpoint(w http.ResponseWriter, r *http.Request) {
+	var req updateBreakpointReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.BadJSON())
+		return
+	}
+
+	bp, err := h.usecase.UpdateBreakpoint(r.Context(), simulationapp.UpdateBreakpointInput{
+		SessionID:    r.PathValue("sessionID"),
+		BreakpointID: r.PathValue("breakpointID"),
+		Condition:    req.Condition,
+		Enabled:      req.Enabled,
+	})
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, bp)
+}
+
+func (h *SimulationHandler) DeleteBreakpoint(w http.ResponseWriter, r *http.Request) {
+	if err := h.usecase.DeleteBreakpoint(r.Context(), r.PathValue("sessionID"), r.PathValue("breakpointID")); err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Events はセッションの通知を Server-Sent Events で配信する（切断まで返らない）
+func (h *SimulationHandler) Events(w http.ResponseWriter, r *http.Request) {
+	events, cancel, err := h.usecase.Subscribe(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeUseCaseError(w, err)
+		return
+	}
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(n)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", n.Type, data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeUseCaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apperr.ErrConflict):
+		utils.WriteJSON(w, http.StatusConflict, utils.VersionConflict())
+	case errors.Is(err, simulationapp.ErrInvalidTickDelta):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_TICK_DELTA", "invalid tick delta"))
+	case errors.Is(err, simulationapp.ErrInvalidEngine):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_ENGINE", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidSnapshot), errors.Is(err, simulationapp.ErrUnsupportedSnapshotVersion):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_SNAPSHOT", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidRewindTarget):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_REWIND_TARGET", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidForecast):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_FORECAST", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidFastForward):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_FAST_FORWARD", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidPriorityRules):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_PRIORITY_RULES", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidChangesQuery):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_CHANGES_QUERY", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidTimetable):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_TIMETABLE", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidDiagramQuery):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_DIAGRAM_QUERY", err.Error()))
+	case errors.Is(err, simulationapp.ErrInvalidBreakpoint):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_BREAKPOINT", err.Error()))
+	case errors.Is(err, simulationapp.ErrBreakpointNotFound):
+		utils.WriteJSON(w, http.StatusNotFound, utils.ErrBody("BREAKPOINT_NOT_FOUND", "breakpoint not found"))
+	case errors.Is(err, simulationapp.ErrInvalidSessionID):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrBody("INVALID_SESSION_ID", "invalid session ID"))
+	case errors.Is(err, simulationapp.ErrSessionNotFound):
+		utils.WriteJSON(w, http.StatusNotFound, utils.ErrBody("SESSION_NOT_FOUND", "session not found"))
+	case errors.Is(err, simulationapp.ErrSimulationNotStarted):
+		utils.WriteJSON(w, http.StatusConflict, utils.ErrBody("SIMULATION_NOT_STARTED", "simulation has not been started"))
+	case errors.Is(err, simulationapp.ErrSessionNotRunning):
+		utils.WriteJSON(w, http.StatusConflict, utils.ErrBody("SESSION_NOT_RUNNING", "session is not running"))
+	default:
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrBody("INTERNAL", "internal error"))
+	}
+}
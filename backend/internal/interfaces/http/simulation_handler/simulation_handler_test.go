@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	simulationapp "github.com/right1121/railway-control-center-simulator/internal/application/simulation"
+	domain "github.com/right1121/railway-control-center-simulator/internal/domain/simulation"
 )
 
 func TestGetReturnsSimulationDTO(t *testing.T) {
@@ -18,7 +20,7 @@ func TestGetReturnsSimulationDTO(t *testing.T) {
 	}
 	handler := NewSimulationHandler(uc)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/simulation", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation", nil)
 	rec := httptest.NewRecorder()
 	handler.Get(rec, req)
 
@@ -43,7 +45,7 @@ func TestGetReturnsInternalErrorOnUseCaseFailure(t *testing.T) {
 	uc := &stubSimulationUseCase{getErr: errors.New("boom")}
 	handler := NewSimulationHandler(uc)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/simulation", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation", nil)
 	rec := httptest.NewRecorder()
 	handler.Get(rec, req)
 
@@ -60,7 +62,7 @@ func TestTickReturnsSimulationDTO(t *testing.T) {
 	}
 	handler := NewSimulationHandler(uc)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/simulation/tick", strings.NewReader(`{"deltaMillis":1000}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":1000}`))
 	rec := httptest.NewRecorder()
 	handler.Tick(rec, req)
 
@@ -84,7 +86,7 @@ func TestTickReturnsBadJSONOnDecodeFailure(t *testing.T) {
 	uc := &stubSimulationUseCase{}
 	handler := NewSimulationHandler(uc)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/simulation/tick", strings.NewReader(`{"deltaMillis":`))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":`))
 	rec := httptest.NewRecorder()
 	handler.Tick(rec, req)
 
@@ -99,7 +101,7 @@ func TestTickReturnsValidationErrorOnInvalidTickDelta(t *testing.T) {
 	uc := &stubSimulationUseCase{tickErr: simulationapp.ErrInvalidTickDelta}
 	handler := NewSimulationHandler(uc)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/simulation/tick", strings.NewReader(`{"deltaMillis":0}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":0}`))
 	rec := httptest.NewRecorder()
 	handler.Tick(rec, req)
 
@@ -110,11 +112,47 @@ func TestTickReturnsValidationErrorOnInvalidTickDelta(t *testing.T) {
 	assertErrorBody(t, rec.Body.Bytes(), "INVALID_TICK_DELTA", "invalid tick delta")
 }
 
+func TestTickPassesEngineAndRejectsUnknownEngine(t *testing.T) {
+	uc := &stubSimulationUseCase{tickErr: simulationapp.ErrInvalidEngine}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":1000,"engine":"WARP"}`))
+	rec := httptest.NewRecorder()
+	handler.Tick(rec, req)
+
+	if uc.tickInput.Engine != "WARP" {
+		t.Fatalf("expected engine WARP, got %q", uc.tickInput.Engine)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_ENGINE", "invalid simulation engine")
+}
+
+func TestGetReturnsNotFoundOnUnknownSession(t *testing.T) {
+	uc := &stubSimulationUseCase{getErr: simulationapp.ErrSessionNotFound}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/missing/simulation", nil)
+	req.SetPathValue("sessionID", "missing")
+	rec := httptest.NewRecorder()
+	handler.Get(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+	if uc.getID != "missing" {
+		t.Fatalf("expected session id missing, got %q", uc.getID)
+	}
+
+	assertErrorBody(t, rec.Body.Bytes(), "SESSION_NOT_FOUND", "session not found")
+}
+
 func TestTickReturnsInternalErrorOnUseCaseFailure(t *testing.T) {
 	uc := &stubSimulationUseCase{tickErr: errors.New("boom")}
 	handler := NewSimulationHandler(uc)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/simulation/tick", strings.NewReader(`{"deltaMillis":1000}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":1000}`))
 	rec := httptest.NewRecorder()
 	handler.Tick(rec, req)
 
@@ -125,16 +163,472 @@ func TestTickReturnsInternalErrorOnUseCaseFailure(t *testing.T) {
 	assertErrorBody(t, rec.Body.Bytes(), "INTERNAL", "internal error")
 }
 
+func TestTickPassesIfMatchVersionAndReturnsConflict(t *testing.T) {
+	uc := &stubSimulationUseCase{tickErr: domain.ErrVersionConflict}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":1000}`))
+	req.Header.Set("If-Match", `"3"`)
+	rec := httptest.NewRecorder()
+	handler.Tick(rec, req)
+
+	if uc.tickInput.ExpectedVersion == nil || *uc.tickInput.ExpectedVersion != 3 {
+		t.Fatalf("expected expected version 3, got %v", uc.tickInput.ExpectedVersion)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+
+	assertErrorBody(t, rec.Body.Bytes(), "VERSION_CONFLICT", "resource was modified by another request")
+}
+
+func TestTickReturnsBadRequestOnInvalidIfMatch(t *testing.T) {
+	uc := &stubSimulationUseCase{}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/tick", strings.NewReader(`{"deltaMillis":1000}`))
+	req.Header.Set("If-Match", `"abc"`)
+	rec := httptest.NewRecorder()
+	handler.Tick(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_IF_MATCH", "invalid If-Match header")
+}
+
+func TestImportSnapshotReturnsValidationErrorOnInvalidSnapshot(t *testing.T) {
+	uc := &stubSimulationUseCase{importErr: simulationapp.ErrInvalidSnapshot}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/snapshot", strings.NewReader(`{"schemaVersion":1}`))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+	handler.ImportSnapshot(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if uc.importInput.SessionID != "room-a" || uc.importInput.Document.SchemaVersion != 1 {
+		t.Fatalf("unexpected import input: %+v", uc.importInput)
+	}
+
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_SNAPSHOT", "invalid snapshot")
+}
+
+func TestRewindPassesTargetAndReturnsSimulation(t *testing.T) {
+	dto := testSimulationDTO()
+	dto.Version = 7
+	uc := &stubSimulationUseCase{tickDTO: dto}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/rewind", strings.NewReader(`{"targetSimTimeMillis":500}`))
+	req.SetPathValue("sessionID", "room-a")
+	req.Header.Set("If-Match", `"6"`)
+	rec := httptest.NewRecorder()
+
+	handler.Rewind(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if uc.rewindInput.SessionID != "room-a" || uc.rewindInput.TargetSimTimeMillis != 500 {
+		t.Fatalf("unexpected rewind input: %+v", uc.rewindInput)
+	}
+	if uc.rewindInput.ExpectedVersion == nil || *uc.rewindInput.ExpectedVersion != 6 {
+		t.Fatalf("expected version 6, got %v", uc.rewindInput.ExpectedVersion)
+	}
+	if got := rec.Header().Get("ETag"); got != `"7"` {
+		t.Fatalf("expected ETag \"7\", got %q", got)
+	}
+}
+
+func TestRewindReturnsBadRequestForUnavailableTarget(t *testing.T) {
+	uc := &stubSimulationUseCase{rewindErr: simulationapp.ErrInvalidRewindTarget}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/rewind", strings.NewReader(`{"targetSimTimeMillis":500}`))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.Rewind(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_REWIND_TARGET", "invalid rewind target")
+}
+
+func TestEventsStreamsNotifications(t *testing.T) {
+	uc := &stubSimulationUseCase{notifications: []simulationapp.Notification{
+		{Type: simulationapp.NotificationRewound, SessionID: "room-a", Version: 3, SimTimeMillis: 500},
+	}}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/events", nil)
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.Events(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", got)
+	}
+	want := "event: SIMULATION_REWOUND\ndata: {\"type\":\"SIMULATION_REWOUND\",\"sessionId\":\"room-a\",\"version\":3,\"simTimeMillis\":500}\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("unexpected stream body: %q", rec.Body.String())
+	}
+}
+
+func TestForecastPassesHypotheticalCommands(t *testing.T) {
+	uc := &stubSimulationUseCase{}
+	handler := NewSimulationHandler(uc)
+
+	body := `{"horizonMillis":600000,"commands":[{"type":"HOLD_TRAIN","trainId":"T0","durationMillis":120000}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/forecast", strings.NewReader(body))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.Forecast(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	in := uc.forecastInput
+	if in.SessionID != "room-a" || in.HorizonMillis != 600000 || len(in.Commands) != 1 {
+		t.Fatalf("unexpected forecast input: %+v", in)
+	}
+	if c := in.Commands[0]; c.Type != "HOLD_TRAIN" || c.TrainID != "T0" || c.DurationMillis != 120000 {
+		t.Fatalf("unexpected command: %+v", c)
+	}
+}
+
+func TestFastForwardPassesStopConditions(t *testing.T) {
+	uc := &stubSimulationUseCase{}
+	handler := NewSimulationHandler(uc)
+
+	body := `{"untilSimTimeMillis":3600000,"stepMillis":500,"conditions":[{"type":"TRAIN_BLOCKED_FOR","durationMillis":30000}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/fast-forward", strings.NewReader(body))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.FastForward(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	in := uc.fastForwardInput
+	if in.SessionID != "room-a" || in.UntilSimTimeMillis != 3600000 || in.StepMillis != 500 {
+		t.Fatalf("unexpected fast forward input: %+v", in)
+	}
+	if len(in.Conditions) != 1 || in.Conditions[0].Type != "TRAIN_BLOCKED_FOR" || in.Conditions[0].DurationMillis != 30000 {
+		t.Fatalf("unexpected conditions: %+v", in.Conditions)
+	}
+}
+
+func TestFastForwardReturnsBadRequestForInvalidCondition(t *testing.T) {
+	uc := &stubSimulationUseCase{fastForwardErr: simulationapp.ErrInvalidFastForward}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/fast-forward", strings.NewReader(`{"untilSimTimeMillis":1000}`))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.FastForward(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_FAST_FORWARD", "invalid fast forward request")
+}
+
+func TestCreateBreakpointReturnsCreated(t *testing.T) {
+	uc := &stubSimulationUseCase{}
+	handler := NewSimulationHandler(uc)
+
+	body := `{"condition":{"type":"TRAIN_REACHES_STATION","trainId":"T0","stationId":"S1"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/room-a/simulation/breakpoints", strings.NewReader(body))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.CreateBreakpoint(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+	in := uc.createBreakpointInput
+	if in.SessionID != "room-a" || in.Enabled != nil || in.Condition.Type != "TRAIN_REACHES_STATION" || in.Condition.StationID != "S1" {
+		t.Fatalf("unexpected create input: %+v", in)
+	}
+}
+
+func TestUpdateBreakpointPassesPartialFields(t *testing.T) {
+	uc := &stubSimulationUseCase{}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/sessions/room-a/simulation/breakpoints/bp-1", strings.NewReader(`{"enabled":false}`))
+	req.SetPathValue("sessionID", "room-a")
+	req.SetPathValue("breakpointID", "bp-1")
+	rec := httptest.NewRecorder()
+
+	handler.UpdateBreakpoint(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	in := uc.updateBreakpointInput
+	if in.BreakpointID != "bp-1" || in.Condition != nil || in.Enabled == nil || *in.Enabled {
+		t.Fatalf("unexpected update input: %+v", in)
+	}
+}
+
+func TestDeleteBreakpointReturnsNotFound(t *testing.T) {
+	uc := &stubSimulationUseCase{breakpointErr: simulationapp.ErrBreakpointNotFound}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/sessions/room-a/simulation/breakpoints/bp-1", nil)
+	req.SetPathValue("sessionID", "room-a")
+	req.SetPathValue("breakpointID", "bp-1")
+	rec := httptest.NewRecorder()
+
+	handler.DeleteBreakpoint(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+	assertErrorBody(t, rec.Body.Bytes(), "BREAKPOINT_NOT_FOUND", "breakpoint not found")
+}
+
+func TestSetPriorityRulesReturnsBadRequestForUnknownRule(t *testing.T) {
+	uc := &stubSimulationUseCase{priorityRulesErr: simulationapp.ErrInvalidPriorityRules}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/sessions/room-a/simulation/priority-rules", strings.NewReader(`{"rules":["NOPE"]}`))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.SetPriorityRules(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if in := uc.priorityRulesInput; in.SessionID != "room-a" || len(in.Rules) != 1 || in.Rules[0] != "NOPE" {
+		t.Fatalf("unexpected priority rules input: %+v", in)
+	}
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_PRIORITY_RULES", "invalid priority rules")
+}
+
+func TestChangesPassesQuery(t *testing.T) {
+	uc := &stubSimulationUseCase{}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/changes?since=3&waitMillis=500", nil)
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.Changes(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if in := uc.changesInput; in.SessionID != "room-a" || in.SinceVersion != 3 || in.WaitMillis != 500 {
+		t.Fatalf("unexpected changes input: %+v", in)
+	}
+	if got := rec.Header().Get("ETag"); got != `"4"` {
+		t.Fatalf("expected ETag of the latest version, got %q", got)
+	}
+}
+
+func TestChangesRejectsMissingSince(t *testing.T) {
+	handler := NewSimulationHandler(&stubSimulationUseCase{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/changes", nil)
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+
+	handler.Changes(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	assertErrorBody(t, rec.Body.Bytes(), "INVALID_CHANGES_QUERY", "since must be a version number")
+}
+
+func TestTopologyReturnsNotModifiedForCachedRevision(t *testing.T) {
+	uc := &stubSimulationUseCase{topology: simulationapp.TopologyDTO{Revision: "abc", Stations: []string{"S0", "S1"}, Blocks: []string{"B0"}}}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/topology", nil)
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+	handler.Topology(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"abc"` {
+		t.Fatalf("expected topology with ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/topology", nil)
+	req.SetPathValue("sessionID", "room-a")
+	req.Header.Set("If-None-Match", `"abc"`)
+	rec = httptest.NewRecorder()
+	handler.Topology(rec, req)
+
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected 304 without body, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSchematicReturnsSVG(t *testing.T) {
+	uc := &stubSimulationUseCase{getDTO: simulationapp.SimulationDTO{
+		SessionID: "room-a",
+		Version:   3,
+		Line: simulationapp.LineDTO{
+			Stations: []string{"S0", "S1"},
+			Blocks:   []string{"B0"},
+			Schematic: &simulationapp.LineSchematicDTO{
+				Stations: []simulationapp.StationSchematicDTO{{ID: "S0"}, {ID: "S1", Position: simulationapp.PointDTO{X: 100}}},
+				Blocks:   []simulationapp.BlockSchematicDTO{{ID: "B0", Polyline: []simulationapp.PointDTO{{}, {X: 100}}}},
+			},
+		},
+		Trains: []simulationapp.TrainDTO{{ID: "T0", BlockID: "B0", Progress: 0.5, Forward: true}},
+	}}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/schematic.svg", nil)
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+	handler.Schematic(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected an SVG response, got %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Header().Get("ETag"))
+	}
+	if !strings.HasPrefix(rec.Body.String(), "<svg") || !strings.Contains(rec.Body.String(), `data-train-id="T0"`) {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestSchematicReturnsNotModifiedForCachedVersion(t *testing.T) {
+	uc := &stubSimulationUseCase{getDTO: simulationapp.SimulationDTO{
+		SessionID: "room-a",
+		Version:   3,
+		Line: simulationapp.LineDTO{
+			Stations: []string{"S0", "S1"},
+			Blocks:   []string{"B0"},
+			Schematic: &simulationapp.LineSchematicDTO{
+				Stations: []simulationapp.StationSchematicDTO{{ID: "S0"}, {ID: "S1", Position: simulationapp.PointDTO{X: 100}}},
+				Blocks:   []simulationapp.BlockSchematicDTO{{ID: "B0", Polyline: []simulationapp.PointDTO{{}, {X: 100}}}},
+			},
+		},
+	}}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/schematic.svg", nil)
+	req.SetPathValue("sessionID", "room-a")
+	req.Header.Set("If-None-Match", `"3"`)
+	rec := httptest.NewRecorder()
+	handler.Schematic(rec, req)
+
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected 304 without body, got %d %q %q", rec.Code, rec.Header().Get("ETag"), rec.Body.String())
+	}
+
+	// 版が進んでいれば描き直す
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/schematic.svg", nil)
+	req.SetPathValue("sessionID", "room-a")
+	req.Header.Set("If-None-Match", `"2"`)
+	rec = httptest.NewRecorder()
+	handler.Schematic(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "<svg") {
+		t.Fatalf("expected a fresh SVG, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSetTimetableMapsInvalidTimetable(t *testing.T) {
+	uc := &stubSimulationUseCase{timetableErr: fmt.Errorf("%w: bad stop", simulationapp.ErrInvalidTimetable)}
+	handler := NewSimulationHandler(uc)
+
+	body := `{"services":[{"id":"101","stops":[{"stationId":"S0","arrivalSimTimeMillis":0,"departureSimTimeMillis":0},{"stationId":"S1","arrivalSimTimeMillis":60000,"departureSimTimeMillis":60000}]}]}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/sessions/room-a/simulation/timetable", strings.NewReader(body))
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+	handler.SetTimetable(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "INVALID_TIMETABLE") {
+		t.Fatalf("expected INVALID_TIMETABLE, got %d %s", rec.Code, rec.Body.String())
+	}
+	services := uc.timetableInput.Timetable.Services
+	if len(services) != 1 || services[0].Stops[1].ArrivalSimTimeMillis != 60000 {
+		t.Fatalf("unexpected input: %+v", uc.timetableInput)
+	}
+}
+
+func TestDiagramSVGDownload(t *testing.T) {
+	uc := &stubSimulationUseCase{diagram: simulationapp.DiagramDTO{
+		SessionID: "room-a",
+		LineID:    "RED",
+		Stations:  []simulationapp.DiagramStationDTO{{ID: "A0"}, {ID: "X", Distance: 1}},
+		Actual:    []simulationapp.ActualRunDTO{{TrainID: "T0", Points: []simulationapp.DiagramPointDTO{{SimTimeMillis: 0}, {SimTimeMillis: 30000, Distance: 0.5}}}},
+	}}
+	handler := NewSimulationHandler(uc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/room-a/simulation/diagram.svg?line=RED&download=1", nil)
+	req.SetPathValue("sessionID", "room-a")
+	rec := httptest.NewRecorder()
+	handler.DiagramSVG(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected an SVG response, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="diagram-room-a-RED.svg"` {
+		t.Fatalf("unexpected Content-Disposition: %q", got)
+	}
+	if uc.diagramInput.LineID != "RED" || !strings.Contains(rec.Body.String(), `data-train-id="T0"`) {
+		t.Fatalf("unexpected diagram request %+v or body %s", uc.diagramInput, rec.Body.String())
+	}
+}
+
 type stubSimulationUseCase struct {
-	getDTO    simulationapp.SimulationDTO
-	tickDTO   simulationapp.SimulationDTO
-	getErr    error
-	tickErr   error
-	tickInput simulationapp.TickInput
+	getDTO      simulationapp.SimulationDTO
+	tickDTO     simulationapp.SimulationDTO
+	getErr      error
+	tickErr     error
+	importErr   error
+	getID       string
+	tickInput   simulationapp.TickInput
+	importInput simulationapp.ImportSnapshotInput
+
+	rewindErr     error
+	rewindInput   simulationapp.RewindInput
+	notifications []simulationapp.Notification
+
+	forecastErr   error
+	forecastInput simulationapp.ForecastInput
+
+	fastForwardErr   error
+	fastForwardInput simulationapp.FastForwardInput
+
+	breakpointErr         error
+	createBreakpointInput simulationapp.CreateBreakpointInput
+	updateBreakpointInput simulationapp.UpdateBreakpointInput
+	deletedBreakpointID   string
+
+	priorityRulesErr   error
+	priorityRulesInput simulationapp.SetPriorityRulesInput
+
+	changesInput simulationapp.ChangesInput
+	topology     simulationapp.TopologyDTO
+
+	timetableErr   error
+	timetableInput simulationapp.SetTimetableInput
+	diagramInput   simulationapp.DiagramInput
+	diagram        simulationapp.DiagramDTO
 }
 
-func (s *stubSimulationUseCase) GetSimulation(ctx context.Context) (simulationapp.SimulationDTO, error) {
+func (s *stubSimulationUseCase) GetSimulation(ctx context.Context, sessionID string) (simulationapp.SimulationDTO, error) {
 	_ = ctx
+	s.getID = sessionID
 	return s.getDTO, s.getErr
 }
 
@@ -144,6 +638,123 @@ func (s *stubSimulationUseCase) Tick(ctx context.Context, input simulationapp.Ti
 	return s.tickDTO, s.tickErr
 }
 
+func (s *stubSimulationUseCase) ExportSnapshot(ctx context.Context, sessionID string) (simulationapp.SnapshotDocument, error) {
+	_ = ctx
+	s.getID = sessionID
+	return simulationapp.SnapshotDocument{}, s.getErr
+}
+
+func (s *stubSimulationUseCase) ImportSnapshot(ctx context.Context, input simulationapp.ImportSnapshotInput) (simulationapp.SimulationDTO, error) {
+	_ = ctx
+	s.importInput = input
+	return s.tickDTO, s.importErr
+}
+
+func (s *stubSimulationUseCase) GetInputLog(ctx context.Context, sessionID string) ([]simulationapp.InputRecordDTO, error) {
+	_ = ctx
+	s.getID = sessionID
+	return nil, s.getErr
+}
+
+func (s *stubSimulationUseCase) VerifyReplay(ctx context.Context, sessionID string) (simulationapp.ReplayResultDTO, error) {
+	_ = ctx
+	s.getID = sessionID
+	return simulationapp.ReplayResultDTO{}, s.getErr
+}
+
+func (s *stubSimulationUseCase) Rewind(ctx context.Context, input simulationapp.RewindInput) (simulationapp.RewindResultDTO, error) {
+	_ = ctx
+	s.rewindInput = input
+	return simulationapp.RewindResultDTO{Simulation: s.tickDTO}, s.rewindErr
+}
+
+func (s *stubSimulationUseCase) Subscribe(ctx context.Context, sessionID string) (<-chan simulationapp.Notification, func(), error) {
+	_ = ctx
+	s.getID = sessionID
+	if s.getErr != nil {
+		return nil, nil, s.getErr
+	}
+	ch := make(chan simulationapp.Notification, len(s.notifications))
+	for _, n := range s.notifications {
+		ch <- n
+	}
+	close(ch)
+	return ch, func() {}, nil
+}
+
+func (s *stubSimulationUseCase) Forecast(ctx context.Context, input simulationapp.ForecastInput) (simulationapp.ForecastDTO, error) {
+	_ = ctx
+	s.forecastInput = input
+	return simulationapp.ForecastDTO{}, s.forecastErr
+}
+
+func (s *stubSimulationUseCase) FastForward(ctx context.Context, input simulationapp.FastForwardInput) (simulationapp.FastForwardResultDTO, error) {
+	_ = ctx
+	s.fastForwardInput = input
+	return simulationapp.FastForwardResultDTO{Simulation: s.tickDTO}, s.fastForwardErr
+}
+
+func (s *stubSimulationUseCase) ListBreakpoints(ctx context.Context, sessionID string) ([]simulationapp.BreakpointDTO, error) {
+	_ = ctx
+	s.getID = sessionID
+	return []simulationapp.BreakpointDTO{}, s.breakpointErr
+}
+
+func (s *stubSimulationUseCase) CreateBreakpoint(ctx context.Context, input simulationapp.CreateBreakpointInput) (simulationapp.BreakpointDTO, error) {
+	_ = ctx
+	s.createBreakpointInput = input
+	return simulationapp.BreakpointDTO{ID: "bp-1", Condition: input.Condition, Enabled: true}, s.breakpointErr
+}
+
+func (s *stubSimulationUseCase) UpdateBreakpoint(ctx context.Context, input simulationapp.UpdateBreakpointInput) (simulationapp.BreakpointDTO, error) {
+	_ = ctx
+	s.updateBreakpointInput = input
+	return simulationapp.BreakpointDTO{ID: input.BreakpointID}, s.breakpointErr
+}
+
+func (s *stubSimulationUseCase) DeleteBreakpoint(ctx context.Context, sessionID string, breakpointID string) error {
+	_ = ctx
+	s.getID = sessionID
+	s.deletedBreakpointID = breakpointID
+	return s.breakpointErr
+}
+
+func (s *stubSimulationUseCase) SetPriorityRules(ctx context.Context, input simulationapp.SetPriorityRulesInput) (simulationapp.SimulationDTO, error) {
+	_ = ctx
+	s.priorityRulesInput = input
+	return s.tickDTO, s.priorityRulesErr
+}
+
+func (s *stubSimulationUseCase) GetTimetable(ctx context.Context, sessionID string) (simulationapp.TimetableDTO, error) {
+	_ = ctx
+	s.getID = sessionID
+	return s.timetableInput.Timetable, s.timetableErr
+}
+
+func (s *stubSimulationUseCase) SetTimetable(ctx context.Context, input simulationapp.SetTimetableInput) (simulationapp.TimetableDTO, error) {
+	_ = ctx
+	s.timetableInput = input
+	return input.Timetable, s.timetableErr
+}
+
+func (s *stubSimulationUseCase) GetDiagram(ctx context.Context, input simulationapp.DiagramInput) (simulationapp.DiagramDTO, error) {
+	_ = ctx
+	s.diagramInput = input
+	return s.diagram, s.getErr
+}
+
+func (s *stubSimulationUseCase) GetChanges(ctx context.Context, input simulationapp.ChangesInput) (simulationapp.SimulationDeltaDTO, error) {
+	_ = ctx
+	s.changesInput = input
+	return simulationapp.SimulationDeltaDTO{SessionID: input.SessionID, FromVersion: input.SinceVersion, Version: input.SinceVersion + 1}, s.getErr
+}
+
+func (s *stubSimulationUseCase) GetTopology(ctx context.Context, sessionID string) (simulationapp.TopologyDTO, error) {
+	_ = ctx
+	s.getID = sessionID
+	return s.topology, s.getErr
+}
+
 func testSimulationDTO() simulationapp.SimulationDTO {
 	return simulationapp.SimulationDTO{
 		SimTimeMillis: 1000,
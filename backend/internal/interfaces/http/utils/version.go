@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidIfMatch = errors.New("invalid If-Match header")
+
+// IfMatchVersion は If-Match ヘッダから集約バージョンを取り出す。
+// ヘッダが無い、または "*" の場合は nil（無条件更新）を返す。
+func IfMatchVersion(r *http.Request) (*int64, error) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return nil, nil
+	}
+	raw = strings.TrimPrefix(raw, "W/")
+	raw = strings.Trim(raw, `"`)
+
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 0 {
+		return nil, ErrInvalidIfMatch
+	}
+	return &v, nil
+}
+
+// SetETag は集約バージョンを ETag ヘッダに設定する
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+func InvalidIfMatch() map[string]any {
+	return ErrBody("INVALID_IF_MATCH", "invalid If-Match header")
+}
+
+func VersionConflict() map[string]any {
+	return ErrBody("VERSION_CONFLICT", "resource was modified by another request")
+}
+
+// SetRevisionETag は内容から決まる識別子を ETag ヘッダに設定する
+func SetRevisionETag(w http.ResponseWriter, revision string) {
+	w.Header().Set("ETag", `"`+revision+`"`)
+}
+
+// IfNoneMatch は If-None-Match ヘッダが revision を含むか（クライアントの控えが最新か）を返す
+func IfNoneMatch(r *http.Request, revision string) bool {
+	for _, raw := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "*" {
+			return true
+		}
+		raw = strings.TrimPrefix(raw, "W/")
+		if strings.Trim(raw, `"`) == revision {
+			return true
+		}
+	}
+	return false
+}